@@ -246,7 +246,7 @@ func MakePreState(db ethdb.Database, accounts core.GenesisAlloc) *state.StateDB
 	statedb, _ := state.New(common.Hash{}, sdb, nil)
 	for addr, a := range accounts {
 		statedb.SetCode(addr, a.Code, firehose.NoOpContext)
-		statedb.SetNonce(addr, a.Nonce, firehose.NoOpContext)
+		statedb.SetNonce(addr, a.Nonce, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 		statedb.SetBalance(addr, a.Balance, firehose.NoOpContext, firehose.IgnoredBalanceChangeReason)
 		for k, v := range a.Storage {
 			statedb.SetState(addr, k, v, firehose.NoOpContext)
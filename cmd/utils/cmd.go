@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/internal/debug"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
@@ -84,6 +85,8 @@ func StartNode(ctx *cli.Context, stack *node.Node) {
 		if minFreeDiskSpace > 0 {
 			go monitorFreeDiskSpace(sigc, stack.InstanceDir(), uint64(minFreeDiskSpace)*1024*1024)
 		}
+		go monitorFirehoseOutput(sigc)
+		go monitorFirehoseStopBlock(sigc)
 
 		<-sigc
 		log.Info("Got interrupt, shutting down...")
@@ -99,6 +102,22 @@ func StartNode(ctx *cli.Context, stack *node.Node) {
 	}()
 }
 
+// monitorFirehoseOutput gracefully shuts the node down once the Firehose output is broken,
+// block import already stopped at that point so the database isn't ahead of the stream.
+func monitorFirehoseOutput(sigc chan os.Signal) {
+	<-firehose.OutputBroken()
+	log.Error("Firehose output is broken. Gracefully shutting down Geth to keep the database in sync with the stream.")
+	sigc <- syscall.SIGTERM
+}
+
+// monitorFirehoseStopBlock gracefully shuts the node down once the Firehose stop block is the
+// head, its data is written at that point and the output is closed on exit.
+func monitorFirehoseStopBlock(sigc chan os.Signal) {
+	<-firehose.StopBlockReached()
+	log.Info("Firehose stop block reached. Gracefully shutting down Geth.", "number", firehose.StopBlockNum)
+	sigc <- syscall.SIGTERM
+}
+
 func monitorFreeDiskSpace(sigc chan os.Signal, path string, freeDiskSpaceCritical uint64) {
 	for {
 		freeSpace, err := getFreeDiskSpace(path)
@@ -87,6 +87,9 @@ type Engine interface {
 	//
 	// Note: The block header and state database might be updated to reflect any
 	// consensus rules that happen at finalization (e.g. block rewards).
+	//
+	// Rewards are credited through the state's AddBalance with the Firehose context
+	// and a reward reason, so Firehose records whatever the engine pays.
 	Finalize(chain ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction,
 		uncles []*types.Header, firehoseContext *firehose.Context)
 
@@ -126,3 +129,12 @@ type PoW interface {
 	// Hashrate returns the current mining hashrate of a PoW consensus engine.
 	Hashrate() float64
 }
+
+// FirehoseSystemCaller is implemented by the consensus engines opening their own Firehose
+// system calls on the context given to Finalize, each named after what performs the state
+// changes recorded in it, like the state sync events Bor executes at the end of some blocks.
+type FirehoseSystemCaller interface {
+	// OpensFirehoseSystemCalls tells if the engine opens its own system calls, the block
+	// processor then doesn't open the `block_finalize` one around Finalize.
+	OpensFirehoseSystemCalls() bool
+}
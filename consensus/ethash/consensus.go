@@ -625,18 +625,25 @@ func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header
 	if config.IsConstantinople(header.Number) {
 		blockReward = ConstantinopleBlockReward
 	}
-	// Accumulate the rewards for the miner and any included uncles
-	reward := new(big.Int).Set(blockReward)
+	// Accumulate the rewards for the miner and any included uncles, the miner's inclusion
+	// bonus being credited apart from the block reward so Firehose records them separately
+	nephewReward := new(big.Int)
 	r := new(big.Int)
 	for _, uncle := range uncles {
 		r.Add(uncle.Number, big8)
 		r.Sub(r, header.Number)
 		r.Mul(r, blockReward)
 		r.Div(r, big8)
+		if firehoseContext.Enabled() {
+			firehoseContext.RecordUncleReward(uncle, r)
+		}
 		state.AddBalance(uncle.Coinbase, r, false, firehoseContext, firehose.BalanceChangeReason("reward_mine_uncle"))
 
 		r.Div(blockReward, big32)
-		reward.Add(reward, r)
+		nephewReward.Add(nephewReward, r)
+	}
+	state.AddBalance(header.Coinbase, blockReward, false, firehoseContext, firehose.BalanceChangeReason("reward_mine_block"))
+	if nephewReward.Sign() > 0 {
+		state.AddBalance(header.Coinbase, nephewReward, false, firehoseContext, firehose.BalanceChangeReason("reward_mine_nephew"))
 	}
-	state.AddBalance(header.Coinbase, reward, false, firehoseContext, firehose.BalanceChangeReason("reward_mine_block"))
 }
@@ -19,15 +19,20 @@ package ethash
 import (
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"math/big"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -186,3 +191,46 @@ func BenchmarkDifficultyCalculator(b *testing.B) {
 		}
 	})
 }
+
+func TestAccumulateRewardsRecordsUncleRewards(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		miner   = common.HexToAddress("0xaa")
+		header  = &types.Header{Number: big.NewInt(10), Coinbase: miner}
+		uncles  = []*types.Header{{Number: big.NewInt(9), Coinbase: common.HexToAddress("0xbb")}, {Number: big.NewInt(8), Coinbase: common.HexToAddress("0xcc")}}
+		printer = firehose.NewToBufferPrinter(1024)
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	accumulateRewards(params.TestChainConfig, statedb, header, uncles, firehose.NewContext(printer, true))
+
+	// Constantinople block reward of 2 ether, uncles are paid 7/8 and 6/8 of it, the miner 1/32 for each
+	var (
+		blockReward  = ConstantinopleBlockReward
+		firstUncle   = new(big.Int).Div(new(big.Int).Mul(blockReward, big.NewInt(7)), big8)
+		secondUncle  = new(big.Int).Div(new(big.Int).Mul(blockReward, big.NewInt(6)), big8)
+		nephewReward = new(big.Int).Div(new(big.Int).Mul(blockReward, big.NewInt(2)), big32)
+	)
+
+	expected := []string{
+		fmt.Sprintf("FIRE UNCLE_REWARD 9 %s %s %s 1", firehose.Hash(uncles[0].Hash()), firehose.Addr(uncles[0].Coinbase), firehose.BigInt(firstUncle)),
+		fmt.Sprintf("FIRE CREATED_ACCOUNT 0 %s 2 transfer", firehose.Addr(uncles[0].Coinbase)),
+		fmt.Sprintf("FIRE BALANCE_CHANGE 0 %s . %s reward_mine_uncle 3", firehose.Addr(uncles[0].Coinbase), firehose.BigInt(firstUncle)),
+		fmt.Sprintf("FIRE UNCLE_REWARD 8 %s %s %s 4", firehose.Hash(uncles[1].Hash()), firehose.Addr(uncles[1].Coinbase), firehose.BigInt(secondUncle)),
+		fmt.Sprintf("FIRE CREATED_ACCOUNT 0 %s 5 transfer", firehose.Addr(uncles[1].Coinbase)),
+		fmt.Sprintf("FIRE BALANCE_CHANGE 0 %s . %s reward_mine_uncle 6", firehose.Addr(uncles[1].Coinbase), firehose.BigInt(secondUncle)),
+		fmt.Sprintf("FIRE CREATED_ACCOUNT 0 %s 7 transfer", firehose.Addr(miner)),
+		fmt.Sprintf("FIRE BALANCE_CHANGE 0 %s . %s reward_mine_block 8", firehose.Addr(miner), firehose.BigInt(blockReward)),
+		fmt.Sprintf("FIRE BALANCE_CHANGE 0 %s %s %s reward_mine_nephew 9", firehose.Addr(miner), firehose.BigInt(blockReward), firehose.BigInt(new(big.Int).Add(blockReward, nephewReward))),
+	}
+
+	got := strings.Split(strings.TrimSuffix(printer.Buffer().String(), "\n"), "\n")
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("rewards mismatch:\nhave %q\nwant %q", got, expected)
+	}
+	if balance := statedb.GetBalance(miner); balance.Cmp(new(big.Int).Add(blockReward, nephewReward)) != 0 {
+		t.Fatalf("miner balance mismatch: have %v", balance)
+	}
+}
@@ -72,10 +72,14 @@ func VerifyDAOHeaderExtraData(config *params.ChainConfig, header *types.Header)
 // ApplyDAOHardFork modifies the state database according to the DAO hard-fork
 // rules, transferring all balances of a set of DAO accounts to a single refund
 // contract.
+//
+// The refund contract credit is recorded with the `dao_refund_contract` reason and the
+// drain of each DAO account, even an empty one, with the `dao_adjust_balance` reason, the
+// caller is responsible of wrapping them in a `dao_fork` system call.
 func ApplyDAOHardFork(statedb *state.StateDB, firehoseContext *firehose.Context) {
 	// Retrieve the contract to refund balances into
 	if !statedb.Exist(params.DAORefundContract) {
-		statedb.CreateAccount(params.DAORefundContract, firehoseContext)
+		statedb.CreateAccount(params.DAORefundContract, firehoseContext, firehose.AccountCreationReason("dao_refund_contract"))
 	}
 
 	// Move every DAO account and extra-balance account funds into the refund contract
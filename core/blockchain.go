@@ -260,7 +260,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if err != nil {
 		return nil, err
 	}
-	bc.genesisBlock = bc.GetBlockByNumber(0)
+	bc.genesisBlock = bc.GetBlockByNumber(bc.hc.genesisHeader.Number.Uint64())
 	if bc.genesisBlock == nil {
 		return nil, ErrNoGenesis
 	}
@@ -284,6 +284,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+
+	firehose.InitChainConfig(chainConfig, bc.genesisBlock.Hash(), bc.CurrentBlock().NumberU64())
+
 	// Make sure the state associated with the block is available
 	head := bc.CurrentBlock()
 	if _, err := state.New(head.Root(), bc.stateCache, bc.snaps); err != nil {
@@ -343,6 +346,22 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			}
 		}
 	}
+	// In Firehose finalized only mode, the blocks above the last emitted one were buffered and
+	// dropped on shutdown, they are executed again
+	if firehose.Enabled && firehose.FinalizedOnlyEnabled {
+		next := bc.CurrentBlock().NumberU64() + 1
+		if bc.CurrentBlock().Hash() == bc.genesisBlock.Hash() {
+			next = bc.genesisBlock.NumberU64()
+		}
+		if last := rawdb.ReadFirehoseLastEmitted(bc.db); last != nil {
+			next = *last + 1
+		}
+		firehose.ResumeFinalized(next)
+
+		if err := bc.replayFinalizedBlocks(next); err != nil {
+			return nil, err
+		}
+	}
 	// The first thing the node will do is reconstruct the verification data for
 	// the head block (ethash cache or clique voting snapshot). Might as well do
 	// it in advance.
@@ -379,18 +398,16 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		bc.snaps, _ = snapshot.New(bc.db, bc.stateCache.TrieDB(), bc.cacheConfig.SnapshotLimit, head.Root(), !bc.cacheConfig.SnapshotWait, true, recover)
 	}
 
-	if firehose.Enabled && bc.CurrentBlock().NumberU64() == 0 {
-		if bc.genesisBlock == nil {
-			panic(fmt.Errorf("expected to have genesis block here"))
-		}
-
-		if firehose.GenesisConfig == nil {
-			panic(firehose.MissingGenesisPanicMessage)
-		}
-
-		genesis := firehose.GenesisConfig.(*Genesis)
+	// The genesis block is at a non-zero height on chains started from a regenesis, its
+	// initial state is recorded at that height, the first block following it
+	if firehose.Enabled && bc.CurrentBlock().NumberU64() == bc.genesisBlock.NumberU64() {
+		genesis, _ := firehose.GenesisConfig.(*Genesis)
 		if genesis == nil {
-			panic(firehose.MissingGenesisPanicMessage)
+			// Known networks don't need a genesis file, the one stored tells which they are
+			if genesis = knownGenesisBlock(bc.genesisBlock.Hash()); genesis == nil {
+				panic(firehose.MissingGenesisPanicMessage)
+			}
+			log.Info("Firehose using the embedded genesis of the stored genesis block's network", "hash", bc.genesisBlock.Hash())
 		}
 
 		// As far as I can tell, the block's hash comes from the keccak hash of the rlp encoding
@@ -402,7 +419,11 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			panic("firehose genesis block hash mismatch vs geth computed genesis block hash")
 		}
 
-		firehose.MaybeSyncContext().RecordGenesisBlock(bc.genesisBlock, func(ctx *firehose.Context) {
+		// The genesis block is recorded like any other block, large allocations are streamed or
+		// spilled the same way depending on the output settings, see `firehose.NewBlockContext`
+		genesisContext := firehose.NewBlockContext()
+
+		genesisContext.RecordGenesisBlock(bc.genesisBlock, func(ctx *firehose.Context) {
 			sortedAddrs := make([]common.Address, len(genesis.Alloc))
 			i := 0
 			for addr := range genesis.Alloc {
@@ -417,7 +438,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			for _, addr := range sortedAddrs {
 				account := genesis.Alloc[addr]
 
-				ctx.RecordNewAccount(addr)
+				ctx.RecordNewAccount(addr, firehose.AccountCreationReason("genesis"))
 
 				ctx.RecordBalanceChange(addr, common.Big0, account.Balance, firehose.BalanceChangeReason("genesis_balance"))
 				if len(account.Code) > 0 {
@@ -425,16 +446,34 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 				}
 
 				if account.Nonce > 0 {
-					ctx.RecordNonceChange(addr, 0, account.Nonce)
+					ctx.RecordNonceChange(addr, 0, account.Nonce, firehose.NonceChangeReason("genesis"))
+				}
+
+				sortedKeys := make([]common.Hash, 0, len(account.Storage))
+				for key := range account.Storage {
+					sortedKeys = append(sortedKeys, key)
 				}
 
-				for key, value := range account.Storage {
-					ctx.RecordStorageChange(addr, key, common.Hash{}, value)
+				sort.Slice(sortedKeys, func(i, j int) bool {
+					return bytes.Compare(sortedKeys[i][:], sortedKeys[j][:]) <= -1
+				})
+
+				for _, key := range sortedKeys {
+					ctx.RecordStorageChange(addr, key, common.Hash{}, account.Storage[key])
 				}
 			}
 		})
+		genesisContext.FlushBlock()
 	}
 
+	// A node restarted with its head at the Firehose stop block has nothing left to import
+	firehose.RecordHeadBlock(bc.CurrentBlock().NumberU64())
+
+	firehose.StartHeartbeat(func() (uint64, common.Hash) {
+		head := bc.CurrentBlock()
+		return head.NumberU64(), head.Hash()
+	})
+
 	// Take ownership of this particular state
 	go bc.update()
 	if txLookupLimit != nil {
@@ -539,6 +578,10 @@ func (bc *BlockChain) loadLastState() error {
 // delete minimal data from disk whilst retaining chain consistency.
 func (bc *BlockChain) SetHead(head uint64) error {
 	_, err := bc.SetHeadBeyondRoot(head, common.Hash{})
+	if err == nil {
+		// Firehose readers restart from the rewound head, the handshake is emitted again
+		firehose.InitChainConfig(bc.chainConfig, bc.genesisBlock.Hash(), bc.CurrentBlock().NumberU64())
+	}
 	return err
 }
 
@@ -779,7 +822,9 @@ func (bc *BlockChain) ResetWithGenesisBlock(genesis *types.Block) error {
 	if err := batch.Write(); err != nil {
 		log.Crit("Failed to write genesis block", "err", err)
 	}
-	bc.writeHeadBlock(genesis)
+	if err := bc.writeHeadBlock(genesis); err != nil {
+		return err
+	}
 
 	// Last update all in-memory chain markers
 	bc.genesisBlock = genesis
@@ -827,10 +872,18 @@ func (bc *BlockChain) ExportN(w io.Writer, first uint64, last uint64) error {
 // writeHeadBlock injects a new head block into the current block chain. This method
 // assumes that the block is indeed a true head. It will also reset the head
 // header and the head fast sync block to this very same block if they are older
-// or if they are on a different side chain.
+// or if they are on a different side chain. The head is left untouched, and the
+// error returned, if the Firehose data of the block could not be written.
 //
 // Note, this function assumes that the `mu` mutex is held!
-func (bc *BlockChain) writeHeadBlock(block *types.Block) {
+func (bc *BlockChain) writeHeadBlock(block *types.Block) error {
+	// Firehose data is written by its own goroutine, the head must never move past a
+	// block whose Firehose data was not fully written
+	if err := firehose.WaitOutputWritten(); err != nil {
+		log.Error("Not moving head, block Firehose data could not be written", "number", block.Number(), "hash", block.Hash(), "err", err)
+		return err
+	}
+
 	// If the block is on a side chain or an unknown one, force other heads onto it too
 	updateHeads := rawdb.ReadCanonicalHash(bc.db, block.NumberU64()) != block.Hash()
 
@@ -857,6 +910,95 @@ func (bc *BlockChain) writeHeadBlock(block *types.Block) {
 	}
 	bc.currentBlock.Store(block)
 	headBlockGauge.Update(int64(block.NumberU64()))
+
+	firehose.RecordHeadBlock(block.NumberU64())
+
+	if firehose.Enabled && firehose.FinalizedOnlyEnabled {
+		return bc.emitFinalizedBlocks(block.NumberU64())
+	}
+	return nil
+}
+
+// emitFinalizedBlocks emits the Firehose blocks final with the head at the given height, in
+// finalized only mode, and records the last one emitted once written, the emission resumes
+// after it on restart, see `replayFinalizedBlocks`.
+func (bc *BlockChain) emitFinalizedBlocks(head uint64) error {
+	last, emitted, err := firehose.EmitFinalized(head, func(number uint64) common.Hash {
+		return rawdb.ReadCanonicalHash(bc.db, number)
+	})
+	if err != nil {
+		log.Error("Firehose finalized blocks could not be emitted", "head", head, "err", err)
+		return err
+	}
+	if !emitted {
+		return nil
+	}
+	if err := firehose.WaitOutputWritten(); err != nil {
+		log.Error("Firehose finalized blocks could not be written", "last", last, "err", err)
+		return err
+	}
+	rawdb.WriteFirehoseLastEmitted(bc.db, last)
+	return nil
+}
+
+// replayFinalizedBlocks executes again the canonical blocks from `next` up to the head, in
+// Firehose finalized only mode, their records buffered until final being dropped on shutdown.
+// The blocks are executed from the closest ancestor whose state is available, the ones below
+// `next` without being recorded, and the chain data is left untouched. At most
+// `firehose.FinalizedMaxReplayBlocks` blocks are executed, it fails otherwise.
+func (bc *BlockChain) replayFinalizedBlocks(next uint64) error {
+	head := bc.CurrentBlock()
+	if next > head.NumberU64() || next <= bc.genesisBlock.NumberU64() {
+		return nil
+	}
+
+	start := bc.GetBlockByNumber(next - 1)
+	for start != nil && !bc.HasState(start.Root()) && head.NumberU64()-start.NumberU64() < firehose.FinalizedMaxReplayBlocks {
+		start = bc.GetBlock(start.ParentHash(), start.NumberU64()-1)
+	}
+	if start == nil || head.NumberU64()-start.NumberU64() > firehose.FinalizedMaxReplayBlocks || !bc.HasState(start.Root()) {
+		return fmt.Errorf("no state within %d blocks below head #%d to execute the Firehose finalized blocks from #%d", firehose.FinalizedMaxReplayBlocks, head.NumberU64(), next)
+	}
+	log.Info("Executing again the Firehose blocks not final on shutdown", "from", next, "to", head.Number(), "state", start.Number())
+
+	// The intermediate states are only kept in memory, each one released once the next is
+	// committed, the chain's state is left untouched
+	database := state.NewDatabase(bc.db)
+	triedb := database.TrieDB()
+	root := start.Root()
+	for number := start.NumberU64() + 1; number <= head.NumberU64(); number++ {
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			return fmt.Errorf("canonical block #%d missing to execute the Firehose finalized blocks", number)
+		}
+		statedb, err := state.New(root, database, nil)
+		if err != nil {
+			return err
+		}
+
+		firehoseContext := firehose.NoOpContext
+		if number >= next {
+			firehoseContext = firehose.NewReplayContextAt(number)
+		}
+		if _, _, _, err := bc.processor.Process(block, statedb, bc.vmConfig, firehoseContext); err != nil {
+			firehoseContext.CancelBlock(block, err)
+			return fmt.Errorf("execute block #%d again for Firehose: %w", number, err)
+		}
+		if firehoseContext.Enabled() {
+			firehoseContext.EndBlock(block, bc.GetTd(block.Hash(), number))
+			firehoseContext.FlushBlock()
+		}
+
+		parentRoot := root
+		if root, err = statedb.Commit(bc.chainConfig.IsEIP158(block.Number())); err != nil {
+			return err
+		}
+		triedb.Reference(root, common.Hash{})
+		if number > start.NumberU64()+1 {
+			triedb.Dereference(parentRoot)
+		}
+	}
+	return bc.emitFinalizedBlocks(head.NumberU64())
 }
 
 // Genesis retrieves the chain's genesis block.
@@ -1053,6 +1195,8 @@ func (bc *BlockChain) Stop() {
 	if !atomic.CompareAndSwapInt32(&bc.running, 0, 1) {
 		return
 	}
+	firehose.StopHeartbeat()
+
 	// Unsubscribe all subscriptions registered from blockchain
 	bc.scope.Close()
 	close(bc.quit)
@@ -1082,7 +1226,7 @@ func (bc *BlockChain) Stop() {
 		triedb := bc.stateCache.TrieDB()
 
 		for _, offset := range []uint64{0, 1, TriesInMemory - 1} {
-			if number := bc.CurrentBlock().NumberU64(); number > offset {
+			if number := bc.CurrentBlock().NumberU64(); number > bc.genesisBlock.NumberU64()+offset {
 				recent := bc.GetBlockByNumber(number - offset)
 
 				log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
@@ -1536,19 +1680,20 @@ func (bc *BlockChain) writeBlockWithoutState(block *types.Block, td *big.Int) (e
 }
 
 // writeKnownBlock updates the head block flag with a known block
-// and introduces chain reorg if necessary.
-func (bc *BlockChain) writeKnownBlock(block *types.Block) error {
+// and introduces chain reorg if necessary, flushing the block's pending Firehose data after it.
+func (bc *BlockChain) writeKnownBlock(block *types.Block, firehoseContext *firehose.Context) error {
 	bc.wg.Add(1)
 	defer bc.wg.Done()
 
 	current := bc.CurrentBlock()
 	if block.ParentHash() != current.Hash() {
 		if err := bc.reorg(current, block); err != nil {
+			firehoseContext.CancelBlock(block, err)
 			return err
 		}
 	}
-	bc.writeHeadBlock(block)
-	return nil
+	firehoseContext.FlushBlock()
+	return bc.writeHeadBlock(block)
 }
 
 // WriteBlockWithState writes the block and all associated state to the database.
@@ -1556,15 +1701,22 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	bc.chainmu.Lock()
 	defer bc.chainmu.Unlock()
 
-	return bc.writeBlockWithState(block, receipts, logs, state, emitHeadEvent)
+	return bc.writeBlockWithState(block, receipts, logs, state, emitHeadEvent, firehose.NoOpContext)
 }
 
 // writeBlockWithState writes the block and all associated state to the database,
-// but is expects the chain mutex to be held.
-func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, emitHeadEvent bool) (status WriteStatus, err error) {
+// but is expects the chain mutex to be held. The block's pending Firehose data is
+// flushed once its status is known, or canceled if the block can't be written.
+func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, emitHeadEvent bool, firehoseContext *firehose.Context) (status WriteStatus, err error) {
 	bc.wg.Add(1)
 	defer bc.wg.Done()
 
+	defer func() {
+		if err != nil {
+			firehoseContext.CancelBlock(block, err)
+		}
+	}()
+
 	// Calculate the total difficulty of the block
 	ptd := bc.GetTd(block.ParentHash(), block.NumberU64()-1)
 	if ptd == nil {
@@ -1675,9 +1827,14 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	} else {
 		status = SideStatTy
 	}
-	// Set new head.
+	firehoseContext.FlushBlock()
+	firehoseContext = firehose.NoOpContext
+
+	// Set new head, block import stops here if the block Firehose data could not be written
 	if status == CanonStatTy {
-		bc.writeHeadBlock(block)
+		if err := bc.writeHeadBlock(block); err != nil {
+			return NonStatTy, err
+		}
 	}
 	bc.futureBlocks.Remove(block.Hash())
 
@@ -1700,6 +1857,68 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	return status, nil
 }
 
+// newFirehoseBlockContext returns the context recording the imported block, always staged when
+// it's not built on the head as the records of the reorg it might trigger must come first.
+func (bc *BlockChain) newFirehoseBlockContext(block *types.Block) *firehose.Context {
+	if firehose.StreamingBlocksEnabled && block.ParentHash() != bc.CurrentBlock().Hash() {
+		return firehose.NewReplayContextAt(block.NumberU64())
+	}
+	return firehose.NewBlockContextAt(block.NumberU64())
+}
+
+// flushFirehoseBlock hands the Firehose data of the imported block over to the output if it's
+// built on the head, otherwise the returned context holds it until flushed after the reorg.
+func (bc *BlockChain) flushFirehoseBlock(block *types.Block, firehoseContext *firehose.Context) (pending *firehose.Context) {
+	if block.ParentHash() != bc.CurrentBlock().Hash() {
+		return firehoseContext
+	}
+
+	firehoseContext.FlushBlock()
+	return firehose.NoOpContext
+}
+
+// reemitBlocks records the reorg from the old head to the new one, then emits again the blocks
+// of the new branch below its head, given from the highest one, in canonical order. They are all
+// executed again from their parent's state before anything is emitted, so nothing is emitted if
+// one fails. This runs under the chain lock, costing the execution of the whole new branch.
+func (bc *BlockChain) reemitBlocks(oldHead, newHead *types.Block, commonAncestor uint64, blocks types.Blocks) error {
+	if len(blocks) > 0 {
+		log.Info("Executing the Firehose blocks of the new branch again", "count", len(blocks),
+			"from", blocks[len(blocks)-1].Number(), "to", blocks[0].Number())
+	}
+
+	contexts := make([]*firehose.Context, 0, len(blocks))
+	for i := len(blocks) - 1; i >= 0; i-- {
+		block := blocks[i]
+
+		firehoseContext := firehose.NewReplayContextAt(block.NumberU64())
+		if !firehoseContext.Enabled() {
+			continue
+		}
+
+		parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+		if parent == nil {
+			return fmt.Errorf("re-emitting Firehose block #%d [%x]: %w", block.NumberU64(), block.Hash(), consensus.ErrUnknownAncestor)
+		}
+		statedb, err := bc.StateAt(parent.Root)
+		if err != nil {
+			return fmt.Errorf("re-emitting Firehose block #%d [%x]: %w", block.NumberU64(), block.Hash(), err)
+		}
+		if _, _, _, err := bc.processor.Process(block, statedb, bc.vmConfig, firehoseContext); err != nil {
+			return fmt.Errorf("re-emitting Firehose block #%d [%x]: %w", block.NumberU64(), block.Hash(), err)
+		}
+
+		firehoseContext.EndBlock(block, bc.GetTd(block.Hash(), block.NumberU64()))
+		contexts = append(contexts, firehoseContext)
+	}
+
+	firehose.MaybeSyncContext().RecordReorg(oldHead, newHead, commonAncestor)
+	for _, firehoseContext := range contexts {
+		firehoseContext.FlushBlock()
+	}
+	return nil
+}
+
 // addFutureBlock checks if the block is within the max allowed window to get
 // accepted for future processing, and returns an error if the block is too far
 // ahead and was not added.
@@ -1828,7 +2047,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		// head full block(new pivot point).
 		for block != nil && err == ErrKnownBlock {
 			log.Debug("Writing previously known block", "number", block.Number(), "hash", block.Hash())
-			if err := bc.writeKnownBlock(block); err != nil {
+			if err := bc.writeKnownBlock(block, firehose.NoOpContext); err != nil {
 				return it.index, err
 			}
 			lastCanon = block
@@ -1888,6 +2107,10 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 			bc.reportBlock(block, nil, ErrBlacklistedHash)
 			return it.index, ErrBlacklistedHash
 		}
+		// Nothing is imported once the Firehose stop block is the head, nor above it
+		if err := firehose.CheckStopBlock(block.NumberU64()); err != nil {
+			return it.index, err
+		}
 		// If the block is known (in the middle of the chain), it's a special case for
 		// Clique blocks where they can share state among each other, so importing an
 		// older block might complete the state of the subsequent one. In this case,
@@ -1916,18 +2139,20 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 				log.Error("Please file an issue, skip known block execution without receipt",
 					"hash", block.Hash(), "number", block.NumberU64())
 			}
-			if err := bc.writeKnownBlock(block); err != nil {
-				return it.index, err
-			}
-
-			// some blocks with 0 transactions are only processed here
-			if firehoseContext := firehose.MaybeSyncContext(); firehoseContext.Enabled() {
-				firehoseContext.StartBlock(block)
+			// some blocks with 0 transactions are only processed here, the block is flushed
+			// before being written so the head never moves past a block Firehose did not output
+			firehoseContext := bc.newFirehoseBlockContext(block)
+			if firehoseContext.Enabled() {
+				firehoseContext.StartBlock(block, firehoseBlockProducer(bc.engine, block.Header()))
+				firehoseContext.RecordForkActivation(bc.chainConfig, bc.genesisBlock.Hash(), block.NumberU64())
 				firehoseContext.FinalizeBlock(block)
 				ptd := bc.GetTd(block.ParentHash(), block.NumberU64()-1)
 				td := new(big.Int).Add(block.Difficulty(), ptd)
 				firehoseContext.EndBlock(block, td)
-				firehoseContext.FlushBlock()
+			}
+
+			if err := bc.writeKnownBlock(block, bc.flushFirehoseBlock(block, firehoseContext)); err != nil {
+				return it.index, err
 			}
 
 			stats.processed++
@@ -1970,14 +2195,13 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 			}
 		}
 		// Process block using the parent state as reference point
-		firehoseContext := firehose.NoOpContext
-		if firehose.Enabled {
-			firehoseContext = firehose.NewSpeculativeExecutionContextWithBuffer(firehose.BlockSyncBuffer)
-		}
+		firehoseContext := bc.newFirehoseBlockContext(block)
 
 		substart := time.Now()
 		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig, firehoseContext)
 		if err != nil {
+			// Only needed when streaming blocks, a staged block is simply never written
+			firehoseContext.CancelBlock(block, err)
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
 			return it.index, err
@@ -1998,6 +2222,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		// Validate the state using the default validator
 		substart = time.Now()
 		if err := bc.validator.ValidateState(block, statedb, receipts, usedGas); err != nil {
+			firehoseContext.CancelBlock(block, err)
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
 			return it.index, err
@@ -2018,19 +2243,30 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 
 		blockValidationTimer.Update(time.Since(substart) - (statedb.AccountHashes + statedb.StorageHashes - triehash))
 
+		flushed := false
+		if firehoseContext.Enabled() {
+			// The block is handed over to the Firehose writer goroutine before being written to
+			// the chain so both happen concurrently, moving the head waits for the Firehose data
+			// to be fully written (or the node to crash), so the head never moves past a block
+			// whose Firehose data was not output.
+			pending := bc.flushFirehoseBlock(block, firehoseContext)
+			flushed = pending != firehoseContext
+			firehoseContext = pending
+		}
+
 		// Write the block to the chain and get the status.
 		substart = time.Now()
-		status, err := bc.writeBlockWithState(block, receipts, logs, statedb, false)
+		status, err := bc.writeBlockWithState(block, receipts, logs, statedb, false, firehoseContext)
 		atomic.StoreUint32(&followupInterrupt, 1)
 		if err != nil {
+			// A flushed block can't be canceled anymore, Firehose consumers already have it
+			// while the chain doesn't, stop the node instead of letting both diverge.
+			if flushed && !errors.Is(err, firehose.ErrOutputBroken) {
+				log.Crit("Failed to write block whose Firehose data was emitted", "number", block.Number(), "hash", block.Hash(), "err", err)
+			}
 			return it.index, err
 		}
 
-		if firehoseContext.Enabled() {
-			// This is last point where there is no more an early return due to an error, we flush here
-			firehoseContext.FlushBlock()
-		}
-
 		// Update the metrics touched during block commit
 		accountCommitTimer.Update(statedb.AccountCommits)   // Account commits are complete, we can mark them
 		storageCommitTimer.Update(statedb.StorageCommits)   // Storage commits are complete, we can mark them
@@ -2225,6 +2461,9 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		oldChain    types.Blocks
 		commonBlock *types.Block
 
+		// The heads the chain switches between, recorded by Firehose
+		oldHead, newHead = oldBlock, newBlock
+
 		deletedTxs types.Transactions
 		addedTxs   types.Transactions
 
@@ -2336,11 +2575,25 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	} else {
 		log.Error("Impossible reorg, please file an issue", "oldnum", oldBlock.Number(), "oldhash", oldBlock.Hash(), "newnum", newBlock.Number(), "newhash", newBlock.Hash())
 	}
+	// The blocks of the new branch were emitted when executed, before being canonical, readers
+	// learn the old ones are undone before the head moves and get the new ones again, the new
+	// head being emitted by its import once the reorg is written
+	if len(oldChain) > 0 && firehose.Enabled && !firehose.FinalizedOnlyEnabled {
+		var reemitted types.Blocks
+		if len(newChain) > 0 {
+			reemitted = newChain[1:]
+		}
+		if err := bc.reemitBlocks(oldHead, newHead, commonBlock.NumberU64(), reemitted); err != nil {
+			return err
+		}
+	}
 	// Insert the new chain(except the head block(reverse order)),
 	// taking care of the proper incremental order.
 	for i := len(newChain) - 1; i >= 1; i-- {
 		// Insert the block in the canonical way, re-writing history
-		bc.writeHeadBlock(newChain[i])
+		if err := bc.writeHeadBlock(newChain[i]); err != nil {
+			return err
+		}
 
 		// Collect reborn logs due to chain reorg
 		collectLogs(newChain[i].Hash(), false)
@@ -17,12 +17,16 @@
 package core
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
 	"os"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -3110,3 +3114,640 @@ func TestEIP2718Transition(t *testing.T) {
 
 	}
 }
+
+// TestReorgRecordedAfterNewBranch tests that a reorg undoing several blocks is recorded once
+// the new branch reaches the new head and before any block built on it, the blocks of the new
+// branch being emitted again after it, also when the node restarted while importing the new
+// branch and when blocks are streamed.
+func TestReorgRecordedAfterNewBranch(t *testing.T) {
+	defer func(enabled, streaming bool, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.StreamingBlocksEnabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = enabled, streaming, genesis, buffer
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.StreamingBlocksEnabled, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+
+	gspec := &Genesis{Config: params.TestChainConfig}
+	genesis := gspec.MustCommit(rawdb.NewMemoryDatabase())
+
+	// The second branch is heavier from its fifth block, its blocks being closer in time
+	canonical, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), rawdb.NewMemoryDatabase(), 5, func(i int, b *BlockGen) {})
+	fork, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), rawdb.NewMemoryDatabase(), 7, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0xbb})
+		b.OffsetTime(-2)
+	})
+
+	labels := map[common.Hash]string{genesis.Hash(): "G"}
+	for i := range canonical {
+		labels[canonical[i].Hash()] = fmt.Sprintf("A%d", i+1)
+	}
+	for i := range fork {
+		labels[fork[i].Hash()] = fmt.Sprintf("B%d", i+1)
+	}
+
+	record := func(restart, streaming bool) []string {
+		db := rawdb.NewMemoryDatabase()
+		gspec.MustCommit(db)
+
+		output := &bytes.Buffer{}
+		firehose.SetWriter(output)
+		firehose.Enabled, firehose.StreamingBlocksEnabled = true, streaming
+		firehose.GenesisConfig, firehose.BlockSyncBuffer = gspec, &bytes.Buffer{}
+
+		insert := func(blockchain *BlockChain, blocks []*types.Block) {
+			if _, err := blockchain.InsertChain(blocks); err != nil {
+				t.Fatalf("failed to insert blocks: %v", err)
+			}
+		}
+
+		blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+		insert(blockchain, canonical)
+		if restart {
+			// The first blocks of the new branch are imported, then the node restarts
+			insert(blockchain, fork[:4])
+			blockchain.Stop()
+			blockchain, _ = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+		}
+		insert(blockchain, fork)
+		blockchain.Stop()
+
+		var events []string
+		for _, line := range strings.Split(output.String(), "\n") {
+			fields := strings.Split(line, " ")
+			switch {
+			case strings.HasPrefix(line, "FIRE END_BLOCK "):
+				var block struct {
+					Header struct {
+						Hash common.Hash `json:"hash"`
+					} `json:"header"`
+				}
+				if err := json.Unmarshal([]byte(fields[4]), &block); err != nil {
+					t.Fatalf("failed to decode END_BLOCK: %v", err)
+				}
+				events = append(events, labels[block.Header.Hash])
+			case strings.HasPrefix(line, "FIRE REORG "):
+				events = append(events, fmt.Sprintf("REORG %s %s %s %s %s",
+					fields[2], labels[common.HexToHash(fields[3])], fields[4], labels[common.HexToHash(fields[5])], fields[6]))
+			}
+		}
+		return events
+	}
+
+	for _, streaming := range []bool{false, true} {
+		// The new head's records are held until the reorg is recorded, it's emitted once after
+		// it, also when streaming
+		expected := []string{"G", "A1", "A2", "A3", "A4", "A5", "B1", "B2", "B3", "B4", "REORG 5 A5 5 B5 0", "B1", "B2", "B3", "B4", "B5", "B6", "B7"}
+		events := record(false, streaming)
+		if !reflect.DeepEqual(events, expected) {
+			t.Fatalf("streaming %t: got events %q, expected %q", streaming, events, expected)
+		}
+		assertEmittedOnceAfterReorg(t, events, []string{"B1", "B2", "B3", "B4", "B5", "B6", "B7"})
+
+		// The state of the new branch's first blocks was not persisted, they are executed again
+		expected = []string{"G", "A1", "A2", "A3", "A4", "A5", "B1", "B2", "B3", "B4", "B1", "B2", "B3", "B4", "REORG 5 A5 5 B5 0", "B1", "B2", "B3", "B4", "B5", "B6", "B7"}
+		events = record(true, streaming)
+		if !reflect.DeepEqual(events, expected) {
+			t.Fatalf("streaming %t: got events %q after a restart, expected %q", streaming, events, expected)
+		}
+		assertEmittedOnceAfterReorg(t, events, []string{"B1", "B2", "B3", "B4", "B5", "B6", "B7"})
+	}
+}
+
+// TestReorgFailsWhenReemitFails tests that a reorg whose blocks of the new branch can't be
+// executed again fails before anything is emitted and before the head moves.
+func TestReorgFailsWhenReemitFails(t *testing.T) {
+	defer func(enabled bool, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = enabled, genesis, buffer
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+
+	gspec := &Genesis{Config: params.TestChainConfig}
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+
+	canonical, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), rawdb.NewMemoryDatabase(), 3, func(i int, b *BlockGen) {})
+	fork, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), rawdb.NewMemoryDatabase(), 3, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0xbb})
+	})
+
+	output := &bytes.Buffer{}
+	firehose.SetWriter(output)
+	firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = true, gspec, &bytes.Buffer{}
+
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer blockchain.Stop()
+	if _, err := blockchain.InsertChain(canonical); err != nil {
+		t.Fatalf("failed to insert blocks: %v", err)
+	}
+	output.Reset()
+
+	// The blocks of the new branch are stored without their state
+	for _, block := range fork {
+		rawdb.WriteBlock(db, block)
+	}
+	if err := blockchain.reorg(canonical[2], fork[2]); err == nil || !strings.Contains(err.Error(), "re-emitting Firehose block #2") {
+		t.Fatalf("got error %v, expected the re-execution of block #2 to fail", err)
+	}
+	if output.Len() != 0 {
+		t.Fatalf("got output %q after the failed reorg, expected none", output.String())
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != canonical[2].Hash() {
+		t.Fatalf("head is #%d after the failed reorg, expected #%d", head.NumberU64(), canonical[2].NumberU64())
+	}
+}
+
+// assertEmittedOnceAfterReorg checks that each of the blocks is emitted exactly once after the
+// last REORG event.
+func assertEmittedOnceAfterReorg(t *testing.T, events []string, blocks []string) {
+	t.Helper()
+
+	last := -1
+	for i, event := range events {
+		if strings.HasPrefix(event, "REORG ") {
+			last = i
+		}
+	}
+	if last < 0 {
+		t.Fatalf("no REORG in events %q", events)
+	}
+
+	counts := map[string]int{}
+	for _, event := range events[last+1:] {
+		counts[event]++
+	}
+	for _, block := range blocks {
+		if counts[block] != 1 {
+			t.Fatalf("block %s emitted %d times after the reorg in events %q, expected once", block, counts[block], events)
+		}
+	}
+}
+
+// TestStartBlockNumSuppressesEarlierBlocks tests that the blocks below the start block are
+// processed without being emitted, staged or streamed, the output starting with the INIT
+// handshake and the genesis block followed by the blocks from the start one.
+func TestStartBlockNumSuppressesEarlierBlocks(t *testing.T) {
+	defer func(enabled, streaming bool, start uint64, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.StreamingBlocksEnabled, firehose.StartBlockNum = enabled, streaming, start
+		firehose.GenesisConfig, firehose.BlockSyncBuffer = genesis, buffer
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.StreamingBlocksEnabled, firehose.StartBlockNum, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+
+	gspec := &Genesis{Config: params.TestChainConfig}
+	genesis := gspec.MustCommit(rawdb.NewMemoryDatabase())
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), rawdb.NewMemoryDatabase(), 5, func(i int, b *BlockGen) {})
+
+	for _, streaming := range []bool{false, true} {
+		db := rawdb.NewMemoryDatabase()
+		gspec.MustCommit(db)
+
+		output, staged := &bytes.Buffer{}, &bytes.Buffer{}
+		firehose.SetWriter(output)
+		firehose.Enabled, firehose.StreamingBlocksEnabled, firehose.StartBlockNum = true, streaming, 3
+		firehose.GenesisConfig, firehose.BlockSyncBuffer = gspec, staged
+
+		blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+		genesisStaged := staged.String()
+		if _, err := blockchain.InsertChain(blocks[:2]); err != nil {
+			t.Fatalf("failed to insert blocks: %v", err)
+		}
+		if staged.String() != genesisStaged {
+			t.Fatalf("streaming %t: blocks below the start block were staged: %q", streaming, strings.TrimPrefix(staged.String(), genesisStaged))
+		}
+		if _, err := blockchain.InsertChain(blocks[2:]); err != nil {
+			t.Fatalf("failed to insert blocks: %v", err)
+		}
+		blockchain.Stop()
+
+		var events []string
+		for _, line := range strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n") {
+			fields := strings.Split(line, " ")
+			switch fields[1] {
+			case "INIT":
+				events = append(events, "INIT")
+			case "BEGIN_BLOCK", "END_BLOCK":
+				events = append(events, fields[1]+" "+fields[2])
+			}
+		}
+
+		expected := []string{"INIT", "BEGIN_BLOCK 0", "END_BLOCK 0", "BEGIN_BLOCK 3", "END_BLOCK 3", "BEGIN_BLOCK 4", "END_BLOCK 4", "BEGIN_BLOCK 5", "END_BLOCK 5"}
+		if !reflect.DeepEqual(events, expected) {
+			t.Fatalf("streaming %t: got events %q, expected %q", streaming, events, expected)
+		}
+	}
+}
+
+// TestStartBlockNumSuppressesKnownBlocks tests that a known block met in the middle of an
+// import, skipped without execution, is suppressed below the start block and staged or
+// streamed like the executed ones from it.
+func TestStartBlockNumSuppressesKnownBlocks(t *testing.T) {
+	defer func(enabled, streaming bool, start uint64, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.StreamingBlocksEnabled, firehose.StartBlockNum = enabled, streaming, start
+		firehose.GenesisConfig, firehose.BlockSyncBuffer = genesis, buffer
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.StreamingBlocksEnabled, firehose.StartBlockNum, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+
+	gspec := &Genesis{Config: params.TestChainConfig}
+
+	tests := []struct {
+		start     uint64
+		streaming bool
+		expected  []string
+	}{
+		{3, false, []string{"INIT", "BEGIN_BLOCK 0", "END_BLOCK 0", "BEGIN_BLOCK 3", "END_BLOCK 3"}},
+		{3, true, []string{"INIT", "BEGIN_BLOCK 0", "END_BLOCK 0", "BEGIN_BLOCK 3", "END_BLOCK 3"}},
+		{2, false, []string{"INIT", "BEGIN_BLOCK 0", "END_BLOCK 0", "BEGIN_BLOCK 2", "END_BLOCK 2", "BEGIN_BLOCK 3", "END_BLOCK 3"}},
+		{2, true, []string{"INIT", "BEGIN_BLOCK 0", "END_BLOCK 0", "BEGIN_BLOCK 2", "END_BLOCK 2", "BEGIN_BLOCK 3", "END_BLOCK 3"}},
+	}
+	for _, test := range tests {
+		db := rawdb.NewMemoryDatabase()
+		genesis := gspec.MustCommit(db)
+
+		// The states of the blocks are committed in the chain's database, block 2 being known
+		// once written too
+		blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 3, func(i int, b *BlockGen) {})
+		td := new(big.Int).Add(genesis.Difficulty(), blocks[0].Difficulty())
+		rawdb.WriteBlock(db, blocks[1])
+		rawdb.WriteTd(db, blocks[1].Hash(), 2, td.Add(td, blocks[1].Difficulty()))
+
+		output, staged := &bytes.Buffer{}, &bytes.Buffer{}
+		firehose.SetWriter(output)
+		firehose.Enabled, firehose.StreamingBlocksEnabled, firehose.StartBlockNum = true, test.streaming, test.start
+		firehose.GenesisConfig, firehose.BlockSyncBuffer = gspec, staged
+
+		blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+		genesisStaged := staged.String()
+		if _, err := blockchain.InsertChain(blocks[:2]); err != nil {
+			t.Fatalf("start %d: failed to insert blocks: %v", test.start, err)
+		}
+		if test.start > 2 && staged.String() != genesisStaged {
+			t.Fatalf("start %d, streaming %t: blocks below the start block were staged: %q", test.start, test.streaming, strings.TrimPrefix(staged.String(), genesisStaged))
+		}
+		if _, err := blockchain.InsertChain(blocks[2:]); err != nil {
+			t.Fatalf("start %d: failed to insert blocks: %v", test.start, err)
+		}
+		blockchain.Stop()
+
+		var events []string
+		for _, line := range strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n") {
+			fields := strings.Split(line, " ")
+			switch fields[1] {
+			case "INIT":
+				events = append(events, "INIT")
+			case "BEGIN_BLOCK", "END_BLOCK":
+				events = append(events, fields[1]+" "+fields[2])
+			}
+		}
+		if !reflect.DeepEqual(events, test.expected) {
+			t.Fatalf("start %d, streaming %t: got events %q, expected %q", test.start, test.streaming, events, test.expected)
+		}
+	}
+}
+
+// TestStopBlockNumStopsImport tests that block import stops once the block at the stop height
+// becomes canonical, a lighter block at that height or below not stopping it, and that nothing
+// above it is imported.
+func TestStopBlockNumStopsImport(t *testing.T) {
+	defer func(enabled bool, stop uint64, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.StopBlockNum, firehose.GenesisConfig, firehose.BlockSyncBuffer = enabled, stop, genesis, buffer
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.StopBlockNum, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+
+	gspec := &Genesis{Config: params.TestChainConfig}
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+
+	// The first branch is heavier block for block, its blocks being closer in time, the second
+	// branch only becomes canonical at the stop height with its third block
+	heavy, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), rawdb.NewMemoryDatabase(), 3, func(i int, b *BlockGen) {
+		b.OffsetTime(-2)
+	})
+	light, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), rawdb.NewMemoryDatabase(), 4, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0xbb})
+	})
+
+	output := &bytes.Buffer{}
+	firehose.SetWriter(output)
+	firehose.Enabled, firehose.StopBlockNum, firehose.GenesisConfig, firehose.BlockSyncBuffer = true, 3, gspec, &bytes.Buffer{}
+
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer blockchain.Stop()
+
+	if _, err := blockchain.InsertChain(heavy[:2]); err != nil {
+		t.Fatalf("failed to insert blocks: %v", err)
+	}
+	if n, err := blockchain.InsertChain(light); n != 3 || err != firehose.ErrStopBlockReached {
+		t.Fatalf("got %d, %v importing past the stop block, expected 3, %v", n, err, firehose.ErrStopBlockReached)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != light[2].Hash() {
+		t.Fatalf("head is #%d %x, expected the stop block %x", head.NumberU64(), head.Hash(), light[2].Hash())
+	}
+	select {
+	case <-firehose.StopBlockReached():
+	default:
+		t.Fatalf("stop block not reported as reached")
+	}
+
+	// Blocks at the stop height are refused once it was reached
+	if _, err := blockchain.InsertChain(heavy[2:]); err != firehose.ErrStopBlockReached {
+		t.Fatalf("got %v importing at the stop height, expected %v", err, firehose.ErrStopBlockReached)
+	}
+
+	var emitted []string
+	for _, line := range strings.Split(output.String(), "\n") {
+		if strings.HasPrefix(line, "FIRE END_BLOCK ") {
+			emitted = append(emitted, strings.Split(line, " ")[2])
+		}
+	}
+	if expected := []string{"0", "1", "2", "1", "2", "1", "2", "3"}; !reflect.DeepEqual(emitted, expected) {
+		t.Fatalf("got blocks %q emitted, expected %q", emitted, expected)
+	}
+}
+
+// TestFinalizedOnlyEmitsCanonicalBlocks tests that in Firehose finalized only mode blocks are
+// emitted once final, the ones reorged out before never being emitted, and that the blocks
+// above the last emitted one, buffered then dropped on shutdown, are executed again on restart
+// without the chain being rewound, also when their state was not persisted.
+func TestFinalizedOnlyEmitsCanonicalBlocks(t *testing.T) {
+	defer func(enabled, finalizedOnly bool, confirmations uint64, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.FinalizedOnlyEnabled, firehose.FinalizedConfirmations = enabled, finalizedOnly, confirmations
+		firehose.GenesisConfig, firehose.BlockSyncBuffer = genesis, buffer
+		firehose.ResumeFinalized(0)
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.FinalizedOnlyEnabled, firehose.FinalizedConfirmations, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+
+	gspec := &Genesis{Config: params.TestChainConfig}
+
+	// The second branch forks from the third block and is heavier from its second block
+	genDb := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(genDb)
+	canonical, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), genDb, 5, func(i int, b *BlockGen) {})
+	fork, _ := GenerateChain(gspec.Config, canonical[2], ethash.NewFaker(), genDb, 4, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0xbb})
+		b.OffsetTime(-2)
+	})
+
+	labels := map[common.Hash]string{genesis.Hash(): "G"}
+	for i := range canonical {
+		labels[canonical[i].Hash()] = fmt.Sprintf("A%d", i+1)
+	}
+	for i := range fork {
+		labels[fork[i].Hash()] = fmt.Sprintf("B%d", i+4)
+	}
+
+	output := &bytes.Buffer{}
+	firehose.SetWriter(output)
+	firehose.Enabled, firehose.FinalizedOnlyEnabled, firehose.FinalizedConfirmations = true, true, 2
+	firehose.GenesisConfig, firehose.BlockSyncBuffer = gspec, &bytes.Buffer{}
+
+	emitted := func() []string {
+		firehose.WaitOutputWritten()
+
+		var events []string
+		for _, line := range strings.Split(output.String(), "\n") {
+			fields := strings.Split(line, " ")
+			switch {
+			case strings.HasPrefix(line, "FIRE INIT "):
+				events = append(events, "INIT")
+			case strings.HasPrefix(line, "FIRE END_BLOCK "):
+				var block struct {
+					Header struct {
+						Hash common.Hash `json:"hash"`
+					} `json:"header"`
+				}
+				if err := json.Unmarshal([]byte(fields[4]), &block); err != nil {
+					t.Fatalf("failed to decode END_BLOCK: %v", err)
+				}
+				events = append(events, labels[block.Header.Hash])
+			case strings.HasPrefix(line, "FIRE REORG "):
+				events = append(events, "REORG")
+			}
+		}
+		output.Reset()
+		return events
+	}
+
+	archive := &CacheConfig{TrieDirtyDisabled: true, TrieTimeLimit: 5 * time.Minute}
+	for _, cacheConfig := range []*CacheConfig{archive, nil} {
+		db := rawdb.NewMemoryDatabase()
+		gspec.MustCommit(db)
+		firehose.ResumeFinalized(0)
+
+		blockchain, _ := NewBlockChain(db, cacheConfig, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+		for _, blocks := range [][]*types.Block{canonical, fork[:3]} {
+			if _, err := blockchain.InsertChain(blocks); err != nil {
+				t.Fatalf("failed to insert blocks: %v", err)
+			}
+		}
+		blockchain.Stop()
+
+		if events, expected := emitted(), []string{"INIT", "G", "A1", "A2", "A3", "B4"}; !reflect.DeepEqual(events, expected) {
+			t.Fatalf("archive %t: got events %q, expected %q", cacheConfig != nil, events, expected)
+		}
+
+		// The buffered blocks were dropped, they are executed again from the last emitted one
+		// while the head stays
+		blockchain, _ = NewBlockChain(db, cacheConfig, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+		if head := blockchain.CurrentBlock(); head.Hash() != fork[2].Hash() {
+			t.Fatalf("archive %t: head is #%d %s after restart, expected B6", cacheConfig != nil, head.NumberU64(), labels[head.Hash()])
+		}
+		if _, err := blockchain.InsertChain(fork[1:]); err != nil {
+			t.Fatalf("failed to insert blocks: %v", err)
+		}
+		blockchain.Stop()
+
+		if events, expected := emitted(), []string{"INIT", "B5"}; !reflect.DeepEqual(events, expected) {
+			t.Fatalf("archive %t: got events %q after restart, expected %q", cacheConfig != nil, events, expected)
+		}
+	}
+}
+
+// TestReorgReemitsKnownSideChainBlocks tests that the blocks of a side chain becoming canonical
+// again are emitted again after the reorg, in canonical order, while their import is skipped as
+// they are known with their state.
+func TestReorgReemitsKnownSideChainBlocks(t *testing.T) {
+	defer func(enabled bool, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = enabled, genesis, buffer
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+
+	gspec := &Genesis{Config: params.TestChainConfig}
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+
+	// The second branch is heavier up to its third block, the first one is heavier with its
+	// fourth block
+	canonical, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), rawdb.NewMemoryDatabase(), 4, func(i int, b *BlockGen) {})
+	fork, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), rawdb.NewMemoryDatabase(), 3, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0xbb})
+		b.OffsetTime(-2)
+	})
+
+	labels := map[common.Hash]string{genesis.Hash(): "G"}
+	for i := range canonical {
+		labels[canonical[i].Hash()] = fmt.Sprintf("A%d", i+1)
+	}
+	for i := range fork {
+		labels[fork[i].Hash()] = fmt.Sprintf("B%d", i+1)
+	}
+
+	output := &bytes.Buffer{}
+	firehose.SetWriter(output)
+	firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = true, gspec, &bytes.Buffer{}
+
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	for _, blocks := range [][]*types.Block{canonical[:3], fork, canonical} {
+		if _, err := blockchain.InsertChain(blocks); err != nil {
+			t.Fatalf("failed to insert blocks: %v", err)
+		}
+	}
+	blockchain.Stop()
+
+	var events []string
+	for _, line := range strings.Split(output.String(), "\n") {
+		fields := strings.Split(line, " ")
+		switch {
+		case strings.HasPrefix(line, "FIRE BEGIN_BLOCK "):
+			events = append(events, "#"+fields[2])
+		case strings.HasPrefix(line, "FIRE END_BLOCK "):
+			var block struct {
+				Header struct {
+					Hash common.Hash `json:"hash"`
+				} `json:"header"`
+			}
+			if err := json.Unmarshal([]byte(fields[4]), &block); err != nil {
+				t.Fatalf("failed to decode END_BLOCK: %v", err)
+			}
+			events[len(events)-1] = labels[block.Header.Hash]
+		case strings.HasPrefix(line, "FIRE REORG "):
+			events = append(events, fmt.Sprintf("REORG %s %s", labels[common.HexToHash(fields[3])], labels[common.HexToHash(fields[5])]))
+		}
+	}
+
+	// The first blocks of the first branch are known, only its fourth block is executed
+	expected := []string{"G", "A1", "A2", "A3", "B1", "B2", "REORG A3 B3", "B1", "B2", "B3", "REORG B3 A4", "A1", "A2", "A3", "A4"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("got events %q, expected %q", events, expected)
+	}
+	assertEmittedOnceAfterReorg(t, events, []string{"A1", "A2", "A3", "A4"})
+}
+
+// TestBlockChainFromNonZeroGenesis tests a chain whose genesis block is at a non-zero height, as
+// after a regenesis: blocks and headers are imported on top of it, the chain is re-opened from
+// the database and rewound down to the genesis block.
+func TestBlockChainFromNonZeroGenesis(t *testing.T) {
+	gspec := &Genesis{
+		Config:     params.TestChainConfig,
+		Number:     1000,
+		ParentHash: common.HexToHash("0xee"),
+		Alloc:      GenesisAlloc{common.HexToAddress("0xc0"): {Balance: big.NewInt(1)}},
+	}
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+	genDb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(genDb)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), genDb, 4, func(i int, b *BlockGen) {})
+
+	// The header chain starts from the genesis height
+	hc, err := NewHeaderChain(rawdb.NewMemoryDatabase(), gspec.Config, ethash.NewFaker(), func() bool { return false })
+	if err != ErrNoGenesis {
+		t.Fatalf("got error %v on an empty database, expected %v", err, ErrNoGenesis)
+	}
+	headerDb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(headerDb)
+	if hc, err = NewHeaderChain(headerDb, gspec.Config, ethash.NewFaker(), func() bool { return false }); err != nil {
+		t.Fatalf("failed to create header chain: %v", err)
+	}
+	if hc.genesisHeader.Hash() != genesis.Hash() {
+		t.Fatalf("got genesis header #%d, expected #%d", hc.genesisHeader.Number, genesis.Number())
+	}
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if _, err := hc.InsertHeaderChain(headers, time.Now()); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	if head := hc.CurrentHeader(); head.Hash() != blocks[3].Hash() {
+		t.Fatalf("header head is #%d, expected #%d", head.Number, blocks[3].Number())
+	}
+
+	blockchain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if blockchain.Genesis().Hash() != genesis.Hash() {
+		t.Fatalf("got genesis #%d, expected #%d", blockchain.Genesis().NumberU64(), genesis.NumberU64())
+	}
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert blocks: %v", err)
+	}
+	blockchain.Stop()
+
+	// The head and the genesis block are found again once re-opened
+	if blockchain, err = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil); err != nil {
+		t.Fatalf("failed to re-open blockchain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	if head := blockchain.CurrentBlock(); head.Hash() != blocks[3].Hash() {
+		t.Fatalf("head is #%d after re-opening, expected #%d", head.NumberU64(), blocks[3].NumberU64())
+	}
+	if blockchain.Genesis().Hash() != genesis.Hash() {
+		t.Fatalf("got genesis #%d after re-opening, expected #%d", blockchain.Genesis().NumberU64(), genesis.NumberU64())
+	}
+
+	// Rewinding down to the genesis block keeps it, with its state
+	if err := blockchain.SetHead(genesis.NumberU64()); err != nil {
+		t.Fatalf("failed to rewind to the genesis block: %v", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != genesis.Hash() {
+		t.Fatalf("head is #%d after rewinding, expected the genesis #%d", head.NumberU64(), genesis.NumberU64())
+	}
+	if head := blockchain.CurrentHeader(); head.Hash() != genesis.Hash() {
+		t.Fatalf("header head is #%d after rewinding, expected the genesis #%d", head.Number, genesis.NumberU64())
+	}
+	if !blockchain.HasState(genesis.Root()) {
+		t.Fatalf("genesis state missing after rewinding")
+	}
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert blocks again after rewinding: %v", err)
+	}
+}
+
+// TestFinalizedOnlyReplayLimited tests that in Firehose finalized only mode the node fails to
+// start when more than `firehose.FinalizedMaxReplayBlocks` blocks must be executed again.
+func TestFinalizedOnlyReplayLimited(t *testing.T) {
+	defer func(enabled, finalizedOnly bool, confirmations, maxReplay uint64, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.FinalizedOnlyEnabled, firehose.FinalizedConfirmations = enabled, finalizedOnly, confirmations
+		firehose.FinalizedMaxReplayBlocks, firehose.GenesisConfig, firehose.BlockSyncBuffer = maxReplay, genesis, buffer
+		firehose.ResumeFinalized(0)
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.FinalizedOnlyEnabled, firehose.FinalizedConfirmations, firehose.FinalizedMaxReplayBlocks, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+
+	gspec := &Genesis{Config: params.TestChainConfig}
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), rawdb.NewMemoryDatabase(), 5, func(i int, b *BlockGen) {})
+
+	firehose.SetWriter(&bytes.Buffer{})
+	firehose.Enabled, firehose.FinalizedOnlyEnabled, firehose.FinalizedConfirmations = true, true, 2
+	firehose.GenesisConfig, firehose.BlockSyncBuffer = gspec, &bytes.Buffer{}
+
+	// The blocks up to the third one are emitted, the two above it are executed again on restart
+	archive := &CacheConfig{TrieDirtyDisabled: true, TrieTimeLimit: 5 * time.Minute}
+	blockchain, _ := NewBlockChain(db, archive, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert blocks: %v", err)
+	}
+	blockchain.Stop()
+
+	firehose.FinalizedMaxReplayBlocks = 1
+	if _, err := NewBlockChain(db, archive, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil); err == nil || !strings.Contains(err.Error(), "no state within 1 blocks below head #5") {
+		t.Fatalf("got error %v, expected the replay to be refused", err)
+	}
+
+	firehose.FinalizedMaxReplayBlocks = 2
+	blockchain, err := NewBlockChain(db, archive, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	blockchain.Stop()
+}
@@ -18,11 +18,15 @@ package core
 
 import (
 	"math/big"
+	"strings"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -158,3 +162,87 @@ func TestDAOForkRangeExtradata(t *testing.T) {
 		t.Fatalf("pro-fork chain didn't accept contra-fork block post-fork: %v", err)
 	}
 }
+
+// Tests that the irregular state transfer of the DAO hard fork records the drain of every
+// DAO account into the refund contract in the fork block's system call, the balance changes
+// summing to zero.
+func TestDAOForkRecordsRefundTransfers(t *testing.T) {
+	config := params.ChainConfig{
+		ChainID:        big.NewInt(1),
+		HomesteadBlock: big.NewInt(0),
+		DAOForkBlock:   big.NewInt(1),
+		DAOForkSupport: true,
+		Ethash:         new(params.EthashConfig),
+	}
+
+	db := rawdb.NewMemoryDatabase()
+	gspec := &Genesis{Config: &config, Alloc: GenesisAlloc{}}
+	for i, addr := range params.DAODrainList() {
+		gspec.Alloc[addr] = GenesisAccount{Balance: big.NewInt(int64(i + 1))}
+	}
+	genesis := gspec.MustCommit(db)
+
+	blockchain, _ := NewBlockChain(db, nil, &config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer blockchain.Stop()
+
+	// Enabled once the chain is created, otherwise it would record the genesis block
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	blocks, _ := GenerateChain(&config, genesis, ethash.NewFaker(), db, 1, func(i int, gen *BlockGen) {})
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	printer := firehose.NewToBufferPrinter(1024)
+	if _, _, _, err := NewStateProcessor(&config, blockchain, ethash.NewFaker()).Process(blocks[0], statedb, vm.Config{}, firehose.NewContext(printer, true)); err != nil {
+		t.Fatalf("failed to process fork block: %v", err)
+	}
+
+	amount := func(field string) *big.Int {
+		value, _ := new(big.Int).SetString(strings.TrimPrefix(field, "."), 16)
+		if value == nil {
+			return new(big.Int)
+		}
+		return value
+	}
+
+	var (
+		inDAOFork bool
+		drained   = make(map[common.Address]bool)
+		sum       = new(big.Int)
+	)
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		fields := strings.Split(line, " ")
+		switch {
+		case strings.HasPrefix(line, "FIRE BEGIN_SYSTEM_CALL dao_fork "):
+			inDAOFork = true
+		case strings.HasPrefix(line, "FIRE END_SYSTEM_CALL "):
+			inDAOFork = false
+		case inDAOFork && strings.HasPrefix(line, "FIRE BALANCE_CHANGE "):
+			address := common.HexToAddress(fields[3])
+			switch fields[6] {
+			case "dao_refund_contract":
+				if address != params.DAORefundContract {
+					t.Fatalf("refund recorded on %s instead of the refund contract", address)
+				}
+			case "dao_adjust_balance":
+				if fields[5] != "." {
+					t.Fatalf("account %s not drained: %s", address, line)
+				}
+				drained[address] = true
+			default:
+				t.Fatalf("unexpected balance change in the DAO fork system call: %s", line)
+			}
+			sum.Add(sum, new(big.Int).Sub(amount(fields[5]), amount(fields[4])))
+		}
+	}
+	for _, addr := range params.DAODrainList() {
+		if !drained[addr] {
+			t.Errorf("drain of DAO account %s not recorded", addr)
+		}
+	}
+	if sum.Sign() != 0 {
+		t.Errorf("DAO fork balance changes sum to %v instead of zero", sum)
+	}
+	if statedb.GetBalance(params.DAORefundContract).Sign() == 0 {
+		t.Errorf("refund contract not credited")
+	}
+}
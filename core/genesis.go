@@ -161,8 +161,18 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 	if genesis != nil && genesis.Config == nil {
 		return params.AllEthashProtocolChanges, common.Hash{}, errGenesisNoConfig
 	}
+	// The overrides apply to a copy, the configurations of the known networks are shared
+	applyOverrides := func(config *params.ChainConfig) *params.ChainConfig {
+		if overrideBerlin == nil {
+			return config
+		}
+		overridden := *config
+		overridden.BerlinBlock = overrideBerlin
+		return &overridden
+	}
 	// Just commit the new block if there is no stored genesis block.
-	stored := rawdb.ReadCanonicalHash(db, 0)
+	number := rawdb.ReadGenesisNumber(db)
+	stored := rawdb.ReadCanonicalHash(db, number)
 	if (stored == common.Hash{}) {
 		if genesis == nil {
 			log.Info("Writing default main-net genesis block")
@@ -170,6 +180,10 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 		} else {
 			log.Info("Writing custom genesis block")
 		}
+		overridden := *genesis
+		overridden.Config = applyOverrides(genesis.Config)
+		genesis = &overridden
+
 		block, err := genesis.Commit(db)
 		if err != nil {
 			return genesis.Config, common.Hash{}, err
@@ -178,7 +192,7 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 	}
 	// We have the genesis block in database(perhaps in ancient database)
 	// but the corresponding state is missing.
-	header := rawdb.ReadHeader(db, stored, 0)
+	header := rawdb.ReadHeader(db, stored, number)
 	if _, err := state.New(header.Root, state.NewDatabaseWithConfig(db, nil), nil); err != nil {
 		if genesis == nil {
 			genesis = DefaultGenesisBlock()
@@ -202,10 +216,7 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 		}
 	}
 	// Get the existing chain configuration.
-	newcfg := genesis.configOrDefault(stored)
-	if overrideBerlin != nil {
-		newcfg.BerlinBlock = overrideBerlin
-	}
+	newcfg := applyOverrides(genesis.configOrDefault(stored))
 	if err := newcfg.CheckConfigForkOrder(); err != nil {
 		return newcfg, common.Hash{}, err
 	}
@@ -217,9 +228,12 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 	}
 	// Special case: don't change the existing config of a non-mainnet chain if no new
 	// config is supplied. These chains would get AllProtocolChanges (and a compat error)
-	// if we just continued here.
+	// if we just continued here. The overrides still apply, checked against the head below.
 	if genesis == nil && stored != params.MainnetGenesisHash {
-		return storedcfg, stored, nil
+		if overrideBerlin == nil {
+			return storedcfg, stored, nil
+		}
+		newcfg = applyOverrides(storedcfg)
 	}
 	// Check config compatibility and write the config. Compatibility errors
 	// are returned to the caller unless we're already at block zero.
@@ -228,7 +242,7 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 		return newcfg, stored, fmt.Errorf("missing block number for head header hash")
 	}
 	compatErr := storedcfg.CheckCompatible(newcfg, *height)
-	if compatErr != nil && *height != 0 && compatErr.RewindTo != 0 {
+	if compatErr != nil && *height != number && compatErr.RewindTo != 0 {
 		return newcfg, stored, compatErr
 	}
 	rawdb.WriteChainConfig(db, stored, newcfg)
@@ -264,7 +278,7 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 	for addr, account := range g.Alloc {
 		statedb.AddBalance(addr, account.Balance, false, firehose.NoOpContext, firehose.BalanceChangeReason("genesis_balance"))
 		statedb.SetCode(addr, account.Code, firehose.NoOpContext)
-		statedb.SetNonce(addr, account.Nonce, firehose.NoOpContext)
+		statedb.SetNonce(addr, account.Nonce, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 		for key, value := range account.Storage {
 			statedb.SetState(addr, key, value, firehose.NoOpContext)
 		}
@@ -296,12 +310,10 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 }
 
 // Commit writes the block and state of a genesis specification to the database.
-// The block is committed as the canonical head block.
+// The block is committed as the canonical head block. A genesis with a non-zero number,
+// as after a regenesis, has its number recorded so the chain is looked up from there.
 func (g *Genesis) Commit(db ethdb.Database) (*types.Block, error) {
 	block := g.ToBlock(db)
-	if block.Number().Sign() != 0 {
-		return nil, fmt.Errorf("can't commit genesis block with number > 0")
-	}
 	config := g.Config
 	if config == nil {
 		config = params.AllEthashProtocolChanges
@@ -317,6 +329,9 @@ func (g *Genesis) Commit(db ethdb.Database) (*types.Block, error) {
 	rawdb.WriteHeadFastBlockHash(db, block.Hash())
 	rawdb.WriteHeadHeaderHash(db, block.Hash())
 	rawdb.WriteChainConfig(db, block.Hash(), config)
+	if block.NumberU64() != 0 {
+		rawdb.WriteGenesisNumber(db, block.NumberU64())
+	}
 	return block, nil
 }
 
@@ -336,6 +351,24 @@ func GenesisBlockForTesting(db ethdb.Database, addr common.Address, balance *big
 	return g.MustCommit(db)
 }
 
+// knownGenesisBlock returns the embedded genesis of the known network whose
+// genesis block has the given hash, nil if none has.
+func knownGenesisBlock(hash common.Hash) *Genesis {
+	switch hash {
+	case params.MainnetGenesisHash:
+		return DefaultGenesisBlock()
+	case params.RopstenGenesisHash:
+		return DefaultRopstenGenesisBlock()
+	case params.RinkebyGenesisHash:
+		return DefaultRinkebyGenesisBlock()
+	case params.GoerliGenesisHash:
+		return DefaultGoerliGenesisBlock()
+	case params.YoloV3GenesisHash:
+		return DefaultYoloV3GenesisBlock()
+	}
+	return nil
+}
+
 // DefaultGenesisBlock returns the Ethereum main net genesis block.
 func DefaultGenesisBlock() *Genesis {
 	return &Genesis{
@@ -17,16 +17,22 @@
 package core
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"math/big"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -162,3 +168,364 @@ func TestSetupGenesis(t *testing.T) {
 		}
 	}
 }
+
+// TestSetupGenesisAtNonZeroHeight tests the genesis setup on a database whose genesis block is
+// at a non-zero height, as after a regenesis.
+func TestSetupGenesisAtNonZeroHeight(t *testing.T) {
+	regenesis := Genesis{
+		Config:     &params.ChainConfig{HomesteadBlock: big.NewInt(1003)},
+		Number:     1000,
+		ParentHash: common.HexToHash("0xee"),
+		Alloc:      GenesisAlloc{{1}: {Balance: big.NewInt(1)}},
+	}
+	oldregenesis := regenesis
+	oldregenesis.Config = &params.ChainConfig{HomesteadBlock: big.NewInt(1002)}
+	regenesisHash := regenesis.ToBlock(nil).Hash()
+
+	tests := []struct {
+		name       string
+		fn         func(ethdb.Database) (*params.ChainConfig, common.Hash, error)
+		wantConfig *params.ChainConfig
+		wantHash   common.Hash
+		wantErr    error
+	}{
+		{
+			name: "regenesis block in DB, genesis == nil",
+			fn: func(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
+				regenesis.MustCommit(db)
+				return SetupGenesisBlock(db, nil)
+			},
+			wantHash:   regenesisHash,
+			wantConfig: regenesis.Config,
+		},
+		{
+			name: "regenesis block in DB, genesis == ropsten",
+			fn: func(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
+				regenesis.MustCommit(db)
+				return SetupGenesisBlock(db, DefaultRopstenGenesisBlock())
+			},
+			wantErr:    &GenesisMismatchError{Stored: regenesisHash, New: params.RopstenGenesisHash},
+			wantHash:   params.RopstenGenesisHash,
+			wantConfig: params.RopstenChainConfig,
+		},
+		{
+			name: "compatible config in DB",
+			fn: func(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
+				oldregenesis.MustCommit(db)
+				return SetupGenesisBlock(db, &regenesis)
+			},
+			wantHash:   regenesisHash,
+			wantConfig: regenesis.Config,
+		},
+		{
+			name: "incompatible config in DB",
+			fn: func(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
+				// Advance to block #1004, past the homestead transition block of the new config
+				genesis := oldregenesis.MustCommit(db)
+
+				bc, _ := NewBlockChain(db, nil, oldregenesis.Config, ethash.NewFullFaker(), vm.Config{}, nil, nil)
+				defer bc.Stop()
+
+				blocks, _ := GenerateChain(oldregenesis.Config, genesis, ethash.NewFaker(), db, 4, nil)
+				bc.InsertChain(blocks)
+				return SetupGenesisBlock(db, &regenesis)
+			},
+			wantHash:   regenesisHash,
+			wantConfig: regenesis.Config,
+			wantErr: &params.ConfigCompatError{
+				What:         "Homestead fork block",
+				StoredConfig: big.NewInt(1002),
+				NewConfig:    big.NewInt(1003),
+				RewindTo:     1001,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		db := rawdb.NewMemoryDatabase()
+		config, hash, err := test.fn(db)
+		if !reflect.DeepEqual(err, test.wantErr) {
+			spew := spew.ConfigState{DisablePointerAddresses: true, DisableCapacities: true}
+			t.Errorf("%s: returned error %#v, want %#v", test.name, spew.NewFormatter(err), spew.NewFormatter(test.wantErr))
+		}
+		if !reflect.DeepEqual(config, test.wantConfig) {
+			t.Errorf("%s:\nreturned %v\nwant     %v", test.name, config, test.wantConfig)
+		}
+		if hash != test.wantHash {
+			t.Errorf("%s: returned hash %s, want %s", test.name, hash.Hex(), test.wantHash.Hex())
+		} else if err == nil {
+			if stored := rawdb.ReadBlock(db, test.wantHash, 1000); stored == nil || stored.Hash() != test.wantHash {
+				t.Errorf("%s: block #1000 in DB is %v, want %s", test.name, stored, test.wantHash)
+			}
+		}
+	}
+}
+
+func TestGenesisRecordsAllocCodeAndStorage(t *testing.T) {
+	defer func(enabled bool, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = enabled, genesis, buffer
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+
+	code := []byte{byte(vm.PUSH1), 1, byte(vm.STOP)}
+	gspec := &Genesis{
+		Config: params.TestChainConfig,
+		Alloc: GenesisAlloc{
+			common.HexToAddress("0xc1"): {Balance: big.NewInt(1), Code: code, Storage: map[common.Hash]common.Hash{
+				common.HexToHash("0x03"): common.HexToHash("0x0c"),
+				common.HexToHash("0x01"): common.HexToHash("0x0a"),
+				common.HexToHash("0x02"): common.HexToHash("0x0b"),
+			}},
+			common.HexToAddress("0xc0"): {Balance: big.NewInt(1), Storage: map[common.Hash]common.Hash{
+				common.HexToHash("0x01"): common.HexToHash("0x0d"),
+			}},
+		},
+	}
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	output := &bytes.Buffer{}
+	firehose.SetWriter(output)
+	firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = true, gspec, &bytes.Buffer{}
+
+	blockchain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	var changes []string
+	for _, line := range strings.Split(output.String(), "\n") {
+		fields := strings.Split(line, " ")
+		switch {
+		case strings.HasPrefix(line, "FIRE CODE_CHANGE "):
+			changes = append(changes, fmt.Sprintf("code %s %s", fields[3][38:], fields[7]))
+		case strings.HasPrefix(line, "FIRE STORAGE_CHANGE "):
+			changes = append(changes, fmt.Sprintf("storage %s %s=%s", fields[3][38:], fields[4][62:], fields[6][62:]))
+		}
+	}
+
+	// Accounts and storage keys are recorded in order
+	expected := []string{
+		"storage c0 01=0d",
+		fmt.Sprintf("code c1 %x", code),
+		"storage c1 01=0a",
+		"storage c1 02=0b",
+		"storage c1 03=0c",
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("got genesis changes %q, expected %q", changes, expected)
+	}
+}
+
+func TestBlockChainInitRecordsChainConfig(t *testing.T) {
+	defer func(enabled, syncInstrumentation bool, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.SyncInstrumentationEnabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = enabled, syncInstrumentation, genesis, buffer
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.SyncInstrumentationEnabled, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+
+	gspec := &Genesis{Config: params.TestChainConfig}
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	// The configuration of the genesis given by flag and the one stored in the database
+	flagConfig, _, err := SetupGenesisBlock(db, gspec)
+	if err != nil {
+		t.Fatalf("failed to setup genesis: %v", err)
+	}
+	storedConfig, _, err := SetupGenesisBlock(db, nil)
+	if err != nil {
+		t.Fatalf("failed to setup stored genesis: %v", err)
+	}
+
+	initLines := func(config *params.ChainConfig, setHead bool) []string {
+		output := &bytes.Buffer{}
+		firehose.SetWriter(output)
+		firehose.Enabled, firehose.SyncInstrumentationEnabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = true, true, gspec, &bytes.Buffer{}
+
+		blockchain, err := NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to create blockchain: %v", err)
+		}
+		defer blockchain.Stop()
+
+		if setHead {
+			if err := blockchain.SetHead(0); err != nil {
+				t.Fatalf("failed to set head: %v", err)
+			}
+		}
+
+		var lines []string
+		for _, line := range strings.Split(output.String(), "\n") {
+			if strings.HasPrefix(line, "FIRE INIT ") {
+				lines = append(lines, line)
+			}
+		}
+		return lines
+	}
+
+	fromFlag := initLines(flagConfig, false)
+	if len(fromFlag) != 1 {
+		t.Fatalf("expected a single INIT message, got %q", fromFlag)
+	}
+	fields := strings.Split(fromFlag[0], " ")
+	expected, _ := json.Marshal(params.TestChainConfig)
+	if fields[len(fields)-1] != "chain_config="+string(expected) {
+		t.Fatalf("got INIT last feature %q, expected chain config %s", fields[len(fields)-1], expected)
+	}
+	forkID := forkid.NewID(params.TestChainConfig, gspec.ToBlock(nil).Hash(), 0)
+	if expected := fmt.Sprintf("fork_id=%x:%d", forkID.Hash, forkID.Next); fields[len(fields)-2] != expected {
+		t.Fatalf("got INIT feature %q, expected fork ID %s", fields[len(fields)-2], expected)
+	}
+
+	if fromStore := initLines(storedConfig, false); !reflect.DeepEqual(fromStore, fromFlag) {
+		t.Fatalf("got INIT %q from the stored config, expected %q as from the flag", fromStore, fromFlag)
+	}
+
+	// Rewinding the head emits the handshake again
+	if rewound := initLines(flagConfig, true); !reflect.DeepEqual(rewound, append(fromFlag, fromFlag...)) {
+		t.Fatalf("got INIT %q after setting the head, expected it twice", rewound)
+	}
+}
+
+func TestBlockChainUsesKnownNetworkGenesis(t *testing.T) {
+	defer func(enabled bool, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = enabled, genesis, buffer
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+
+	gspec := DefaultGoerliGenesisBlock()
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	// No genesis was given, the stored one is recognized as Goerli's
+	output := &bytes.Buffer{}
+	firehose.SetWriter(output)
+	firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = true, nil, &bytes.Buffer{}
+
+	blockchain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	if !strings.Contains(output.String(), "FIRE END_BLOCK 0 ") {
+		t.Fatalf("expected the genesis block to be recorded, got %q", output.String())
+	}
+	if known := knownGenesisBlock(common.Hash{0x01}); known != nil {
+		t.Fatalf("expected no known network for an unknown genesis hash")
+	}
+}
+
+func TestBlockChainRecordsRegenesisAtItsHeight(t *testing.T) {
+	defer func(enabled bool, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = enabled, genesis, buffer
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+
+	// A chain restarted from a regenesis at block 1000
+	gspec := &Genesis{
+		Config:     params.TestChainConfig,
+		Number:     1000,
+		ParentHash: common.HexToHash("0xee"),
+		Alloc:      GenesisAlloc{common.HexToAddress("0xc0"): {Balance: big.NewInt(1)}},
+	}
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+
+	if _, hash, err := SetupGenesisBlock(db, gspec); err != nil || hash != genesis.Hash() {
+		t.Fatalf("got stored genesis %s (%v), expected %s", hash, err, genesis.Hash())
+	}
+
+	output := &bytes.Buffer{}
+	firehose.SetWriter(output)
+	firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = true, gspec, &bytes.Buffer{}
+
+	blockchain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	if blockchain.Genesis().Hash() != genesis.Hash() {
+		t.Fatalf("got genesis %s, expected %s", blockchain.Genesis().Hash(), genesis.Hash())
+	}
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {})
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+
+	var recorded []string
+	for _, line := range strings.Split(output.String(), "\n") {
+		if fields := strings.Split(line, " "); len(fields) > 2 && (fields[1] == "BEGIN_BLOCK" || fields[1] == "END_BLOCK") {
+			recorded = append(recorded, fields[1]+" "+fields[2])
+		}
+	}
+
+	// The initial state is recorded at the genesis height, followed by the next block without a gap
+	expected := []string{"BEGIN_BLOCK 1000", "END_BLOCK 1000", "BEGIN_BLOCK 1001", "END_BLOCK 1001"}
+	if !reflect.DeepEqual(recorded, expected) {
+		t.Fatalf("got blocks %q, expected %q", recorded, expected)
+	}
+}
+
+func TestBlockChainRecordsOverriddenFork(t *testing.T) {
+	defer func(enabled bool, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = enabled, genesis, buffer
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+
+	config := *params.TestChainConfig
+	config.BerlinBlock = nil
+	gspec := &Genesis{Config: &config}
+
+	for _, stored := range []bool{false, true} {
+		db := rawdb.NewMemoryDatabase()
+		if stored {
+			gspec.MustCommit(db)
+		}
+
+		// Berlin is scheduled at block 1 by the override flag only
+		overridden, _, err := SetupGenesisBlockWithOverride(db, gspec, big.NewInt(1))
+		if err != nil {
+			t.Fatalf("failed to setup genesis: %v", err)
+		}
+		if gspec.Config.BerlinBlock != nil {
+			t.Fatalf("expected the override to leave the genesis config untouched")
+		}
+		genesis := rawdb.ReadBlock(db, rawdb.ReadCanonicalHash(db, 0), 0)
+
+		output := &bytes.Buffer{}
+		firehose.SetWriter(output)
+		firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = true, gspec, &bytes.Buffer{}
+
+		blockchain, err := NewBlockChain(db, nil, overridden, ethash.NewFaker(), vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to create blockchain: %v", err)
+		}
+		blocks, _ := GenerateChain(overridden, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {})
+		if _, err := blockchain.InsertChain(blocks); err != nil {
+			t.Fatalf("failed to insert block: %v", err)
+		}
+		blockchain.Stop()
+
+		var chainConfig, activation string
+		for _, line := range strings.Split(output.String(), "\n") {
+			fields := strings.Split(line, " ")
+			switch {
+			case strings.HasPrefix(line, "FIRE INIT "):
+				chainConfig = fields[len(fields)-1]
+			case strings.HasPrefix(line, "FIRE FORK_ACTIVATION "):
+				activation = fields[2]
+			}
+		}
+
+		if !strings.Contains(chainConfig, `"berlinBlock":1`) {
+			t.Fatalf("stored %t: got INIT chain config %q, expected the overridden Berlin block", stored, chainConfig)
+		}
+		if activation != "berlin" {
+			t.Fatalf("stored %t: got fork activation %q, expected Berlin at block 1", stored, activation)
+		}
+	}
+}
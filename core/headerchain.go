@@ -98,7 +98,7 @@ func NewHeaderChain(chainDb ethdb.Database, config *params.ChainConfig, engine c
 		engine:        engine,
 	}
 
-	hc.genesisHeader = hc.GetHeaderByNumber(0)
+	hc.genesisHeader = hc.GetHeaderByNumber(rawdb.ReadGenesisNumber(chainDb))
 	if hc.genesisHeader == nil {
 		return nil, ErrNoGenesis
 	}
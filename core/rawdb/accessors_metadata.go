@@ -53,6 +53,61 @@ func WriteDatabaseVersion(db ethdb.KeyValueWriter, version uint64) {
 	}
 }
 
+// ReadGenesisNumber retrieves the number of the genesis block, 0 unless the chain was
+// started from a regenesis at a later height.
+func ReadGenesisNumber(db ethdb.KeyValueReader) uint64 {
+	var number uint64
+
+	enc, _ := db.Get(genesisNumberKey)
+	if len(enc) == 0 {
+		return 0
+	}
+	if err := rlp.DecodeBytes(enc, &number); err != nil {
+		return 0
+	}
+
+	return number
+}
+
+// WriteGenesisNumber stores the number of the genesis block.
+func WriteGenesisNumber(db ethdb.KeyValueWriter, number uint64) {
+	enc, err := rlp.EncodeToBytes(number)
+	if err != nil {
+		log.Crit("Failed to encode genesis number", "err", err)
+	}
+	if err = db.Put(genesisNumberKey, enc); err != nil {
+		log.Crit("Failed to store the genesis number", "err", err)
+	}
+}
+
+// ReadFirehoseLastEmitted retrieves the number of the last block emitted by Firehose in
+// finalized only mode, nil if none was.
+func ReadFirehoseLastEmitted(db ethdb.KeyValueReader) *uint64 {
+	var number uint64
+
+	enc, _ := db.Get(firehoseLastEmittedKey)
+	if len(enc) == 0 {
+		return nil
+	}
+	if err := rlp.DecodeBytes(enc, &number); err != nil {
+		return nil
+	}
+
+	return &number
+}
+
+// WriteFirehoseLastEmitted stores the number of the last block emitted by Firehose in
+// finalized only mode.
+func WriteFirehoseLastEmitted(db ethdb.KeyValueWriter, number uint64) {
+	enc, err := rlp.EncodeToBytes(number)
+	if err != nil {
+		log.Crit("Failed to encode Firehose last emitted block", "err", err)
+	}
+	if err = db.Put(firehoseLastEmittedKey, enc); err != nil {
+		log.Crit("Failed to store the Firehose last emitted block", "err", err)
+	}
+}
+
 // ReadChainConfig retrieves the consensus settings based on the given genesis hash.
 func ReadChainConfig(db ethdb.KeyValueReader, hash common.Hash) *params.ChainConfig {
 	data, _ := db.Get(configKey(hash))
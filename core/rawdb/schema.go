@@ -69,6 +69,14 @@ var (
 	// badBlockKey tracks the list of bad blocks seen by local
 	badBlockKey = []byte("InvalidBlock")
 
+	// genesisNumberKey tracks the number of the genesis block, absent when it's 0, chains
+	// restarted from a regenesis have their genesis at a later height.
+	genesisNumberKey = []byte("GenesisNumber")
+
+	// firehoseLastEmittedKey tracks the last block emitted by Firehose in finalized only mode,
+	// the emission resumes after it.
+	firehoseLastEmittedKey = []byte("FirehoseLastEmitted")
+
 	// uncleanShutdownKey tracks the list of local crashes
 	uncleanShutdownKey = []byte("unclean-shutdown") // config prefix for the db
 
@@ -542,9 +542,9 @@ func (s *stateObject) setCode(codeHash common.Hash, code []byte) {
 	s.dirtyCode = true
 }
 
-func (s *stateObject) SetNonce(nonce uint64, firehoseContext *firehose.Context) {
+func (s *stateObject) SetNonce(nonce uint64, firehoseContext *firehose.Context, reason firehose.NonceChangeReason) {
 	if firehoseContext.Enabled() {
-		firehoseContext.RecordNonceChange(s.address, s.data.Nonce, nonce)
+		firehoseContext.RecordNonceChange(s.address, s.data.Nonce, nonce, reason)
 	}
 
 	s.db.journal.append(nonceChange{
@@ -93,7 +93,7 @@ func TestDump(t *testing.T) {
 func TestNull(t *testing.T) {
 	s := newStateTest()
 	address := common.HexToAddress("0x823140710bf13990e4500136726d8b55")
-	s.state.CreateAccount(address, firehose.NoOpContext)
+	s.state.CreateAccount(address, firehose.NoOpContext, firehose.IgnoredAccountCreationReason)
 	//value := common.FromHex("0x823140710bf13990e4500136726d8b55")
 	var value common.Hash
 
@@ -164,7 +164,7 @@ func TestSnapshot2(t *testing.T) {
 	// db, trie are already non-empty values
 	so0 := state.getStateObject(stateobjaddr0)
 	so0.SetBalance(big.NewInt(42), firehose.NoOpContext, "test")
-	so0.SetNonce(43, firehose.NoOpContext)
+	so0.SetNonce(43, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 	so0.SetCode(crypto.Keccak256Hash([]byte{'c', 'a', 'f', 'e'}), []byte{'c', 'a', 'f', 'e'}, firehose.NoOpContext)
 	so0.suicided = false
 	so0.deleted = false
@@ -176,7 +176,7 @@ func TestSnapshot2(t *testing.T) {
 	// and one with deleted == true
 	so1 := state.getStateObject(stateobjaddr1)
 	so1.SetBalance(big.NewInt(52), firehose.NoOpContext, "test")
-	so1.SetNonce(53, firehose.NoOpContext)
+	so1.SetNonce(53, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 	so1.SetCode(crypto.Keccak256Hash([]byte{'c', 'a', 'f', 'e', '2'}), []byte{'c', 'a', 'f', 'e', '2'}, firehose.NoOpContext)
 	so1.suicided = true
 	so1.deleted = true
@@ -18,6 +18,7 @@
 package state
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
@@ -192,7 +193,7 @@ func (s *StateDB) AddLog(log *types.Log, firehoseContext *firehose.Context) {
 	log.Index = s.logSize
 
 	if firehoseContext.Enabled() {
-		firehoseContext.RecordLog(log)
+		firehoseContext.RecordLog(log, uint(len(s.logs[s.thash])))
 	}
 
 	s.logs[s.thash] = append(s.logs[s.thash], log)
@@ -391,7 +392,12 @@ func (s *StateDB) HasSuicided(addr common.Address) bool {
 
 // AddBalance adds amount to the account associated with addr.
 func (s *StateDB) AddBalance(addr common.Address, amount *big.Int, isPrecompiledAddr bool, firehoseContext *firehose.Context, reason firehose.BalanceChangeReason) {
-	stateObject := s.GetOrNewStateObject(addr, isPrecompiledAddr, firehoseContext)
+	creationReason := firehose.AccountCreationReason("transfer")
+	if isPrecompiledAddr {
+		creationReason = firehose.AccountCreationReason("precompile")
+	}
+
+	stateObject := s.getOrNewStateObject(addr, firehoseContext, creationReason)
 	if stateObject != nil {
 		stateObject.AddBalance(amount, firehoseContext, reason)
 	}
@@ -412,10 +418,10 @@ func (s *StateDB) SetBalance(addr common.Address, amount *big.Int, firehoseConte
 	}
 }
 
-func (s *StateDB) SetNonce(addr common.Address, nonce uint64, firehoseContext *firehose.Context) {
+func (s *StateDB) SetNonce(addr common.Address, nonce uint64, firehoseContext *firehose.Context, reason firehose.NonceChangeReason) {
 	stateObject := s.GetOrNewStateObject(addr, false, firehoseContext)
 	if stateObject != nil {
-		stateObject.SetNonce(nonce, firehoseContext)
+		stateObject.SetNonce(nonce, firehoseContext, reason)
 	}
 }
 
@@ -589,16 +595,25 @@ func (s *StateDB) setStateObject(object *stateObject) {
 
 // GetOrNewStateObject retrieves a state object or create a new state object if nil.
 func (s *StateDB) GetOrNewStateObject(addr common.Address, isPrecompiledAddr bool, firehoseContext *firehose.Context) *stateObject {
+	creationReason := firehose.AccountCreationReason("state_change")
+	if isPrecompiledAddr {
+		creationReason = firehose.AccountCreationReason("precompile")
+	}
+
+	return s.getOrNewStateObject(addr, firehoseContext, creationReason)
+}
+
+func (s *StateDB) getOrNewStateObject(addr common.Address, firehoseContext *firehose.Context, creationReason firehose.AccountCreationReason) *stateObject {
 	stateObject := s.getStateObject(addr)
 	if stateObject == nil {
-		stateObject, _ = s.createObject(addr, isPrecompiledAddr, firehoseContext)
+		stateObject, _ = s.createObject(addr, firehoseContext, creationReason)
 	}
 	return stateObject
 }
 
 // createObject creates a new state object. If there is an existing account with
 // the given address, it is overwritten and returned as the second return value.
-func (s *StateDB) createObject(addr common.Address, isPrecompiledAddr bool, firehoseContext *firehose.Context) (newobj, prev *stateObject) {
+func (s *StateDB) createObject(addr common.Address, firehoseContext *firehose.Context, creationReason firehose.AccountCreationReason) (newobj, prev *stateObject) {
 	prev = s.getDeletedStateObject(addr) // Note, prev might have been deleted, we need that!
 
 	var prevdestruct bool
@@ -616,8 +631,8 @@ func (s *StateDB) createObject(addr common.Address, isPrecompiledAddr bool, fire
 		s.journal.append(resetObjectChange{prev: prev, prevdestruct: prevdestruct})
 	}
 
-	if firehoseContext.Enabled() && !isPrecompiledAddr {
-		firehoseContext.RecordNewAccount(addr)
+	if firehoseContext.Enabled() {
+		firehoseContext.RecordNewAccount(addr, creationReason)
 	}
 
 	s.setStateObject(newobj)
@@ -637,8 +652,8 @@ func (s *StateDB) createObject(addr common.Address, isPrecompiledAddr bool, fire
 //   2. tx_create(sha(account ++ nonce)) (note that this gets the address of 1)
 //
 // Carrying over the balance ensures that Ether doesn't disappear.
-func (s *StateDB) CreateAccount(addr common.Address, firehoseContext *firehose.Context) {
-	newObj, prev := s.createObject(addr, false, firehoseContext)
+func (s *StateDB) CreateAccount(addr common.Address, firehoseContext *firehose.Context, creationReason firehose.AccountCreationReason) {
+	newObj, prev := s.createObject(addr, firehoseContext, creationReason)
 	if prev != nil {
 		newObj.setBalance(prev.data.Balance)
 	}
@@ -802,6 +817,28 @@ func (s *StateDB) GetRefund() uint64 {
 	return s.refund
 }
 
+// RecordEmptyAccountDeletions records the deletion of the empty accounts touched since the
+// state was last finalised, which finalising with deleteEmptyObjects deletes under EIP-158. It
+// must be called right before, accounts are recorded in address order. Like Finalise, it
+// includes the RIPEMD precompile when its touch was reverted, see `journal.dirty`.
+func (s *StateDB) RecordEmptyAccountDeletions(firehoseContext *firehose.Context) {
+	if !firehoseContext.Enabled() {
+		return
+	}
+
+	var deleted []common.Address
+	for addr := range s.journal.dirties {
+		if obj, exist := s.stateObjects[addr]; exist && !obj.deleted && !obj.suicided && obj.empty() {
+			deleted = append(deleted, addr)
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool { return bytes.Compare(deleted[i][:], deleted[j][:]) < 0 })
+
+	for _, addr := range deleted {
+		firehoseContext.RecordAccountDeletion(addr, firehose.AccountDeletionReason("empty_account_cleanup"))
+	}
+}
+
 // Finalise finalises the state by removing the s destructed objects and clears
 // the journal as well as the refunds. Finalise, however, will not push any updates
 // into the tries just yet. Only IntermediateRoot or Commit will do that.
@@ -46,7 +46,7 @@ func TestUpdateLeaks(t *testing.T) {
 	for i := byte(0); i < 255; i++ {
 		addr := common.BytesToAddress([]byte{i})
 		state.AddBalance(addr, big.NewInt(int64(11*i)), false, firehose.NoOpContext, "test")
-		state.SetNonce(addr, uint64(42*i), firehose.NoOpContext)
+		state.SetNonce(addr, uint64(42*i), firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 		if i%2 == 0 {
 			state.SetState(addr, common.BytesToHash([]byte{i, i, i}), common.BytesToHash([]byte{i, i, i, i}), firehose.NoOpContext)
 		}
@@ -79,7 +79,7 @@ func TestIntermediateLeaks(t *testing.T) {
 
 	modify := func(state *StateDB, addr common.Address, i, tweak byte) {
 		state.SetBalance(addr, big.NewInt(int64(11*i)+int64(tweak)), firehose.NoOpContext, "test")
-		state.SetNonce(addr, uint64(42*i+tweak), firehose.NoOpContext)
+		state.SetNonce(addr, uint64(42*i+tweak), firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 		if i%2 == 0 {
 			state.SetState(addr, common.Hash{i, i, i, 0}, common.Hash{}, firehose.NoOpContext)
 			state.SetState(addr, common.Hash{i, i, i, tweak}, common.Hash{i, i, i, i, tweak}, firehose.NoOpContext)
@@ -267,7 +267,7 @@ func newTestAction(addr common.Address, r *rand.Rand) testAction {
 		{
 			name: "SetNonce",
 			fn: func(a testAction, s *StateDB) {
-				s.SetNonce(addr, uint64(a.args[0]), firehose.NoOpContext)
+				s.SetNonce(addr, uint64(a.args[0]), firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 			},
 			args: make([]int64, 1),
 		},
@@ -294,7 +294,7 @@ func newTestAction(addr common.Address, r *rand.Rand) testAction {
 		{
 			name: "CreateAccount",
 			fn: func(a testAction, s *StateDB) {
-				s.CreateAccount(addr, firehose.NoOpContext)
+				s.CreateAccount(addr, firehose.NoOpContext, firehose.IgnoredAccountCreationReason)
 			},
 		},
 		{
@@ -489,6 +489,50 @@ func TestTouchDelete(t *testing.T) {
 	}
 }
 
+// TestRecordEmptyAccountDeletions tests that the empty accounts deleted by EIP-158 are the
+// ones recorded, including the RIPEMD precompile whose reverted touch still deletes it, the
+// consensus exception of mainnet block 2675119.
+func TestRecordEmptyAccountDeletions(t *testing.T) {
+	var (
+		empty    = common.HexToAddress("0xe1")
+		reverted = common.HexToAddress("0xe2")
+		funded   = common.HexToAddress("0xf1")
+		suicided = common.HexToAddress("0xf2")
+	)
+	s := newStateTest()
+	for _, addr := range []common.Address{empty, reverted, ripemd, suicided} {
+		s.state.CreateAccount(addr, firehose.NoOpContext, firehose.IgnoredAccountCreationReason)
+	}
+	root, _ := s.state.Commit(false)
+	s.state, _ = New(root, s.state.db, s.state.snaps)
+
+	// Touching empty accounts with zero value transfers, in a reverted call for some
+	snapshot := s.state.Snapshot()
+	s.state.AddBalance(reverted, new(big.Int), false, firehose.NoOpContext, "test")
+	s.state.AddBalance(ripemd, new(big.Int), false, firehose.NoOpContext, "test")
+	s.state.RevertToSnapshot(snapshot)
+	s.state.AddBalance(empty, new(big.Int), false, firehose.NoOpContext, "test")
+	s.state.AddBalance(funded, big.NewInt(1), false, firehose.NoOpContext, "test")
+	s.state.Suicide(suicided, firehose.NoOpContext)
+
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	printer := firehose.NewToBufferPrinter(1024)
+	s.state.RecordEmptyAccountDeletions(firehose.NewContext(printer, true))
+	s.state.Finalise(true)
+
+	expected := fmt.Sprintf("FIRE DELETED_ACCOUNT 0 %s 1 empty_account_cleanup\nFIRE DELETED_ACCOUNT 0 %s 2 empty_account_cleanup\n", firehose.Addr(ripemd), firehose.Addr(empty))
+	if got := printer.Buffer().String(); got != expected {
+		t.Fatalf("got deletions %q, expected %q", got, expected)
+	}
+	for addr, exist := range map[common.Address]bool{empty: false, ripemd: false, reverted: true, funded: true} {
+		if s.state.Exist(addr) != exist {
+			t.Fatalf("account %x existence is %t, expected %t", addr, !exist, exist)
+		}
+	}
+}
+
 // TestCopyOfCopy tests that modified objects are carried over to the copy, and the copy of the copy.
 // See https://github.com/ethereum/go-ethereum/pull/15225#issuecomment-380191512
 func TestCopyOfCopy(t *testing.T) {
@@ -696,6 +740,51 @@ func TestDeleteCreateRevert(t *testing.T) {
 	}
 }
 
+func TestCreatedAccountRecords(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	state, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	printer := firehose.NewToBufferPrinter(1024)
+	ctx := firehose.NewContext(printer, true)
+
+	contract := toAddr([]byte("contract"))
+	precompile := toAddr([]byte{0x01})
+
+	state.CreateAccount(contract, ctx, firehose.AccountCreationReason("create"))
+	state.AddBalance(contract, big.NewInt(1), false, ctx, "test")
+	state.AddBalance(precompile, new(big.Int), true, ctx, "test")
+	state.Finalise(true)
+
+	// The contract self-destructs and the empty precompile is deleted (EIP-158), both come into
+	// existence again when touched afterwards in the same block
+	state.Suicide(contract, ctx)
+	state.Finalise(true)
+
+	state.AddBalance(contract, big.NewInt(2), false, ctx, "test")
+	state.AddBalance(precompile, new(big.Int), true, ctx, "test")
+	state.SetNonce(toAddr([]byte("other")), 1, ctx, firehose.IgnoredNonceChangeReason)
+
+	var created []string
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		if strings.HasPrefix(line, "FIRE CREATED_ACCOUNT ") {
+			fields := strings.Fields(line)
+			created = append(created, fields[3]+" "+fields[5])
+		}
+	}
+
+	expected := []string{
+		fmt.Sprintf("%x create", contract),
+		fmt.Sprintf("%x precompile", precompile),
+		fmt.Sprintf("%x transfer", contract),
+		fmt.Sprintf("%x precompile", precompile),
+		fmt.Sprintf("%x state_change", toAddr([]byte("other"))),
+	}
+	if !reflect.DeepEqual(created, expected) {
+		t.Fatalf("created accounts mismatch: have %q, want %q", created, expected)
+	}
+}
+
 // TestMissingTrieNodes tests that if the StateDB fails to load parts of the trie,
 // the Commit operation fails with an error
 // If we are missing trie nodes, we should not continue writing to the trie
@@ -54,7 +54,7 @@ func makeTestState() (Database, common.Hash, []*testAccount) {
 		obj.AddBalance(big.NewInt(int64(11*i)), firehose.NoOpContext, "test")
 		acc.balance = big.NewInt(int64(11 * i))
 
-		obj.SetNonce(uint64(42*i), firehose.NoOpContext)
+		obj.SetNonce(uint64(42*i), firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 		acc.nonce = uint64(42 * i)
 
 		if i%3 == 0 {
@@ -21,7 +21,6 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
-	"github.com/ethereum/go-ethereum/consensus/misc"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -66,17 +65,16 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	)
 
 	if firehoseContext.Enabled() {
-		firehoseContext.StartBlock(block)
+		firehoseContext.StartBlock(block, firehoseBlockProducer(p.engine, header))
+		firehoseContext.RecordForkActivation(p.config, p.bc.genesisBlock.Hash(), block.NumberU64())
 	}
 
-	// Mutate the block and state according to any hard-fork specs
-	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
-		misc.ApplyDAOHardFork(statedb, firehoseContext)
-	}
+	// Mutate the block and state according to any hard-fork specs and system operations
+	applySystemOperations(preTransactionsOperations, p.config, header, statedb, firehoseContext)
 
 	txFirehoseContext := firehoseContext
 	if txFirehoseContext.Enabled() {
-		txFirehoseContext = firehose.NewSpeculativeExecutionContextWithBuffer(firehose.TxSyncBuffer)
+		txFirehoseContext = firehoseContext.NewTransactionContext(firehose.TxSyncBuffer)
 	}
 
 	blockContext := NewEVMBlockContext(header, p.bc, nil)
@@ -124,9 +122,21 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		firehose.SyncContext().FinalizeBlock(block)
 	}
 
-	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
+	applySystemOperations(postTransactionsOperations, p.config, header, statedb, firehoseContext)
+
+	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards),
+	// in a system call unless the engine opens its own
+	finalizeSystemCall := firehoseContext.Enabled() && !opensFirehoseSystemCalls(p.engine)
+	if finalizeSystemCall {
+		firehoseContext.StartSystemCall(firehose.SystemCallSource("block_finalize"))
+	}
+
 	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), firehoseContext)
 
+	if finalizeSystemCall {
+		firehoseContext.EndSystemCall()
+	}
+
 	return receipts, allLogs, *usedGas, nil
 }
 
@@ -141,7 +151,11 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainCon
 		return nil, err
 	}
 
-	// Update the state with pending changes.
+	// Update the state with pending changes, the empty accounts deleted are recorded in the transaction
+	if txFirehoseContext.Enabled() && (config.IsByzantium(header.Number) || config.IsEIP158(header.Number)) {
+		statedb.RecordEmptyAccountDeletions(txFirehoseContext)
+	}
+
 	var root []byte
 	if config.IsByzantium(header.Number) {
 		statedb.Finalise(true)
@@ -189,3 +203,21 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, config, cfg, txFirehoseContext)
 	return applyTransaction(msg, config, bc, author, gp, statedb, header, tx, usedGas, vmenv, txFirehoseContext)
 }
+
+// firehoseBlockProducer returns the producer of the block recorded by Firehose, its author
+// according to the consensus engine, which is the signer on clique chains. It falls back to
+// the coinbase when the author can't be recovered, the header being validated already.
+func firehoseBlockProducer(engine consensus.Engine, header *types.Header) common.Address {
+	producer, err := engine.Author(header)
+	if err != nil {
+		return header.Coinbase
+	}
+	return producer
+}
+
+// opensFirehoseSystemCalls tells if the engine opens its own Firehose system calls when
+// finalizing a block, see `consensus.FirehoseSystemCaller`.
+func opensFirehoseSystemCalls(engine consensus.Engine) bool {
+	caller, ok := engine.(consensus.FirehoseSystemCaller)
+	return ok && caller.OpensFirehoseSystemCalls()
+}
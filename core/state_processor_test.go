@@ -17,16 +17,26 @@
 package core
 
 import (
+	"encoding/json"
+	"fmt"
 	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie"
 	"golang.org/x/crypto/sha3"
@@ -150,3 +160,724 @@ func GenerateBadBlock(parent *types.Block, engine consensus.Engine, txs types.Tr
 	// Assemble and return the final block for sealing
 	return types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil))
 }
+
+// TestStateProcessorRecordsPostStateRoot tests that the intermediate state root of the
+// transactions, carried by the receipts of pre-Byzantium blocks, is recorded when the
+// transaction ends and that none is recorded once Byzantium is active.
+func TestStateProcessorRecordsPostStateRoot(t *testing.T) {
+	var (
+		signer     = types.HomesteadSigner{}
+		testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		testAddr   = crypto.PubkeyToAddress(testKey.PublicKey)
+		db         = rawdb.NewMemoryDatabase()
+		config     = &params.ChainConfig{
+			ChainID:             big.NewInt(1),
+			HomesteadBlock:      big.NewInt(0),
+			EIP150Block:         big.NewInt(0),
+			EIP155Block:         big.NewInt(0),
+			EIP158Block:         big.NewInt(0),
+			ByzantiumBlock:      big.NewInt(2),
+			ConstantinopleBlock: big.NewInt(2),
+			PetersburgBlock:     big.NewInt(2),
+			IstanbulBlock:       big.NewInt(2),
+			Ethash:              new(params.EthashConfig),
+		}
+		gspec = &Genesis{
+			Config: config,
+			Alloc:  GenesisAlloc{testAddr: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		genesis       = gspec.MustCommit(db)
+		blockchain, _ = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	)
+	defer blockchain.Stop()
+
+	// Enabled once the chain is created, otherwise it would record the genesis block
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	blocks, _ := GenerateChain(config, genesis, ethash.NewFaker(), db, 2, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddr), common.Address{0xaa}, big.NewInt(1), params.TxGas, nil, nil), signer, testKey)
+		b.AddTx(tx)
+	})
+
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	processor := NewStateProcessor(config, blockchain, ethash.NewFaker())
+	for _, block := range blocks {
+		printer := firehose.NewToBufferPrinter(1024)
+		receipts, _, _, err := processor.Process(block, statedb, vm.Config{}, firehose.NewContext(printer, true))
+		if err != nil {
+			t.Fatalf("block %d: processing failed: %v", block.NumberU64(), err)
+		}
+
+		postState := receipts[0].PostState
+		if config.IsByzantium(block.Number()) != (len(postState) == 0) {
+			t.Fatalf("block %d: unexpected post state root %x", block.NumberU64(), postState)
+		}
+
+		var ended []string
+		for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+			if strings.HasPrefix(line, "FIRE END_APPLY_TRX ") {
+				ended = strings.Split(line, " ")
+			}
+		}
+		if len(ended) < 4 || ended[3] != firehose.Hex(postState) {
+			t.Fatalf("block %d: expected post state root %x to be recorded, got %q", block.NumberU64(), postState, ended)
+		}
+	}
+}
+
+// TestStateProcessorRecordsForkActivation tests that the forks activated by a block are
+// recorded with the fork ID they start, and only by that block.
+func TestStateProcessorRecordsForkActivation(t *testing.T) {
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		config = &params.ChainConfig{
+			ChainID:             big.NewInt(1),
+			HomesteadBlock:      big.NewInt(0),
+			EIP150Block:         big.NewInt(0),
+			EIP155Block:         big.NewInt(0),
+			EIP158Block:         big.NewInt(0),
+			ByzantiumBlock:      big.NewInt(2),
+			ConstantinopleBlock: big.NewInt(2),
+			PetersburgBlock:     big.NewInt(2),
+			IstanbulBlock:       big.NewInt(3),
+			Ethash:              new(params.EthashConfig),
+		}
+		gspec         = &Genesis{Config: config}
+		genesis       = gspec.MustCommit(db)
+		blockchain, _ = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	)
+	defer blockchain.Stop()
+
+	// Enabled once the chain is created, otherwise it would record the genesis block
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	blocks, _ := GenerateChain(config, genesis, ethash.NewFaker(), db, 3, func(i int, b *BlockGen) {})
+
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	processor := NewStateProcessor(config, blockchain, ethash.NewFaker())
+	var activations []string
+	for _, block := range blocks {
+		printer := firehose.NewToBufferPrinter(1024)
+		if _, _, _, err := processor.Process(block, statedb, vm.Config{}, firehose.NewContext(printer, true)); err != nil {
+			t.Fatalf("block %d: processing failed: %v", block.NumberU64(), err)
+		}
+
+		for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+			if strings.HasPrefix(line, "FIRE FORK_ACTIVATION ") {
+				activations = append(activations, fmt.Sprintf("%d %s", block.NumberU64(), strings.TrimPrefix(line, "FIRE FORK_ACTIVATION ")))
+			}
+		}
+	}
+
+	byzantium, istanbul := forkid.NewID(config, genesis.Hash(), 2), forkid.NewID(config, genesis.Hash(), 3)
+	expected := []string{
+		fmt.Sprintf("2 byzantium,constantinople,petersburg %x 3", byzantium.Hash),
+		fmt.Sprintf("3 istanbul %x 0", istanbul.Hash),
+	}
+	if !reflect.DeepEqual(activations, expected) {
+		t.Fatalf("got fork activations %q, expected %q", activations, expected)
+	}
+}
+
+// TestStateProcessorRecordsSystemCalls tests that the state changes made by the consensus
+// engine when finalizing the block are recorded in a system call, outside of transactions.
+func TestStateProcessorRecordsSystemCalls(t *testing.T) {
+	var (
+		signer     = types.HomesteadSigner{}
+		testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		testAddr   = crypto.PubkeyToAddress(testKey.PublicKey)
+		db         = rawdb.NewMemoryDatabase()
+		gspec      = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{testAddr: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		genesis       = gspec.MustCommit(db)
+		blockchain, _ = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	)
+	defer blockchain.Stop()
+
+	// Enabled once the chain is created, otherwise it would record the genesis block
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0xbb})
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddr), common.Address{0xaa}, big.NewInt(1), params.TxGas, nil, nil), signer, testKey)
+		b.AddTx(tx)
+	})
+
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	printer := firehose.NewToBufferPrinter(1024)
+	if _, _, _, err := NewStateProcessor(gspec.Config, blockchain, ethash.NewFaker()).Process(blocks[0], statedb, vm.Config{}, firehose.NewContext(printer, true)); err != nil {
+		t.Fatalf("processing failed: %v", err)
+	}
+
+	var section string
+	rewarded := false
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		fields := strings.Split(line, " ")
+		switch {
+		case strings.HasPrefix(line, "FIRE BEGIN_APPLY_TRX "):
+			section = "transaction"
+		case strings.HasPrefix(line, "FIRE BEGIN_SYSTEM_CALL "):
+			section = fields[2]
+		case strings.HasPrefix(line, "FIRE END_APPLY_TRX "), strings.HasPrefix(line, "FIRE END_SYSTEM_CALL "):
+			section = ""
+		case strings.HasPrefix(line, "FIRE BALANCE_CHANGE ") && fields[6] == "reward_mine_block":
+			if section != "block_finalize" {
+				t.Fatalf("expected block reward to be recorded in the block finalize system call, got %q section", section)
+			}
+			rewarded = true
+		}
+	}
+	if !rewarded {
+		t.Fatalf("block reward not recorded in %q", printer.Buffer().String())
+	}
+}
+
+// TestStateProcessorRecordsSystemOperations tests that the state changes of the registered
+// system operations, like a system contract storing historical block hashes, are recorded in
+// system calls named after them before and after the transactions.
+func TestStateProcessorRecordsSystemOperations(t *testing.T) {
+	defer func(pre, post []SystemOperation) {
+		preTransactionsOperations, postTransactionsOperations = pre, post
+	}(preTransactionsOperations, postTransactionsOperations)
+
+	var (
+		signer         = types.HomesteadSigner{}
+		testKey, _     = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		testAddr       = crypto.PubkeyToAddress(testKey.PublicKey)
+		systemContract = common.HexToAddress("0xfffffffffffffffffffffffffffffffffffffffe")
+		db             = rawdb.NewMemoryDatabase()
+		gspec          = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{testAddr: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		genesis       = gspec.MustCommit(db)
+		blockchain, _ = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	)
+	defer blockchain.Stop()
+
+	storeBlockHash := func(config *params.ChainConfig, header *types.Header, statedb *state.StateDB, firehoseContext *firehose.Context) {
+		statedb.SetState(systemContract, common.BigToHash(header.Number), header.ParentHash, firehoseContext)
+	}
+	always := func(config *params.ChainConfig, header *types.Header) bool { return true }
+	RegisterSystemOperation(SystemOperation{Name: "historical_block_hashes", Applies: always, Apply: storeBlockHash}, true)
+	RegisterSystemOperation(SystemOperation{Name: "post_block_hashes", Applies: always, Apply: storeBlockHash}, false)
+	RegisterSystemOperation(SystemOperation{Name: "never", Applies: func(*params.ChainConfig, *types.Header) bool { return false }, Apply: storeBlockHash}, false)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected registering a system operation twice to panic")
+			}
+		}()
+		RegisterSystemOperation(SystemOperation{Name: "historical_block_hashes"}, false)
+	}()
+
+	// Enabled once the chain is created, otherwise it would record the genesis block
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddr), common.Address{0xaa}, big.NewInt(1), params.TxGas, nil, nil), signer, testKey)
+		b.AddTx(tx)
+	})
+
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	printer := firehose.NewToBufferPrinter(1024)
+	if _, _, _, err := NewStateProcessor(gspec.Config, blockchain, ethash.NewFaker()).Process(blocks[0], statedb, vm.Config{}, firehose.NewContext(printer, true)); err != nil {
+		t.Fatalf("processing failed: %v", err)
+	}
+
+	var sections []string
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		fields := strings.Split(line, " ")
+		switch {
+		case strings.HasPrefix(line, "FIRE BEGIN_APPLY_TRX "):
+			sections = append(sections, "transaction")
+		case strings.HasPrefix(line, "FIRE BEGIN_SYSTEM_CALL "):
+			sections = append(sections, fields[2])
+		case strings.HasPrefix(line, "FIRE STORAGE_CHANGE ") && fields[3] == fmt.Sprintf("%x", systemContract):
+			sections = append(sections, sections[len(sections)-1]+" storage")
+		}
+	}
+
+	expected := []string{
+		"historical_block_hashes", "historical_block_hashes storage",
+		"transaction",
+		"post_block_hashes", "post_block_hashes storage",
+		"block_finalize",
+	}
+	if !reflect.DeepEqual(sections, expected) {
+		t.Fatalf("got sections %q, expected %q", sections, expected)
+	}
+}
+
+// customRewardEngine is an ethash engine patched with its own emission schedule.
+type customRewardEngine struct {
+	consensus.Engine
+	reward *big.Int
+}
+
+func (e *customRewardEngine) Finalize(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, firehoseContext *firehose.Context) {
+	state.AddBalance(header.Coinbase, e.reward, false, firehoseContext, firehose.BalanceChangeReason("reward_mine_block"))
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+}
+
+// TestStateProcessorRecordsEngineReward tests that the recorded block reward is the one the
+// consensus engine credits and not a mainnet constant.
+func TestStateProcessorRecordsEngineReward(t *testing.T) {
+	var (
+		db            = rawdb.NewMemoryDatabase()
+		gspec         = &Genesis{Config: params.TestChainConfig}
+		genesis       = gspec.MustCommit(db)
+		engine        = &customRewardEngine{Engine: ethash.NewFaker(), reward: big.NewInt(1234)}
+		blockchain, _ = NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	)
+	defer blockchain.Stop()
+
+	// Enabled once the chain is created, otherwise it would record the genesis block
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0xbb})
+	})
+
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	printer := firehose.NewToBufferPrinter(1024)
+	if _, _, _, err := NewStateProcessor(gspec.Config, blockchain, engine).Process(blocks[0], statedb, vm.Config{}, firehose.NewContext(printer, true)); err != nil {
+		t.Fatalf("processing failed: %v", err)
+	}
+
+	var rewards []string
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		if fields := strings.Split(line, " "); strings.HasPrefix(line, "FIRE BALANCE_CHANGE ") && strings.HasPrefix(fields[6], "reward_") {
+			rewards = append(rewards, fmt.Sprintf("%s %s %s", fields[3], fields[5], fields[6]))
+		}
+	}
+	want := []string{fmt.Sprintf("%s %s reward_mine_block", firehose.Addr(common.Address{0xbb}), firehose.BigInt(engine.reward))}
+	if !reflect.DeepEqual(rewards, want) {
+		t.Fatalf("got rewards %q, want %q", rewards, want)
+	}
+}
+
+// stateSyncEngine is an ethash engine executing state sync events when finalizing blocks, in
+// system calls of its own like Bor does.
+type stateSyncEngine struct {
+	consensus.Engine
+	receiver common.Address
+}
+
+func (e *stateSyncEngine) OpensFirehoseSystemCalls() bool { return true }
+
+func (e *stateSyncEngine) Finalize(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, firehoseContext *firehose.Context) {
+	firehoseContext.StartSystemCall(firehose.SystemCallSource("state_sync"))
+	state.SetState(e.receiver, common.Hash{0x01}, common.Hash{0x02}, firehoseContext)
+	firehoseContext.EndSystemCall()
+
+	firehoseContext.StartSystemCall(firehose.SystemCallSource("block_reward"))
+	state.AddBalance(header.Coinbase, big.NewInt(1), false, firehoseContext, firehose.BalanceChangeReason("reward_mine_block"))
+	firehoseContext.EndSystemCall()
+
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+}
+
+// TestStateProcessorRecordsEngineSystemCalls tests that the state changes of an engine opening
+// its own system calls are recorded in them, without the block_finalize one around.
+func TestStateProcessorRecordsEngineSystemCalls(t *testing.T) {
+	var (
+		db            = rawdb.NewMemoryDatabase()
+		gspec         = &Genesis{Config: params.TestChainConfig}
+		genesis       = gspec.MustCommit(db)
+		engine        = &stateSyncEngine{Engine: ethash.NewFaker(), receiver: common.Address{0xcc}}
+		blockchain, _ = NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	)
+	defer blockchain.Stop()
+
+	// Enabled once the chain is created, otherwise it would record the genesis block
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 1, func(i int, b *BlockGen) {})
+
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	printer := firehose.NewToBufferPrinter(1024)
+	if _, _, _, err := NewStateProcessor(gspec.Config, blockchain, engine).Process(blocks[0], statedb, vm.Config{}, firehose.NewContext(printer, true)); err != nil {
+		t.Fatalf("processing failed: %v", err)
+	}
+
+	var section string
+	var changes []string
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		fields := strings.Split(line, " ")
+		switch {
+		case strings.HasPrefix(line, "FIRE BEGIN_SYSTEM_CALL "):
+			section = fields[2]
+		case strings.HasPrefix(line, "FIRE END_SYSTEM_CALL "):
+			section = ""
+		case strings.HasPrefix(line, "FIRE STORAGE_CHANGE "), strings.HasPrefix(line, "FIRE BALANCE_CHANGE "):
+			changes = append(changes, section+" "+fields[1])
+		}
+	}
+	if expected := []string{"state_sync STORAGE_CHANGE", "block_reward BALANCE_CHANGE"}; !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("got changes %q, expected %q", changes, expected)
+	}
+}
+
+// TestStateProcessorRecordsPreByzantiumStatus tests that the status recorded on Frontier and
+// Homestead blocks, whose receipts have no status, is the execution result of the transaction,
+// including a transaction whose deepest calls run out of gas, as during the 2016 DoS attacks,
+// and a successful transaction consuming all of its gas.
+func TestStateProcessorRecordsPreByzantiumStatus(t *testing.T) {
+	for name, config := range map[string]*params.ChainConfig{
+		"frontier":  {ChainID: big.NewInt(1), Ethash: new(params.EthashConfig)},
+		"homestead": {ChainID: big.NewInt(1), HomesteadBlock: big.NewInt(0), Ethash: new(params.EthashConfig)},
+	} {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			var (
+				signer     = types.HomesteadSigner{}
+				testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+				testAddr   = crypto.PubkeyToAddress(testKey.PublicKey)
+				recursive  = common.Address{0xcc}
+				failing    = common.Address{0xdd}
+				db         = rawdb.NewMemoryDatabase()
+				gspec      = &Genesis{
+					Config: config,
+					Alloc: GenesisAlloc{
+						testAddr: {Balance: big.NewInt(1000000000000000000)},
+						// CALL itself with all the gas but 4096 and STOP whatever the outcome, the
+						// deepest call requests more gas than it has left and runs out of gas
+						recursive: {Balance: common.Big0, Code: []byte{
+							byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+							byte(vm.ADDRESS), byte(vm.PUSH2), 0x10, 0x00, byte(vm.GAS), byte(vm.SUB), byte(vm.CALL), byte(vm.STOP),
+						}},
+						// INVALID opcode
+						failing: {Balance: common.Big0, Code: []byte{0xfe}},
+					},
+				}
+				genesis       = gspec.MustCommit(db)
+				blockchain, _ = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+			)
+			defer blockchain.Stop()
+
+			blocks, _ := GenerateChain(config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+				for _, tx := range []*types.Transaction{
+					types.NewTransaction(b.TxNonce(testAddr), recursive, common.Big0, 1000000, big.NewInt(1), nil),
+					types.NewTransaction(b.TxNonce(testAddr)+1, failing, common.Big0, 100000, big.NewInt(1), nil),
+					types.NewTransaction(b.TxNonce(testAddr)+2, common.Address{0xaa}, big.NewInt(1), params.TxGas, big.NewInt(1), nil),
+				} {
+					signed, _ := types.SignTx(tx, signer, testKey)
+					b.AddTx(signed)
+				}
+			})
+
+			// Enabled once the chain is created, otherwise it would record the genesis block
+			defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+			firehose.Enabled = true
+
+			statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+			printer := firehose.NewToBufferPrinter(1024)
+			receipts, _, _, err := NewStateProcessor(config, blockchain, ethash.NewFaker()).Process(blocks[0], statedb, vm.Config{}, firehose.NewContext(printer, true))
+			if err != nil {
+				t.Fatalf("processing failed: %v", err)
+			}
+			if receipts[2].GasUsed != params.TxGas {
+				t.Fatalf("expected the transfer to consume all of its gas, used %d", receipts[2].GasUsed)
+			}
+
+			var statuses []string
+			deepestFailure := uint64(0)
+			for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+				fields := strings.Split(line, " ")
+				switch {
+				case strings.HasPrefix(line, "FIRE END_APPLY_TRX "):
+					statuses = append(statuses, fields[8])
+				case strings.HasPrefix(line, "FIRE EVM_CALL_FAILED ") && len(statuses) == 0:
+					if index, _ := strconv.ParseUint(fields[2], 10, 64); index > deepestFailure {
+						deepestFailure = index
+					}
+				}
+			}
+			if expected := []string{"1", "0", "1"}; !reflect.DeepEqual(statuses, expected) {
+				t.Fatalf("got statuses %q, expected %q", statuses, expected)
+			}
+			if deepestFailure < 100 {
+				t.Fatalf("expected a call deep in the stack to fail, deepest failed call is #%d", deepestFailure)
+			}
+		})
+	}
+}
+
+// TestStateProcessorRecordsReceipts tests that the receipts re-assembled from the fields
+// recorded when transactions end, including a failed one and one emitting a log, match the
+// receipts root of the block.
+func TestStateProcessorRecordsReceipts(t *testing.T) {
+	var (
+		signer     = types.HomesteadSigner{}
+		testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		testAddr   = crypto.PubkeyToAddress(testKey.PublicKey)
+		logger     = common.Address{0xcc}
+		failing    = common.Address{0xdd}
+		db         = rawdb.NewMemoryDatabase()
+		gspec      = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				testAddr: {Balance: big.NewInt(1000000000000000000)},
+				// LOG1 a 32 bytes word with the 0xaa topic
+				logger: {Balance: common.Big0, Code: []byte{
+					byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0, byte(vm.MSTORE),
+					byte(vm.PUSH1), 0xaa, byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.LOG1),
+				}},
+				// INVALID opcode
+				failing: {Balance: common.Big0, Code: []byte{0xfe}},
+			},
+		}
+		genesis       = gspec.MustCommit(db)
+		blockchain, _ = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	)
+	defer blockchain.Stop()
+
+	// Enabled once the chain is created, otherwise it would record the genesis block
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		for _, to := range []common.Address{{0xaa}, logger, failing} {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddr), to, common.Big0, 100000, nil, nil), signer, testKey)
+			b.AddTx(tx)
+		}
+	})
+
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	printer := firehose.NewToBufferPrinter(1024)
+	if _, _, _, err := NewStateProcessor(gspec.Config, blockchain, ethash.NewFaker()).Process(blocks[0], statedb, vm.Config{}, firehose.NewContext(printer, true)); err != nil {
+		t.Fatalf("processing failed: %v", err)
+	}
+
+	bytesField := func(field string) []byte {
+		if field == "." {
+			return nil
+		}
+		return common.FromHex(field)
+	}
+
+	var receipts types.Receipts
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		if !strings.HasPrefix(line, "FIRE END_APPLY_TRX ") {
+			continue
+		}
+
+		// FIRE END_APPLY_TRX <gas used> <post state> <cumulative gas used> <logs bloom> <ordinal> <logs> <status>
+		fields := strings.Split(line, " ")
+		receipt := &types.Receipt{PostState: bytesField(fields[3]), Bloom: types.BytesToBloom(bytesField(fields[5]))}
+		receipt.CumulativeGasUsed, _ = strconv.ParseUint(fields[4], 10, 64)
+		receipt.Status, _ = strconv.ParseUint(fields[8], 10, 64)
+
+		var logs []struct {
+			Address common.Address `json:"address"`
+			Topics  []common.Hash  `json:"topics"`
+			Data    hexutil.Bytes  `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(fields[7]), &logs); err != nil {
+			t.Fatalf("invalid logs %q: %v", fields[7], err)
+		}
+		for _, log := range logs {
+			receipt.Logs = append(receipt.Logs, &types.Log{Address: log.Address, Topics: log.Topics, Data: log.Data})
+		}
+
+		receipts = append(receipts, receipt)
+	}
+
+	if len(receipts) != 3 || len(receipts[1].Logs) != 1 || receipts[2].Status != types.ReceiptStatusFailed {
+		t.Fatalf("unexpected receipts recorded in %q", printer.Buffer().String())
+	}
+	if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != blocks[0].ReceiptHash() {
+		t.Fatalf("re-assembled receipts root %x, want %x", root, blocks[0].ReceiptHash())
+	}
+	if bloom := types.CreateBloom(receipts); bloom != blocks[0].Bloom() {
+		t.Fatalf("re-assembled logs bloom %x, want %x", bloom, blocks[0].Bloom())
+	}
+}
+
+// TestStateProcessorRecordsGasChanges tests that every gas unit a transaction uses is recorded
+// with a reason, the gas changes of its top level call adding up to its gas used minus the
+// intrinsic gas, before the refund.
+func TestStateProcessorRecordsGasChanges(t *testing.T) {
+	var (
+		signer     = types.HomesteadSigner{}
+		testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		testAddr   = crypto.PubkeyToAddress(testKey.PublicKey)
+		contract   = common.Address{0xcc}
+		failing    = common.Address{0xdd}
+		db         = rawdb.NewMemoryDatabase()
+		gspec      = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				testAddr: {Balance: big.NewInt(1000000000000000000)},
+				// Expand the memory, hash it, clear the storage slot 1 for a refund, access an
+				// account cold, log and call an account without code returning the gas given
+				contract: {Balance: common.Big0, Storage: map[common.Hash]common.Hash{{31: 1}: {31: 1}}, Code: []byte{
+					byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.MSTORE),
+					byte(vm.PUSH1), 2, byte(vm.PUSH1), 3, byte(vm.EXP), byte(vm.POP),
+					byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.SHA3), byte(vm.POP),
+					byte(vm.PUSH1), 1, byte(vm.SLOAD), byte(vm.POP),
+					byte(vm.PUSH1), 0, byte(vm.PUSH1), 1, byte(vm.SSTORE),
+					byte(vm.PUSH1), 0xaa, byte(vm.BALANCE), byte(vm.POP),
+					byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.LOG0),
+					byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+					byte(vm.PUSH1), 0xdd, byte(vm.PUSH2), 0x10, 0x00, byte(vm.CALL), byte(vm.POP),
+				}},
+				// INVALID opcode
+				failing: {Balance: common.Big0, Code: []byte{0xfe}},
+			},
+		}
+		genesis       = gspec.MustCommit(db)
+		blockchain, _ = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	)
+	defer blockchain.Stop()
+
+	// Enabled once the chain is created, otherwise it would record the genesis block
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		for _, to := range []common.Address{contract, failing, {0xaa}} {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddr), to, common.Big0, 100000, nil, nil), signer, testKey)
+			b.AddTx(tx)
+		}
+	})
+
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	printer := firehose.NewToBufferPrinter(1024)
+	receipts, _, _, err := NewStateProcessor(gspec.Config, blockchain, ethash.NewFaker()).Process(blocks[0], statedb, vm.Config{}, firehose.NewContext(printer, true))
+	if err != nil {
+		t.Fatalf("processing failed: %v", err)
+	}
+
+	// FIRE GAS_CHANGE <call index> <old value> <new value> <reason> <ordinal> [<refund counter>]
+	var intrinsic, refund, executed int64
+	reasons := map[string]bool{}
+	tx := 0
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		fields := strings.Split(line, " ")
+		switch {
+		case strings.HasPrefix(line, "FIRE GAS_CHANGE "):
+			oldValue, _ := strconv.ParseInt(fields[3], 10, 64)
+			newValue, _ := strconv.ParseInt(fields[4], 10, 64)
+			reasons[fields[5]] = true
+
+			switch {
+			case fields[5] == "intrinsic_gas":
+				intrinsic += oldValue - newValue
+			case fields[5] == "refund":
+				refund += newValue - oldValue
+			case fields[2] == "1":
+				executed += oldValue - newValue
+			}
+
+		case strings.HasPrefix(line, "FIRE END_APPLY_TRX "):
+			if gasUsed := int64(receipts[tx].GasUsed); executed != gasUsed-intrinsic+refund {
+				t.Fatalf("tx %d: recorded %d gas executed, expected gas used %d minus intrinsic gas %d before refund %d", tx, executed, gasUsed, intrinsic, refund)
+			}
+			intrinsic, refund, executed = 0, 0, 0
+			tx++
+		}
+	}
+	if tx != 3 {
+		t.Fatalf("recorded %d transactions, expected 3", tx)
+	}
+
+	expected := []string{
+		"intrinsic_gas", "op_code", "memory_expansion", "exp", "keccak", "state_cold_access", "storage_read", "storage_write",
+		"balance", "event_log", "call", "refund_after_execution", "failed_execution", "refund",
+	}
+	for _, reason := range expected {
+		if !reasons[reason] {
+			t.Errorf("missing gas change reason %q", reason)
+		}
+		delete(reasons, reason)
+	}
+	if len(reasons) != 0 {
+		t.Errorf("unexpected gas change reasons %v", reasons)
+	}
+}
+
+// TestStateProcessorRecordsSenders tests that the sender of both EIP-155 protected and
+// unprotected transactions is recorded right after the transaction starts.
+func TestStateProcessorRecordsSenders(t *testing.T) {
+	var (
+		testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		testAddr   = crypto.PubkeyToAddress(testKey.PublicKey)
+		db         = rawdb.NewMemoryDatabase()
+		gspec      = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{testAddr: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		genesis       = gspec.MustCommit(db)
+		blockchain, _ = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	)
+	defer blockchain.Stop()
+
+	// Enabled once the chain is created, otherwise it would record the genesis block
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	signers := []types.Signer{types.HomesteadSigner{}, types.NewEIP155Signer(gspec.Config.ChainID)}
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		for _, signer := range signers {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddr), common.Address{0xaa}, common.Big0, params.TxGas, nil, nil), signer, testKey)
+			b.AddTx(tx)
+		}
+	})
+	if blocks[0].Transactions()[0].Protected() || !blocks[0].Transactions()[1].Protected() {
+		t.Fatalf("expected an unprotected and a protected transaction")
+	}
+
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	printer := firehose.NewToBufferPrinter(1024)
+	if _, _, _, err := NewStateProcessor(gspec.Config, blockchain, ethash.NewFaker()).Process(blocks[0], statedb, vm.Config{}, firehose.NewContext(printer, true)); err != nil {
+		t.Fatalf("processing failed: %v", err)
+	}
+
+	var senders []string
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		if strings.HasPrefix(line, "FIRE TRX_FROM ") {
+			senders = append(senders, strings.TrimPrefix(line, "FIRE TRX_FROM "))
+		}
+	}
+	if want := firehose.Addr(testAddr); len(senders) != 2 || senders[0] != want || senders[1] != want {
+		t.Fatalf("expected both transactions sent by %s, got %q", want, senders)
+	}
+}
+
+func TestFirehoseBlockProducer(t *testing.T) {
+	var (
+		signerKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		signer       = crypto.PubkeyToAddress(signerKey.PublicKey)
+		engine       = clique.New(params.AllCliqueProtocolChanges.Clique, rawdb.NewMemoryDatabase())
+	)
+
+	// A clique block's coinbase is the voted address, the producer is the sealing signer
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(2), Coinbase: common.Address{0xaa}, Extra: make([]byte, 32+crypto.SignatureLength)}
+	seal, err := crypto.Sign(clique.SealHash(header).Bytes(), signerKey)
+	if err != nil {
+		t.Fatalf("failed to seal header: %v", err)
+	}
+	copy(header.Extra[32:], seal)
+	if producer := firehoseBlockProducer(engine, header); producer != signer {
+		t.Fatalf("clique producer mismatch: have %x, want %x", producer, signer)
+	}
+
+	unsealed := &types.Header{Number: big.NewInt(1), Coinbase: common.Address{0xaa}}
+	if producer := firehoseBlockProducer(engine, unsealed); producer != unsealed.Coinbase {
+		t.Fatalf("unsealed producer mismatch: have %x, want %x", producer, unsealed.Coinbase)
+	}
+	if producer := firehoseBlockProducer(ethash.NewFaker(), unsealed); producer != unsealed.Coinbase {
+		t.Fatalf("ethash producer mismatch: have %x, want %x", producer, unsealed.Coinbase)
+	}
+}
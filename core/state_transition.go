@@ -258,6 +258,7 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	}
 
 	if st.firehoseContext.Enabled() {
+		st.firehoseContext.RecordTrxIntrinsicGas(gas)
 		st.firehoseContext.RecordGasConsume(st.gas, gas, firehose.GasChangeReason("intrinsic_gas"))
 	}
 	st.gas -= gas
@@ -280,7 +281,7 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 		ret, _, st.gas, vmerr = st.evm.Create(sender, st.data, st.gas, st.value)
 	} else {
 		// Increment the nonce for the next transaction
-		st.state.SetNonce(msg.From(), st.state.GetNonce(sender.Address())+1, st.firehoseContext)
+		st.state.SetNonce(msg.From(), st.state.GetNonce(sender.Address())+1, st.firehoseContext, firehose.NonceChangeReason("transaction"))
 		ret, st.gas, vmerr = st.evm.Call(sender, st.to(), st.data, st.gas, st.value)
 	}
 	st.refundGas()
@@ -299,6 +300,9 @@ func (st *StateTransition) refundGas() {
 	if refund > st.state.GetRefund() {
 		refund = st.state.GetRefund()
 	}
+	if st.firehoseContext.Enabled() {
+		st.firehoseContext.RecordTransactionGasRefund(st.gas, st.state.GetRefund(), refund)
+	}
 	st.gas += refund
 
 	// Return ETH for remaining gas, exchanged at the original rate.
@@ -0,0 +1,84 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// TestTransitionDbRecordsIntrinsicGas checks the recorded intrinsic gas of a contract
+// creation with one zero and one non-zero data byte follows each fork's pricing.
+func TestTransitionDbRecordsIntrinsicGas(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	berlin := *params.AllEthashProtocolChanges
+	berlin.BerlinBlock = big.NewInt(0)
+
+	accessList := types.AccessList{{Address: common.HexToAddress("0xaa"), StorageKeys: []common.Hash{{}}}}
+
+	tests := []struct {
+		name       string
+		config     *params.ChainConfig
+		accessList types.AccessList
+		expected   uint64
+	}{
+		{"frontier", &params.ChainConfig{ChainID: big.NewInt(1)}, nil, params.TxGas + params.TxDataNonZeroGasFrontier + params.TxDataZeroGas},
+		{"homestead", &params.ChainConfig{ChainID: big.NewInt(1), HomesteadBlock: big.NewInt(0)}, nil, params.TxGasContractCreation + params.TxDataNonZeroGasFrontier + params.TxDataZeroGas},
+		{"istanbul", params.AllEthashProtocolChanges, nil, params.TxGasContractCreation + params.TxDataNonZeroGasEIP2028 + params.TxDataZeroGas},
+		{"berlin", &berlin, accessList, params.TxGasContractCreation + params.TxDataNonZeroGasEIP2028 + params.TxDataZeroGas + params.TxAccessListAddressGas + params.TxAccessListStorageKeyGas},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from := common.HexToAddress("0x01")
+			statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+			statedb.AddBalance(from, big.NewInt(params.Ether), false, firehose.NoOpContext, firehose.IgnoredBalanceChangeReason)
+
+			blockContext := vm.BlockContext{
+				CanTransfer: CanTransfer,
+				Transfer:    Transfer,
+				BlockNumber: big.NewInt(1),
+				GasLimit:    10_000_000,
+			}
+
+			firehoseContext := firehose.NewSpeculativeExecutionContext(1024)
+			evm := vm.NewEVM(blockContext, vm.TxContext{Origin: from, GasPrice: big.NewInt(1)}, statedb, test.config, vm.Config{}, firehoseContext)
+
+			msg := types.NewMessage(from, nil, 0, new(big.Int), 100_000, big.NewInt(1), []byte{0x00, 0x01}, test.accessList, true)
+			if _, err := ApplyMessage(evm, msg, new(GasPool).AddGas(100_000)); err != nil {
+				t.Fatalf("apply message failed: %v", err)
+			}
+
+			expected := fmt.Sprintf("FIRE TRX_INTRINSIC_GAS %d\n", test.expected)
+			if got := string(firehoseContext.FirehoseLog()); !strings.Contains(got, expected) {
+				t.Fatalf("missing %q in %q", expected, got)
+			}
+		})
+	}
+}
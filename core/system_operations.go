@@ -0,0 +1,81 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// SystemOperation is a state change the state processor performs outside of the block's
+// transactions, before or after them, like the DAO hard fork or the writes of a system
+// contract storing historical block hashes. Firehose records its state changes in a system
+// call named after it, so new operations need no new record type. Downstream forks register
+// their operations with `RegisterSystemOperation`, from an `init` function.
+type SystemOperation struct {
+	// Name is the source of the system call recording the operation, it must be unique.
+	Name string
+
+	// Applies tells if the operation is performed in the block.
+	Applies func(config *params.ChainConfig, header *types.Header) bool
+
+	// Apply performs the operation's state changes, recording them in the context.
+	Apply func(config *params.ChainConfig, header *types.Header, statedb *state.StateDB, firehoseContext *firehose.Context)
+}
+
+// daoForkOperation moves the funds of the DAO accounts to the refund contract at the block of
+// the DAO hard fork.
+var daoForkOperation = SystemOperation{
+	Name: "dao_fork",
+	Applies: func(config *params.ChainConfig, header *types.Header) bool {
+		return config.DAOForkSupport && config.DAOForkBlock != nil && config.DAOForkBlock.Cmp(header.Number) == 0
+	},
+	Apply: func(config *params.ChainConfig, header *types.Header, statedb *state.StateDB, firehoseContext *firehose.Context) {
+		misc.ApplyDAOHardFork(statedb, firehoseContext)
+	},
+}
+
+var (
+	preTransactionsOperations  = []SystemOperation{daoForkOperation}
+	postTransactionsOperations []SystemOperation
+)
+
+// RegisterSystemOperation registers an operation performed before the block's transactions or
+// after them, before the consensus engine finalizes the block. Operations are performed in
+// registration order, it panics if the name is already registered.
+func RegisterSystemOperation(operation SystemOperation, beforeTransactions bool) {
+	for _, registered := range append(preTransactionsOperations, postTransactionsOperations...) {
+		if registered.Name == operation.Name {
+			panic(fmt.Errorf("system operation %q is already registered", operation.Name))
+		}
+	}
+
+	if beforeTransactions {
+		preTransactionsOperations = append(preTransactionsOperations, operation)
+	} else {
+		postTransactionsOperations = append(postTransactionsOperations, operation)
+	}
+}
+
+// applySystemOperations performs the operations applying to the block, each in its own
+// system call.
+func applySystemOperations(operations []SystemOperation, config *params.ChainConfig, header *types.Header, statedb *state.StateDB, firehoseContext *firehose.Context) {
+	for _, operation := range operations {
+		if !operation.Applies(config, header) {
+			continue
+		}
+
+		if firehoseContext.Enabled() {
+			firehoseContext.StartSystemCall(firehose.SystemCallSource(operation.Name))
+		}
+
+		operation.Apply(config, header, statedb, firehoseContext)
+
+		if firehoseContext.Enabled() {
+			firehoseContext.EndSystemCall()
+		}
+	}
+}
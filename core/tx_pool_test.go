@@ -178,7 +178,7 @@ func (c *testChain) State() (*state.StateDB, error) {
 	if *c.trigger {
 		c.statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
 		// simulate that the new head block included tx0 and tx1
-		c.statedb.SetNonce(c.address, 2, firehose.NoOpContext)
+		c.statedb.SetNonce(c.address, 2, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 		c.statedb.SetBalance(c.address, new(big.Int).SetUint64(params.Ether), firehose.NoOpContext, "test")
 		*c.trigger = false
 	}
@@ -254,7 +254,7 @@ func TestInvalidTransactions(t *testing.T) {
 		t.Error("expected", ErrIntrinsicGas, "got", err)
 	}
 
-	pool.currentState.SetNonce(from, 1, firehose.NoOpContext)
+	pool.currentState.SetNonce(from, 1, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 	pool.currentState.AddBalance(from, big.NewInt(0xffffffffffffff), false, firehose.NoOpContext, "test")
 	tx = transaction(0, 100000, key)
 	if err := pool.AddRemote(tx); !errors.Is(err, ErrNonceTooLow) {
@@ -290,7 +290,7 @@ func TestTransactionQueue(t *testing.T) {
 
 	tx = transaction(1, 100, key)
 	from, _ = deriveSender(tx)
-	pool.currentState.SetNonce(from, 2, firehose.NoOpContext)
+	pool.currentState.SetNonce(from, 2, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 	pool.enqueueTx(tx.Hash(), tx, false, true)
 
 	<-pool.requestPromoteExecutables(newAccountSet(pool.signer, from))
@@ -453,7 +453,7 @@ func TestTransactionNonceRecovery(t *testing.T) {
 	defer pool.Stop()
 
 	addr := crypto.PubkeyToAddress(key.PublicKey)
-	pool.currentState.SetNonce(addr, n, firehose.NoOpContext)
+	pool.currentState.SetNonce(addr, n, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 	pool.currentState.AddBalance(addr, big.NewInt(100000000000000), false, firehose.NoOpContext, "test")
 	<-pool.requestReset(nil, nil)
 
@@ -462,7 +462,7 @@ func TestTransactionNonceRecovery(t *testing.T) {
 		t.Error(err)
 	}
 	// simulate some weird re-order of transactions and missing nonce(s)
-	pool.currentState.SetNonce(addr, n-1, firehose.NoOpContext)
+	pool.currentState.SetNonce(addr, n-1, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 	<-pool.requestReset(nil, nil)
 	if fn := pool.Nonce(addr); fn != n-1 {
 		t.Errorf("expected nonce to be %d, got %d", n-1, fn)
@@ -955,8 +955,8 @@ func testTransactionQueueTimeLimiting(t *testing.T, nolocals bool) {
 	}
 
 	// remove current transactions and increase nonce to prepare for a reset and cleanup
-	statedb.SetNonce(crypto.PubkeyToAddress(remote.PublicKey), 2, firehose.NoOpContext)
-	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 2, firehose.NoOpContext)
+	statedb.SetNonce(crypto.PubkeyToAddress(remote.PublicKey), 2, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
+	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 2, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 	<-pool.requestReset(nil, nil)
 
 	// make sure queue, pending are cleared
@@ -1810,7 +1810,7 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	}
 	// Terminate the old pool, bump the local nonce, create a new pool and ensure relevant transaction survive
 	pool.Stop()
-	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 1, firehose.NoOpContext)
+	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 1, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 	blockchain = &testBlockChain{statedb, 1000000, new(event.Feed)}
 
 	pool = NewTxPool(config, params.TestChainConfig, blockchain)
@@ -1832,12 +1832,12 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 		t.Fatalf("pool internal state corrupted: %v", err)
 	}
 	// Bump the nonce temporarily and ensure the newly invalidated transaction is removed
-	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 2, firehose.NoOpContext)
+	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 2, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 	<-pool.requestReset(nil, nil)
 	time.Sleep(2 * config.Rejournal)
 	pool.Stop()
 
-	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 1, firehose.NoOpContext)
+	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 1, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 	blockchain = &testBlockChain{statedb, 1000000, new(event.Feed)}
 	pool = NewTxPool(config, params.TestChainConfig, blockchain)
 
@@ -136,6 +136,10 @@ func init() {
 // - any error that occurred
 func RunPrecompiledContract(p PrecompiledContract, input []byte, suppliedGas uint64, firehoseContext *firehose.Context) (ret []byte, remainingGas uint64, err error) {
 	gasCost := p.RequiredGas(input)
+	if firehoseContext.Enabled() {
+		firehoseContext.RecordCallPrecompiled(gasCost)
+	}
+
 	if suppliedGas < gasCost {
 		return nil, 0, ErrOutOfGas
 	}
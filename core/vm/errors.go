@@ -19,6 +19,8 @@ package vm
 import (
 	"errors"
 	"fmt"
+
+	"github.com/ethereum/go-ethereum/firehose"
 )
 
 // List evm execution errors
@@ -69,3 +71,47 @@ type ErrInvalidOpCode struct {
 }
 
 func (e *ErrInvalidOpCode) Error() string { return fmt.Sprintf("invalid opcode: %s", e.opcode) }
+
+// firehoseCallFailure maps an evm execution error to the kind of call failure recorded by
+// the Firehose instrumentation, a stable identifier grouping the errors of the same nature
+// whatever their message, like the out of gas ones. It lives here rather than in the firehose
+// package as the latter can't depend on this one.
+func firehoseCallFailure(err error) firehose.CallFailure {
+	switch err.(type) {
+	case *ErrStackUnderflow:
+		return firehose.CallFailure("stack_underflow")
+	case *ErrStackOverflow:
+		return firehose.CallFailure("stack_overflow")
+	case *ErrInvalidOpCode:
+		return firehose.CallFailure("invalid_opcode")
+	}
+
+	switch err {
+	case ErrOutOfGas, ErrCodeStoreOutOfGas, ErrGasUintOverflow:
+		return firehose.CallFailure("out_of_gas")
+	case ErrInvalidJump:
+		return firehose.CallFailure("invalid_jump")
+	case ErrWriteProtection:
+		return firehose.CallFailure("write_protection")
+	case ErrReturnDataOutOfBounds:
+		return firehose.CallFailure("return_data_out_of_bounds")
+	case ErrDepth:
+		return firehose.CallFailure("depth_limit")
+	case ErrInsufficientBalance:
+		return firehose.CallFailure("insufficient_balance")
+	case ErrExecutionReverted:
+		return firehose.CallFailure("revert")
+	case ErrContractAddressCollision:
+		return firehose.CallFailure("contract_address_collision")
+	case ErrMaxCodeSizeExceeded:
+		return firehose.CallFailure("max_code_size_exceeded")
+	case ErrInvalidSubroutineEntry:
+		return firehose.CallFailure("invalid_subroutine_entry")
+	case ErrInvalidRetsub:
+		return firehose.CallFailure("invalid_retsub")
+	case ErrReturnStackExceeded:
+		return firehose.CallFailure("return_stack_exceeded")
+	}
+
+	return firehose.UnknownCallFailure
+}
@@ -155,6 +155,10 @@ type EVM struct {
 	// available gas is calculated in gasCall* according to the 63/64 rule and later
 	// applied in opCall*.
 	callGasTemp uint64
+	// coldAccessGasTemp holds the EIP-2929 cold access surcharge included in the dynamic gas
+	// of the current operation, it's set by the gasFunc so Firehose records it apart from the
+	// operation's own cost.
+	coldAccessGasTemp uint64
 
 	firehoseContext *firehose.Context
 }
@@ -225,6 +229,23 @@ func (evm *EVM) Interpreter() Interpreter {
 	return evm.interpreter
 }
 
+// isReadOnly tells if the interpreter forbids state modifications, which is the case for
+// the whole call tree of a static call.
+func (evm *EVM) isReadOnly() bool {
+	interpreter, ok := evm.interpreter.(*EVMInterpreter)
+	return ok && interpreter.readOnly
+}
+
+// firehoseCallStipend is the stipend included in the gas of a CALL or CALLCODE, which the
+// opcodes grant to calls transferring value, the top level call of a transaction having none.
+func (evm *EVM) firehoseCallStipend(value *big.Int) uint64 {
+	if evm.depth > 0 && value.Sign() != 0 {
+		return params.CallStipend
+	}
+
+	return 0
+}
+
 // Call executes the contract associated with the addr with the given input as
 // parameters. It also handles any necessary value transfer required and takes
 // the necessary steps to create accounts and reverses the state in case of an
@@ -232,12 +253,12 @@ func (evm *EVM) Interpreter() Interpreter {
 func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas uint64, value *big.Int) (ret []byte, leftOverGas uint64, err error) {
 	if evm.firehoseContext.Enabled() {
 		evm.firehoseContext.StartCall("CALL")
-		evm.firehoseContext.RecordCallParams("CALL", caller.Address(), addr, value, gas, input)
+		evm.firehoseContext.RecordCallParams("CALL", caller.Address(), addr, value, gas, evm.firehoseCallStipend(value), input, evm.isReadOnly(), addr)
 	}
 
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, firehoseCallFailure(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, nil
@@ -245,7 +266,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, firehoseCallFailure(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, ErrDepth
@@ -253,7 +274,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	// Fail if we're trying to transfer more than the available balance
 	if value.Sign() != 0 && !evm.Context.CanTransfer(evm.StateDB, caller.Address(), value) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrInsufficientBalance.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, firehoseCallFailure(ErrInsufficientBalance), ErrInsufficientBalance.Error())
 		}
 
 		return nil, gas, ErrInsufficientBalance
@@ -275,7 +296,11 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 
 			return nil, gas, nil
 		}
-		evm.StateDB.CreateAccount(addr, evm.firehoseContext)
+		creationReason := firehose.AccountCreationReason("transfer")
+		if isPrecompile {
+			creationReason = firehose.AccountCreationReason("precompile")
+		}
+		evm.StateDB.CreateAccount(addr, evm.firehoseContext, creationReason)
 	}
 	evm.Context.Transfer(evm.StateDB, caller.Address(), addr, value, evm.firehoseContext)
 
@@ -314,7 +339,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	// when we're in homestead this also counts for code storage gas errors.
 	if err != nil {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.RecordCallFailed(gas, err.Error())
+			evm.firehoseContext.RecordCallFailed(gas, firehoseCallFailure(err), err.Error())
 		}
 
 		evm.StateDB.RevertToSnapshot(snapshot)
@@ -326,7 +351,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 			gas = 0
 		} else {
 			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
+				evm.firehoseContext.RecordCallReverted(ret)
 			}
 		}
 		// TODO: consider clearing up unused snapshots:
@@ -351,11 +376,11 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte, gas uint64, value *big.Int) (ret []byte, leftOverGas uint64, err error) {
 	if evm.firehoseContext.Enabled() {
 		evm.firehoseContext.StartCall("CALLCODE")
-		evm.firehoseContext.RecordCallParams("CALLCODE", caller.Address(), addr, value, gas, input)
+		evm.firehoseContext.RecordCallParams("CALLCODE", caller.Address(), addr, value, gas, evm.firehoseCallStipend(value), input, evm.isReadOnly(), caller.Address())
 	}
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, firehoseCallFailure(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, nil
@@ -363,7 +388,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, firehoseCallFailure(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, ErrDepth
@@ -374,7 +399,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	// over-charging itself. So the check here is necessary.
 	if !evm.Context.CanTransfer(evm.StateDB, caller.Address(), value) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrInsufficientBalance.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, firehoseCallFailure(ErrInsufficientBalance), ErrInsufficientBalance.Error())
 		}
 
 		return nil, gas, ErrInsufficientBalance
@@ -395,7 +420,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	}
 	if err != nil {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.RecordCallFailed(gas, err.Error())
+			evm.firehoseContext.RecordCallFailed(gas, firehoseCallFailure(err), err.Error())
 		}
 
 		evm.StateDB.RevertToSnapshot(snapshot)
@@ -407,7 +432,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 			gas = 0
 		} else {
 			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
+				evm.firehoseContext.RecordCallReverted(ret)
 			}
 		}
 	}
@@ -443,11 +468,11 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 
 		// It's a sure thing that caller is a Contract, it cannot be anything else, so we are safe
 		parent := caller.(*Contract)
-		evm.firehoseContext.RecordCallParams("DELEGATE", parent.Address(), addr, parent.value, gas, input)
+		evm.firehoseContext.RecordCallParams("DELEGATE", parent.Address(), addr, parent.value, gas, 0, input, evm.isReadOnly(), parent.Address())
 	}
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, firehoseCallFailure(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, nil
@@ -455,7 +480,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, firehoseCallFailure(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, ErrDepth
@@ -475,7 +500,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	}
 	if err != nil {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.RecordCallFailed(gas, err.Error())
+			evm.firehoseContext.RecordCallFailed(gas, firehoseCallFailure(err), err.Error())
 		}
 
 		evm.StateDB.RevertToSnapshot(snapshot)
@@ -486,7 +511,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 			gas = 0
 		} else {
 			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
+				evm.firehoseContext.RecordCallReverted(ret)
 			}
 		}
 	}
@@ -505,11 +530,11 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte, gas uint64) (ret []byte, leftOverGas uint64, err error) {
 	if evm.firehoseContext.Enabled() {
 		evm.firehoseContext.StartCall("STATIC")
-		evm.firehoseContext.RecordCallParams("STATIC", caller.Address(), addr, firehose.EmptyValue, gas, input)
+		evm.firehoseContext.RecordCallParams("STATIC", caller.Address(), addr, firehose.EmptyValue, gas, 0, input, true, addr)
 	}
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, firehoseCallFailure(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, nil
@@ -517,7 +542,7 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, firehoseCallFailure(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, ErrDepth
@@ -556,7 +581,7 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	}
 	if err != nil {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.RecordCallFailed(gas, err.Error())
+			evm.firehoseContext.RecordCallFailed(gas, firehoseCallFailure(err), err.Error())
 		}
 
 		evm.StateDB.RevertToSnapshot(snapshot)
@@ -568,7 +593,7 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 			gas = 0
 		} else {
 			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
+				evm.firehoseContext.RecordCallReverted(ret)
 			}
 		}
 	}
@@ -596,27 +621,34 @@ func (c *codeAndHash) Hash() common.Hash {
 func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64, value *big.Int, address common.Address) ([]byte, common.Address, uint64, error) {
 	if evm.firehoseContext.Enabled() {
 		evm.firehoseContext.StartCall("CREATE")
-		evm.firehoseContext.RecordCallParams("CREATE", caller.Address(), address, value, gas, nil)
+		evm.firehoseContext.RecordCallParams("CREATE", caller.Address(), address, value, gas, 0, codeAndHash.code, evm.isReadOnly(), address)
 	}
 
 	// Depth check execution. Fail if we're trying to execute above the
 	// limit.
 	if evm.depth > int(params.CallCreateDepth) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.RecordCreateFailed(address, evm.StateDB.Exist(address))
+			evm.firehoseContext.EndFailedCall(gas, true, firehoseCallFailure(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, common.Address{}, gas, ErrDepth
 	}
 	if !evm.Context.CanTransfer(evm.StateDB, caller.Address(), value) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrInsufficientBalance.Error())
+			evm.firehoseContext.RecordCreateFailed(address, evm.StateDB.Exist(address))
+			evm.firehoseContext.EndFailedCall(gas, true, firehoseCallFailure(ErrInsufficientBalance), ErrInsufficientBalance.Error())
 		}
 
 		return nil, common.Address{}, gas, ErrInsufficientBalance
 	}
 	nonce := evm.StateDB.GetNonce(caller.Address())
-	evm.StateDB.SetNonce(caller.Address(), nonce+1, evm.firehoseContext)
+	// At depth 0, the caller is the sender of a contract creation transaction
+	nonceChangeReason := firehose.NonceChangeReason("contract_creator")
+	if evm.depth == 0 {
+		nonceChangeReason = firehose.NonceChangeReason("transaction")
+	}
+	evm.StateDB.SetNonce(caller.Address(), nonce+1, evm.firehoseContext, nonceChangeReason)
 	// We add this to the access list _before_ taking a snapshot. Even if the creation fails,
 	// the access-list change should not be rolled back
 	if evm.chainRules.IsBerlin {
@@ -626,12 +658,14 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	contractHash := evm.StateDB.GetCodeHash(address)
 	if evm.StateDB.GetNonce(address) != 0 || (contractHash != (common.Hash{}) && contractHash != emptyCodeHash) {
 		if evm.firehoseContext.Enabled() {
+			evm.firehoseContext.RecordCreateFailed(address, evm.StateDB.Exist(address))
+
 			// In the case of a contract collision, the gas is fully consume since the retured gas value in the
 			// return a little below is 0. This means we are facing not a revertion like other early failure
 			// reasons we usually see but with an actual assertion failure which burns the remaining gas that
 			// was allowed to the creation. Hence why we have an `EndFailedCall` and using `false` to show
 			// the call is **not** reverted.
-			evm.firehoseContext.EndFailedCall(gas, false, ErrContractAddressCollision.Error())
+			evm.firehoseContext.EndFailedCall(gas, false, firehoseCallFailure(ErrContractAddressCollision), ErrContractAddressCollision.Error())
 		}
 
 		return nil, common.Address{}, 0, ErrContractAddressCollision
@@ -639,9 +673,9 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 
 	// Create a new account on the state
 	snapshot := evm.StateDB.Snapshot()
-	evm.StateDB.CreateAccount(address, evm.firehoseContext)
+	evm.StateDB.CreateAccount(address, evm.firehoseContext, firehose.AccountCreationReason("create"))
 	if evm.chainRules.IsEIP158 {
-		evm.StateDB.SetNonce(address, 1, evm.firehoseContext)
+		evm.StateDB.SetNonce(address, 1, evm.firehoseContext, firehose.NonceChangeReason("new_contract"))
 	}
 	evm.Context.Transfer(evm.StateDB, caller.Address(), address, value, evm.firehoseContext)
 
@@ -652,7 +686,8 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.RecordCreateFailed(address, evm.StateDB.Exist(address))
+			evm.firehoseContext.EndFailedCall(gas, true, firehoseCallFailure(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, address, gas, nil
@@ -664,6 +699,7 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	start := time.Now()
 
 	ret, err := run(evm, contract, nil, false)
+	codeDeployed := false
 
 	// check whether the max code size has been exceeded
 	maxCodeSizeExceeded := evm.chainRules.IsEIP158 && len(ret) > params.MaxCodeSize
@@ -675,7 +711,13 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 		createDataGas := uint64(len(ret)) * params.CreateDataGas
 
 		if contract.UseGas(createDataGas, firehose.GasChangeReason("code_storage")) {
-			evm.StateDB.SetCode(address, ret, evm.firehoseContext)
+			if evm.firehoseContext.Enabled() {
+				evm.firehoseContext.RecordCreationCodeChange(address, emptyCodeHash, codeAndHash.Hash(), codeAndHash.code, true, ret)
+			}
+
+			// The code change is recorded above along with the init code
+			evm.StateDB.SetCode(address, ret, firehose.NoOpContext)
+			codeDeployed = true
 		} else {
 			err = ErrCodeStoreOutOfGas
 		}
@@ -688,10 +730,11 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 		evm.StateDB.RevertToSnapshot(snapshot)
 
 		if evm.firehoseContext.Enabled() {
+			evm.firehoseContext.RecordCreateFailed(address, evm.StateDB.Exist(address))
 			if err != nil {
-				evm.firehoseContext.RecordCallFailed(contract.Gas, err.Error())
+				evm.firehoseContext.RecordCallFailed(contract.Gas, firehoseCallFailure(err), err.Error())
 			} else {
-				evm.firehoseContext.RecordCallFailed(contract.Gas, ErrMaxCodeSizeExceeded.Error())
+				evm.firehoseContext.RecordCallFailed(contract.Gas, firehoseCallFailure(ErrMaxCodeSizeExceeded), ErrMaxCodeSizeExceeded.Error())
 			}
 		}
 
@@ -699,7 +742,7 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 			contract.UseGas(contract.Gas, firehose.FailedExecutionGasChangeReason)
 		} else {
 			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
+				evm.firehoseContext.RecordCallReverted(ret)
 			}
 		}
 	}
@@ -712,7 +755,16 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	}
 
 	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.EndCall(contract.Gas, nil)
+		if !codeDeployed {
+			evm.firehoseContext.RecordCreationCodeChange(address, emptyCodeHash, codeAndHash.Hash(), codeAndHash.code, false, nil)
+		}
+
+		// The return data of a creation is the deployed code, it's only recorded when reverted
+		var returnData []byte
+		if err == ErrExecutionReverted {
+			returnData = ret
+		}
+		evm.firehoseContext.EndCall(contract.Gas, returnData)
 	}
 
 	return ret, address, contract.Gas, err
@@ -0,0 +1,935 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestCreateRecordsInitCode(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	tests := []struct {
+		name           string
+		initCode       string
+		newCodeHash    string
+		newCode        string
+		expectedFailed bool
+	}{
+		// PUSH1 0x60 PUSH1 0 MSTORE8 PUSH1 1 PUSH1 0 RETURN
+		{"runtime code", "0x606060005360016000f3", fmt.Sprintf("%x", crypto.Keccak256([]byte{0x60})), "60", false},
+		// STOP, the constructor returns no code
+		{"empty runtime code", "0x00", fmt.Sprintf("%x", emptyCodeHash), ".", false},
+		// INVALID
+		{"failed creation", "0xfe", ".", ".", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+			vmctx := BlockContext{
+				CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+				Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+			}
+
+			printer := firehose.NewToBufferPrinter(1024)
+			vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{}, firehose.NewContext(printer, true))
+
+			initCode := hexutil.MustDecode(test.initCode)
+			_, address, _, err := vmenv.Create(AccountRef(common.Address{}), initCode, 100000, new(big.Int))
+			if failed := err != nil; failed != test.expectedFailed {
+				t.Fatalf("failure mismatch: have %v, want failed %t", err, test.expectedFailed)
+			}
+
+			expected := fmt.Sprintf("FIRE CODE_CHANGE 1 %x %x . %s %s", address, emptyCodeHash, test.newCodeHash, test.newCode)
+			expectedInit := fmt.Sprintf("%x %x\n", crypto.Keccak256(initCode), initCode)
+
+			var codeChanges []string
+			for _, line := range strings.SplitAfter(printer.Buffer().String(), "\n") {
+				if strings.HasPrefix(line, "FIRE CODE_CHANGE ") {
+					codeChanges = append(codeChanges, line)
+				}
+			}
+
+			if len(codeChanges) != 1 || !strings.HasPrefix(codeChanges[0], expected+" ") || !strings.HasSuffix(codeChanges[0], expectedInit) {
+				t.Fatalf("code changes mismatch: have %q, want one %q with init code %q", codeChanges, expected, expectedInit)
+			}
+		})
+	}
+}
+
+func TestCallRecordsPrecompiledCall(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	vmctx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+	}
+
+	printer := firehose.NewToBufferPrinter(1024)
+	vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{}, firehose.NewContext(printer, true))
+
+	sha256Address := common.BytesToAddress([]byte{2})
+	input := []byte("firehose")
+	gasCost := (&sha256hash{}).RequiredGas(input)
+
+	ret, _, err := vmenv.Call(AccountRef(common.Address{}), sha256Address, input, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+
+	expected := []string{
+		"FIRE EVM_RUN_CALL CALL 1 1\n",
+		fmt.Sprintf("FIRE EVM_PARAM CALL 1 %x %x . 100000 %x false 0 %x %x\n", common.Address{}, sha256Address, input, sha256Address, sha256Address),
+		fmt.Sprintf("FIRE CREATED_ACCOUNT 1 %x 2 precompile\n", sha256Address),
+		fmt.Sprintf("FIRE PRECOMPILED_CALL 1 %d\n", gasCost),
+		fmt.Sprintf("FIRE GAS_CHANGE 1 100000 %d precompiled_contract 3\n", 100000-gasCost),
+		fmt.Sprintf("FIRE EVM_END_CALL 1 %d %x 4 false %d 0\n", 100000-gasCost, ret, gasCost),
+	}
+	if got := printer.Buffer().String(); got != strings.Join(expected, "") {
+		t.Fatalf("got %q, expected %q", got, strings.Join(expected, ""))
+	}
+}
+
+func TestCreateRecordsNonceChangeReasons(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	for depth, creatorReason := range []string{"transaction", "contract_creator"} {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		vmctx := BlockContext{
+			BlockNumber: big.NewInt(1),
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+		}
+
+		printer := firehose.NewToBufferPrinter(1024)
+		vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{}, firehose.NewContext(printer, true))
+		vmenv.depth = depth
+
+		creator := common.HexToAddress("0x01")
+		_, address, _, err := vmenv.Create(AccountRef(creator), []byte{0x00}, 100000, new(big.Int))
+		if err != nil {
+			t.Fatalf("depth %d: create failed: %v", depth, err)
+		}
+
+		var nonceChanges []string
+		for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+			if fields := strings.Fields(line); len(fields) > 1 && fields[1] == "NONCE_CHANGE" {
+				nonceChanges = append(nonceChanges, strings.Join(append(fields[3:6:6], fields[7]), " "))
+			}
+		}
+
+		expected := []string{
+			fmt.Sprintf("%x 0 1 %s", creator, creatorReason),
+			fmt.Sprintf("%x 0 1 new_contract", address),
+		}
+		if strings.Join(nonceChanges, "\n") != strings.Join(expected, "\n") {
+			t.Fatalf("depth %d: nonce changes mismatch: have %q, want %q", depth, nonceChanges, expected)
+		}
+	}
+}
+
+func TestCallParamsValueAndStatic(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	// callTo returns the code calling the address with the opcode, with `value` for the opcodes taking one
+	callTo := func(op OpCode, addr common.Address, value byte) []byte {
+		code := []byte{byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0}
+		if op == CALL || op == CALLCODE {
+			code = append(code, byte(PUSH1), value)
+		}
+		code = append(code, byte(PUSH20))
+		code = append(code, addr.Bytes()...)
+		return append(code, byte(GAS), byte(op), byte(POP), byte(STOP))
+	}
+
+	var (
+		sender = common.HexToAddress("0x1000")
+		a      = common.HexToAddress("0x100a")
+		b      = common.HexToAddress("0x100b")
+		c      = common.HexToAddress("0x100c")
+		d      = common.HexToAddress("0x100d")
+		e      = common.HexToAddress("0x100e")
+		f      = common.HexToAddress("0x100f")
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(a, callTo(DELEGATECALL, b, 0), firehose.NoOpContext)
+	statedb.SetCode(b, callTo(CALLCODE, c, 3), firehose.NoOpContext)
+	statedb.SetCode(c, callTo(STATICCALL, d, 0), firehose.NoOpContext)
+	statedb.SetCode(d, callTo(DELEGATECALL, e, 0), firehose.NoOpContext)
+	statedb.SetCode(e, callTo(CALL, f, 0), firehose.NoOpContext)
+	statedb.SetCode(f, []byte{byte(STOP)}, firehose.NoOpContext)
+	statedb.AddBalance(sender, big.NewInt(10), false, firehose.NoOpContext, firehose.IgnoredBalanceChangeReason)
+
+	vmctx := BlockContext{
+		BlockNumber: big.NewInt(1),
+		CanTransfer: func(db StateDB, addr common.Address, amount *big.Int) bool {
+			return db.GetBalance(addr).Cmp(amount) >= 0
+		},
+		Transfer: func(db StateDB, sender, recipient common.Address, amount *big.Int, firehoseContext *firehose.Context) {
+			db.SubBalance(sender, amount, firehoseContext, firehose.BalanceChangeReason("transfer"))
+			db.AddBalance(recipient, amount, false, firehoseContext, firehose.BalanceChangeReason("transfer"))
+		},
+	}
+
+	printer := firehose.NewToBufferPrinter(1024)
+	vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{}, firehose.NewContext(printer, true))
+	if _, _, err := vmenv.Call(AccountRef(sender), a, nil, 1000000, big.NewInt(7)); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+
+	var callParams []string
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 && fields[1] == "EVM_PARAM" {
+			// Drop the gas limit, the input and the stipend
+			callParams = append(callParams, strings.Join(append(fields[2:7:7], fields[9], fields[11], fields[12]), " "))
+		}
+	}
+
+	expected := []string{
+		fmt.Sprintf("CALL 1 %x %x 07 false %x %x", sender, a, a, a),
+		fmt.Sprintf("DELEGATE 2 %x %x 07 false %x %x", a, b, a, b),
+		fmt.Sprintf("CALLCODE 3 %x %x 03 false %x %x", a, c, a, c),
+		fmt.Sprintf("STATIC 4 %x %x . true %x %x", a, d, d, d),
+		fmt.Sprintf("DELEGATE 5 %x %x . true %x %x", d, e, d, e),
+		fmt.Sprintf("CALL 6 %x %x . true %x %x", d, f, f, f),
+	}
+	if strings.Join(callParams, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("call params mismatch:\nhave %q\nwant %q", callParams, expected)
+	}
+}
+
+func TestCallCodeRecordsCallerStorage(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		sender  = common.HexToAddress("0x1000")
+		wallet  = common.HexToAddress("0x100a")
+		library = common.HexToAddress("0x100b")
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	// CALLCODE the library with a value of 3
+	code := []byte{byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 3, byte(PUSH20)}
+	code = append(code, library.Bytes()...)
+	statedb.SetCode(wallet, append(code, byte(GAS), byte(CALLCODE), byte(POP), byte(STOP)), firehose.NoOpContext)
+	// Store the call value in slot 1 and the executing address in slot 2
+	statedb.SetCode(library, []byte{
+		byte(CALLVALUE), byte(PUSH1), 1, byte(SSTORE),
+		byte(ADDRESS), byte(PUSH1), 2, byte(SSTORE), byte(STOP),
+	}, firehose.NoOpContext)
+	statedb.AddBalance(sender, big.NewInt(10), false, firehose.NoOpContext, firehose.IgnoredBalanceChangeReason)
+	statedb.PrepareAccessList(sender, &wallet, nil, nil)
+
+	vmctx := BlockContext{
+		BlockNumber: big.NewInt(1),
+		CanTransfer: func(db StateDB, addr common.Address, amount *big.Int) bool {
+			return db.GetBalance(addr).Cmp(amount) >= 0
+		},
+		Transfer: func(db StateDB, sender, recipient common.Address, amount *big.Int, firehoseContext *firehose.Context) {
+			db.SubBalance(sender, amount, firehoseContext, firehose.BalanceChangeReason("transfer"))
+			db.AddBalance(recipient, amount, false, firehoseContext, firehose.BalanceChangeReason("transfer"))
+		},
+	}
+
+	printer := firehose.NewToBufferPrinter(1024)
+	vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{}, firehose.NewContext(printer, true))
+	if _, _, err := vmenv.Call(AccountRef(sender), wallet, nil, 1000000, big.NewInt(7)); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+
+	var records []string
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[1] {
+		case "EVM_PARAM":
+			// Drop the gas limit, the input, the static flag and the stipend
+			records = append(records, strings.Join(append(fields[1:7:7], fields[11], fields[12]), " "))
+		case "STORAGE_CHANGE":
+			// Drop the ordinal
+			records = append(records, strings.Join(fields[1:7], " "))
+		case "BALANCE_CHANGE":
+			records = append(records, strings.Join(fields[1:4], " "))
+		}
+	}
+
+	// The library's code runs against the wallet's storage, its value is not transferred again
+	expected := []string{
+		fmt.Sprintf("EVM_PARAM CALL 1 %x %x 07 %x %x", sender, wallet, wallet, wallet),
+		fmt.Sprintf("BALANCE_CHANGE 1 %x", sender),
+		fmt.Sprintf("BALANCE_CHANGE 1 %x", wallet),
+		fmt.Sprintf("EVM_PARAM CALLCODE 2 %x %x 03 %x %x", wallet, library, wallet, library),
+		fmt.Sprintf("STORAGE_CHANGE 2 %x %x %x %x", wallet, common.HexToHash("0x01"), common.Hash{}, common.HexToHash("0x03")),
+		fmt.Sprintf("STORAGE_CHANGE 2 %x %x %x %x", wallet, common.HexToHash("0x02"), common.Hash{}, wallet.Hash()),
+	}
+	if strings.Join(records, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("records mismatch:\nhave %q\nwant %q", records, expected)
+	}
+}
+
+func TestCallRecordsRevertReason(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	// Error("nope") revert payload
+	payload := common.FromHex("0x08c379a0")
+	payload = append(payload, common.LeftPadBytes([]byte{0x20}, 32)...)
+	payload = append(payload, common.LeftPadBytes([]byte{4}, 32)...)
+	payload = append(payload, common.RightPadBytes([]byte("nope"), 32)...)
+
+	// CODECOPY the payload appended after the code and REVERT with it
+	code := append([]byte{
+		byte(PUSH1), byte(len(payload)), byte(PUSH1), 12, byte(PUSH1), 0, byte(CODECOPY),
+		byte(PUSH1), byte(len(payload)), byte(PUSH1), 0, byte(REVERT),
+	}, payload...)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	contract := common.HexToAddress("0xc0")
+	statedb.SetCode(contract, code, firehose.NoOpContext)
+
+	vmctx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+		BlockNumber: big.NewInt(1),
+	}
+
+	printer := firehose.NewToBufferPrinter(1024)
+	vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{}, firehose.NewContext(printer, true))
+
+	ret, _, err := vmenv.Call(AccountRef(common.Address{}), contract, nil, 100000, new(big.Int))
+	if err != ErrExecutionReverted {
+		t.Fatalf("expected revert, got %v", err)
+	}
+
+	got := printer.Buffer().String()
+	if !strings.Contains(got, "FIRE EVM_REVERTED 1 false \"nope\"\n") {
+		t.Fatalf("missing decoded revert reason in %q", got)
+	}
+	if !strings.Contains(got, fmt.Sprintf(" %x ", ret)) {
+		t.Fatalf("missing revert data in %q", got)
+	}
+}
+
+func TestCallRecordsLogIndexes(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	var (
+		logger   = common.HexToAddress("0xaa")
+		caller   = common.HexToAddress("0xbb")
+		reverter = common.HexToAddress("0xcc")
+	)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	// LOG0
+	statedb.SetCode(logger, []byte{byte(PUSH1), 0, byte(PUSH1), 0, byte(LOG0)}, firehose.NoOpContext)
+	// LOG0, CALL reverter, LOG0
+	statedb.SetCode(caller, []byte{
+		byte(PUSH1), 0, byte(PUSH1), 0, byte(LOG0),
+		byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0xcc, byte(GAS), byte(CALL), byte(POP),
+		byte(PUSH1), 0, byte(PUSH1), 0, byte(LOG0),
+	}, firehose.NoOpContext)
+	// LOG0, REVERT
+	statedb.SetCode(reverter, []byte{byte(PUSH1), 0, byte(PUSH1), 0, byte(LOG0), byte(PUSH1), 0, byte(PUSH1), 0, byte(REVERT)}, firehose.NoOpContext)
+
+	vmctx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+		BlockNumber: big.NewInt(1),
+	}
+
+	printer := firehose.NewToBufferPrinter(1024)
+	vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{}, firehose.NewContext(printer, true))
+
+	for i, to := range []common.Address{logger, caller} {
+		statedb.Prepare(common.BigToHash(big.NewInt(int64(i+1))), common.Hash{}, i)
+		if _, _, err := vmenv.Call(AccountRef(common.Address{}), to, nil, 100000, new(big.Int)); err != nil {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+	}
+
+	// <block_index> <trx_log_index> <log_index>, the reverted log's indexes being reused by the next log
+	var indexes []string
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		if fields := strings.Split(line, " "); len(fields) > 1 && fields[1] == "ADD_LOG" {
+			indexes = append(indexes, strings.Join([]string{fields[3], fields[8], fields[9]}, " "))
+		}
+	}
+
+	expected := []string{"0 0 0", "1 0 1", "2 1 2", "3 1 2"}
+	if strings.Join(indexes, ",") != strings.Join(expected, ",") {
+		t.Fatalf("log indexes mismatch:\nhave %q\nwant %q", indexes, expected)
+	}
+	if logs := statedb.GetLogs(common.BigToHash(big.NewInt(2))); len(logs) != 2 || logs[1].Index != 2 {
+		t.Fatalf("unexpected receipt logs %v", logs)
+	}
+}
+
+func TestCallRecordsTouchedAccounts(t *testing.T) {
+	defer func(enabled, touchedAccounts bool) {
+		firehose.Enabled, firehose.TouchedAccountsEnabled = enabled, touchedAccounts
+	}(firehose.Enabled, firehose.TouchedAccountsEnabled)
+
+	contract := common.HexToAddress("0xc0")
+	// BALANCE 0xaa, EXTCODESIZE 0xbb, BALANCE 0xaa, CALL 0xcc, SELFDESTRUCT 0xdd
+	code := []byte{
+		byte(PUSH1), 0xaa, byte(BALANCE), byte(POP),
+		byte(PUSH1), 0xbb, byte(EXTCODESIZE), byte(POP),
+		byte(PUSH1), 0xaa, byte(BALANCE), byte(POP),
+		byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0xcc, byte(GAS), byte(CALL), byte(POP),
+		byte(PUSH1), 0xdd, byte(SELFDESTRUCT),
+	}
+
+	for _, touchedAccounts := range []bool{false, true} {
+		firehose.Enabled, firehose.TouchedAccountsEnabled = true, touchedAccounts
+
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetCode(contract, code, firehose.NoOpContext)
+
+		vmctx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+			BlockNumber: big.NewInt(1),
+		}
+
+		printer := firehose.NewToBufferPrinter(1024)
+		firehoseContext := firehose.NewContext(printer, true)
+		vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{}, firehoseContext)
+
+		firehoseContext.StartTransactionRaw(common.Hash{}, &contract, new(big.Int), nil, nil, nil, 100000, new(big.Int), 0, nil, nil, nil, nil, 0, 0, nil, 0, nil)
+		if _, _, err := vmenv.Call(AccountRef(common.Address{}), contract, nil, 100000, new(big.Int)); err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+		firehoseContext.EndTransaction(&types.Receipt{})
+
+		var touched []string
+		for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+			if strings.HasPrefix(line, "FIRE TRX_TOUCHED_ACCOUNTS ") {
+				touched = append(touched, strings.TrimPrefix(line, "FIRE TRX_TOUCHED_ACCOUNTS "))
+			}
+		}
+
+		var expected []string
+		if touchedAccounts {
+			addresses := make([]string, 0, 4)
+			for _, addr := range []string{"0xaa", "0xbb", "0xcc", "0xdd"} {
+				addresses = append(addresses, fmt.Sprintf("%x", common.HexToAddress(addr)))
+			}
+			expected = []string{strings.Join(addresses, ",")}
+		}
+		if strings.Join(touched, "\n") != strings.Join(expected, "\n") {
+			t.Fatalf("touched accounts %t: got %q, expected %q", touchedAccounts, touched, expected)
+		}
+	}
+}
+
+func TestCallRecordsGasChangeReasons(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	berlin := *params.AllEthashProtocolChanges
+	berlin.BerlinBlock = big.NewInt(0)
+
+	contract := common.HexToAddress("0xc0")
+	code := []byte{
+		byte(PUSH1), 1, byte(PUSH1), 0, byte(MSTORE),
+		byte(PUSH1), 2, byte(PUSH1), 3, byte(EXP), byte(POP),
+		byte(PUSH1), 32, byte(PUSH1), 0, byte(SHA3), byte(POP),
+		byte(PUSH1), 1, byte(SLOAD), byte(POP),
+		byte(PUSH1), 1, byte(PUSH1), 1, byte(SSTORE),
+		byte(PUSH1), 0xaa, byte(BALANCE), byte(POP),
+		byte(PUSH1), 0xaa, byte(EXTCODESIZE), byte(POP),
+		byte(PUSH1), 0xbb, byte(EXTCODEHASH), byte(POP),
+		byte(PUSH1), 32, byte(PUSH1), 0, byte(PUSH1), 32, byte(PUSH1), 0xcc, byte(EXTCODECOPY),
+		byte(PUSH1), 0, byte(PUSH1), 0, byte(LOG0),
+		byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0xdd, byte(GAS), byte(CALL), byte(POP),
+	}
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(contract, code, firehose.NoOpContext)
+
+	vmctx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+		BlockNumber: big.NewInt(1),
+	}
+
+	printer := firehose.NewToBufferPrinter(1024)
+	vmenv := NewEVM(vmctx, TxContext{}, statedb, &berlin, Config{}, firehose.NewContext(printer, true))
+	statedb.PrepareAccessList(common.Address{}, &contract, vmenv.ActivePrecompiles(), nil)
+
+	_, gasLeft, err := vmenv.Call(AccountRef(common.Address{}), contract, nil, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+
+	// The gas changes of the call are contiguous and add up to its gas used
+	reasons := map[string]bool{}
+	gas := uint64(100000)
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		if fields := strings.Split(line, " "); len(fields) > 5 && fields[1] == "GAS_CHANGE" {
+			reasons[fields[5]] = true
+			if fields[2] == "1" {
+				if fields[3] != fmt.Sprint(gas) {
+					t.Fatalf("gas change %q not starting at the gas left %d", line, gas)
+				}
+				gas, _ = strconv.ParseUint(fields[4], 10, 64)
+			}
+		}
+	}
+	if gas != gasLeft {
+		t.Fatalf("gas changes end with %d gas left, the call with %d", gas, gasLeft)
+	}
+
+	expected := []string{
+		"op_code", "memory_expansion", "exp", "keccak", "state_cold_access", "storage_read", "storage_write", "balance",
+		"ext_code_size", "ext_code_hash", "ext_code_copy", "event_log", "call", "refund_after_execution",
+	}
+	for _, reason := range expected {
+		if !reasons[reason] {
+			t.Errorf("missing gas change reason %q", reason)
+		}
+		delete(reasons, reason)
+	}
+	if len(reasons) != 0 {
+		t.Errorf("unexpected gas change reasons %v", reasons)
+	}
+}
+
+func TestCallRecordsCallFailures(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	tests := []struct {
+		name        string
+		code        []byte
+		value       int64
+		depth       int
+		wantFailure string
+		wantErr     string
+	}{
+		{"out of gas", []byte{byte(JUMPDEST), byte(PUSH1), 0, byte(JUMP)}, 0, 0, "out_of_gas", ErrOutOfGas.Error()},
+		{"stack underflow", []byte{byte(ADD)}, 0, 0, "stack_underflow", "stack underflow (0 <=> 2)"},
+		{"invalid opcode", []byte{0xfe}, 0, 0, "invalid_opcode", "invalid opcode: opcode 0xfe not defined"},
+		{"invalid jump", []byte{byte(PUSH1), 5, byte(JUMP)}, 0, 0, "invalid_jump", ErrInvalidJump.Error()},
+		{"revert", []byte{byte(PUSH1), 0, byte(PUSH1), 0, byte(REVERT)}, 0, 0, "revert", ErrExecutionReverted.Error()},
+		{"insufficient balance", nil, 1, 0, "insufficient_balance", ErrInsufficientBalance.Error()},
+		{"depth limit", nil, 0, int(params.CallCreateDepth) + 1, "depth_limit", ErrDepth.Error()},
+	}
+
+	for _, test := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		contract := common.HexToAddress("0xc0")
+		statedb.SetCode(contract, test.code, firehose.NoOpContext)
+
+		vmctx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return test.value == 0 },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+			BlockNumber: big.NewInt(1),
+		}
+
+		printer := firehose.NewToBufferPrinter(1024)
+		vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{}, firehose.NewContext(printer, true))
+		vmenv.depth = test.depth
+
+		_, gasLeft, err := vmenv.Call(AccountRef(common.Address{}), contract, nil, 100000, big.NewInt(test.value))
+		if err == nil || err.Error() != test.wantErr {
+			t.Fatalf("%s: expected error %q, got %v", test.name, test.wantErr, err)
+		}
+
+		var failed, ended []string
+		for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+			if strings.HasPrefix(line, "FIRE EVM_CALL_FAILED ") {
+				failed = strings.Split(line, " ")
+			}
+			if strings.HasPrefix(line, "FIRE EVM_END_CALL ") {
+				ended = strings.Split(line, " ")
+			}
+		}
+		if len(failed) != 6 || failed[4] != test.wantFailure || failed[5] != firehose.Text(test.wantErr) {
+			t.Fatalf("%s: unexpected failure record %q", test.name, failed)
+		}
+		if len(ended) == 0 || ended[3] != fmt.Sprint(gasLeft) {
+			t.Fatalf("%s: expected call ended with %d gas left, got %q", test.name, gasLeft, ended)
+		}
+		if test.code == nil && gasLeft != 100000 {
+			t.Fatalf("%s: expected no gas consumed, got %d gas left", test.name, gasLeft)
+		}
+	}
+}
+
+func TestFirehoseCallFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want firehose.CallFailure
+	}{
+		{ErrInvalidSubroutineEntry, "invalid_subroutine_entry"},
+		{ErrOutOfGas, "out_of_gas"},
+		{ErrCodeStoreOutOfGas, "out_of_gas"},
+		{ErrDepth, "depth_limit"},
+		{ErrInsufficientBalance, "insufficient_balance"},
+		{ErrContractAddressCollision, "contract_address_collision"},
+		{ErrExecutionReverted, "revert"},
+		{ErrMaxCodeSizeExceeded, "max_code_size_exceeded"},
+		{ErrInvalidJump, "invalid_jump"},
+		{ErrWriteProtection, "write_protection"},
+		{ErrReturnDataOutOfBounds, "return_data_out_of_bounds"},
+		{ErrGasUintOverflow, "out_of_gas"},
+		{ErrInvalidRetsub, "invalid_retsub"},
+		{ErrReturnStackExceeded, "return_stack_exceeded"},
+		{&ErrStackUnderflow{}, "stack_underflow"},
+		{&ErrStackOverflow{}, "stack_overflow"},
+		{&ErrInvalidOpCode{}, "invalid_opcode"},
+		{errors.New("other"), firehose.UnknownCallFailure},
+	}
+
+	for _, test := range tests {
+		if got := firehoseCallFailure(test.err); got != test.want {
+			t.Fatalf("%q: expected failure %q, got %q", test.err, test.want, got)
+		}
+	}
+}
+
+func TestCallRecordsNestedReturnData(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	caller, callee := common.HexToAddress("0xc0"), common.HexToAddress("0xc1")
+
+	// CALL the callee with a 1 byte out buffer and RETURN that buffer
+	statedb.SetCode(caller, []byte{
+		byte(PUSH1), 1, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0xc1, byte(GAS), byte(CALL),
+		byte(PUSH1), 1, byte(PUSH1), 0, byte(RETURN),
+	}, firehose.NoOpContext)
+	// RETURN a 32 bytes word
+	statedb.SetCode(callee, []byte{
+		byte(PUSH1), 0x2a, byte(PUSH1), 0, byte(MSTORE), byte(PUSH1), 32, byte(PUSH1), 0, byte(RETURN),
+	}, firehose.NoOpContext)
+
+	vmctx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+		BlockNumber: big.NewInt(1),
+	}
+
+	printer := firehose.NewToBufferPrinter(1024)
+	vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{}, firehose.NewContext(printer, true))
+
+	ret, _, err := vmenv.Call(AccountRef(common.Address{}), caller, nil, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+
+	returned := map[string]string{}
+	for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+		if strings.HasPrefix(line, "FIRE EVM_END_CALL ") {
+			fields := strings.Split(line, " ")
+			returned[fields[2]] = fields[4]
+		}
+	}
+	if want := fmt.Sprintf("%x", common.LeftPadBytes([]byte{0x2a}, 32)); returned["2"] != want {
+		t.Fatalf("expected nested call to record the whole payload %s, got %s", want, returned["2"])
+	}
+	if want := fmt.Sprintf("%x", ret); want != "00" || returned["1"] != want {
+		t.Fatalf("expected top level call to record %s, got %s", want, returned["1"])
+	}
+}
+
+func TestCallRecordsGasBreakdown(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	tests := []struct {
+		name   string
+		gas    []byte
+		capped bool
+	}{
+		{"requested gas", []byte{byte(PUSH2), 0x03, 0xe8}, false},
+		{"63/64 retention", append([]byte{byte(PUSH32)}, bytes.Repeat([]byte{0xff}, 32)...), true},
+	}
+
+	for _, test := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		caller, callee := common.HexToAddress("0xc0"), common.HexToAddress("0xc1")
+
+		// CALL the callee with a value of 1 and the test's gas
+		code := []byte{byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 1, byte(PUSH1), 0xc1}
+		statedb.SetCode(caller, append(append(code, test.gas...), byte(CALL), byte(STOP)), firehose.NoOpContext)
+		// PUSH1 0 POP STOP, using 5 gas
+		statedb.SetCode(callee, []byte{byte(PUSH1), 0, byte(POP), byte(STOP)}, firehose.NoOpContext)
+
+		vmctx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+			BlockNumber: big.NewInt(1),
+		}
+
+		printer := firehose.NewToBufferPrinter(1024)
+		vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{}, firehose.NewContext(printer, true))
+
+		if _, _, err := vmenv.Call(AccountRef(common.Address{}), caller, nil, 100000, big.NewInt(1)); err != nil {
+			t.Fatalf("%s: call failed: %v", test.name, err)
+		}
+
+		callParams, ends := map[string][]uint64{}, map[string][]uint64{}
+		for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+			fields := strings.Split(line, " ")
+			switch {
+			case strings.HasPrefix(line, "FIRE EVM_PARAM "):
+				callParams[fields[3]] = []uint64{parseUint64(t, fields[7]), parseUint64(t, fields[10])}
+			case strings.HasPrefix(line, "FIRE EVM_END_CALL "):
+				ends[fields[2]] = []uint64{parseUint64(t, fields[3]), parseUint64(t, fields[7]), parseUint64(t, fields[8])}
+			}
+		}
+
+		// The top level call has no stipend, the nested one transferring value has
+		if callParams["1"][1] != 0 || callParams["2"][1] != params.CallStipend {
+			t.Fatalf("%s: unexpected stipends %d and %d", test.name, callParams["1"][1], callParams["2"][1])
+		}
+		if !test.capped && callParams["2"][0] != 1000+params.CallStipend {
+			t.Fatalf("%s: expected nested call gas limit of %d, got %d", test.name, 1000+params.CallStipend, callParams["2"][0])
+		}
+		if ends["2"][1] != 5 || ends["2"][2] != 0 {
+			t.Fatalf("%s: expected nested call to use 5 gas itself, got %d and %d by children", test.name, ends["2"][1], ends["2"][2])
+		}
+
+		// The nested call used 5 gas out of its stipend, the top level call paying none of it
+		childGasUsed := callParams["2"][0] - ends["2"][0]
+		if ends["1"][2] != 0 || ends["1"][1] != 100000-ends["1"][0]+params.CallStipend-childGasUsed {
+			t.Fatalf("%s: unexpected top level call gas breakdown %v", test.name, ends["1"])
+		}
+		if test.capped {
+			// The gas available to the caller at the CALL once its cost paid, all but one 64th of it being provided
+			provided := callParams["2"][0] - params.CallStipend
+			available := ends["1"][0] - ends["2"][0] + provided
+			if provided != available-available/64 {
+				t.Fatalf("%s: expected %d gas provided out of %d available, got %d", test.name, available-available/64, available, provided)
+			}
+		}
+	}
+}
+
+func TestCallRecordsAccesses(t *testing.T) {
+	defer func(enabled, accesses bool) {
+		firehose.Enabled, firehose.AccessesEnabled = enabled, accesses
+	}(firehose.Enabled, firehose.AccessesEnabled)
+	firehose.Enabled, firehose.AccessesEnabled = true, true
+
+	preBerlin := *params.AllEthashProtocolChanges
+	preBerlin.BerlinBlock = nil
+
+	tests := []struct {
+		name     string
+		config   *params.ChainConfig
+		expected []string
+	}{
+		{"berlin", params.AllEthashProtocolChanges, []string{
+			"STORAGE_ACCESS 1 00000000000000000000000000000000000000c0 " + fmt.Sprintf("%x", common.HexToHash("0x01")) + " true",
+			"STORAGE_ACCESS 1 00000000000000000000000000000000000000c0 " + fmt.Sprintf("%x", common.HexToHash("0x01")) + " false",
+			"ACCOUNT_ACCESS 1 00000000000000000000000000000000000000c1 true",
+			"ACCOUNT_ACCESS 1 00000000000000000000000000000000000000c1 false",
+		}},
+		{"pre-berlin", &preBerlin, nil},
+	}
+
+	for _, test := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		contract := common.HexToAddress("0xc0")
+		// SLOAD the slot 1 twice then BALANCE the account 0xc1 twice
+		statedb.SetCode(contract, []byte{
+			byte(PUSH1), 1, byte(SLOAD), byte(PUSH1), 1, byte(SLOAD),
+			byte(PUSH1), 0xc1, byte(BALANCE), byte(PUSH1), 0xc1, byte(BALANCE), byte(STOP),
+		}, firehose.NoOpContext)
+
+		vmctx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+			BlockNumber: big.NewInt(1),
+		}
+
+		printer := firehose.NewToBufferPrinter(1024)
+		vmenv := NewEVM(vmctx, TxContext{}, statedb, test.config, Config{}, firehose.NewContext(printer, true))
+
+		if _, _, err := vmenv.Call(AccountRef(common.Address{}), contract, nil, 100000, new(big.Int)); err != nil {
+			t.Fatalf("%s: call failed: %v", test.name, err)
+		}
+
+		var accesses []string
+		for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+			if strings.HasPrefix(line, "FIRE ACCOUNT_ACCESS ") || strings.HasPrefix(line, "FIRE STORAGE_ACCESS ") {
+				// Ordinal excluded
+				accesses = append(accesses, line[len("FIRE "):strings.LastIndex(line, " ")])
+			}
+		}
+		if strings.Join(accesses, "\n") != strings.Join(test.expected, "\n") {
+			t.Fatalf("%s: got accesses %q, expected %q", test.name, accesses, test.expected)
+		}
+	}
+}
+
+func TestCallRecordsDeepTrace(t *testing.T) {
+	defer func(enabled bool, addresses map[common.Address]struct{}, maxRecords int) {
+		firehose.Enabled, firehose.DeepTraceAddresses, firehose.DeepTraceMaxRecords = enabled, addresses, maxRecords
+	}(firehose.Enabled, firehose.DeepTraceAddresses, firehose.DeepTraceMaxRecords)
+	firehose.Enabled, firehose.DeepTraceMaxRecords = true, 4
+
+	contract := common.HexToAddress("0xc0")
+	tests := []struct {
+		name      string
+		addresses map[common.Address]struct{}
+		expected  []string
+	}{
+		{"traced", map[common.Address]struct{}{contract: {}}, []string{
+			"EVM_OPCODE 1 0 PUSH1 100000 3  0 0",
+			"EVM_OPCODE 1 2 PUSH1 99997 3 2a 0 0",
+			"EVM_OPCODE 1 4 MSTORE 99994 12 40,2a 64 32",
+			"EVM_OPCODE 1 5 PUSH1 99982 3  0 0",
+		}},
+		{"not traced", map[common.Address]struct{}{common.HexToAddress("0xc1"): {}}, nil},
+		{"empty", nil, nil},
+	}
+
+	for _, test := range tests {
+		firehose.DeepTraceAddresses = test.addresses
+
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		// MSTORE 0x2a at 0x40 then RETURN the word, the records are capped before the RETURN
+		statedb.SetCode(contract, []byte{
+			byte(PUSH1), 0x2a, byte(PUSH1), 0x40, byte(MSTORE),
+			byte(PUSH1), 0x20, byte(PUSH1), 0x40, byte(RETURN),
+		}, firehose.NoOpContext)
+
+		vmctx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+			BlockNumber: big.NewInt(1),
+		}
+
+		printer := firehose.NewToBufferPrinter(1024)
+		vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{}, firehose.NewContext(printer, true))
+
+		if _, _, err := vmenv.Call(AccountRef(common.Address{}), contract, nil, 100000, new(big.Int)); err != nil {
+			t.Fatalf("%s: call failed: %v", test.name, err)
+		}
+
+		var opcodes []string
+		for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+			if strings.HasPrefix(line, "FIRE EVM_OPCODE ") {
+				// Ordinal excluded
+				opcodes = append(opcodes, line[len("FIRE "):strings.LastIndex(line, " ")])
+			}
+		}
+		if strings.Join(opcodes, "\n") != strings.Join(test.expected, "\n") {
+			t.Fatalf("%s: got opcodes %q, expected %q", test.name, opcodes, test.expected)
+		}
+	}
+}
+
+func parseUint64(t *testing.T, in string) uint64 {
+	value, err := strconv.ParseUint(in, 10, 64)
+	if err != nil {
+		t.Fatalf("invalid number %q: %v", in, err)
+	}
+	return value
+}
+
+func TestCreate2RecordsFailedAddress(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	// PUSH1 0 PUSH1 0 REVERT
+	initCode := []byte{byte(PUSH1), 0, byte(PUSH1), 0, byte(REVERT)}
+	salt := uint256.NewInt().SetUint64(1)
+	address := crypto.CreateAddress2(common.Address{}, salt.Bytes32(), crypto.Keccak256(initCode))
+
+	tests := []struct {
+		name        string
+		prepare     func(statedb *state.StateDB)
+		wantErr     error
+		wantExists  bool
+		wantFailure string
+	}{
+		{"reverted", func(*state.StateDB) {}, ErrExecutionReverted, false, "revert"},
+		{"funded beforehand", func(statedb *state.StateDB) {
+			statedb.AddBalance(address, big.NewInt(1), false, firehose.NoOpContext, firehose.IgnoredBalanceChangeReason)
+		}, ErrExecutionReverted, true, "revert"},
+		{"collision", func(statedb *state.StateDB) {
+			statedb.SetCode(address, []byte{byte(STOP)}, firehose.NoOpContext)
+		}, ErrContractAddressCollision, true, "contract_address_collision"},
+	}
+
+	for _, test := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		test.prepare(statedb)
+
+		vmctx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int, *firehose.Context) {},
+			BlockNumber: big.NewInt(1),
+		}
+
+		printer := firehose.NewToBufferPrinter(1024)
+		vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{}, firehose.NewContext(printer, true))
+
+		if _, _, _, err := vmenv.Create2(AccountRef(common.Address{}), initCode, 100000, new(big.Int), salt); err != test.wantErr {
+			t.Fatalf("%s: expected error %v, got %v", test.name, test.wantErr, err)
+		}
+
+		got := printer.Buffer().String()
+		if want := fmt.Sprintf("FIRE EVM_CREATE_FAILED 1 %x %t\n", address, test.wantExists); !strings.Contains(got, want) {
+			t.Fatalf("%s: missing %q in %q", test.name, want, got)
+		}
+
+		failed := false
+		for _, line := range strings.Split(got, "\n") {
+			if strings.HasPrefix(line, "FIRE EVM_CALL_FAILED 1 ") {
+				failed = strings.Split(line, " ")[4] == test.wantFailure
+			}
+		}
+		if !failed {
+			t.Fatalf("%s: missing %s call failure in %q", test.name, test.wantFailure, got)
+		}
+	}
+}
@@ -83,7 +83,7 @@ func TestEIP2200(t *testing.T) {
 		address := common.BytesToAddress([]byte("contract"))
 
 		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
-		statedb.CreateAccount(address, firehose.NoOpContext)
+		statedb.CreateAccount(address, firehose.NoOpContext, firehose.IgnoredAccountCreationReason)
 		statedb.SetCode(address, hexutil.MustDecode(tt.input), firehose.NoOpContext)
 		statedb.SetState(address, common.Hash{}, common.BytesToHash([]byte{tt.original}), firehose.NoOpContext)
 		statedb.Finalise(true) // Push the state into the "original" slot
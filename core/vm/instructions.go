@@ -264,6 +264,7 @@ func opAddress(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([
 func opBalance(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
 	slot := callContext.stack.peek()
 	address := common.Address(slot.Bytes20())
+	recordTouchedAccount(interpreter, address)
 	slot.SetFromBig(interpreter.evm.StateDB.GetBalance(address))
 	return nil, nil
 }
@@ -346,6 +347,7 @@ func opReturnDataCopy(pc *uint64, interpreter *EVMInterpreter, callContext *call
 
 func opExtCodeSize(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
 	slot := callContext.stack.peek()
+	recordTouchedAccount(interpreter, slot.Bytes20())
 	slot.SetUint64(uint64(interpreter.evm.StateDB.GetCodeSize(slot.Bytes20())))
 	return nil, nil
 }
@@ -386,6 +388,7 @@ func opExtCodeCopy(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx
 		uint64CodeOffset = 0xffffffffffffffff
 	}
 	addr := common.Address(a.Bytes20())
+	recordTouchedAccount(interpreter, addr)
 	codeCopy := getData(interpreter.evm.StateDB.GetCode(addr), uint64CodeOffset, length.Uint64())
 	callContext.memory.Set(memOffset.Uint64(), length.Uint64(), codeCopy)
 
@@ -421,6 +424,7 @@ func opExtCodeCopy(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx
 func opExtCodeHash(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
 	slot := callContext.stack.peek()
 	address := common.Address(slot.Bytes20())
+	recordTouchedAccount(interpreter, address)
 	if interpreter.evm.StateDB.Empty(address) {
 		slot.Clear()
 	} else {
@@ -514,6 +518,9 @@ func opSload(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]b
 	loc := callContext.stack.peek()
 	hash := common.Hash(loc.Bytes32())
 	val := interpreter.evm.StateDB.GetState(callContext.contract.Address(), hash)
+	if firehose.StorageReadsEnabled && interpreter.evm.firehoseContext.Enabled() {
+		interpreter.evm.firehoseContext.RecordStorageRead(callContext.contract.Address(), hash, val)
+	}
 	loc.SetBytes(val.Bytes())
 	return nil, nil
 }
@@ -663,6 +670,7 @@ func opCall(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]by
 	// Pop other call parameters.
 	addr, value, inOffset, inSize, retOffset, retSize := stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop()
 	toAddr := common.Address(addr.Bytes20())
+	recordTouchedAccount(interpreter, toAddr)
 	// Get the arguments from the memory.
 	args := callContext.memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 
@@ -704,6 +712,7 @@ func opCallCode(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) (
 	// Pop other call parameters.
 	addr, value, inOffset, inSize, retOffset, retSize := stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop()
 	toAddr := common.Address(addr.Bytes20())
+	recordTouchedAccount(interpreter, toAddr)
 	// Get arguments from the memory.
 	args := callContext.memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 
@@ -742,6 +751,7 @@ func opDelegateCall(pc *uint64, interpreter *EVMInterpreter, callContext *callCt
 	// Pop other call parameters.
 	addr, inOffset, inSize, retOffset, retSize := stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop()
 	toAddr := common.Address(addr.Bytes20())
+	recordTouchedAccount(interpreter, toAddr)
 	// Get arguments from the memory.
 	args := callContext.memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 
@@ -773,6 +783,7 @@ func opStaticCall(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx)
 	// Pop other call parameters.
 	addr, inOffset, inSize, retOffset, retSize := stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop()
 	toAddr := common.Address(addr.Bytes20())
+	recordTouchedAccount(interpreter, toAddr)
 	// Get arguments from the memory.
 	args := callContext.memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 
@@ -815,12 +826,24 @@ func opStop(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]by
 
 func opSuicide(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
 	beneficiary := callContext.stack.pop()
+	recordTouchedAccount(interpreter, beneficiary.Bytes20())
 	balance := interpreter.evm.StateDB.GetBalance(callContext.contract.Address())
+	// The beneficiary's credit is a `suicide_refund` and the contract's debit, recorded by `Suicide`, a
+	// `suicide_withdraw`. When the beneficiary is the contract itself, it's first credited its own
+	// balance and then debited of twice its balance, the balance being burned.
 	interpreter.evm.StateDB.AddBalance(beneficiary.Bytes20(), balance, false, interpreter.evm.firehoseContext, firehose.BalanceChangeReason("suicide_refund"))
 	interpreter.evm.StateDB.Suicide(callContext.contract.Address(), interpreter.evm.firehoseContext)
 	return nil, nil
 }
 
+// recordTouchedAccount records the account accessed by the instruction when touched accounts
+// are recorded, see `firehose.TouchedAccountsEnabled`.
+func recordTouchedAccount(interpreter *EVMInterpreter, addr common.Address) {
+	if firehose.TouchedAccountsEnabled && interpreter.evm.firehoseContext.Enabled() {
+		interpreter.evm.firehoseContext.RecordTouchedAccount(addr)
+	}
+}
+
 // following functions are used by the instruction jump  table
 
 // make log instruction function
@@ -21,9 +21,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/params"
@@ -537,6 +540,110 @@ func TestOpMstore(t *testing.T) {
 	}
 }
 
+func TestOpSloadRecordsStorageRead(t *testing.T) {
+	defer func(enabled, storageReads bool) {
+		firehose.Enabled, firehose.StorageReadsEnabled = enabled, storageReads
+	}(firehose.Enabled, firehose.StorageReadsEnabled)
+
+	address := common.HexToAddress("0x02")
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetState(address, common.HexToHash("0x01"), common.HexToHash("0xaa"), firehose.NoOpContext)
+
+	for _, storageReads := range []bool{false, true} {
+		firehose.Enabled, firehose.StorageReadsEnabled = true, storageReads
+
+		var (
+			printer        = firehose.NewToBufferPrinter(1024)
+			env            = NewEVM(BlockContext{}, TxContext{}, statedb, params.TestChainConfig, Config{}, firehose.NewContext(printer, true))
+			stack          = newstack()
+			evmInterpreter = NewEVMInterpreter(env, env.vmConfig)
+			contract       = NewContract(AccountRef(address), AccountRef(address), new(big.Int), 0, firehose.NoOpContext)
+			pc             = uint64(0)
+		)
+
+		env.interpreter = evmInterpreter
+		stack.push(new(uint256.Int).SetUint64(1))
+		opSload(&pc, evmInterpreter, &callCtx{nil, stack, contract})
+
+		if got := stack.peek().Uint64(); got != 0xaa {
+			t.Fatalf("Sload fail, got %x, expected aa", got)
+		}
+
+		expected := ""
+		if storageReads {
+			expected = fmt.Sprintf("FIRE STORAGE_READ 0 %x %x %x 1\n", address, common.HexToHash("0x01"), common.HexToHash("0xaa"))
+		}
+		if got := printer.Buffer().String(); got != expected {
+			t.Fatalf("storage reads %t: got %q, expected %q", storageReads, got, expected)
+		}
+	}
+}
+
+func TestOpSuicideRecordsBalanceChanges(t *testing.T) {
+	defer func(enabled bool) { firehose.Enabled = enabled }(firehose.Enabled)
+	firehose.Enabled = true
+
+	contractAddress := common.HexToAddress("0x02")
+	otherAddress := common.HexToAddress("0x03")
+
+	tests := []struct {
+		name        string
+		beneficiary common.Address
+		expected    []string
+	}{
+		{
+			"other beneficiary",
+			otherAddress,
+			[]string{
+				fmt.Sprintf("BALANCE_CHANGE 0 %x 05 14 suicide_refund 1", otherAddress),
+				fmt.Sprintf("SUICIDE_CHANGE 0 %x false 0f", contractAddress),
+				fmt.Sprintf("BALANCE_CHANGE 0 %x 0f . suicide_withdraw 2", contractAddress),
+			},
+		},
+		{
+			"self beneficiary",
+			contractAddress,
+			[]string{
+				fmt.Sprintf("BALANCE_CHANGE 0 %x 0f 1e suicide_refund 1", contractAddress),
+				fmt.Sprintf("SUICIDE_CHANGE 0 %x false 1e", contractAddress),
+				fmt.Sprintf("BALANCE_CHANGE 0 %x 1e . suicide_withdraw 2", contractAddress),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+			statedb.AddBalance(contractAddress, big.NewInt(15), false, firehose.NoOpContext, firehose.IgnoredBalanceChangeReason)
+			statedb.AddBalance(otherAddress, big.NewInt(5), false, firehose.NoOpContext, firehose.IgnoredBalanceChangeReason)
+
+			var (
+				printer        = firehose.NewToBufferPrinter(1024)
+				env            = NewEVM(BlockContext{}, TxContext{}, statedb, params.TestChainConfig, Config{}, firehose.NewContext(printer, true))
+				stack          = newstack()
+				evmInterpreter = NewEVMInterpreter(env, env.vmConfig)
+				contract       = NewContract(AccountRef(otherAddress), AccountRef(contractAddress), new(big.Int), 0, firehose.NoOpContext)
+				pc             = uint64(0)
+			)
+
+			env.interpreter = evmInterpreter
+			stack.push(new(uint256.Int).SetBytes(test.beneficiary.Bytes()))
+			opSuicide(&pc, evmInterpreter, &callCtx{nil, stack, contract})
+
+			expected := ""
+			for _, line := range test.expected {
+				expected += "FIRE " + line + "\n"
+			}
+			if got := printer.Buffer().String(); got != expected {
+				t.Fatalf("got %q, expected %q", got, expected)
+			}
+			if balance := statedb.GetBalance(contractAddress); balance.Sign() != 0 {
+				t.Fatalf("contract balance is %s after selfdestruct, expected 0", balance)
+			}
+		})
+	}
+}
+
 func BenchmarkOpMstore(bench *testing.B) {
 	var (
 		env            = NewEVM(BlockContext{}, TxContext{}, nil, params.TestChainConfig, Config{}, firehose.NoOpContext)
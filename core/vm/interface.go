@@ -26,14 +26,14 @@ import (
 
 // StateDB is an EVM database for full state querying.
 type StateDB interface {
-	CreateAccount(common.Address, *firehose.Context)
+	CreateAccount(common.Address, *firehose.Context, firehose.AccountCreationReason)
 
 	SubBalance(common.Address, *big.Int, *firehose.Context, firehose.BalanceChangeReason)
 	AddBalance(common.Address, *big.Int, bool, *firehose.Context, firehose.BalanceChangeReason)
 	GetBalance(common.Address) *big.Int
 
 	GetNonce(common.Address) uint64
-	SetNonce(common.Address, uint64, *firehose.Context)
+	SetNonce(common.Address, uint64, *firehose.Context, firehose.NonceChangeReason)
 
 	GetCodeHash(common.Address) common.Hash
 	GetCode(common.Address) []byte
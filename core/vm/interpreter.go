@@ -199,6 +199,10 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			}
 		}()
 	}
+	// Deep traced contracts are looked up once per call, the loop only checks the outcome
+	deepTraced := in.evm.firehoseContext.Enabled() && contract.CodeAddr != nil && firehose.DeepTraced(*contract.CodeAddr)
+	deepTraceRecords := 0
+
 	// The Interpreter main run loop (contextual). This loop runs until either an
 	// explicit STOP, RETURN or SELFDESTRUCT is executed, an error occurred during
 	// the execution of one of the operations or until the done flag is set by the
@@ -240,8 +244,10 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 		}
 		// Static portion of gas
 		cost = operation.constantGas // For tracing
+		gasBefore := contract.Gas
+		in.evm.coldAccessGasTemp = 0
 
-		// Firehose we ignore constant cost because below, we perform a single GAS_CHANGE for both constant + dynamic to aggregate the 2 gas change events
+		// Firehose records the constant and dynamic costs in parts once both are charged, see `firehoseRecordOperationGas`
 		if !contract.UseGas(operation.constantGas, firehose.IgnoredGasChangeReason) {
 			return nil, ErrOutOfGas
 		}
@@ -254,22 +260,28 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 		if operation.memorySize != nil {
 			memSize, overflow := operation.memorySize(stack)
 			if overflow {
+				in.firehoseRecordOperationGas(op, contract, gasBefore, operation.constantGas, 0)
 				return nil, ErrGasUintOverflow
 			}
 			// memory is expanded in words of 32 bytes. Gas
 			// is also calculated in words.
 			if memorySize, overflow = math.SafeMul(toWordSize(memSize), 32); overflow {
+				in.firehoseRecordOperationGas(op, contract, gasBefore, operation.constantGas, 0)
 				return nil, ErrGasUintOverflow
 			}
 		}
 		// Dynamic portion of gas
 		// consume the gas and return an error if not enough gas is available.
 		// cost is explicitly set so that the capture state defer method can get the proper cost
+		var memoryExpansionCost uint64
 		if operation.dynamicGas != nil {
 			var dynamicCost uint64
+			memoryGasCost := mem.lastGasCost
 			dynamicCost, err = operation.dynamicGas(in.evm, contract, stack, mem, memorySize)
+			memoryExpansionCost = mem.lastGasCost - memoryGasCost
 			cost += dynamicCost // total cost, for debug tracing
 			if err != nil || !contract.UseGas(dynamicCost, firehose.IgnoredGasChangeReason) {
+				in.firehoseRecordOperationGas(op, contract, gasBefore, operation.constantGas, 0)
 				return nil, ErrOutOfGas
 			}
 		}
@@ -277,20 +289,12 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			mem.Resize(memorySize)
 		}
 
-		if in.evm.firehoseContext.Enabled() {
-			if cost != 0 {
-				gasChangeReason := OpCodeToGasChangeReason(op)
-				if gasChangeReason != firehose.IgnoredGasChangeReason {
-					// When execution reach this point, `contract.UseGas` has been called once
-					// (for only a static) or twice (for both static + dynamic cost). Since it
-					// has been called, it's mean the `c.Gas` has already been adjusted down
-					// to remaining after cost.
-					//
-					// Hence to retrieve the `gasOld` value, we need to come back at state when
-					// gas was not consumed, which means doing `contract.Gas + cost`.
-					in.evm.firehoseContext.RecordGasConsume(contract.Gas+cost, cost, gasChangeReason)
-				}
-			}
+		in.firehoseRecordOperationGas(op, contract, gasBefore, operation.constantGas, memoryExpansionCost)
+
+		if deepTraced && deepTraceRecords < firehose.DeepTraceMaxRecords {
+			deepTraceRecords++
+			rangeOffset, rangeSize := firehoseMemoryRange(op, stack)
+			in.evm.firehoseContext.RecordOpcode(pc, op.String(), gasBefore, gasBefore-contract.Gas, stack.firehoseTop(firehose.DeepTraceStackItems), rangeOffset, rangeSize)
 		}
 
 		if in.cfg.Debug {
@@ -325,3 +329,35 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 func (in *EVMInterpreter) CanRun(code []byte) bool {
 	return true
 }
+
+// firehoseRecordOperationGas records the gas consumed by the operation since `gasBefore`, also
+// when charging its dynamic cost failed, in parts: the cold access surcharge, the memory
+// expansion and the operation's own cost. The memory expansion and the cost of the op codes
+// without a reason of their own are frequent and small, they are summed per call.
+func (in *EVMInterpreter) firehoseRecordOperationGas(op OpCode, contract *Contract, gasBefore, constantGas, memoryExpansionCost uint64) {
+	consumed := gasBefore - contract.Gas
+	if !in.evm.firehoseContext.Enabled() || consumed == 0 {
+		return
+	}
+
+	// The surcharge of a call is charged before the rest of its dynamic cost, it's only part
+	// of the consumed gas if it could be paid
+	coldAccessCost := in.evm.coldAccessGasTemp
+	if coldAccessCost > consumed-constantGas {
+		coldAccessCost = consumed - constantGas
+	}
+	operationCost := consumed - coldAccessCost - memoryExpansionCost
+
+	gasOld := gasBefore
+	in.evm.firehoseContext.RecordGasConsume(gasOld, coldAccessCost, firehose.ColdAccessGasChangeReason)
+	gasOld -= coldAccessCost
+
+	in.evm.firehoseContext.AccumulateGasConsume(gasOld, memoryExpansionCost, firehose.MemoryExpansionGasChangeReason)
+	gasOld -= memoryExpansionCost
+
+	if reason := OpCodeToGasChangeReason(op); reason == firehose.OpCodeGasChangeReason {
+		in.evm.firehoseContext.AccumulateGasConsume(gasOld, operationCost, reason)
+	} else {
+		in.evm.firehoseContext.RecordGasConsume(gasOld, operationCost, reason)
+	}
+}
@@ -16,6 +16,8 @@
 
 package vm
 
+import "github.com/holiman/uint256"
+
 func memorySha3(stack *Stack) (uint64, bool) {
 	return calcMemSize64(stack.Back(0), stack.Back(1))
 }
@@ -111,3 +113,53 @@ func memoryRevert(stack *Stack) (uint64, bool) {
 func memoryLog(stack *Stack) (uint64, bool) {
 	return calcMemSize64(stack.Back(0), stack.Back(1))
 }
+
+// firehoseMemoryRange returns the memory range read or written by the operation, for Firehose
+// deep traces. For calls, it spans the input and output ranges. The memory size of the
+// operation having been checked, an offset with a non-zero size fits in an uint64.
+func firehoseMemoryRange(op OpCode, stack *Stack) (offset, size uint64) {
+	switch op {
+	case SHA3, RETURN, REVERT, LOG0, LOG1, LOG2, LOG3, LOG4:
+		return firehoseMemorySpan(stack.Back(0), stack.Back(1))
+	case CALLDATACOPY, CODECOPY, RETURNDATACOPY:
+		return firehoseMemorySpan(stack.Back(0), stack.Back(2))
+	case EXTCODECOPY:
+		return firehoseMemorySpan(stack.Back(1), stack.Back(3))
+	case MLOAD, MSTORE:
+		return stack.Back(0).Uint64(), 32
+	case MSTORE8:
+		return stack.Back(0).Uint64(), 1
+	case CREATE, CREATE2:
+		return firehoseMemorySpan(stack.Back(1), stack.Back(2))
+	case CALL, CALLCODE:
+		return firehoseMemorySpan(stack.Back(3), stack.Back(4), stack.Back(5), stack.Back(6))
+	case DELEGATECALL, STATICCALL:
+		return firehoseMemorySpan(stack.Back(2), stack.Back(3), stack.Back(4), stack.Back(5))
+	}
+
+	return 0, 0
+}
+
+// firehoseMemorySpan returns the range spanning the non-empty ones of the offset and size pairs.
+func firehoseMemorySpan(offsetsAndSizes ...*uint256.Int) (offset, size uint64) {
+	var end uint64
+	for i := 0; i < len(offsetsAndSizes); i += 2 {
+		if offsetsAndSizes[i+1].IsZero() {
+			continue
+		}
+
+		rangeOffset, rangeSize := offsetsAndSizes[i].Uint64(), offsetsAndSizes[i+1].Uint64()
+		if end == 0 || rangeOffset < offset {
+			offset = rangeOffset
+		}
+		if rangeOffset+rangeSize > end {
+			end = rangeOffset + rangeSize
+		}
+	}
+
+	if end == 0 {
+		return 0, 0
+	}
+
+	return offset, end - offset
+}
@@ -567,14 +567,23 @@ var opCodeToGasChangeReasonMap = map[OpCode]firehose.GasChangeReason{
 	CODECOPY:       firehose.GasChangeReason("code_copy"),
 	EXTCODECOPY:    firehose.GasChangeReason("ext_code_copy"),
 	RETURNDATACOPY: firehose.GasChangeReason("return_data_copy"),
+	EXP:            firehose.GasChangeReason("exp"),
+	SHA3:           firehose.GasChangeReason("keccak"),
+	SLOAD:          firehose.GasChangeReason("storage_read"),
+	SSTORE:         firehose.GasChangeReason("storage_write"),
+	BALANCE:        firehose.GasChangeReason("balance"),
+	EXTCODESIZE:    firehose.GasChangeReason("ext_code_size"),
+	EXTCODEHASH:    firehose.GasChangeReason("ext_code_hash"),
 }
 
-// We only track a few high costs op code that gives a rough idea where gas is spent
+// OpCodeToGasChangeReason is the reason of the op code's own cost, op codes having a dynamic
+// or a high constant cost have a reason of their own, the others share `OpCodeGasChangeReason`.
+// The cold access surcharge and the memory expansion of any op code are recorded apart.
 func OpCodeToGasChangeReason(op OpCode) firehose.GasChangeReason {
 	reason, found := opCodeToGasChangeReasonMap[op]
 	if found {
 		return reason
 	}
 
-	return firehose.IgnoredGasChangeReason
+	return firehose.OpCodeGasChangeReason
 }
@@ -56,8 +56,11 @@ func gasSStoreEIP2929(evm *EVM, contract *Contract, stack *Stack, mem *Memory, m
 		cost    = uint64(0)
 	)
 	// Check slot presence in the access list
-	if addrPresent, slotPresent := evm.StateDB.SlotInAccessList(contract.Address(), slot); !slotPresent {
+	addrPresent, slotPresent := evm.StateDB.SlotInAccessList(contract.Address(), slot)
+	recordStorageAccess(evm, contract.Address(), slot, !slotPresent)
+	if !slotPresent {
 		cost = ColdSloadCostEIP2929
+		evm.coldAccessGasTemp = cost
 		// If the caller cannot afford the cost, this change will be rolled back
 		evm.StateDB.AddSlotToAccessList(contract.Address(), slot)
 		if !addrPresent {
@@ -121,10 +124,13 @@ func gasSLoadEIP2929(evm *EVM, contract *Contract, stack *Stack, mem *Memory, me
 	loc := stack.peek()
 	slot := common.Hash(loc.Bytes32())
 	// Check slot presence in the access list
-	if _, slotPresent := evm.StateDB.SlotInAccessList(contract.Address(), slot); !slotPresent {
+	_, slotPresent := evm.StateDB.SlotInAccessList(contract.Address(), slot)
+	recordStorageAccess(evm, contract.Address(), slot, !slotPresent)
+	if !slotPresent {
 		// If the caller cannot afford the cost, this change will be rolled back
 		// If he does afford it, we can skip checking the same thing later on, during execution
 		evm.StateDB.AddSlotToAccessList(contract.Address(), slot)
+		evm.coldAccessGasTemp = ColdSloadCostEIP2929 - WarmStorageReadCostEIP2929
 		return ColdSloadCostEIP2929, nil
 	}
 	return WarmStorageReadCostEIP2929, nil
@@ -143,13 +149,16 @@ func gasExtCodeCopyEIP2929(evm *EVM, contract *Contract, stack *Stack, mem *Memo
 	}
 	addr := common.Address(stack.peek().Bytes20())
 	// Check slot presence in the access list
-	if !evm.StateDB.AddressInAccessList(addr) {
+	addrPresent := evm.StateDB.AddressInAccessList(addr)
+	recordAccountAccess(evm, addr, !addrPresent)
+	if !addrPresent {
 		evm.StateDB.AddAddressToAccessList(addr)
 		var overflow bool
 		// We charge (cold-warm), since 'warm' is already charged as constantGas
 		if gas, overflow = math.SafeAdd(gas, ColdAccountAccessCostEIP2929-WarmStorageReadCostEIP2929); overflow {
 			return 0, ErrGasUintOverflow
 		}
+		evm.coldAccessGasTemp = ColdAccountAccessCostEIP2929 - WarmStorageReadCostEIP2929
 		return gas, nil
 	}
 	return gas, nil
@@ -165,11 +174,14 @@ func gasExtCodeCopyEIP2929(evm *EVM, contract *Contract, stack *Stack, mem *Memo
 func gasEip2929AccountCheck(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
 	addr := common.Address(stack.peek().Bytes20())
 	// Check slot presence in the access list
-	if !evm.StateDB.AddressInAccessList(addr) {
+	addrPresent := evm.StateDB.AddressInAccessList(addr)
+	recordAccountAccess(evm, addr, !addrPresent)
+	if !addrPresent {
 		// If the caller cannot afford the cost, this change will be rolled back
 		evm.StateDB.AddAddressToAccessList(addr)
 		// The warm storage read cost is already charged as constantGas
-		return ColdAccountAccessCostEIP2929 - WarmStorageReadCostEIP2929, nil
+		evm.coldAccessGasTemp = ColdAccountAccessCostEIP2929 - WarmStorageReadCostEIP2929
+		return evm.coldAccessGasTemp, nil
 	}
 	return 0, nil
 }
@@ -178,10 +190,14 @@ func makeCallVariantGasCallEIP2929(oldCalculator gasFunc) gasFunc {
 	return func(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
 		addr := common.Address(stack.Back(1).Bytes20())
 		// Check slot presence in the access list
-		if !evm.StateDB.AddressInAccessList(addr) {
+		addrPresent := evm.StateDB.AddressInAccessList(addr)
+		recordAccountAccess(evm, addr, !addrPresent)
+		if !addrPresent {
 			evm.StateDB.AddAddressToAccessList(addr)
-			// The WarmStorageReadCostEIP2929 (100) is already deducted in the form of a constant cost
-			if !contract.UseGas(ColdAccountAccessCostEIP2929-WarmStorageReadCostEIP2929, firehose.GasChangeReason("state_cold_access")) {
+			// The WarmStorageReadCostEIP2929 (100) is already deducted in the form of a constant cost,
+			// Firehose records the surcharge along the operation's cost
+			evm.coldAccessGasTemp = ColdAccountAccessCostEIP2929 - WarmStorageReadCostEIP2929
+			if !contract.UseGas(evm.coldAccessGasTemp, firehose.IgnoredGasChangeReason) {
 				return 0, ErrOutOfGas
 			}
 		}
@@ -206,10 +222,13 @@ func gasSelfdestructEIP2929(evm *EVM, contract *Contract, stack *Stack, mem *Mem
 		gas     uint64
 		address = common.Address(stack.peek().Bytes20())
 	)
-	if !evm.StateDB.AddressInAccessList(address) {
+	addrPresent := evm.StateDB.AddressInAccessList(address)
+	recordAccountAccess(evm, address, !addrPresent)
+	if !addrPresent {
 		// If the caller cannot afford the cost, this change will be rolled back
 		evm.StateDB.AddAddressToAccessList(address)
 		gas = ColdAccountAccessCostEIP2929
+		evm.coldAccessGasTemp = gas
 	}
 	// if empty and transfers value
 	if evm.StateDB.Empty(address) && evm.StateDB.GetBalance(contract.Address()).Sign() != 0 {
@@ -221,3 +240,19 @@ func gasSelfdestructEIP2929(evm *EVM, contract *Contract, stack *Stack, mem *Mem
 	return gas, nil
 
 }
+
+// recordAccountAccess records the access to the account checked against the access list when
+// accesses are recorded, see `firehose.AccessesEnabled`.
+func recordAccountAccess(evm *EVM, addr common.Address, cold bool) {
+	if firehose.AccessesEnabled && evm.firehoseContext.Enabled() {
+		evm.firehoseContext.RecordAccountAccess(addr, cold)
+	}
+}
+
+// recordStorageAccess records the access to the storage slot checked against the access list
+// when accesses are recorded, see `firehose.AccessesEnabled`.
+func recordStorageAccess(evm *EVM, addr common.Address, slot common.Hash, cold bool) {
+	if firehose.AccessesEnabled && evm.firehoseContext.Enabled() {
+		evm.firehoseContext.RecordStorageAccess(addr, slot, cold)
+	}
+}
@@ -118,7 +118,7 @@ func Execute(code, input []byte, cfg *Config) ([]byte, *state.StateDB, error) {
 	if cfg.ChainConfig.IsBerlin(vmenv.Context.BlockNumber) {
 		cfg.State.PrepareAccessList(cfg.Origin, &address, vmenv.ActivePrecompiles(), nil)
 	}
-	cfg.State.CreateAccount(address, firehose.NoOpContext)
+	cfg.State.CreateAccount(address, firehose.NoOpContext, firehose.IgnoredAccountCreationReason)
 	// set the receiver's (the executing contract) code for execution.
 	cfg.State.SetCode(address, code, firehose.NoOpContext)
 	// Call the code with the given configuration.
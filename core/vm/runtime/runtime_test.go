@@ -164,7 +164,7 @@ func benchmarkEVM_Create(bench *testing.B, code string) {
 		receiver   = common.BytesToAddress([]byte("receiver"))
 	)
 
-	statedb.CreateAccount(sender, firehose.NoOpContext)
+	statedb.CreateAccount(sender, firehose.NoOpContext, firehose.IgnoredAccountCreationReason)
 	statedb.SetCode(receiver, common.FromHex(code), firehose.NoOpContext)
 	runtimeConfig := Config{
 		Origin:      sender,
@@ -358,15 +358,15 @@ func benchmarkNonModifyingCode(gas uint64, code []byte, name string, b *testing.
 		vmenv       = NewEnv(cfg)
 		sender      = vm.AccountRef(cfg.Origin)
 	)
-	cfg.State.CreateAccount(destination, firehose.NoOpContext)
+	cfg.State.CreateAccount(destination, firehose.NoOpContext, firehose.IgnoredAccountCreationReason)
 	eoa := common.HexToAddress("E0")
 	{
-		cfg.State.CreateAccount(eoa, firehose.NoOpContext)
-		cfg.State.SetNonce(eoa, 100, firehose.NoOpContext)
+		cfg.State.CreateAccount(eoa, firehose.NoOpContext, firehose.IgnoredAccountCreationReason)
+		cfg.State.SetNonce(eoa, 100, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 	}
 	reverting := common.HexToAddress("EE")
 	{
-		cfg.State.CreateAccount(reverting, firehose.NoOpContext)
+		cfg.State.CreateAccount(reverting, firehose.NoOpContext, firehose.IgnoredAccountCreationReason)
 		cfg.State.SetCode(reverting, []byte{
 			byte(vm.PUSH1), 0x00,
 			byte(vm.PUSH1), 0x00,
@@ -98,3 +98,17 @@ func (st *Stack) Print() {
 	}
 	fmt.Println("#############")
 }
+
+// firehoseTop returns up to n items from the top of the stack, top first.
+func (st *Stack) firehoseTop(n int) []uint256.Int {
+	if n > len(st.data) {
+		n = len(st.data)
+	}
+
+	top := make([]uint256.Int, n)
+	for i := range top {
+		top[i] = st.data[len(st.data)-1-i]
+	}
+
+	return top
+}
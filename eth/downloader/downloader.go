@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
@@ -1931,6 +1932,7 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 	log.Debug("Committing fast sync pivot as new head", "number", block.Number(), "hash", block.Hash())
 
 	// Commit the pivot block as the new head, will require full sync from here on
+	from := d.blockchain.CurrentBlock().NumberU64() + 1
 	if _, err := d.blockchain.InsertReceiptChain([]*types.Block{block}, []types.Receipts{result.Receipts}, d.ancientLimit); err != nil {
 		return err
 	}
@@ -1938,6 +1940,7 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 		return err
 	}
 	atomic.StoreInt32(&d.committed, 1)
+	firehose.MaybeSyncContext().RecordSkippedRange(from, block.NumberU64(), firehose.SkippedRangeReason(d.getMode().String()+"_sync"))
 
 	// If we had a bloom filter for the state sync, deallocate it now. Note, we only
 	// deallocate internally, but keep the empty wrapper. This ensures that if we do
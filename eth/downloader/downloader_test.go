@@ -17,6 +17,7 @@
 package downloader
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
@@ -32,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/trie"
 )
 
@@ -543,6 +545,35 @@ func testCanonSync(t *testing.T, protocol uint, mode SyncMode) {
 	assertOwnChain(t, tester, chain.len())
 }
 
+// Tests that committing the fast sync pivot reports the blocks below it, never executed, as a
+// Firehose skipped range.
+func TestFastSyncRecordsSkippedRange(t *testing.T) {
+	defer func(enabled bool) {
+		firehose.Enabled = enabled
+		firehose.SetWriter(nil)
+	}(firehose.Enabled)
+
+	output := &bytes.Buffer{}
+	firehose.SetWriter(output)
+	firehose.Enabled = true
+
+	tester := newTester()
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(blockCacheMaxItems - 15)
+	tester.newPeer("peer", 66, chain)
+
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	firehose.WaitOutputWritten()
+
+	pivot := tester.downloader.pivotHeader.Number.Uint64()
+	if want := fmt.Sprintf("FIRE SKIPPED_RANGE 1 %d fast_sync\n", pivot); output.String() != want {
+		t.Fatalf("firehose output mismatch: have %q, want %q", output.String(), want)
+	}
+}
+
 // Tests that if a large batch of blocks are being downloaded, it is throttled
 // until the cached blocks are retrieved.
 func TestThrottling64Full(t *testing.T) { testThrottling(t, 64, FullSync) }
@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params"
@@ -169,6 +170,16 @@ func newHandler(config *handlerConfig) (*handler, error) {
 			}
 		}
 	}
+	// Blocks below the fast sync pivot are never executed, Firehose refuses it unless allowed
+	if atomic.LoadUint32(&h.fastSync) == 1 {
+		mode := downloader.FastSync
+		if atomic.LoadUint32(&h.snapSync) == 1 {
+			mode = downloader.SnapSync
+		}
+		if err := firehose.ValidateSyncMode(mode.String()); err != nil {
+			return nil, err
+		}
+	}
 	// If we have trusted checkpoints, enforce them on the chain
 	if config.Checkpoint != nil {
 		h.checkpointNumber = (config.Checkpoint.SectionIndex+1)*params.CHTFrequency - 1
@@ -303,12 +303,12 @@ func (cs *chainSyncer) startSync(op *chainSyncOp) {
 
 // doSync synchronizes the local blockchain with a remote peer.
 func (h *handler) doSync(op *chainSyncOp) error {
-	if firehose.Enabled {
+	if firehose.Enabled && !firehose.AllowNonArchive {
 		// If Firehose is enabled, we force the mode to be a FullSync mode to ensure we correctly
-		// process all transactions. It should probably be adapter so that speculative execution
-		// node could use fast sync which is not the case here.
+		// process all transactions, unless skipping the blocks below the pivot is explicitly
+		// allowed, the range skipped is then reported when the pivot is committed.
 		if op.mode != downloader.FullSync {
-			log.Warn("Firehose changed syncing mode to 'full', it is required for proper extraction of the data when enabling Firehose instrumentation through --firehose-enabled", "old", op.mode, "new", downloader.FullSync)
+			log.Warn("Firehose changed syncing mode to 'full', it is required for proper extraction of the data when enabling Firehose instrumentation through --firehose-enabled, unless --firehose-allow-non-archive is set", "old", op.mode, "new", downloader.FullSync)
 		}
 
 		op.mode = downloader.FullSync
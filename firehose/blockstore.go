@@ -0,0 +1,259 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// blockStoreUploadAttempts is how many times a block upload is attempted before the output
+// is considered broken, which halts the node.
+var blockStoreUploadAttempts = 8
+
+// blockStore stores objects by name, see `openBlockStore` for the supported stores.
+type blockStore interface {
+	Put(name string, payload []byte) error
+}
+
+// openBlockStore opens the block store at the URL, either `file:///<directory>` or
+// `s3://<bucket>[/<prefix>][?endpoint=<url>&region=<region>]`, the endpoint being the one
+// of an S3-compatible service, addressed in path style, instead of AWS.
+func openBlockStore(rawURL string) (blockStore, error) {
+	storeURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block store url %q: %w", rawURL, err)
+	}
+
+	switch storeURL.Scheme {
+	case "file":
+		dir := storeURL.Path
+		if storeURL.Host != "" {
+			dir = storeURL.Host + dir
+		}
+
+		if dir == "" {
+			return nil, fmt.Errorf("invalid block store url %q: missing directory", rawURL)
+		}
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("create block store directory: %w", err)
+		}
+
+		return fileBlockStore{dir: dir}, nil
+
+	case "s3":
+		if storeURL.Host == "" {
+			return nil, fmt.Errorf("invalid block store url %q: missing bucket", rawURL)
+		}
+
+		config := &aws.Config{}
+		if region := storeURL.Query().Get("region"); region != "" {
+			config.Region = aws.String(region)
+		}
+		if endpoint := storeURL.Query().Get("endpoint"); endpoint != "" {
+			config.Endpoint = aws.String(endpoint)
+			config.S3ForcePathStyle = aws.Bool(true)
+		}
+
+		session, err := session.NewSession(config)
+		if err != nil {
+			return nil, fmt.Errorf("create s3 session: %w", err)
+		}
+
+		return s3BlockStore{
+			client: s3.New(session),
+			bucket: storeURL.Host,
+			prefix: strings.Trim(storeURL.Path, "/"),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("invalid block store url %q: unsupported scheme %q, valid schemes are 'file' and 's3'", rawURL, storeURL.Scheme)
+}
+
+// fileBlockStore stores objects as files of a local directory, each file is written under
+// a temporary name first so a file bearing an object name is always complete.
+type fileBlockStore struct {
+	dir string
+}
+
+func (s fileBlockStore) Put(name string, payload []byte) error {
+	file, err := ioutil.TempFile(s.dir, "."+name+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(payload); err != nil {
+		discardSpillFile(file)
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return os.Rename(file.Name(), filepath.Join(s.dir, name))
+}
+
+type s3BlockStore struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+func (s s3BlockStore) Put(name string, payload []byte) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path.Join(s.prefix, name)),
+		Body:   bytes.NewReader(payload),
+	})
+
+	return err
+}
+
+// blockStoreName is the object name of a block, the number is zero padded so objects list
+// in block order.
+func blockStoreName(num uint64, hash common.Hash) string {
+	return fmt.Sprintf("%010d-%s.fireblock", num, hex.EncodeToString(hash[:]))
+}
+
+// blockStoreSink uploads the Firehose payload of each block to a `blockStore` from a pool
+// of goroutines. Block import may only be `window` blocks ahead of the oldest block whose
+// upload was not acknowledged yet, `upload` blocks otherwise.
+//
+// An upload still failing after `blockStoreUploadAttempts` marks the output broken, which
+// stops block import and shuts the node down, see `OutputBroken`.
+type blockStoreSink struct {
+	store  blockStore
+	window uint64
+	jobs   chan blockStoreUpload
+	done   sync.WaitGroup
+
+	lock    sync.Mutex
+	cond    *sync.Cond
+	pending map[uint64]int
+}
+
+type blockStoreUpload struct {
+	num     uint64
+	name    string
+	payload []byte
+}
+
+// activeBlockStore is the sink blocks are uploaded to by `FlushBlock`, `nil` when disabled.
+var activeBlockStore *blockStoreSink
+
+func newBlockStoreSink(store blockStore, concurrency int, window int) *blockStoreSink {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if window <= 0 {
+		window = 1
+	}
+
+	s := &blockStoreSink{
+		store:   store,
+		window:  uint64(window),
+		jobs:    make(chan blockStoreUpload, concurrency),
+		pending: map[uint64]int{},
+	}
+	s.cond = sync.NewCond(&s.lock)
+
+	s.done.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go s.run()
+	}
+
+	return s
+}
+
+// upload queues the upload of the block's payload, which must not be modified afterwards,
+// waiting while the block is `window` blocks ahead of the oldest pending upload. It's a
+// no-op once the output is broken.
+func (s *blockStoreSink) upload(num uint64, hash common.Hash, payload []byte) {
+	s.lock.Lock()
+	for !outputIsBroken.Load() && s.tooFarAhead(num) {
+		s.cond.Wait()
+	}
+
+	if outputIsBroken.Load() {
+		s.lock.Unlock()
+		return
+	}
+
+	s.pending[num]++
+	s.lock.Unlock()
+
+	s.jobs <- blockStoreUpload{num: num, name: blockStoreName(num, hash), payload: payload}
+}
+
+// tooFarAhead tells if the block is `window` blocks ahead of the oldest pending upload, the
+// lock must be held.
+func (s *blockStoreSink) tooFarAhead(num uint64) bool {
+	for pending := range s.pending {
+		if num >= pending+s.window {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *blockStoreSink) run() {
+	defer s.done.Done()
+
+	for job := range s.jobs {
+		if err := s.put(job); err != nil {
+			markOutputBroken(fmt.Sprintf("uploading block #%d to block store", job.num), err)
+		}
+
+		s.lock.Lock()
+		if s.pending[job.num]--; s.pending[job.num] == 0 {
+			delete(s.pending, job.num)
+		}
+		s.cond.Broadcast()
+		s.lock.Unlock()
+	}
+}
+
+// put uploads the block, retrying with an exponential backoff on failure.
+func (s *blockStoreSink) put(job blockStoreUpload) (err error) {
+	backoff := writeRetryMinBackoff
+
+	for attempt := 1; attempt <= blockStoreUploadAttempts; attempt++ {
+		if err = s.store.Put(job.name, job.payload); err == nil {
+			return nil
+		}
+
+		if attempt < blockStoreUploadAttempts {
+			log.Warn("Firehose block store upload failed, retrying", "block", job.num, "attempt", attempt, "backoff", backoff, "err", err)
+			time.Sleep(backoff)
+
+			if backoff *= 2; backoff > writeRetryMaxBackoff {
+				backoff = writeRetryMaxBackoff
+			}
+		}
+	}
+
+	return err
+}
+
+// close waits for the queued uploads to complete, `upload` must not be called anymore.
+func (s *blockStoreSink) close() {
+	close(s.jobs)
+	s.done.Wait()
+}
@@ -0,0 +1,126 @@
+package firehose
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedBlockStore records the uploads, blocking those of the blocks in `held` until released.
+type gatedBlockStore struct {
+	lock     sync.Mutex
+	uploaded map[string][]byte
+	held     map[string]chan struct{}
+	err      error
+}
+
+func (s *gatedBlockStore) Put(name string, payload []byte) error {
+	s.lock.Lock()
+	gate := s.held[name]
+	s.lock.Unlock()
+
+	if gate != nil {
+		<-gate
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.uploaded[name] = payload
+	return nil
+}
+
+func TestBlockStore_uploadsBlockPayload(t *testing.T) {
+	defer func(enabled bool, ctx *Context, sink *blockStoreSink) {
+		Enabled, syncContext, activeBlockStore = enabled, ctx, sink
+	}(Enabled, syncContext, activeBlockStore)
+	Enabled = true
+
+	defer func(previous func() string) { captureTime = previous }(captureTime)
+	captureTime = func() string { return "1600000000000000000" }
+
+	dir, err := ioutil.TempDir("", "firehose-blockstore-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := openBlockStore("file://" + dir)
+	require.NoError(t, err)
+
+	output := &bytes.Buffer{}
+	syncContext = NewContext(&DelegateToWriterPrinter{writer: output}, false)
+	activeBlockStore = newBlockStoreSink(store, 2, 4)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(7), Difficulty: big.NewInt(1)})
+	recordBlock(block, 3)
+	activeBlockStore.close()
+
+	payload, err := ioutil.ReadFile(filepath.Join(dir, blockStoreName(7, block.Hash())))
+	require.NoError(t, err)
+	assert.Equal(t, output.String(), string(payload))
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "temporary files are renamed")
+}
+
+func TestBlockStore_window(t *testing.T) {
+	held := make(chan struct{})
+	store := &gatedBlockStore{
+		uploaded: map[string][]byte{},
+		held:     map[string]chan struct{}{blockStoreName(1, common.Hash{}): held},
+	}
+
+	sink := newBlockStoreSink(store, 2, 3)
+	sink.upload(1, common.Hash{}, nil)
+	sink.upload(2, common.Hash{}, nil)
+	sink.upload(3, common.Hash{}, nil)
+
+	advanced := make(chan struct{})
+	go func() {
+		sink.upload(4, common.Hash{}, nil)
+		close(advanced)
+	}()
+
+	select {
+	case <-advanced:
+		t.Fatal("block 4 must wait for the upload of block 1")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(held)
+	<-advanced
+	sink.close()
+
+	assert.Len(t, store.uploaded, 4)
+}
+
+func TestBlockStore_failedUploadBreaksOutput(t *testing.T) {
+	defer resetOutputBroken()
+	defer func(previous int) { blockStoreUploadAttempts = previous }(blockStoreUploadAttempts)
+	blockStoreUploadAttempts = 2
+
+	sink := newBlockStoreSink(&gatedBlockStore{err: errors.New("unavailable")}, 1, 1)
+	sink.upload(1, common.Hash{}, nil)
+
+	<-OutputBroken()
+	assert.Equal(t, ErrOutputBroken, OutputError())
+
+	// Nothing is uploaded anymore once the output is broken
+	sink.upload(2, common.Hash{}, nil)
+	sink.close()
+}
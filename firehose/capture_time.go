@@ -0,0 +1,16 @@
+package firehose
+
+import (
+	"strconv"
+	"time"
+)
+
+// captureStart anchors capture timestamps to the wall clock, they are then advanced using
+// the monotonic clock so they are never affected by wall clock adjustments.
+var captureStart = time.Now()
+
+// captureTime returns the time at which a record is emitted, in nanoseconds since the Unix
+// epoch, see `captureStart`. It's a variable so tests can make it deterministic.
+var captureTime = func() string {
+	return strconv.FormatInt(captureStart.Add(time.Since(captureStart)).UnixNano(), 10)
+}
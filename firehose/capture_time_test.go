@@ -0,0 +1,21 @@
+package firehose
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureTime(t *testing.T) {
+	first, err := strconv.ParseInt(captureTime(), 10, 64)
+	require.NoError(t, err)
+
+	second, err := strconv.ParseInt(captureTime(), 10, 64)
+	require.NoError(t, err)
+
+	assert.True(t, second >= first)
+	assert.InDelta(t, time.Now().UnixNano(), second, float64(time.Second))
+}
@@ -0,0 +1,92 @@
+package firehose
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// initHandshake holds the INIT message prepared by `Init`, emitted by `InitChainConfig` once
+// the chain configuration in effect is known.
+var initHandshake struct {
+	nodeVersion string
+	features    []string
+}
+
+// InitChainConfig emits the INIT handshake with the chain configuration in effect, the chain
+// ID and the fork schedule, as a last `chain_config=<json>` feature so readers don't need to
+// know each network. It's preceded by the EIP-2124 fork ID of the head, as `fork_id=<hash>:<next>`
+// and computed like the eth handshake does, for readers to check they follow the expected network
+// and fork schedule. It's called with the configuration resolved by the blockchain, the same
+// whether the genesis came from a flag or the configuration was stored in the database and with
+// the fork overrides applied, and again when the head is rewound as readers restart from there.
+func InitChainConfig(config *params.ChainConfig, genesis common.Hash, head uint64) {
+	ctx := MaybeSyncContext()
+	if !ctx.Enabled() {
+		return
+	}
+
+	// Compact JSON has no spaces, the feature stays a single field
+	out, err := json.Marshal(config)
+	if err != nil {
+		panic(fmt.Errorf("encode chain config: %w", err))
+	}
+
+	features := append([]string(nil), initHandshake.features...)
+	features = append(features, "fork_id="+formatForkID(forkid.NewID(config, genesis, head)), "chain_config="+string(out))
+	ctx.InitVersion(initHandshake.nodeVersion, params.FirehoseVersion(), params.Variant, features...)
+}
+
+// RecordForkActivation records the forks activated by the block, if any, in a `FORK_ACTIVATION`
+// message with their comma separated names, as in the chain config JSON without the `Block`
+// suffix, and the fork ID hash and next fork they start. Forks activated at genesis are the
+// genesis rule set and are never recorded, like they are not part of the fork ID.
+func (ctx *Context) RecordForkActivation(config *params.ChainConfig, genesis common.Hash, blockNum uint64) {
+	if ctx == nil || blockNum == 0 {
+		return
+	}
+
+	forks := activatedForks(config, blockNum)
+	if len(forks) == 0 {
+		return
+	}
+
+	id := forkid.NewID(config, genesis, blockNum)
+	ctx.printer.Print("FORK_ACTIVATION",
+		strings.Join(forks, ","),
+		Hex(id.Hash[:]),
+		Uint64(id.Next),
+	)
+}
+
+// activatedForks returns the names of the forks scheduled at the block, gathered from the
+// chain config fields like the fork ID does.
+func activatedForks(config *params.ChainConfig, blockNum uint64) (forks []string) {
+	kind := reflect.TypeOf(params.ChainConfig{})
+	conf := reflect.ValueOf(config).Elem()
+
+	for i := 0; i < kind.NumField(); i++ {
+		field := kind.Field(i)
+		if !strings.HasSuffix(field.Name, "Block") || field.Type != reflect.TypeOf(new(big.Int)) {
+			continue
+		}
+
+		rule := conf.Field(i).Interface().(*big.Int)
+		if rule != nil && rule.IsUint64() && rule.Uint64() == blockNum {
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			forks = append(forks, strings.TrimSuffix(name, "Block"))
+		}
+	}
+
+	return forks
+}
+
+func formatForkID(id forkid.ID) string {
+	return Hex(id.Hash[:]) + ":" + Uint64(id.Next)
+}
@@ -0,0 +1,153 @@
+package firehose
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.uber.org/atomic"
+)
+
+// ChunkThreshold is the size above which a field of a line encoded message is emitted in
+// `CHUNK <id> <index> <total> <data>` lines preceding the message, the field being replaced
+// in the message by a `@<id>` reference. Chunking is disabled when 0.
+//
+// Chunking only applies to the line encoding, framed messages have no size constraints.
+var ChunkThreshold = 0
+
+// chunkReferencePrefix prefixes the chunk id replacing a chunked field in the message.
+const chunkReferencePrefix = "@"
+
+var nextChunkID = atomic.NewUint64(0)
+
+// chunkFields splits the fields larger than `ChunkThreshold` in chunk lines, returning the
+// fields with the chunked ones replaced by their reference and the formatted chunk lines,
+// `nil` when no field was chunked.
+func chunkFields(input []string) ([]string, []byte) {
+	var fields []string
+	var chunks []byte
+
+	// The message name is never chunked
+	for i, field := range input[1:] {
+		i++
+		if len(field) <= ChunkThreshold {
+			continue
+		}
+
+		if fields == nil {
+			fields = append([]string(nil), input...)
+		}
+
+		id := Uint64(nextChunkID.Inc())
+		total := (len(field) + ChunkThreshold - 1) / ChunkThreshold
+		for index := 0; index < total; index++ {
+			end := (index + 1) * ChunkThreshold
+			if end > len(field) {
+				end = len(field)
+			}
+
+			chunks = append(chunks, LinePrefix+" CHUNK "+id+" "+strconv.Itoa(index)+" "+strconv.Itoa(total)+" "+field[index*ChunkThreshold:end]+"\n"...)
+		}
+
+		fields[i] = chunkReferencePrefix + id
+	}
+
+	if fields == nil {
+		return input, nil
+	}
+
+	return fields, chunks
+}
+
+// ChunkAssembler reassembles chunked fields on the reading side, feed it the fields of each
+// message read (the `FIRE` prefix excluded) in order.
+//
+// Chunks must be received in order and be all referenced by the message following them,
+// any gap, out of order chunk or unreferenced chunk is an error. As chunk data might contain
+// spaces, a `CHUNK` line must be split in at most 5 fields.
+type ChunkAssembler struct {
+	id       string
+	next     int
+	total    int
+	complete map[string]string
+	current  strings.Builder
+}
+
+// Process handles the next message, returning `nil` for chunk messages and the message with
+// its chunked fields reassembled otherwise.
+func (a *ChunkAssembler) Process(fields []string) ([]string, error) {
+	if len(fields) > 0 && fields[0] == "CHUNK" {
+		return nil, a.addChunk(fields)
+	}
+
+	if a.total != 0 {
+		return nil, fmt.Errorf("chunk %s is incomplete, received %d of %d parts before %s", a.id, a.next, a.total, fields[0])
+	}
+
+	if len(a.complete) == 0 {
+		return fields, nil
+	}
+
+	out := make([]string, len(fields))
+	for i, field := range fields {
+		out[i] = field
+		if !strings.HasPrefix(field, chunkReferencePrefix) {
+			continue
+		}
+
+		if data, found := a.complete[strings.TrimPrefix(field, chunkReferencePrefix)]; found {
+			out[i] = data
+			delete(a.complete, strings.TrimPrefix(field, chunkReferencePrefix))
+		}
+	}
+
+	if len(a.complete) != 0 {
+		return nil, fmt.Errorf("%d chunk(s) not referenced by %s", len(a.complete), fields[0])
+	}
+
+	return out, nil
+}
+
+func (a *ChunkAssembler) addChunk(fields []string) error {
+	if len(fields) != 5 {
+		return fmt.Errorf("invalid chunk, expected 4 fields, got %d", len(fields)-1)
+	}
+
+	id, data := fields[1], fields[4]
+	index, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return fmt.Errorf("invalid chunk %s index %q: %w", id, fields[2], err)
+	}
+
+	total, err := strconv.Atoi(fields[3])
+	if err != nil || total <= 0 {
+		return fmt.Errorf("invalid chunk %s total %q", id, fields[3])
+	}
+
+	if a.total == 0 {
+		if index != 0 {
+			return fmt.Errorf("chunk %s starts at part %d, part 0 is missing", id, index)
+		}
+
+		a.id, a.total, a.next = id, total, 0
+		a.current.Reset()
+	}
+
+	if id != a.id || index != a.next || total != a.total {
+		return fmt.Errorf("unexpected chunk %s part %d/%d, expected chunk %s part %d/%d", id, index, total, a.id, a.next, a.total)
+	}
+
+	a.current.WriteString(data)
+	a.next++
+
+	if a.next == a.total {
+		if a.complete == nil {
+			a.complete = map[string]string{}
+		}
+
+		a.complete[a.id] = a.current.String()
+		a.total = 0
+	}
+
+	return nil
+}
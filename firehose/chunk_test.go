@@ -0,0 +1,80 @@
+package firehose
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readLines splits formatted lines in fields the way a reader does, `CHUNK` data included.
+func readLines(t *testing.T, data string) (out [][]string) {
+	t.Helper()
+
+	for _, line := range strings.Split(strings.TrimSuffix(data, "\n"), "\n") {
+		require.True(t, strings.HasPrefix(line, "FIRE "))
+
+		line = strings.TrimPrefix(line, "FIRE ")
+		if strings.HasPrefix(line, "CHUNK ") {
+			out = append(out, strings.SplitN(line, " ", 5))
+		} else {
+			out = append(out, strings.Split(line, " "))
+		}
+	}
+
+	return
+}
+
+func TestChunkFields_roundTrip(t *testing.T) {
+	defer func(previous int) { ChunkThreshold = previous }(ChunkThreshold)
+	ChunkThreshold = 5
+
+	input := []string{"CODE_CHANGE", "1", "02", ".", ".", "cc", "600160026003", "8"}
+	lines := readLines(t, string(formatMessage(input)))
+	require.Len(t, lines, 4)
+	assert.Equal(t, []string{"CHUNK", lines[0][1], "0", "3", "60016"}, lines[0])
+	assert.Equal(t, "@"+lines[0][1], lines[3][6])
+
+	assembler := &ChunkAssembler{}
+	for _, fields := range lines[:3] {
+		record, err := assembler.Process(fields)
+		require.NoError(t, err)
+		assert.Nil(t, record)
+	}
+
+	record, err := assembler.Process(lines[3])
+	require.NoError(t, err)
+	assert.Equal(t, input, record)
+
+	// Small fields are left untouched
+	assert.Equal(t, "FIRE BEGIN_BLOCK 1\n", string(formatMessage([]string{"BEGIN_BLOCK", "1"})))
+}
+
+func TestChunkAssembler_errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines [][]string
+	}{
+		{"missing first part", [][]string{{"CHUNK", "1", "1", "2", "aa"}}},
+		{"out of order", [][]string{{"CHUNK", "1", "0", "3", "aa"}, {"CHUNK", "1", "2", "3", "aa"}}},
+		{"interleaved", [][]string{{"CHUNK", "1", "0", "2", "aa"}, {"CHUNK", "2", "0", "2", "aa"}}},
+		{"incomplete", [][]string{{"CHUNK", "1", "0", "2", "aa"}, {"EVM_PARAM", "@1"}}},
+		{"unreferenced", [][]string{{"CHUNK", "1", "0", "1", "aa"}, {"EVM_PARAM", "@2"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assembler := &ChunkAssembler{}
+
+			var err error
+			for _, fields := range test.lines {
+				if _, err = assembler.Process(fields); err != nil {
+					break
+				}
+			}
+
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package firehose
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// emptyCodeHash is the code hash of accounts without code.
+var emptyCodeHash = crypto.Keccak256Hash(nil)
+
+// CodeCacheWindow, when non-zero, is the number of blocks the reader keeps the code it
+// received for, announced in the `INIT` message. A `CODE_CHANGE` message then omits the old
+// or new code, keeping its hash only, when the code was recorded in full in one of the last
+// `CodeCacheWindow` ended blocks. An omitted code is recorded as empty along a hash different
+// from the empty code's hash.
+var CodeCacheWindow = 0
+
+// codeCache tracks the block in which each code was last recorded in full, see `CodeCacheWindow`.
+type codeCache struct {
+	lock sync.Mutex
+
+	lastBlockNum uint64
+	blockNums    map[common.Hash]uint64
+}
+
+func newCodeCache() *codeCache {
+	return &codeCache{blockNums: map[common.Hash]uint64{}}
+}
+
+// knownCodes is shared by all contexts as the codes recorded by transactions contexts end up
+// in the same output.
+var knownCodes = newCodeCache()
+
+// known tells if the code was recorded in full within the window, a code recorded in a block
+// after the last one ended, like on a reorg, is not.
+func (c *codeCache) known(codeHash common.Hash) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	blockNum, found := c.blockNums[codeHash]
+	return found && blockNum <= c.lastBlockNum && c.lastBlockNum-blockNum < uint64(CodeCacheWindow)
+}
+
+// add records the codes recorded in full in the ended block, and forgets the ones that went
+// out of the window.
+func (c *codeCache) add(blockNum uint64, codeHashes map[common.Hash]struct{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for codeHash := range codeHashes {
+		c.blockNums[codeHash] = blockNum
+	}
+	c.lastBlockNum = blockNum
+
+	// Pruning walks the whole cache so it's done once per window only
+	if blockNum%uint64(CodeCacheWindow) == 0 {
+		for codeHash, codeBlockNum := range c.blockNums {
+			if codeBlockNum <= blockNum && blockNum-codeBlockNum >= uint64(CodeCacheWindow) {
+				delete(c.blockNums, codeHash)
+			}
+		}
+	}
+}
+
+// cachedCode returns the code to record for the hash, nil when the reader already has it.
+func (ctx *Context) cachedCode(codeHash common.Hash, code []byte) []byte {
+	if CodeCacheWindow <= 0 || len(code) == 0 {
+		return code
+	}
+
+	if _, found := ctx.blockCodes[codeHash]; found || knownCodes.known(codeHash) {
+		return nil
+	}
+
+	if ctx.blockCodes == nil {
+		ctx.blockCodes = map[common.Hash]struct{}{}
+	}
+	ctx.blockCodes[codeHash] = struct{}{}
+
+	return code
+}
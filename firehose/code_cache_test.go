@@ -0,0 +1,63 @@
+package firehose
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordCodeChange_emptyOldCodeHash(t *testing.T) {
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+
+	code := []byte{0x60, 0x01}
+	ctx.RecordCodeChange(common.HexToAddress("0x02"), nil, nil, crypto.Keccak256Hash(code), code)
+
+	fields := strings.Split(strings.TrimSpace(printer.Buffer().String()), " ")
+	assert.Equal(t, hashHex(emptyCodeHash.Hex()), fields[4])
+	assert.Equal(t, ".", fields[5])
+}
+
+func TestRecordCodeChange_codeCacheWindow(t *testing.T) {
+	defer func(window int, cache *codeCache) { CodeCacheWindow, knownCodes = window, cache }(CodeCacheWindow, knownCodes)
+	CodeCacheWindow, knownCodes = 2, newCodeCache()
+
+	code := []byte{0x60, 0x01}
+	codeHash := crypto.Keccak256Hash(code)
+
+	// recordBlock records the block with `changes` code changes to the code, returning the code
+	// recorded by each of them
+	recordBlock := func(number int64, changes int) []string {
+		printer := NewToBufferPrinter(1024)
+		ctx := NewContext(printer, true)
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number)})
+
+		ctx.StartBlock(block, common.Address{})
+		for i := 0; i < changes; i++ {
+			ctx.RecordCodeChange(common.HexToAddress("0x02"), nil, nil, codeHash, code)
+		}
+		ctx.EndBlock(block, big.NewInt(0))
+
+		var recorded []string
+		for _, line := range strings.Split(printer.Buffer().String(), "\n") {
+			if strings.HasPrefix(line, "FIRE CODE_CHANGE ") {
+				recorded = append(recorded, strings.Split(line, " ")[7])
+			}
+		}
+		return recorded
+	}
+
+	assert.Equal(t, []string{"6001", "."}, recordBlock(1, 2), "recorded in full once in the block")
+	assert.Equal(t, []string{"."}, recordBlock(2, 1), "recorded in full in the last ended block")
+	assert.Nil(t, recordBlock(3, 0))
+	assert.Equal(t, []string{"6001"}, recordBlock(4, 1), "recorded in full 3 blocks ago, out of the window")
+	assert.Equal(t, []string{"."}, recordBlock(5, 1))
+
+	CodeCacheWindow = 0
+	assert.Equal(t, []string{"6001", "6001"}, recordBlock(8, 2))
+}
@@ -0,0 +1,157 @@
+package firehose
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// CompactBlocksEnabled makes each block emitted as exactly two messages, `BLOCK_BEGIN <num>`
+	// followed by `BLOCK <num> <hash> <payload>`, the payload being the base64 encoding of the
+	// messages that would have been emitted for the block in incremental mode, byte for byte.
+	CompactBlocksEnabled = false
+
+	// CompactBlocksSpillSize is the size above which a block being accumulated in compact blocks
+	// mode is spilled to a temporary file instead of being kept in memory.
+	CompactBlocksSpillSize = 256 * 1024 * 1024
+)
+
+// compactBlockChunkSize is the size of the chunks the `BLOCK` message is written in.
+const compactBlockChunkSize = 1024 * 1024
+
+// compactBlock is a complete block accumulated in compact blocks mode, the first part of its
+// messages was possibly spilled to a temporary file, the rest being in memory.
+type compactBlock struct {
+	num  uint64
+	hash common.Hash
+
+	spill *os.File
+	tail  []byte
+}
+
+// writePayload writes the messages of the block to the writer, spilled ones first.
+func (b *compactBlock) writePayload(writer io.Writer) error {
+	if b.spill != nil {
+		if _, err := b.spill.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(writer, b.spill); err != nil {
+			return err
+		}
+	}
+
+	_, err := writer.Write(b.tail)
+	return err
+}
+
+func (b *compactBlock) discard() {
+	if b.spill != nil {
+		discardSpillFile(b.spill)
+		b.spill = nil
+	}
+}
+
+// printCompactBlock emits the `BLOCK_BEGIN` and `BLOCK` messages of the block as a single
+// operation so nothing else can be written in between, even when the payload is streamed
+// from a spill file in chunks.
+func (p *DelegateToWriterPrinter) printCompactBlock(block *compactBlock) {
+	sequence := func() string { return "" }
+	if LineSequenceEnabled {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		sequence = func() string { return " " + p.nextSequence() }
+	}
+
+	begin := []byte(LinePrefix + " BLOCK_BEGIN " + Uint64(block.num) + sequence() + "\n")
+	end := sequence() + "\n"
+
+	emit := func() {
+		defer block.discard()
+
+		p.writeOut(begin)
+
+		out := bufio.NewWriterSize(printerOutput{p}, compactBlockChunkSize)
+		out.WriteString(LinePrefix + " BLOCK " + Uint64(block.num) + " " + Hash(block.hash) + " ")
+
+		encoder := base64.NewEncoder(base64.StdEncoding, out)
+		if err := block.writePayload(encoder); err != nil {
+			panic(fmt.Errorf("read compact block #%d spill file: %w", block.num, err))
+		}
+
+		encoder.Close()
+		out.WriteString(end)
+		out.Flush()
+	}
+
+	if p.queue == nil {
+		emit()
+		return
+	}
+
+	p.handOff(printerEntry{emit: emit})
+}
+
+// printerOutput writes straight to the printer's writer, it's only used from the goroutine
+// performing the printer's writes.
+type printerOutput struct {
+	printer *DelegateToWriterPrinter
+}
+
+func (o printerOutput) Write(in []byte) (int, error) {
+	o.printer.writeOut(in)
+	return len(in), nil
+}
+
+// spillBuffer moves the buffered messages to the printer's spill file, creating it if needed.
+func (p *ToBufferPrinter) spillBuffer() {
+	if p.spill == nil {
+		file, err := ioutil.TempFile("", "firehose-block-")
+		if err != nil {
+			panic(fmt.Errorf("create compact block spill file: %w", err))
+		}
+
+		p.spill = file
+	}
+
+	if _, err := p.spill.Write(p.buffer.Bytes()); err != nil {
+		panic(fmt.Errorf("write compact block spill file: %w", err))
+	}
+
+	p.buffer.Reset()
+}
+
+// detachCompactBlock hands the accumulated messages over to a `compactBlock`, the printer
+// is then free to accumulate the next block.
+func (p *ToBufferPrinter) detachCompactBlock(num uint64, hash common.Hash) *compactBlock {
+	block := &compactBlock{
+		num:   num,
+		hash:  hash,
+		spill: p.spill,
+		tail:  append([]byte(nil), p.buffer.Bytes()...),
+	}
+
+	p.spill = nil
+	p.buffer.Reset()
+
+	return block
+}
+
+func (p *ToBufferPrinter) discardSpill() {
+	if p.spill != nil {
+		discardSpillFile(p.spill)
+		p.spill = nil
+	}
+}
+
+func discardSpillFile(file *os.File) {
+	file.Close()
+	os.Remove(file.Name())
+}
@@ -0,0 +1,64 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordBlock records a block with a few balance changes on a buffered context and flushes it.
+func recordBlock(block *types.Block, changes int) {
+	ctx := NewSpeculativeExecutionContext(1024)
+	ctx.StartBlock(block, common.Address{})
+	for i := 0; i < changes; i++ {
+		ctx.printer.Print("BALANCE_CHANGE", Uint64(uint64(i)))
+	}
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, big.NewInt(1))
+	ctx.FlushBlock()
+}
+
+func TestCompactBlocks_payloadIsIncrementalMessages(t *testing.T) {
+	defer func(enabled, compact bool, spillSize int, ctx *Context) {
+		Enabled, CompactBlocksEnabled, CompactBlocksSpillSize, syncContext = enabled, compact, spillSize, ctx
+	}(Enabled, CompactBlocksEnabled, CompactBlocksSpillSize, syncContext)
+	Enabled = true
+
+	defer func(previous func() string) { captureTime = previous }(captureTime)
+	captureTime = func() string { return "1600000000000000000" }
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(7), Difficulty: big.NewInt(1)})
+
+	incremental := bytes.NewBuffer(nil)
+	syncContext = NewContext(&DelegateToWriterPrinter{writer: incremental}, false)
+	recordBlock(block, 100)
+
+	for _, spillSize := range []int{CompactBlocksSpillSize, 64} {
+		CompactBlocksEnabled, CompactBlocksSpillSize = true, spillSize
+
+		compact := bytes.NewBuffer(nil)
+		printer := NewWriterGoroutinePrinter(compact, 4)
+		syncContext = NewContext(printer, false)
+		recordBlock(block, 100)
+		printer.Wait()
+
+		lines := strings.Split(strings.TrimSuffix(compact.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+		assert.Equal(t, "FIRE BLOCK_BEGIN 7", lines[0])
+
+		fields := strings.Split(lines[1], " ")
+		require.Len(t, fields, 5)
+		assert.Equal(t, []string{"FIRE", "BLOCK", "7", Hash(block.Hash())}, fields[0:4])
+
+		payload, err := base64.StdEncoding.DecodeString(fields[4])
+		require.NoError(t, err)
+		assert.Equal(t, incremental.String(), string(payload), "spill size %d", spillSize)
+	}
+}
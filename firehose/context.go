@@ -4,17 +4,19 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
 	"math/big"
 	"os"
 	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"go.uber.org/atomic"
 )
 
@@ -55,7 +57,9 @@ func NewContext(printer Printer, speculative bool) *Context {
 		isSpeculativeContext: speculative,
 		inBlock:              atomic.NewBool(false),
 		inTransaction:        atomic.NewBool(false),
+		inSystemCall:         atomic.NewBool(false),
 		totalOrderingCounter: atomic.NewUint64(0),
+		blockKeccaks:         map[common.Hash]struct{}{},
 	}
 
 	ctx.resetBlock()
@@ -73,39 +77,115 @@ type Context struct {
 
 	// Global state
 	isSpeculativeContext bool
-	flushTxLock          sync.Mutex
 
 	// Block state
 	inBlock              *atomic.Bool
+	blockNum             uint64
+	blockHash            common.Hash
 	blockLogIndex        uint64
 	totalOrderingCounter *atomic.Uint64
 
+	// sharesBlockState is true for a transaction context using its block's ordinals counter and
+	// recorded keccaks, see `NewTransactionContext`
+	sharesBlockState bool
+
+	// emittedOrdinals are the ordinals recorded in the block, see `OrdinalsCheckEnabled`
+	emittedOrdinals []uint64
+
+	// blockKeccaks are the hashes whose preimage was recorded in the block, see `KeccakDedupEnabled`,
+	// shared with the block's transaction contexts
+	blockKeccaks map[common.Hash]struct{}
+
+	// blockCodes are the hashes of the codes recorded in full in the block, see `CodeCacheWindow`
+	blockCodes map[common.Hash]struct{}
+
+	// inSystemCall is true while recording state changes made outside of transactions, see `StartSystemCall`
+	inSystemCall *atomic.Bool
+
 	// Transaction state
 	inTransaction   *atomic.Bool
 	activeCallIndex string
 	nextCallIndex   uint64
 	callIndexStack  *ExtendedStack
+
+	// callGasStack holds the gas accounting of the active calls, in the same order as `callIndexStack`
+	callGasStack []*callGas
+
+	// calls are the calls of the transaction by call index minus one, see `printRevertedCalls`
+	calls []callState
+
+	// revertedCallIndex is the last call recorded as reverted, its return data is capped on end
+	revertedCallIndex string
+
+	// trxFailure and trxFailureReason are the failure of the transaction's top level call, if any
+	trxFailure       CallFailure
+	trxFailureReason string
+
+	// touchedAccounts are the accounts accessed by the transaction, see `TouchedAccountsEnabled`
+	touchedAccounts map[common.Address]struct{}
+
+	// finalStorageChanges are the storage changes of each call pending its end, see `FinalStorageChangesMode`
+	finalStorageChanges map[string]map[storageSlot]*finalStorageChange
+	finalStorageWrites  uint64
+
+	// pendingGasChanges are the gas consumed by the active call and not recorded yet, starting
+	// at `pendingGasOld`, see `AccumulateGasConsume`
+	pendingGasOld     uint64
+	pendingGasChanges []pendingGasChange
 }
 
 func (ctx *Context) resetBlock() {
 	ctx.inBlock.Store(false)
+	ctx.inSystemCall.Store(false)
 	ctx.blockLogIndex = 0
-	ctx.totalOrderingCounter.Store(0)
+	ctx.blockCodes = nil
+	ctx.emittedOrdinals = nil
+
+	// The block context resets the shared state, a transaction context must not
+	if !ctx.sharesBlockState {
+		ctx.totalOrderingCounter.Store(0)
+		ctx.resetBlockKeccaks()
+	}
+}
+
+// resetBlockKeccaks forgets the keccaks recorded in the block, in place as the set is shared
+// with the block's transaction contexts.
+func (ctx *Context) resetBlockKeccaks() {
+	for hash := range ctx.blockKeccaks {
+		delete(ctx.blockKeccaks, hash)
+	}
 }
 
 func (ctx *Context) resetTransaction() {
 	ctx.inTransaction.Store(false)
 	ctx.nextCallIndex = 0
 	ctx.activeCallIndex = "0"
+	ctx.revertedCallIndex = ""
+	ctx.trxFailure = ""
+	ctx.trxFailureReason = ""
+	ctx.touchedAccounts = nil
+	ctx.finalStorageChanges = nil
+	ctx.finalStorageWrites = 0
 	ctx.callIndexStack = &ExtendedStack{}
 	ctx.callIndexStack.Push(ctx.activeCallIndex)
+	ctx.callGasStack = nil
+	ctx.calls = ctx.calls[:0]
+	ctx.pendingGasChanges = ctx.pendingGasChanges[:0]
 }
 
-func (ctx *Context) InitVersion(nodeVersion, dmVersion, variant string) {
+// InitVersion emits the INIT handshake, features are `key=value` tokens appended to it
+// announcing non-default output settings so the reader can adapt to them.
+func (ctx *Context) InitVersion(nodeVersion, dmVersion, variant string, features ...string) {
 	if ctx == nil {
 		return
 	}
-	ctx.printer.Print("INIT", dmVersion, variant, nodeVersion)
+
+	if v, ok := ctx.printer.(*DelegateToWriterPrinter); ok {
+		v.resetSequence()
+	}
+
+	ctx.printer.Print(append([]string{"INIT", dmVersion, variant, nodeVersion}, features...)...)
+	ctx.flushPrinter()
 }
 
 func NewSpeculativeExecutionContext(initialAllocationInBytes int) *Context {
@@ -116,6 +196,44 @@ func NewSpeculativeExecutionContextWithBuffer(buffer *bytes.Buffer) *Context {
 	return NewContext(NewToBufferPrinterWithBuffer(buffer), true)
 }
 
+// NewBlockContext returns the context recording a block being imported. The records of the
+// block are staged in `BlockSyncBuffer` and written to the output at once by `FlushBlock`,
+// unless `StreamingBlocksEnabled` in which case the sync context is returned so records are
+// written as they are produced, the block must then be canceled if its import fails.
+func NewBlockContext() *Context {
+	if !Enabled {
+		return NoOpContext
+	}
+
+	if StreamingBlocksEnabled {
+		return syncContext
+	}
+
+	return NewSpeculativeExecutionContextWithBuffer(BlockSyncBuffer)
+}
+
+// NewBlockContextAt returns the context recording the block at the height, see
+// `NewBlockContext`, or `NoOpContext` for a block below `StartBlockNum`, the block is then
+// processed without any of its records being built.
+func NewBlockContextAt(blockNum uint64) *Context {
+	if blockNum < StartBlockNum {
+		return NoOpContext
+	}
+
+	return NewBlockContext()
+}
+
+// NewReplayContextAt returns the context recording a block executed again at the height, like
+// `NewBlockContextAt` but its records are always staged, in a buffer of its own as the block
+// being imported might still hold `BlockSyncBuffer`.
+func NewReplayContextAt(blockNum uint64) *Context {
+	if !Enabled || blockNum < StartBlockNum {
+		return NoOpContext
+	}
+
+	return NewSpeculativeExecutionContext(16 * 1024)
+}
+
 func (ctx *Context) Enabled() bool {
 	return ctx != nil && Enabled
 }
@@ -146,29 +264,105 @@ func (ctx *Context) RecordGenesisBlock(block *types.Block, recordGenesisAlloc fu
 	zero := common.Address{}
 	root := block.Root()
 
-	ctx.StartBlock(block)
-	ctx.StartTransactionRaw(common.Hash{}, &zero, &big.Int{}, nil, nil, nil, 0, &big.Int{}, 0, nil, nil, nil, nil, 0, 0)
+	ctx.StartBlock(block, block.Coinbase())
+	ctx.StartTransactionRaw(common.Hash{}, &zero, &big.Int{}, nil, nil, nil, 0, &big.Int{}, 0, nil, nil, nil, nil, 0, 0, nil, 0, nil)
 	ctx.RecordTrxFrom(zero)
 	recordGenesisAlloc(ctx)
-	ctx.EndTransaction(&types.Receipt{PostState: root[:]})
+	ctx.EndTransaction(&types.Receipt{PostState: root[:], Status: types.ReceiptStatusSuccessful})
 	ctx.FinalizeBlock(block)
 	ctx.EndBlock(block, block.Difficulty())
 }
 
-func (ctx *Context) StartBlock(block *types.Block) {
+// StartBlock records the beginning of the block with, after its capture time, the header's
+// logs bloom, gas used and gas limit and the block's transaction and uncle counts, so readers
+// can pre-allocate or skip the block before reading the rest of it.
+//
+// The producer ends the message, it's the block's author according to the consensus engine:
+// the coinbase on proof-of-work chains and the signer recovered from the seal in the extra
+// data on clique chains, whose coinbase is meaningless. On clique, the difficulty is 2 when
+// the signer was in turn and 1 otherwise.
+func (ctx *Context) StartBlock(block *types.Block, producer common.Address) {
 	if !ctx.inBlock.CAS(false, true) {
 		panic("entering a block while already in a block scope")
 	}
 
-	ctx.printer.Print("BEGIN_BLOCK", Uint64(block.NumberU64()))
+	ctx.blockNum = block.NumberU64()
+	ctx.blockHash = block.Hash()
+
+	// Blocks uploaded to the block store are needed as a whole, they are never spilled
+	if v, ok := ctx.printer.(*ToBufferPrinter); ok && CompactBlocksEnabled && activeBlockStore == nil {
+		v.spillAbove = CompactBlocksSpillSize
+	}
+
+	ctx.printer.Print("BEGIN_BLOCK",
+		Uint64(block.NumberU64()),
+		captureTime(),
+		Hex(block.Bloom().Bytes()),
+		Uint64(block.GasUsed()),
+		Uint64(block.GasLimit()),
+		Uint(uint(len(block.Transactions()))),
+		Uint(uint(len(block.Uncles()))),
+		Addr(producer),
+	)
+}
+
+// StartSystemCall opens a section of the block recording the state changes made outside of
+// transactions, like the block rewards of the consensus engine or the system operations of the
+// state processor such as the DAO hard fork, the source telling what performed them. Consensus
+// engines can open their own when finalizing a block, see `consensus.FirehoseSystemCaller`,
+// system calls don't nest.
+func (ctx *Context) StartSystemCall(source SystemCallSource) {
+	if ctx == nil {
+		return
+	}
+
+	if ctx.inTransaction.Load() {
+		panic("entering a system call while in a transaction scope")
+	}
+
+	if !ctx.inSystemCall.CAS(false, true) {
+		panic("entering a system call while already in a system call scope")
+	}
+
+	ctx.printer.Print("BEGIN_SYSTEM_CALL",
+		string(source),
+		Uint64(ctx.nextOrdinal()),
+	)
+}
+
+// EndSystemCall closes the section opened by `StartSystemCall`.
+func (ctx *Context) EndSystemCall() {
+	if ctx == nil {
+		return
+	}
+
+	if !ctx.inSystemCall.CAS(true, false) {
+		panic("exiting a system call while not already within a system call scope")
+	}
+
+	ctx.flushFinalStorageChanges(ctx.activeCallIndex)
+
+	ctx.printer.Print("END_SYSTEM_CALL",
+		Uint64(ctx.nextOrdinal()),
+	)
 }
 
 func (ctx *Context) FinalizeBlock(block *types.Block) {
 	// We must not check if the finalize block is actually in the a block since
 	// when firehose block progress only is enabled, it would hit a panic
-	ctx.printer.Print("FINALIZE_BLOCK", Uint64(block.NumberU64()))
+	ctx.printer.Print("FINALIZE_BLOCK", Uint64(block.NumberU64()), captureTime())
+
+	// When only block progress is enabled, FINALIZE_BLOCK is emitted outside of any block
+	// scope and it's then the last line we will see for this block
+	if !ctx.inBlock.Load() {
+		ctx.endBlockPrinter(block.NumberU64())
+	}
 }
 
+// EndBlock records the end of the block with its size, the size of its RLP encoding as cached
+// when the block was decoded, along its meta carrying the full header and uncle headers as well
+// as the chain's total difficulty up to and including the block. Difficulties are hex encoded
+// at arbitrary precision, the total difficulty exceeding 64 bits on mainnet for long.
 func (ctx *Context) EndBlock(block *types.Block, totalDifficulty *big.Int) {
 	ctx.printer.Print("END_BLOCK",
 		Uint64(block.NumberU64()),
@@ -178,7 +372,19 @@ func (ctx *Context) EndBlock(block *types.Block, totalDifficulty *big.Int) {
 			"uncles":          block.Body().Uncles,
 			"totalDifficulty": (*hexutil.Big)(totalDifficulty),
 		}),
+		captureTime(),
 	)
+
+	if CodeCacheWindow > 0 {
+		knownCodes.add(block.NumberU64(), ctx.blockCodes)
+	}
+
+	if OrdinalsCheckEnabled {
+		ctx.checkOrdinals(block.NumberU64())
+	}
+
+	ctx.resetBlockKeccaks()
+	ctx.endBlockPrinter(block.NumberU64())
 }
 
 // FlushBlock flushes the accumulated context's printer to "stdout" and reset's the
@@ -189,10 +395,31 @@ func (ctx *Context) FlushBlock() {
 	}
 
 	// We flush to stdout only if the received `ctx` accumulated all the Firehose
-	// logs in a buffer. Other context already flushed to stdout.
+	// logs in a buffer. Other context already flushed to stdout. The complete block
+	// is handed over to the sync printer's writer goroutine, see `WaitOutputWritten`
+	// to wait until it's actually written. In finalized only mode, it's buffered until
+	// final instead, see `EmitFinalized`.
+	if v, ok := ctx.printer.(*ToBufferPrinter); ok && FinalizedOnlyEnabled {
+		if err := finalized.add(v.detachCompactBlock(ctx.blockNum, ctx.blockHash)); err != nil {
+			markOutputBroken(fmt.Sprintf("buffering finalized block #%d", ctx.blockNum), err)
+		}
+
+		ctx.exitBlock()
+		return
+	}
+
 	if v, ok := ctx.printer.(*ToBufferPrinter); ok {
-		syncContext.printer.Write(v.buffer.Bytes())
+		if activeBlockStore != nil {
+			activeBlockStore.upload(ctx.blockNum, ctx.blockHash, append([]byte(nil), v.buffer.Bytes()...))
+		}
+
+		if printer, ok := syncContext.printer.(*DelegateToWriterPrinter); ok && CompactBlocksEnabled {
+			printer.printCompactBlock(v.detachCompactBlock(ctx.blockNum, ctx.blockHash))
+		} else {
+			syncContext.printer.Write(v.buffer.Bytes())
+		}
 	}
+	syncContext.endBlockPrinter(ctx.blockNum)
 
 	ctx.exitBlock()
 }
@@ -207,6 +434,10 @@ func (ctx *Context) exitBlock() {
 
 	ctx.resetBlock()
 
+	if v, ok := ctx.printer.(*ToBufferPrinter); ok {
+		v.discardSpill()
+	}
+
 	// We must reset transcation because exit block can be called while a transaction is inflight
 	ctx.resetTransaction()
 }
@@ -229,6 +460,40 @@ func (ctx *Context) CancelBlock(block *types.Block, err error) {
 		Uint64(block.NumberU64()),
 		err.Error(),
 	)
+	ctx.flushPrinter()
+}
+
+// RecordReorg emits a `REORG` message when the canonical chain switches from the old head to
+// the new one, nothing is emitted below `StartBlockNum` nor in finalized only mode.
+func (ctx *Context) RecordReorg(oldHead, newHead *types.Block, commonAncestor uint64) {
+	if ctx == nil || oldHead.NumberU64() < StartBlockNum || FinalizedOnlyEnabled {
+		return
+	}
+
+	ctx.printer.Print("REORG",
+		Uint64(oldHead.NumberU64()),
+		Hash(oldHead.Hash()),
+		Uint64(newHead.NumberU64()),
+		Hash(newHead.Hash()),
+		Uint64(commonAncestor),
+	)
+	ctx.flushPrinter()
+}
+
+// flushPrinter flushes the context's printer if it writes to a buffered output, this
+// is called on block boundaries so the reader never waits on an already processed block.
+func (ctx *Context) flushPrinter() {
+	if v, ok := ctx.printer.(*DelegateToWriterPrinter); ok {
+		v.Flush()
+	}
+}
+
+// endBlockPrinter is like flushPrinter but also lets the output know that the given
+// block was completely written to it.
+func (ctx *Context) endBlockPrinter(blockNum uint64) {
+	if v, ok := ctx.printer.(*DelegateToWriterPrinter); ok {
+		v.EndOfBlock(blockNum)
+	}
 }
 
 // Transaction methods
@@ -241,13 +506,19 @@ func (ctx *Context) StartTransaction(tx *types.Transaction, txIndex uint, baseFe
 	hash := tx.Hash()
 	v, r, s := tx.RawSignatureValues()
 
+	// The network encoding, i.e. the EIP-2718 envelope for typed transactions
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		panic(fmt.Errorf("encode transaction %s: %w", hash, err))
+	}
+
 	ctx.StartTransactionRaw(
 		hash,
 		tx.To(),
 		tx.Value(),
-		v.Bytes(),
-		r.Bytes(),
-		s.Bytes(),
+		signatureBytes(v),
+		signatureBytes(r),
+		signatureBytes(s),
 		tx.Gas(),
 		// Once London is active in the patch set, this `nil` value should become
 		gasPrice(tx, nil),
@@ -260,9 +531,22 @@ func (ctx *Context) StartTransaction(tx *types.Transaction, txIndex uint, baseFe
 		nil,
 		tx.Type(),
 		txIndex,
+		raw,
+		uint64(len(raw)),
+		transactionExtraFields(tx),
 	)
 }
 
+// signatureBytes returns the bytes of a signature value, nil for the transaction types without
+// a signature.
+func signatureBytes(value *big.Int) []byte {
+	if value == nil {
+		return nil
+	}
+
+	return value.Bytes()
+}
+
 func gasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
 	// Once London is active in the patch set, this will not be necessary because DynamicTx should be handled properly
 	_ = baseFee
@@ -272,6 +556,13 @@ func gasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
 		return tx.GasPrice()
 	}
 
+	if txType, found := transactionTypes[tx.Type()]; found {
+		if txType.GasPrice == nil {
+			return nil
+		}
+		return txType.GasPrice(tx)
+	}
+
 	panic(errUnhandledTransactionType("gasPrice", tx.Type()))
 }
 
@@ -293,6 +584,9 @@ func (ctx *Context) StartTransactionRaw(
 	maxPriorityFeePerGas *big.Int,
 	txType uint8,
 	txIndex uint,
+	raw []byte,
+	size uint64,
+	extraFields []byte,
 ) {
 	if ctx == nil {
 		return
@@ -308,6 +602,12 @@ func (ctx *Context) StartTransactionRaw(
 		toAsString = Addr(*to)
 	}
 
+	// Chain specific transaction types may have no gas price, see `TransactionType`
+	gasPriceAsString := "."
+	if gasPrice != nil {
+		gasPriceAsString = Hex(gasPrice.Bytes())
+	}
+
 	// London fork not active in this branch yet, add proper handling here when it's the case (and remove this comment)
 	maxFeePerGasAsString := "."
 	// London fork not active in this branch yet, add proper handling here when it's the case (and remove this comment)
@@ -321,18 +621,25 @@ func (ctx *Context) StartTransactionRaw(
 		Hex(r),
 		Hex(s),
 		Uint64(gasLimit),
-		Hex(gasPrice.Bytes()),
+		gasPriceAsString,
 		Uint64(nonce),
 		Hex(data),
 		Hex(accessList.marshal()),
 		maxFeePerGasAsString,
 		maxPriorityFeePerGasAsString,
 		Uint8(txType),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.nextOrdinal()),
 		Uint(txIndex),
+		Hex(raw),
+		Uint64(size),
+		Hex(extraFields),
 	)
 }
 
+// RecordTrxFrom records the sender of the transaction right after it starts. On block import,
+// it's the sender of the message the transaction is applied as, recovered once by the signer
+// and cached on the transaction, be its signature EIP-155 protected or not, so readers don't
+// need to recover it again.
 func (ctx *Context) RecordTrxFrom(from common.Address) {
 	if ctx == nil {
 		return
@@ -348,23 +655,45 @@ func (ctx *Context) RecordTrxFrom(from common.Address) {
 	)
 }
 
+// RecordTrxIntrinsicGas records the intrinsic gas of the transaction, the gas charged
+// before the top-level call for its base cost, data and access list. It's the amount
+// computed by the state transition so it follows the active fork rules.
+func (ctx *Context) RecordTrxIntrinsicGas(gas uint64) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printer.Print("TRX_INTRINSIC_GAS",
+		Uint64(gas),
+	)
+}
+
 // FlushTransaction flushes the transaction context to the printer of the global context
 // so that the transaction it emitted through the global context printer.
 //
 // It also reset automatically the txContext for future re-use, if desired.
+//
+// Both contexts are owned by the importing goroutine so no locking happens here, the
+// block is handed over to the writer goroutine only once complete, see `FlushBlock`.
 func (ctx *Context) FlushTransaction(txContext *Context) {
 	if ctx == nil || txContext == nil {
 		return
 	}
 
 	if v, ok := txContext.printer.(*ToBufferPrinter); ok {
-		ctx.flushTxLock.Lock()
-		defer ctx.flushTxLock.Unlock()
-
 		ctx.printer.Write(v.buffer.Bytes())
 		v.Reset()
 	}
 
+	for codeHash := range txContext.blockCodes {
+		if ctx.blockCodes == nil {
+			ctx.blockCodes = map[common.Hash]struct{}{}
+		}
+		ctx.blockCodes[codeHash] = struct{}{}
+	}
+
+	ctx.emittedOrdinals = append(ctx.emittedOrdinals, txContext.emittedOrdinals...)
+
 	// Reset the transaction context for future re-use, if desired
 	txContext.Reset()
 }
@@ -382,6 +711,14 @@ func (ctx *Context) Reset() {
 	ctx.resetTransaction()
 }
 
+// EndTransaction records the end of the transaction along with the fields of its receipt, as
+// produced by the state processor, so receipts can be re-assembled as is. The post state is
+// the intermediate state root computed after the transaction on pre-Byzantium blocks and is
+// empty otherwise. The status is the execution result of the transaction on every fork, set by
+// the state processor from the result of applying it, even though pre-Byzantium receipts don't
+// carry it on chain, readers must not derive it from the gas used. The failure of the
+// transaction's top level call, and its original error message encoded with `Text`, end the
+// message and are "." when the transaction succeeded, see `RecordCallFailed`.
 func (ctx *Context) EndTransaction(receipt *types.Receipt) {
 	if ctx == nil {
 		return
@@ -400,19 +737,93 @@ func (ctx *Context) EndTransaction(receipt *types.Receipt) {
 		}
 	}
 
+	// Storage changes made outside of any call, like the genesis allocation's
+	ctx.flushFinalStorageChanges(ctx.activeCallIndex)
+
+	if TouchedAccountsEnabled {
+		ctx.printTouchedAccounts()
+	}
+
+	ctx.printRevertedCalls()
+
 	ctx.printer.Print(
 		"END_APPLY_TRX",
 		Uint64(receipt.GasUsed),
 		Hex(receipt.PostState),
 		Uint64(receipt.CumulativeGasUsed),
 		Hex(receipt.Bloom[:]),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.nextOrdinal()),
 		JSON(logItems),
+		Uint64(receipt.Status),
+		ctx.trxFailure.field(),
+		Text(ctx.trxFailureReason),
 	)
 
 	ctx.resetTransaction()
 }
 
+// RecordTouchedAccount adds the account to the ones accessed by the transaction, printed in
+// a `TRX_TOUCHED_ACCOUNTS` message when the transaction ends. It's only called when
+// `TouchedAccountsEnabled`.
+func (ctx *Context) RecordTouchedAccount(addr common.Address) {
+	if ctx == nil {
+		return
+	}
+
+	if ctx.touchedAccounts == nil {
+		ctx.touchedAccounts = make(map[common.Address]struct{})
+	}
+
+	ctx.touchedAccounts[addr] = struct{}{}
+}
+
+func (ctx *Context) printTouchedAccounts() {
+	addresses := make([]common.Address, 0, len(ctx.touchedAccounts))
+	for addr := range ctx.touchedAccounts {
+		addresses = append(addresses, addr)
+	}
+
+	sort.Slice(addresses, func(i, j int) bool { return bytes.Compare(addresses[i][:], addresses[j][:]) < 0 })
+
+	strAddresses := make([]string, len(addresses))
+	for i, addr := range addresses {
+		strAddresses[i] = Addr(addr)
+	}
+
+	ctx.printer.Print("TRX_TOUCHED_ACCOUNTS",
+		strings.Join(strAddresses, ","),
+	)
+}
+
+// callState is the position of a call in the call tree and whether its state changes were
+// reverted, which is first only known for the calls that failed.
+type callState struct {
+	parent   uint64
+	reverted bool
+}
+
+// printRevertedCalls records in a `TRX_REVERTED_CALLS` message the calls of the transaction
+// whose state changes were reverted, because they failed or one of their ancestors did, so
+// the state changes and logs recorded for them never took effect. Nothing is recorded when
+// all state changes persisted.
+func (ctx *Context) printRevertedCalls() {
+	var reverted []string
+	for i, call := range ctx.calls {
+		// A call's index is always greater than its parent's, the parent's state is final here
+		if !call.reverted && call.parent > 0 && ctx.calls[call.parent-1].reverted {
+			ctx.calls[i].reverted = true
+		}
+
+		if ctx.calls[i].reverted {
+			reverted = append(reverted, strconv.Itoa(i+1))
+		}
+	}
+
+	if len(reverted) > 0 {
+		ctx.printer.Print("TRX_REVERTED_CALLS", strings.Join(reverted, ","))
+	}
+}
+
 // Call methods
 
 func (ctx *Context) StartCall(callType string) {
@@ -420,18 +831,23 @@ func (ctx *Context) StartCall(callType string) {
 		return
 	}
 
+	ctx.flushPendingGasChanges()
 	ctx.printer.Print("EVM_RUN_CALL",
 		callType,
 		ctx.openCall(),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.nextOrdinal()),
 	)
 }
 
 func (ctx *Context) openCall() string {
+	parent, _ := strconv.ParseUint(ctx.activeCallIndex, 10, 64)
+	ctx.calls = append(ctx.calls, callState{parent: parent})
+
 	ctx.nextCallIndex++
 	ctx.activeCallIndex = strconv.FormatUint(ctx.nextCallIndex, 10)
 
 	ctx.callIndexStack.Push(ctx.activeCallIndex)
+	ctx.callGasStack = append(ctx.callGasStack, &callGas{})
 
 	return ctx.activeCallIndex
 }
@@ -445,11 +861,40 @@ func (ctx *Context) callIndex() string {
 	return ctx.activeCallIndex
 }
 
-func (ctx *Context) RecordCallParams(callType string, caller common.Address, callee common.Address, value *big.Int, gasLimit uint64, input []byte) {
+// topLevelCallIndex is the index of the first call of the transaction, the active one when
+// no call was started.
+func (ctx *Context) topLevelCallIndex() string {
+	index := ctx.callIndex()
+	if ctx.nextCallIndex > 0 {
+		index = "1"
+	}
+
+	return index
+}
+
+// RecordCallParams records the parameters of the active call, `value` being the value in
+// effect in the call, the parent's one for a delegate call, and `static` telling if state
+// modifications are forbidden in the call, because it's a static call or is nested in one.
+//
+// The `gasLimit` is the gas provided to the call, what's left of the gas requested by the
+// caller once the 63/64 rule is applied plus the `stipend`, the gas granted for free to calls
+// transferring value which is recorded after `static`.
+//
+// The `storageAddress` is the account whose storage and balance the call's code operates on,
+// which is also the address the code sees as its own, and the code address is the `callee`'s.
+// They are the `callee` except for `CALLCODE` and `DELEGATE` calls, which run the `callee`'s
+// code in the context of the `caller`. Both end the message so readers attribute the state
+// changes of every call type without inferring them from the call type.
+func (ctx *Context) RecordCallParams(callType string, caller common.Address, callee common.Address, value *big.Int, gasLimit uint64, stipend uint64, input []byte, static bool, storageAddress common.Address) {
 	if ctx == nil {
 		return
 	}
 
+	if len(ctx.callGasStack) > 0 {
+		ctx.callGasStack[len(ctx.callGasStack)-1].limit = gasLimit
+		ctx.callGasStack[len(ctx.callGasStack)-1].stipend = stipend
+	}
+
 	ctx.printer.Print("EVM_PARAM",
 		callType,
 		ctx.callIndex(),
@@ -458,6 +903,10 @@ func (ctx *Context) RecordCallParams(callType string, caller common.Address, cal
 		Hex(value.Bytes()),
 		Uint64(gasLimit),
 		Hex(input),
+		Bool(static),
+		Uint64(stipend),
+		Addr(storageAddress),
+		Addr(callee),
 	)
 }
 
@@ -471,25 +920,79 @@ func (ctx *Context) RecordCallWithoutCode() {
 	)
 }
 
-func (ctx *Context) RecordCallFailed(gasLeft uint64, reason string) {
+// RecordCallPrecompiled marks the active call as a call to a precompiled contract which
+// charges `gasCost`, its input and output being the ones of the call.
+func (ctx *Context) RecordCallPrecompiled(gasCost uint64) {
 	if ctx == nil {
 		return
 	}
 
-	ctx.printer.Print("EVM_CALL_FAILED",
+	ctx.printer.Print("PRECOMPILED_CALL",
 		ctx.callIndex(),
+		Uint64(gasCost),
+	)
+}
+
+// RecordCallFailed records that the active call failed, `failure` being the kind of the failure
+// and `reason` the original error message, encoded with `Text` as it may contain spaces. The
+// failure of the top level call is the failure of the transaction, also recorded when it ends.
+func (ctx *Context) RecordCallFailed(gasLeft uint64, failure CallFailure, reason string) {
+	if ctx == nil {
+		return
+	}
+
+	callIndex := ctx.callIndex()
+	if callIndex == "1" {
+		ctx.trxFailure = failure
+		ctx.trxFailureReason = reason
+	}
+
+	if index, _ := strconv.ParseUint(callIndex, 10, 64); index > 0 && index <= uint64(len(ctx.calls)) {
+		ctx.calls[index-1].reverted = true
+	}
+
+	ctx.printer.Print("EVM_CALL_FAILED",
+		callIndex,
 		Uint64(gasLeft),
-		reason,
+		failure.field(),
+		Text(reason),
 	)
 }
 
-func (ctx *Context) RecordCallReverted() {
+// RecordCreateFailed records the address a failed creation was attempted at, the active call
+// being the creation, and whether an account exists at this address once the creation's state
+// changes are reverted, like when the creation collided with an existing contract or when
+// the address was funded beforehand.
+func (ctx *Context) RecordCreateFailed(address common.Address, accountExists bool) {
 	if ctx == nil {
 		return
 	}
 
-	ctx.printer.Print("EVM_REVERTED",
+	ctx.printer.Print("EVM_CREATE_FAILED",
 		ctx.callIndex(),
+		Addr(address),
+		Bool(accountExists),
+	)
+}
+
+// RecordCallReverted records that the active call reverted with the return data, decoding
+// the standard revert payloads to a JSON string and flagging a return data larger than
+// `MaxRevertDataSize`, which is truncated to it when ending the call.
+func (ctx *Context) RecordCallReverted(returnData []byte) {
+	if ctx == nil {
+		return
+	}
+
+	reason := "."
+	if decoded, ok := decodeRevertReason(returnData); ok {
+		reason = JSON(decoded)
+	}
+
+	ctx.revertedCallIndex = ctx.callIndex()
+	ctx.printer.Print("EVM_REVERTED",
+		ctx.revertedCallIndex,
+		Bool(len(returnData) > MaxRevertDataSize),
+		reason,
 	)
 }
 
@@ -500,16 +1003,84 @@ func (ctx *Context) closeCall() string {
 	return previousIndex
 }
 
+// callGas is the gas accounting of a call, to break down the gas it used when it ends.
+type callGas struct {
+	limit           uint64
+	childrenGasUsed uint64
+
+	// stipend is the part of `limit` granted for free by the opcode, not taken from the parent,
+	// and childrenStipends the sum of the ones granted to the children
+	stipend          uint64
+	childrenStipends uint64
+}
+
+// closeCallGas pops the gas accounting of the call ending with `gasLeft`, returning the gas it
+// used itself and the gas its children took from it, the latter being added to the parent's
+// one. The gas used by a child includes the stipend it was granted, which the parent never
+// paid, so the children's stipends are subtracted from the gas they used. A child using less
+// than its stipend gives gas to its parent, whose own gas then exceeds the gas it used.
+func (ctx *Context) closeCallGas(gasLeft uint64) (selfGasUsed, childrenGasUsed uint64) {
+	if len(ctx.callGasStack) == 0 {
+		return 0, 0
+	}
+
+	call := ctx.callGasStack[len(ctx.callGasStack)-1]
+	ctx.callGasStack = ctx.callGasStack[:len(ctx.callGasStack)-1]
+
+	var gasUsed uint64
+	if call.limit > gasLeft {
+		gasUsed = call.limit - gasLeft
+	}
+
+	if call.childrenGasUsed > call.childrenStipends {
+		childrenGasUsed = call.childrenGasUsed - call.childrenStipends
+	}
+	if gasUsed+call.childrenStipends > call.childrenGasUsed {
+		selfGasUsed = gasUsed + call.childrenStipends - call.childrenGasUsed
+	}
+
+	if len(ctx.callGasStack) > 0 {
+		parent := ctx.callGasStack[len(ctx.callGasStack)-1]
+		parent.childrenGasUsed += gasUsed
+		parent.childrenStipends += call.stipend
+	}
+
+	return selfGasUsed, childrenGasUsed
+}
+
+// EndCall records the end of the active call with the data it returned, which is the whole
+// payload of its RETURN or REVERT and not the region copied back in the caller's memory. The
+// return data is capped by `MaxRevertDataSize` for a reverted call and by `MaxReturnDataSize`
+// otherwise, a truncation being flagged after the ordinal. The gas left is returned to the
+// parent, the gas used by the call itself and by its children end the message.
 func (ctx *Context) EndCall(gasLeft uint64, returnValue []byte) {
 	if ctx == nil {
 		return
 	}
 
+	ctx.flushPendingGasChanges()
+	callIndex := ctx.closeCall()
+	selfGasUsed, childrenGasUsed := ctx.closeCallGas(gasLeft)
+	ctx.flushFinalStorageChanges(callIndex)
+
+	maxSize := MaxReturnDataSize
+	if callIndex == ctx.revertedCallIndex {
+		maxSize = MaxRevertDataSize
+	}
+
+	truncated := maxSize > 0 && len(returnValue) > maxSize
+	if truncated {
+		returnValue = returnValue[:maxSize]
+	}
+
 	ctx.printer.Print("EVM_END_CALL",
-		ctx.closeCall(),
+		callIndex,
 		Uint64(gasLeft),
 		Hex(returnValue),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.nextOrdinal()),
+		Bool(truncated),
+		Uint64(selfGasUsed),
+		Uint64(childrenGasUsed),
 	)
 }
 
@@ -517,35 +1088,56 @@ func (ctx *Context) EndCall(gasLeft uint64, returnValue []byte) {
 // like EVM_CALL_FAILED and EVM_REVERTED when it's the case. This is used on early exit in the
 // the instrumentation when a failure (and revertion) occurs to reduce the actual method call
 // peformed.
-func (ctx *Context) EndFailedCall(gasLeft uint64, reverted bool, reason string) {
+//
+// When `reverted` is true, the call is ended with `gasLeft` untouched, i.e. no gas consumed,
+// like for failures occurring before the call's frame executes.
+func (ctx *Context) EndFailedCall(gasLeft uint64, reverted bool, failure CallFailure, reason string) {
 	if ctx == nil {
 		return
 	}
 
-	ctx.RecordCallFailed(gasLeft, reason)
+	ctx.flushPendingGasChanges()
+	ctx.RecordCallFailed(gasLeft, failure, reason)
 
 	if reverted {
-		ctx.RecordCallReverted()
+		ctx.RecordCallReverted(nil)
 	} else {
 		ctx.RecordGasConsume(gasLeft, gasLeft, FailedExecutionGasChangeReason)
 		gasLeft = 0
 	}
 
+	callIndex := ctx.closeCall()
+	selfGasUsed, childrenGasUsed := ctx.closeCallGas(gasLeft)
+	ctx.flushFinalStorageChanges(callIndex)
+
 	ctx.printer.Print("EVM_END_CALL",
-		ctx.closeCall(),
+		callIndex,
 		Uint64(gasLeft),
 		Hex(nil),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.nextOrdinal()),
+		Bool(false),
+		Uint64(selfGasUsed),
+		Uint64(childrenGasUsed),
 	)
 }
 
 // In-call methods
 
+// RecordKeccak records the preimage of a hash computed by the SHA3 opcode, only once per block
+// at its first occurrence unless `KeccakDedupEnabled` is false.
 func (ctx *Context) RecordKeccak(hashOfdata common.Hash, data []byte) {
 	if ctx == nil {
 		return
 	}
 
+	if KeccakDedupEnabled {
+		if _, seen := ctx.blockKeccaks[hashOfdata]; seen {
+			return
+		}
+
+		ctx.blockKeccaks[hashOfdata] = struct{}{}
+	}
+
 	ctx.printer.Print("EVM_KECCAK",
 		ctx.callIndex(),
 		Hash(hashOfdata),
@@ -559,12 +1151,34 @@ func (ctx *Context) RecordGasRefund(gasOld, gasRefund uint64) {
 	}
 
 	if gasRefund != 0 {
+		ctx.flushPendingGasChanges()
 		ctx.printer.Print("GAS_CHANGE",
 			ctx.callIndex(),
 			Uint64(gasOld),
 			Uint64(gasOld+gasRefund),
 			string(RefundAfterExecutionGasChangeReason),
-			Uint64(ctx.totalOrderingCounter.Inc()),
+			Uint64(ctx.nextOrdinal()),
+		)
+	}
+}
+
+// RecordTransactionGasRefund records the refund applied at the end of the transaction as a
+// `GAS_CHANGE` of the top-level call with the `refund` reason, going from the gas left before
+// the refund to the gas left after it. It has the refund counter as an extra field, the refund
+// applied being the counter capped to a fraction of the gas used.
+func (ctx *Context) RecordTransactionGasRefund(gasLeft, refundCounter, refund uint64) {
+	if ctx == nil {
+		return
+	}
+
+	if refundCounter != 0 {
+		ctx.printer.Print("GAS_CHANGE",
+			ctx.topLevelCallIndex(),
+			Uint64(gasLeft),
+			Uint64(gasLeft+refund),
+			string(RefundGasChangeReason),
+			Uint64(ctx.nextOrdinal()),
+			Uint64(refundCounter),
 		)
 	}
 }
@@ -575,28 +1189,127 @@ func (ctx *Context) RecordGasConsume(gasOld, gasConsumed uint64, reason GasChang
 	}
 
 	if gasConsumed != 0 && reason != IgnoredGasChangeReason {
+		ctx.flushPendingGasChanges()
 		ctx.printer.Print("GAS_CHANGE",
 			ctx.callIndex(),
 			Uint64(gasOld),
 			Uint64(gasOld-gasConsumed),
 			string(reason),
-			Uint64(ctx.totalOrderingCounter.Inc()),
+			Uint64(ctx.nextOrdinal()),
+		)
+	}
+}
+
+type pendingGasChange struct {
+	reason      GasChangeReason
+	gasConsumed uint64
+}
+
+// AccumulateGasConsume is `RecordGasConsume` for frequent small charges, like the op codes
+// without a reason of their own or the memory expansions. The gas consumed by the active call
+// is summed per reason and recorded in one GAS_CHANGE per reason right before the call's next
+// gas change, child call or end, the gas changes of the call staying contiguous.
+func (ctx *Context) AccumulateGasConsume(gasOld, gasConsumed uint64, reason GasChangeReason) {
+	if ctx == nil || gasConsumed == 0 {
+		return
+	}
+
+	if len(ctx.pendingGasChanges) == 0 {
+		ctx.pendingGasOld = gasOld
+	}
+
+	for i := range ctx.pendingGasChanges {
+		if ctx.pendingGasChanges[i].reason == reason {
+			ctx.pendingGasChanges[i].gasConsumed += gasConsumed
+			return
+		}
+	}
+
+	ctx.pendingGasChanges = append(ctx.pendingGasChanges, pendingGasChange{reason, gasConsumed})
+}
+
+func (ctx *Context) flushPendingGasChanges() {
+	gasOld := ctx.pendingGasOld
+	for _, change := range ctx.pendingGasChanges {
+		ctx.printer.Print("GAS_CHANGE",
+			ctx.callIndex(),
+			Uint64(gasOld),
+			Uint64(gasOld-change.gasConsumed),
+			string(change.reason),
+			Uint64(ctx.nextOrdinal()),
 		)
+		gasOld -= change.gasConsumed
 	}
+
+	ctx.pendingGasChanges = ctx.pendingGasChanges[:0]
 }
 
+// RecordStorageChange records a storage write, or merges it into the final change of the slot
+// in the active call recorded when the call ends when the `FinalStorageChangesMode` is active.
 func (ctx *Context) RecordStorageChange(addr common.Address, key, oldData, newData common.Hash) {
 	if ctx == nil {
 		return
 	}
 
+	if StorageChanges == FinalStorageChangesMode {
+		ctx.recordFinalStorageChange(addr, key, oldData, newData)
+		return
+	}
+
 	ctx.printer.Print("STORAGE_CHANGE",
 		ctx.callIndex(),
 		Addr(addr),
 		Hash(key),
 		Hash(oldData),
 		Hash(newData),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.nextOrdinal()),
+	)
+}
+
+// RecordStorageRead records the value read by an SLOAD, it's only called when
+// `StorageReadsEnabled` as reads are much more frequent than any other message.
+func (ctx *Context) RecordStorageRead(addr common.Address, key, value common.Hash) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printer.Print("STORAGE_READ",
+		ctx.callIndex(),
+		Addr(addr),
+		Hash(key),
+		Hash(value),
+		Uint64(ctx.nextOrdinal()),
+	)
+}
+
+// RecordAccountAccess records an access to the account checked against the access list, `cold`
+// telling if the account wasn't in it yet, it's only called when `AccessesEnabled`.
+func (ctx *Context) RecordAccountAccess(addr common.Address, cold bool) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printer.Print("ACCOUNT_ACCESS",
+		ctx.callIndex(),
+		Addr(addr),
+		Bool(cold),
+		Uint64(ctx.nextOrdinal()),
+	)
+}
+
+// RecordStorageAccess records an access to the storage slot checked against the access list,
+// `cold` telling if the slot wasn't in it yet, it's only called when `AccessesEnabled`.
+func (ctx *Context) RecordStorageAccess(addr common.Address, key common.Hash, cold bool) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printer.Print("STORAGE_ACCESS",
+		ctx.callIndex(),
+		Addr(addr),
+		Hash(key),
+		Bool(cold),
+		Uint64(ctx.nextOrdinal()),
 	)
 }
 
@@ -617,12 +1330,35 @@ func (ctx *Context) RecordBalanceChange(addr common.Address, oldBalance, newBala
 			BigInt(oldBalance),
 			BigInt(newBalance),
 			string(reason),
-			Uint64(ctx.totalOrderingCounter.Inc()),
+			Uint64(ctx.nextOrdinal()),
 		)
 	}
 }
 
-func (ctx *Context) RecordLog(log *types.Log) {
+// RecordUncleReward records which uncle the next `reward_mine_uncle` balance change pays, the
+// balance change directly following it.
+func (ctx *Context) RecordUncleReward(uncle *types.Header, reward *big.Int) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printer.Print("UNCLE_REWARD",
+		Uint64(uncle.Number.Uint64()),
+		Hash(uncle.Hash()),
+		Addr(uncle.Coinbase),
+		BigInt(reward),
+		Uint64(ctx.nextOrdinal()),
+	)
+}
+
+// RecordLog records the log emitted by the active call. Besides the block index counting every
+// log recorded in the block, it records the log's index in its transaction and in the block as
+// derived in receipts (`log.Index`), the `logIndex` returned by `eth_getLogs`.
+//
+// The receipt indexes don't count the logs of reverted calls, a log of a call that later reverts
+// keeps the indexes it had when emitted and the next log reuses them. Readers tell them apart
+// using the reverted state of the emitting call.
+func (ctx *Context) RecordLog(log *types.Log, trxLogIndex uint) {
 	if ctx == nil {
 		return
 	}
@@ -638,7 +1374,9 @@ func (ctx *Context) RecordLog(log *types.Log) {
 		Addr(log.Address),
 		strings.Join(strtopics, ","),
 		Hex(log.Data),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.nextOrdinal()),
+		Uint(trxLogIndex),
+		Uint(log.Index),
 	)
 }
 
@@ -648,6 +1386,11 @@ func (ctx *Context) logIndexInBlock() string {
 	return current
 }
 
+// RecordSuicide records the selfdestruct of the account, `suicided` telling if it already
+// selfdestructed in the transaction, and the withdrawal of its whole balance. Under the fork
+// rules of this chain, which predate EIP-6780, a selfdestruct always deletes the account and
+// its code at the end of the transaction, whether it was created by the transaction or not,
+// unless the selfdestruct is reverted with its call.
 func (ctx *Context) RecordSuicide(addr common.Address, suicided bool, balanceBeforeSuicide *big.Int) {
 	if ctx == nil {
 		return
@@ -669,46 +1412,104 @@ func (ctx *Context) RecordSuicide(addr common.Address, suicided bool, balanceBef
 	}
 }
 
-func (ctx *Context) RecordNewAccount(addr common.Address) {
+// RecordNewAccount records an account coming into existence, which includes an account
+// created again after it was destructed or deleted for being empty.
+func (ctx *Context) RecordNewAccount(addr common.Address, reason AccountCreationReason) {
 	if ctx == nil {
 		return
 	}
 
-	ctx.printer.Print("CREATED_ACCOUNT",
+	if reason != IgnoredAccountCreationReason {
+		ctx.printer.Print("CREATED_ACCOUNT",
+			ctx.callIndex(),
+			Addr(addr),
+			Uint64(ctx.nextOrdinal()),
+			string(reason),
+		)
+	}
+}
+
+// RecordAccountDeletion records an account removed from the state other than by a selfdestruct,
+// which has its own `SUICIDE_CHANGE` message, like the empty accounts touched by a transaction
+// and deleted when it ends under EIP-158.
+func (ctx *Context) RecordAccountDeletion(addr common.Address, reason AccountDeletionReason) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printer.Print("DELETED_ACCOUNT",
 		ctx.callIndex(),
 		Addr(addr),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.nextOrdinal()),
+		string(reason),
 	)
 }
 
+// RecordCodeChange records a code change not coming from a contract creation, its init code
+// fields are empty, see `RecordCreationCodeChange`. An account without prior code, like in the
+// genesis allocation, has the empty code's hash as old code hash.
 func (ctx *Context) RecordCodeChange(addr common.Address, oldCodeHash, oldCode []byte, newCodeHash common.Hash, newCode []byte) {
 	if ctx == nil {
 		return
 	}
 
+	if len(oldCodeHash) == 0 {
+		oldCodeHash = emptyCodeHash[:]
+	}
+
+	oldCode = ctx.cachedCode(common.BytesToHash(oldCodeHash), oldCode)
+	newCode = ctx.cachedCode(newCodeHash, newCode)
+
+	ctx.printCodeChange(addr, oldCodeHash, oldCode, Hash(newCodeHash), newCode, ".", nil)
+}
+
+// RecordCreationCodeChange records the code change of a contract creation with the init code
+// that executed. When the creation failed, `deployed` is false and the new code fields are
+// empty, while a constructor returning no code has the empty code's hash as new code hash.
+func (ctx *Context) RecordCreationCodeChange(addr common.Address, oldCodeHash common.Hash, initCodeHash common.Hash, initCode []byte, deployed bool, runtimeCode []byte) {
+	if ctx == nil {
+		return
+	}
+
+	newCodeHash := "."
+	if deployed {
+		runtimeCodeHash := crypto.Keccak256Hash(runtimeCode)
+		newCodeHash = Hash(runtimeCodeHash)
+		runtimeCode = ctx.cachedCode(runtimeCodeHash, runtimeCode)
+	}
+
+	ctx.printCodeChange(addr, oldCodeHash[:], nil, newCodeHash, runtimeCode, Hash(initCodeHash), initCode)
+}
+
+func (ctx *Context) printCodeChange(addr common.Address, oldCodeHash, oldCode []byte, newCodeHash string, newCode []byte, initCodeHash string, initCode []byte) {
 	ctx.printer.Print("CODE_CHANGE",
 		ctx.callIndex(),
 		Addr(addr),
 		Hex(oldCodeHash),
 		Hex(oldCode),
-		Hash(newCodeHash),
+		newCodeHash,
 		Hex(newCode),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.nextOrdinal()),
+		initCodeHash,
+		Hex(initCode),
 	)
 }
 
-func (ctx *Context) RecordNonceChange(addr common.Address, oldNonce, newNonce uint64) {
+func (ctx *Context) RecordNonceChange(addr common.Address, oldNonce, newNonce uint64, reason NonceChangeReason) {
 	if ctx == nil {
 		return
 	}
 
-	ctx.printer.Print("NONCE_CHANGE",
-		ctx.callIndex(),
-		Addr(addr),
-		Uint64(oldNonce),
-		Uint64(newNonce),
-		Uint64(ctx.totalOrderingCounter.Inc()),
-	)
+	if reason != IgnoredNonceChangeReason {
+		ctx.printer.Print("NONCE_CHANGE",
+			ctx.callIndex(),
+			Addr(addr),
+			Uint64(oldNonce),
+			Uint64(newNonce),
+			Uint64(ctx.nextOrdinal()),
+			string(reason),
+		)
+	}
 }
 
 // Mempool methods
@@ -795,3 +1596,56 @@ func (l AccessList) marshal() (out []byte) {
 
 	return out[0:offset]
 }
+
+// UnmarshalAccessList decodes an access list from the binary format of the BEGIN_APPLY_TRX
+// `access_list` field, see `AccessList.marshal` for the format.
+func UnmarshalAccessList(in []byte) (AccessList, error) {
+	reader := bytes.NewReader(in)
+
+	count, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read access list length: %w", err)
+	}
+
+	// Each tuple takes at least 21 bytes, it protects against allocating for invalid lengths
+	if count > uint64(reader.Len()/21) {
+		return nil, fmt.Errorf("access list length %d exceeds the remaining %d bytes", count, reader.Len())
+	}
+
+	var out AccessList
+	if count > 0 {
+		out = make(AccessList, count)
+	}
+
+	for i := range out {
+		if _, err := io.ReadFull(reader, out[i].Address[:]); err != nil {
+			return nil, fmt.Errorf("read tuple %d address: %w", i, err)
+		}
+
+		keyCount, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, fmt.Errorf("read tuple %d storage keys length: %w", i, err)
+		}
+
+		if keyCount > uint64(reader.Len()/32) {
+			return nil, fmt.Errorf("tuple %d storage keys length %d exceeds the remaining %d bytes", i, keyCount, reader.Len())
+		}
+
+		if keyCount == 0 {
+			continue
+		}
+
+		out[i].StorageKeys = make([]common.Hash, keyCount)
+		for j := range out[i].StorageKeys {
+			if _, err := io.ReadFull(reader, out[i].StorageKeys[j][:]); err != nil {
+				return nil, fmt.Errorf("read tuple %d storage key %d: %w", i, j, err)
+			}
+		}
+	}
+
+	if reader.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after access list", reader.Len())
+	}
+
+	return out, nil
+}
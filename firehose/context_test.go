@@ -1,12 +1,21 @@
 package firehose
 
 import (
+	"bytes"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
 	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -92,10 +101,154 @@ func TestAccessList_marshal(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			assert.Equal(t, spaceRegex.ReplaceAllString(tt.wantOut, ""), hex.EncodeToString(tt.l.marshal()))
+
+			decoded, err := UnmarshalAccessList(tt.l.marshal())
+			require.NoError(t, err)
+			assert.Equal(t, tt.l, decoded)
+		})
+	}
+}
+
+func TestUnmarshalAccessList_invalid(t *testing.T) {
+	valid := AccessList{{Address: common.HexToAddress("0x02"), StorageKeys: []common.Hash{common.HexToHash("0x01")}}}.marshal()
+
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{"empty", nil},
+		{"truncated address", valid[:10]},
+		{"truncated storage key", valid[:len(valid)-1]},
+		{"trailing bytes", append(append([]byte{}, valid...), 0x00)},
+		{"length overflow", []byte{0xff, 0xff, 0xff, 0xff, 0x0f}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := UnmarshalAccessList(tt.in)
+			assert.Error(t, err)
 		})
 	}
 }
 
+func TestStartTransaction_largeAccessListChunked(t *testing.T) {
+	defer func(previous int) { ChunkThreshold = previous }(ChunkThreshold)
+	ChunkThreshold = 4096
+
+	keys := make([]common.Hash, 5000)
+	for i := range keys {
+		keys[i] = common.BigToHash(big.NewInt(int64(i)))
+	}
+	accessList := AccessList{{Address: common.HexToAddress("0x02"), StorageKeys: keys}, {Address: common.HexToAddress("0x03")}}
+
+	ctx := NewSpeculativeExecutionContext(1024)
+	ctx.StartTransactionRaw(common.HexToHash("0xaa"), nil, big.NewInt(0), nil, nil, nil, 21000, big.NewInt(1), 0, nil, accessList, nil, nil, types.AccessListTxType, 0, nil, 0, nil)
+
+	assembler := &ChunkAssembler{}
+	var record []string
+	for _, fields := range readLines(t, string(ctx.FirehoseLog())) {
+		assert.LessOrEqual(t, len(strings.Join(fields, " ")), ChunkThreshold+64)
+
+		out, err := assembler.Process(fields)
+		require.NoError(t, err)
+		if out != nil {
+			record = out
+		}
+	}
+
+	require.Equal(t, "BEGIN_APPLY_TRX", record[0])
+	encoded, err := DecodeBytes(record[11], ActiveBytesEncoding)
+	require.NoError(t, err)
+
+	decoded, err := UnmarshalAccessList(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, accessList, decoded)
+}
+
+func TestStartTransaction_raw(t *testing.T) {
+	defer func(previous int) { ChunkThreshold = previous }(ChunkThreshold)
+	ChunkThreshold = 4096
+
+	key, _ := crypto.GenerateKey()
+	signer := types.NewEIP2930Signer(big.NewInt(1))
+	to := common.HexToAddress("0x02")
+	data := make([]byte, 10000)
+
+	for _, txData := range []types.TxData{
+		&types.LegacyTx{Nonce: 1, To: &to, Gas: 100000, GasPrice: big.NewInt(1), Data: data},
+		&types.AccessListTx{ChainID: big.NewInt(1), Nonce: 1, To: &to, Gas: 100000, GasPrice: big.NewInt(1), Data: data, AccessList: types.AccessList{{Address: to}}},
+	} {
+		signed, err := types.SignNewTx(key, signer, txData)
+		require.NoError(t, err)
+
+		// A transaction decoded from the network or the database caches the size of its whole
+		// envelope, unlike a signed one
+		encoded, err := signed.MarshalBinary()
+		require.NoError(t, err)
+		received := new(types.Transaction)
+		require.NoError(t, received.UnmarshalBinary(encoded))
+
+		for _, tx := range []*types.Transaction{signed, received} {
+			assertStartTransactionRecord(t, tx)
+		}
+	}
+}
+
+func assertStartTransactionRecord(t *testing.T, tx *types.Transaction) {
+	t.Helper()
+
+	ctx := NewSpeculativeExecutionContext(1024)
+	ctx.StartTransaction(tx, 0, nil)
+
+	assembler := &ChunkAssembler{}
+	var record []string
+	for _, fields := range readLines(t, string(ctx.FirehoseLog())) {
+		out, err := assembler.Process(fields)
+		require.NoError(t, err)
+		if out != nil {
+			record = out
+		}
+	}
+
+	require.Equal(t, "BEGIN_APPLY_TRX", record[0])
+	raw, err := DecodeBytes(record[len(record)-3], ActiveBytesEncoding)
+	require.NoError(t, err)
+
+	expected, err := tx.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, expected, raw)
+
+	// The size is the envelope's for typed transactions
+	assert.Equal(t, strconv.Itoa(len(expected)), record[len(record)-2])
+
+	// Built in types have no extra fields
+	assert.Equal(t, ".", record[len(record)-1])
+
+	decoded := new(types.Transaction)
+	require.NoError(t, decoded.UnmarshalBinary(raw))
+	assert.Equal(t, tx.Hash(), decoded.Hash())
+	assert.Equal(t, tx.Type(), decoded.Type())
+}
+
+func TestStartTransaction_chainSpecificType(t *testing.T) {
+	defer func(previous map[uint8]TransactionType) { transactionTypes = previous }(transactionTypes)
+	transactionTypes = map[uint8]TransactionType{}
+
+	assert.Panics(t, func() { RegisterTransactionType(types.AccessListTxType, TransactionType{}) })
+	RegisterTransactionType(0x7e, TransactionType{})
+	assert.Panics(t, func() { RegisterTransactionType(0x7e, TransactionType{}) })
+
+	// A deposit like transaction, without signature nor gas price and with fields of its own
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+	ctx.StartTransactionRaw(common.HexToHash("0xaa"), nil, big.NewInt(0), nil, nil, nil, 21000, nil, 0, nil, nil, nil, nil, 0x7e, 0, []byte{0x7e}, 1, []byte{0xca, 0xfe})
+
+	fields := strings.Split(strings.TrimSuffix(printer.Buffer().String(), "\n"), " ")
+	assert.Equal(t, ".", fields[9])
+	assert.Equal(t, "126", fields[15])
+	assert.Equal(t, "cafe", fields[len(fields)-1])
+}
+
 func address(t *testing.T, in string) common.Address {
 	t.Helper()
 
@@ -110,3 +263,433 @@ func hash(t *testing.T, in string) common.Hash {
 
 	return common.HexToHash(in)
 }
+
+func TestNewBlockContext_streamingBlocks(t *testing.T) {
+	defer func(enabled, streaming bool) { Enabled, StreamingBlocksEnabled = enabled, streaming }(Enabled, StreamingBlocksEnabled)
+	defer func(previous *bytes.Buffer) { BlockSyncBuffer = previous }(BlockSyncBuffer)
+	BlockSyncBuffer = bytes.NewBuffer(nil)
+
+	Enabled = false
+	assert.Equal(t, NoOpContext, NewBlockContext())
+
+	Enabled = true
+	assert.IsType(t, &ToBufferPrinter{}, NewBlockContext().printer)
+
+	StreamingBlocksEnabled = true
+	assert.Equal(t, syncContext, NewBlockContext())
+}
+
+func TestNewBlockContextAt_startBlockNum(t *testing.T) {
+	defer func(enabled bool, start uint64) { Enabled, StartBlockNum = enabled, start }(Enabled, StartBlockNum)
+	defer func(previous *bytes.Buffer) { BlockSyncBuffer = previous }(BlockSyncBuffer)
+	BlockSyncBuffer = bytes.NewBuffer(nil)
+
+	Enabled, StartBlockNum = true, 10
+	assert.Equal(t, NoOpContext, NewBlockContextAt(9))
+	assert.IsType(t, &ToBufferPrinter{}, NewBlockContextAt(10).printer)
+
+	// Records of the reorgs undoing blocks never emitted are dropped
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, false)
+	ctx.RecordReorg(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(9)}), types.NewBlockWithHeader(&types.Header{Number: big.NewInt(9)}), 7)
+	assert.Empty(t, printer.Buffer().String())
+}
+
+func TestRecordTransactionGasRefund_topLevelCall(t *testing.T) {
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+	ctx.resetTransaction()
+
+	ctx.StartCall("CALL")
+	ctx.StartCall("CALL")
+	ctx.EndCall(10, nil)
+	ctx.EndCall(20, nil)
+	printer.Buffer().Reset()
+
+	ctx.RecordTransactionGasRefund(20, 0, 0)
+	ctx.RecordTransactionGasRefund(20, 40, 15)
+	assert.Equal(t, "FIRE GAS_CHANGE 1 20 35 refund 5 40\n", printer.Buffer().String())
+}
+
+func TestRecordKeccak_dedup(t *testing.T) {
+	defer func(previous bool) { KeccakDedupEnabled = previous }(KeccakDedupEnabled)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	record := func() string {
+		printer := NewToBufferPrinter(1024)
+		ctx := NewContext(printer, true)
+
+		for i := 0; i < 2; i++ {
+			ctx.StartBlock(block, common.Address{})
+			printer.Buffer().Reset()
+
+			ctx.RecordKeccak(common.HexToHash("0xaa"), []byte{0x01})
+			ctx.RecordKeccak(common.HexToHash("0xbb"), []byte{0x02})
+			ctx.RecordKeccak(common.HexToHash("0xaa"), []byte{0x01})
+			ctx.resetBlock()
+		}
+
+		return printer.Buffer().String()
+	}
+
+	KeccakDedupEnabled = true
+	assert.Equal(t, "FIRE EVM_KECCAK 0 "+hashHex("aa")+" 01\nFIRE EVM_KECCAK 0 "+hashHex("bb")+" 02\n", record())
+
+	KeccakDedupEnabled = false
+	assert.Equal(t, "FIRE EVM_KECCAK 0 "+hashHex("aa")+" 01\nFIRE EVM_KECCAK 0 "+hashHex("bb")+" 02\nFIRE EVM_KECCAK 0 "+hashHex("aa")+" 01\n", record())
+}
+
+func TestRecordKeccak_dedupAcrossTransactions(t *testing.T) {
+	defer func(previous bool) { KeccakDedupEnabled = previous }(KeccakDedupEnabled)
+	KeccakDedupEnabled = true
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+	txContext := ctx.NewTransactionContext(new(bytes.Buffer))
+
+	for i := 0; i < 2; i++ {
+		ctx.StartBlock(block, common.Address{})
+		printer.Buffer().Reset()
+
+		// Both transactions hash the same input, its preimage is recorded by the first one only
+		for j := 0; j < 2; j++ {
+			txContext.RecordKeccak(common.HexToHash("0xaa"), []byte{0x01})
+			ctx.FlushTransaction(txContext)
+		}
+		ctx.RecordKeccak(common.HexToHash("0xaa"), []byte{0x01})
+		assert.Equal(t, "FIRE EVM_KECCAK 0 "+hashHex("aa")+" 01\n", printer.Buffer().String(), "block %d", i)
+
+		// The next block records it again
+		ctx.EndBlock(block, big.NewInt(1))
+		ctx.exitBlock()
+	}
+}
+
+func hashHex(in string) string {
+	return hex.EncodeToString(common.HexToHash(in).Bytes())
+}
+
+func TestEndCall_maxReturnDataSize(t *testing.T) {
+	defer func(previous int) { MaxReturnDataSize = previous }(MaxReturnDataSize)
+
+	record := func() string {
+		printer := NewToBufferPrinter(1024)
+		ctx := NewContext(printer, true)
+
+		ctx.StartCall("CALL")
+		ctx.StartCall("STATIC")
+		printer.Buffer().Reset()
+
+		ctx.EndCall(10, []byte{1, 2, 3})
+		ctx.EndCall(20, []byte{4, 5})
+
+		return printer.Buffer().String()
+	}
+
+	MaxReturnDataSize = 0
+	assert.Equal(t, "FIRE EVM_END_CALL 2 10 010203 3 false 0 0\nFIRE EVM_END_CALL 1 20 0405 4 false 0 0\n", record())
+
+	MaxReturnDataSize = 2
+	assert.Equal(t, "FIRE EVM_END_CALL 2 10 0102 3 true 0 0\nFIRE EVM_END_CALL 1 20 0405 4 false 0 0\n", record())
+}
+
+func TestEndCall_valueTransferStipend(t *testing.T) {
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+
+	ctx.StartCall("CALL")
+	ctx.RecordCallParams("CALL", common.Address{}, common.Address{}, big.NewInt(0), 100000, 0, nil, false, common.Address{})
+
+	// The parent pays 9700 for the CALL and 1000 forwarded, the child gets 2300 more for free
+	// and uses 3000
+	ctx.StartCall("CALL")
+	ctx.RecordCallParams("CALL", common.Address{}, common.Address{}, big.NewInt(1), 1000+params.CallStipend, params.CallStipend, nil, false, common.Address{})
+	printer.Buffer().Reset()
+
+	ctx.EndCall(300, nil)
+	ctx.EndCall(100000-9700-1000+300, nil)
+
+	// The parent used 9700 itself, the child 700 of its gas on top of the stipend
+	assert.Equal(t, "FIRE EVM_END_CALL 2 300 . 3 false 3000 0\nFIRE EVM_END_CALL 1 89600 . 4 false 9700 700\n", printer.Buffer().String())
+}
+
+func TestAccumulateGasConsume(t *testing.T) {
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+
+	ctx.StartCall("CALL")
+	printer.Buffer().Reset()
+
+	// Summed per reason until the next recorded gas change, which stays contiguous
+	ctx.AccumulateGasConsume(1000, 3, OpCodeGasChangeReason)
+	ctx.AccumulateGasConsume(997, 6, MemoryExpansionGasChangeReason)
+	ctx.AccumulateGasConsume(991, 3, OpCodeGasChangeReason)
+	ctx.RecordGasConsume(988, 100, GasChangeReason("storage_read"))
+	ctx.AccumulateGasConsume(888, 2, OpCodeGasChangeReason)
+	ctx.EndCall(886, nil)
+
+	assert.Equal(t, "FIRE GAS_CHANGE 1 1000 994 op_code 2\n"+
+		"FIRE GAS_CHANGE 1 994 988 memory_expansion 3\n"+
+		"FIRE GAS_CHANGE 1 988 888 storage_read 4\n"+
+		"FIRE GAS_CHANGE 1 888 886 op_code 5\n"+
+		"FIRE EVM_END_CALL 1 886 . 6 false 0 0\n", printer.Buffer().String())
+}
+
+func TestRecordCallFailed_reason(t *testing.T) {
+	record := func(failure CallFailure, reason string) string {
+		printer := NewToBufferPrinter(1024)
+		ctx := NewContext(printer, true)
+
+		ctx.StartCall("CALL")
+		printer.Buffer().Reset()
+		ctx.RecordCallFailed(10, failure, reason)
+
+		return printer.Buffer().String()
+	}
+
+	// The reason is a single field whatever its content, empty values being "."
+	assert.Equal(t, "FIRE EVM_CALL_FAILED 1 10 revert .\n", record(CallFailure("revert"), ""))
+	assert.Equal(t, "FIRE EVM_CALL_FAILED 1 10 . .\n", record("", ""))
+	assert.Equal(t, "FIRE EVM_CALL_FAILED 1 10 out_of_gas 6f7574206f6620676173\n", record(CallFailure("out_of_gas"), "out of gas"))
+}
+
+// BenchmarkEndCall measures the memory recorded for the return data of a deep trace, each
+// call of the 1024 frames returning 4 KiB.
+func BenchmarkEndCall(b *testing.B) {
+	defer func(previous int) { MaxReturnDataSize = previous }(MaxReturnDataSize)
+
+	returnData := make([]byte, 4096)
+	for _, maxSize := range []int{0, 1024} {
+		b.Run(fmt.Sprintf("max_%d", maxSize), func(b *testing.B) {
+			MaxReturnDataSize = maxSize
+			printer := NewToBufferPrinter(1024)
+			ctx := NewContext(printer, true)
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				printer.Buffer().Reset()
+				for depth := 0; depth < 1024; depth++ {
+					ctx.StartCall("CALL")
+				}
+				for depth := 0; depth < 1024; depth++ {
+					ctx.EndCall(0, returnData)
+				}
+			}
+
+			b.ReportMetric(float64(printer.Buffer().Len()), "bytes/trace")
+		})
+	}
+}
+
+func TestStartSystemCall(t *testing.T) {
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+
+	ctx.StartSystemCall(SystemCallSource("block_finalize"))
+	ctx.RecordBalanceChange(common.HexToAddress("0x02"), big.NewInt(0), big.NewInt(10), BalanceChangeReason("reward_mine_block"))
+	assert.Panics(t, func() { ctx.StartSystemCall(SystemCallSource("block_finalize")) })
+	ctx.EndSystemCall()
+	assert.Panics(t, func() { ctx.EndSystemCall() })
+
+	assert.Equal(t, "FIRE BEGIN_SYSTEM_CALL block_finalize 1\n"+
+		"FIRE BALANCE_CHANGE 0 0000000000000000000000000000000000000002 . 0a reward_mine_block 2\n"+
+		"FIRE END_SYSTEM_CALL 3\n", printer.Buffer().String())
+}
+
+func TestEndBlock_meta(t *testing.T) {
+	header := &types.Header{
+		ParentHash:  common.HexToHash("0x01"),
+		UncleHash:   types.EmptyUncleHash,
+		Coinbase:    common.HexToAddress("0x02"),
+		Root:        common.HexToHash("0x03"),
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Bloom:       types.BytesToBloom([]byte{0x04}),
+		Difficulty:  big.NewInt(131072),
+		Number:      big.NewInt(4370000),
+		GasLimit:    8000000,
+		GasUsed:     21000,
+		Time:        1508131331,
+		Extra:       []byte("extra"),
+		MixDigest:   common.HexToHash("0x05"),
+		Nonce:       types.EncodeNonce(6),
+	}
+	// The maximum of two uncles, one mined before the Byzantium fork block of the including block
+	uncles := []*types.Header{types.CopyHeader(header), types.CopyHeader(header)}
+	uncles[0].Number, uncles[0].Coinbase, uncles[0].Difficulty = big.NewInt(4369999), common.HexToAddress("0xaa"), big.NewInt(131000)
+	uncles[1].Number, uncles[1].Coinbase, uncles[1].Difficulty = big.NewInt(4369994), common.HexToAddress("0xbb"), big.NewInt(130000)
+	block := types.NewBlockWithHeader(header).WithBody(nil, uncles)
+
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+	// Mainnet terminal total difficulty, larger than an uint64
+	td, _ := new(big.Int).SetString("58750003716598352816469", 10)
+	ctx.EndBlock(block, td)
+
+	fields := strings.Split(strings.TrimSuffix(printer.Buffer().String(), "\n"), " ")
+	require.Len(t, fields, 6)
+
+	var meta struct {
+		Header          *types.Header   `json:"header"`
+		Uncles          []*types.Header `json:"uncles"`
+		TotalDifficulty *hexutil.Big    `json:"totalDifficulty"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(fields[4]), &meta))
+
+	// Every field of the header is emitted, the block and uncle hashes can be recomputed from them
+	assert.Equal(t, block.Hash(), meta.Header.Hash())
+	require.Len(t, meta.Uncles, 2)
+	for i, uncle := range uncles {
+		assert.Equal(t, uncle.Hash(), meta.Uncles[i].Hash())
+		assert.Equal(t, uncle.Coinbase, meta.Uncles[i].Coinbase)
+		assert.Equal(t, uncle.Difficulty, meta.Uncles[i].Difficulty)
+	}
+
+	assert.Equal(t, td, meta.TotalDifficulty.ToInt())
+}
+
+func TestEndBlock_terminalDifficulty(t *testing.T) {
+	defer func(previous OutputFormat) { ActiveOutputFormat = previous }(ActiveOutputFormat)
+
+	// The last proof-of-work block of mainnet, its total difficulty being past the terminal one
+	difficulty, _ := new(big.Int).SetString("11055787484078698", 10)
+	td, _ := new(big.Int).SetString("58750003716598352816469", 10)
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(15537393), Difficulty: difficulty})
+
+	for _, format := range []OutputFormat{FireOutputFormat, JSONOutputFormat} {
+		ActiveOutputFormat = format
+		printer := NewToBufferPrinter(1024)
+		NewContext(printer, true).EndBlock(block, td)
+
+		var meta struct {
+			Header          *types.Header `json:"header"`
+			TotalDifficulty *hexutil.Big  `json:"totalDifficulty"`
+		}
+		if format == JSONOutputFormat {
+			var message struct {
+				Meta json.RawMessage `json:"meta"`
+			}
+			require.NoError(t, json.Unmarshal(printer.Buffer().Bytes(), &message))
+			require.NoError(t, json.Unmarshal(message.Meta, &meta))
+		} else {
+			fields := strings.Split(strings.TrimSuffix(printer.Buffer().String(), "\n"), " ")
+			require.NoError(t, json.Unmarshal([]byte(fields[4]), &meta))
+		}
+
+		assert.Equal(t, difficulty, meta.Header.Difficulty, format)
+		assert.Equal(t, td, meta.TotalDifficulty.ToInt(), format)
+	}
+}
+
+func TestEndTransaction_failure(t *testing.T) {
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+	ctx.StartBlock(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}), common.Address{})
+
+	record := func(failures ...CallFailure) string {
+		ctx.StartTransactionRaw(common.Hash{}, nil, &big.Int{}, nil, nil, nil, 0, &big.Int{}, 0, nil, nil, nil, nil, 0, 0, nil, 0, nil)
+		ctx.StartCall("CALL")
+		ctx.StartCall("CALL")
+		ctx.EndFailedCall(0, false, CallFailure("depth_limit"), "max call depth exceeded")
+		for _, failure := range failures {
+			ctx.EndFailedCall(0, true, failure, "out of gas")
+		}
+		if len(failures) == 0 {
+			ctx.EndCall(0, nil)
+		}
+		printer.Buffer().Reset()
+
+		ctx.EndTransaction(&types.Receipt{})
+		lines := strings.Split(strings.TrimSuffix(printer.Buffer().String(), "\n"), "\n")
+		fields := strings.Split(lines[len(lines)-1], " ")
+		return strings.Join(fields[8:], " ")
+	}
+
+	// Only the failure of the top level call is the transaction's
+	assert.Equal(t, "0 . .", record())
+	assert.Equal(t, "0 out_of_gas 6f7574206f6620676173", record(CallFailure("out_of_gas")))
+}
+
+func TestEndTransaction_line(t *testing.T) {
+	record := func(status uint64, failure CallFailure, reason string) string {
+		printer := NewToBufferPrinter(1024)
+		ctx := NewContext(printer, true)
+		ctx.StartBlock(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}), common.Address{})
+		ctx.StartTransactionRaw(common.Hash{}, nil, &big.Int{}, nil, nil, nil, 0, &big.Int{}, 0, nil, nil, nil, nil, 0, 0, nil, 0, nil)
+		ctx.StartCall("CALL")
+		if failure != "" {
+			ctx.EndFailedCall(0, true, failure, reason)
+		} else {
+			ctx.EndCall(0, nil)
+		}
+		printer.Buffer().Reset()
+
+		ctx.EndTransaction(&types.Receipt{GasUsed: 21000, CumulativeGasUsed: 42000, Status: status})
+		lines := strings.Split(strings.TrimSuffix(printer.Buffer().String(), "\n"), "\n")
+		return lines[len(lines)-1]
+	}
+
+	bloom := Hex(types.Bloom{}.Bytes())
+
+	// The failure and its reason are single fields, "." when the transaction succeeded
+	assert.Equal(t, "FIRE END_APPLY_TRX 21000 . 42000 "+bloom+" 4 [] 1 . .", record(types.ReceiptStatusSuccessful, "", ""))
+	assert.Equal(t, "FIRE END_APPLY_TRX 21000 . 42000 "+bloom+" 4 [] 0 revert 657865637574696f6e207265766572746564", record(types.ReceiptStatusFailed, CallFailure("revert"), "execution reverted"))
+}
+
+func TestStartBlock_summary(t *testing.T) {
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+
+	header := &types.Header{Number: big.NewInt(7), GasLimit: 30000000, GasUsed: 42000, Bloom: types.BytesToBloom([]byte{0x01})}
+	tx := types.NewTransaction(0, common.HexToAddress("0x02"), big.NewInt(0), 21000, big.NewInt(1), nil)
+	block := types.NewBlockWithHeader(header).WithBody([]*types.Transaction{tx, tx}, []*types.Header{{Number: big.NewInt(6)}})
+	// On clique, the producer is the signer and not the coinbase
+	signer := common.HexToAddress("0x03")
+	ctx.StartBlock(block, signer)
+
+	fields := strings.Split(strings.TrimSuffix(printer.Buffer().String(), "\n"), " ")
+	require.Len(t, fields, 10)
+	assert.Equal(t, "7", fields[2])
+	assert.Equal(t, []string{hex.EncodeToString(header.Bloom.Bytes()), "42000", "30000000", "2", "1", Addr(signer)}, fields[4:])
+}
+
+func TestEndTransaction_revertedCalls(t *testing.T) {
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+	ctx.StartBlock(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}), common.Address{})
+
+	record := func(calls func()) string {
+		ctx.StartTransactionRaw(common.Hash{}, nil, &big.Int{}, nil, nil, nil, 0, &big.Int{}, 0, nil, nil, nil, nil, 0, 0, nil, 0, nil)
+		calls()
+		printer.Buffer().Reset()
+
+		ctx.EndTransaction(&types.Receipt{})
+		lines := strings.Split(printer.Buffer().String(), "\n")
+		return strings.Join(lines[:len(lines)-2], "\n")
+	}
+
+	// The call 3 succeeded but its parent 2 failed, the call 4 is a sibling of 2
+	assert.Equal(t, "FIRE TRX_REVERTED_CALLS 2,3", record(func() {
+		ctx.StartCall("CALL")
+		ctx.StartCall("CALL")
+		ctx.StartCall("CALL")
+		ctx.EndCall(0, nil)
+		ctx.EndFailedCall(0, true, CallFailure("revert"), "")
+		ctx.StartCall("CALL")
+		ctx.EndCall(0, nil)
+		ctx.EndCall(0, nil)
+	}))
+
+	assert.Equal(t, "FIRE TRX_REVERTED_CALLS 1,2", record(func() {
+		ctx.StartCall("CALL")
+		ctx.StartCall("CALL")
+		ctx.EndCall(0, nil)
+		ctx.EndFailedCall(0, true, CallFailure("revert"), "")
+	}))
+
+	assert.Equal(t, "", record(func() {
+		ctx.StartCall("CALL")
+		ctx.EndCall(0, nil)
+	}))
+}
@@ -0,0 +1,79 @@
+package firehose
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// DeepTraceAddresses are the contracts whose execution is recorded opcode by opcode, in
+// `EVM_OPCODE` messages, to debug them. It's empty by default, the interpreter then never
+// looks it up.
+var DeepTraceAddresses map[common.Address]struct{}
+
+// DeepTraceMaxRecords caps the number of `EVM_OPCODE` messages recorded per call, the
+// following opcodes of a call reaching it are not recorded.
+var DeepTraceMaxRecords = 1000
+
+// DeepTraceStackItems is the number of items from the top of the stack recorded by an
+// `EVM_OPCODE` message.
+var DeepTraceStackItems = 4
+
+// ParseDeepTraceAddresses parses the comma separated list of hex addresses of the
+// contracts to trace, nil when empty.
+func ParseDeepTraceAddresses(in string) (map[common.Address]struct{}, error) {
+	if strings.TrimSpace(in) == "" {
+		return nil, nil
+	}
+
+	addresses := map[common.Address]struct{}{}
+	for _, element := range strings.Split(in, ",") {
+		element = strings.TrimSpace(element)
+		if !common.IsHexAddress(element) {
+			return nil, fmt.Errorf("invalid deep trace address %q", element)
+		}
+
+		addresses[common.HexToAddress(element)] = struct{}{}
+	}
+
+	return addresses, nil
+}
+
+// DeepTraced tells if the execution of the contract's code is recorded opcode by opcode.
+func DeepTraced(addr common.Address) bool {
+	if len(DeepTraceAddresses) == 0 {
+		return false
+	}
+
+	_, found := DeepTraceAddresses[addr]
+	return found
+}
+
+// RecordOpcode records an opcode about to execute in a deep traced contract, with the gas
+// left before it and its cost, the top of the stack, top first, and the memory range it reads
+// or writes, empty when none. It's only called when the code address is `DeepTraced`, at most
+// `DeepTraceMaxRecords` times per call.
+func (ctx *Context) RecordOpcode(pc uint64, opcode string, gas, cost uint64, stackTop []uint256.Int, memoryOffset, memorySize uint64) {
+	if ctx == nil {
+		return
+	}
+
+	stack := make([]string, len(stackTop))
+	for i := range stackTop {
+		stack[i] = Hex(stackTop[i].Bytes())
+	}
+
+	ctx.printer.Print("EVM_OPCODE",
+		ctx.callIndex(),
+		Uint64(pc),
+		opcode,
+		Uint64(gas),
+		Uint64(cost),
+		strings.Join(stack, ","),
+		Uint64(memoryOffset),
+		Uint64(memorySize),
+		Uint64(ctx.nextOrdinal()),
+	)
+}
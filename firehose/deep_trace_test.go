@@ -0,0 +1,25 @@
+package firehose
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeepTraceAddresses(t *testing.T) {
+	addresses, err := ParseDeepTraceAddresses("")
+	require.NoError(t, err)
+	assert.Nil(t, addresses)
+
+	addresses, err = ParseDeepTraceAddresses("0x00000000000000000000000000000000000000c0, 00000000000000000000000000000000000000C1")
+	require.NoError(t, err)
+	assert.Equal(t, map[common.Address]struct{}{
+		common.HexToAddress("0xc0"): {},
+		common.HexToAddress("0xc1"): {},
+	}, addresses)
+
+	_, err = ParseDeepTraceAddresses("0xc0")
+	assert.EqualError(t, err, `invalid deep trace address "0xc0"`)
+}
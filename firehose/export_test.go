@@ -0,0 +1,16 @@
+package firehose
+
+// Exports for the tests of the `firehose_test` package, they import packages depending on
+// this one to record whole chains.
+
+// UpdateGolden tells if the tests update their golden files, see the `-update-golden` flag.
+var UpdateGolden = updateGolden
+
+// SetCaptureTime makes the capture time of the records constant, the returned function
+// restores it.
+func SetCaptureTime(value string) (restore func()) {
+	previous := captureTime
+	captureTime = func() string { return value }
+
+	return func() { captureTime = previous }
+}
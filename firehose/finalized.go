@@ -0,0 +1,182 @@
+package firehose
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// FinalizedOnlyEnabled buffers the executed blocks and only emits them once final, in
+	// canonical order, so a block reorged out is never seen, see `EmitFinalized`. REORG messages
+	// are then never emitted.
+	FinalizedOnlyEnabled = false
+
+	// FinalizedConfirmations is the number of blocks on top of a canonical block for it to be
+	// final, chains have no finality signal before the merge.
+	FinalizedConfirmations uint64 = 64
+
+	// FinalizedBufferSize is the size of the blocks waiting to be final kept in memory, the
+	// following blocks are spilled to temporary files until the buffered ones are emitted.
+	FinalizedBufferSize = 512 * 1024 * 1024
+
+	// FinalizedMaxReplayBlocks is the number of blocks below the head executed at most again on
+	// restart, the buffered blocks being dropped on shutdown, the node fails to start if the last
+	// emitted block or the closest state are further below.
+	FinalizedMaxReplayBlocks uint64 = 8192
+)
+
+// finalizedBuffer holds the executed blocks waiting to be final, by hash as several blocks
+// can be executed at a height, the canonical one being emitted.
+type finalizedBuffer struct {
+	lock sync.Mutex
+
+	// next is the height of the next block emitted, blocks below it are dropped
+	next   uint64
+	blocks map[common.Hash]*compactBlock
+
+	// memory is the size of the buffered blocks not spilled
+	memory int
+}
+
+var finalized = &finalizedBuffer{blocks: map[common.Hash]*compactBlock{}}
+
+// ResumeFinalized drops the buffered blocks and sets the height of the next block emitted in
+// finalized only mode, the one following the last emitted block when the chain starts.
+func ResumeFinalized(next uint64) {
+	finalized.lock.Lock()
+	defer finalized.lock.Unlock()
+
+	finalized.discardBelow(^uint64(0))
+	finalized.next = next
+}
+
+// EmitFinalized emits the buffered blocks final with the canonical head at `head`, in order
+// from the last emitted one, the canonical hash at each height telling which of the blocks
+// executed at that height is emitted. Heights without a buffered canonical block, like the
+// ones below `StartBlockNum`, are skipped. It returns the height of the last final block, the
+// one emission resumes after, and if it moved. A block that can't be read back breaks the
+// output, see `ErrOutputBroken`, the error is returned.
+func EmitFinalized(head uint64, canonicalHash func(number uint64) common.Hash) (last uint64, emitted bool, err error) {
+	finalized.lock.Lock()
+	defer finalized.lock.Unlock()
+
+	if head < FinalizedConfirmations {
+		return 0, false, nil
+	}
+
+	start := finalized.next
+	for ; finalized.next <= head-FinalizedConfirmations; finalized.next++ {
+		hash := canonicalHash(finalized.next)
+		if block, found := finalized.blocks[hash]; found && block.num == finalized.next {
+			delete(finalized.blocks, hash)
+			finalized.memory -= len(block.tail)
+
+			if err := emitFinalizedBlock(block); err != nil {
+				markOutputBroken(fmt.Sprintf("emitting finalized block #%d", block.num), err)
+				return finalized.next - 1, finalized.next > start, err
+			}
+		}
+	}
+
+	// The blocks left at the emitted heights were reorged out
+	finalized.discardBelow(finalized.next)
+
+	return finalized.next - 1, finalized.next > start, nil
+}
+
+// add buffers the block until it's final, it's dropped if a block was already emitted at its
+// height.
+func (b *finalizedBuffer) add(block *compactBlock) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if block.num < b.next {
+		block.discard()
+		return nil
+	}
+
+	if previous, found := b.blocks[block.hash]; found {
+		b.memory -= len(previous.tail)
+		previous.discard()
+	}
+
+	if b.memory+len(block.tail) > FinalizedBufferSize {
+		if err := spillFinalizedBlock(block); err != nil {
+			block.discard()
+			return err
+		}
+	}
+
+	b.blocks[block.hash] = block
+	b.memory += len(block.tail)
+	return nil
+}
+
+func (b *finalizedBuffer) discardBelow(next uint64) {
+	for hash, block := range b.blocks {
+		if block.num < next {
+			delete(b.blocks, hash)
+			b.memory -= len(block.tail)
+			block.discard()
+		}
+	}
+}
+
+// spillFinalizedBlock moves the in memory part of the block to its spill file, creating it
+// if needed.
+func spillFinalizedBlock(block *compactBlock) error {
+	if block.spill == nil {
+		file, err := ioutil.TempFile("", "firehose-finalized-")
+		if err != nil {
+			return fmt.Errorf("create finalized block spill file: %w", err)
+		}
+
+		block.spill = file
+	}
+
+	if _, err := block.spill.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("seek finalized block #%d spill file: %w", block.num, err)
+	}
+
+	if _, err := block.spill.Write(block.tail); err != nil {
+		return fmt.Errorf("write finalized block #%d spill file: %w", block.num, err)
+	}
+
+	block.tail = nil
+	return nil
+}
+
+// emitFinalizedBlock writes the block to the output like `FlushBlock` does when not buffering,
+// the block store upload and the other output settings applying the same.
+func emitFinalizedBlock(block *compactBlock) error {
+	printer, _ := syncContext.printer.(*DelegateToWriterPrinter)
+	if printer != nil && CompactBlocksEnabled && activeBlockStore == nil {
+		printer.printCompactBlock(block)
+		syncContext.endBlockPrinter(block.num)
+		return nil
+	}
+
+	payload := new(bytes.Buffer)
+	err := block.writePayload(payload)
+	block.discard()
+	if err != nil {
+		return fmt.Errorf("read finalized block #%d spill file: %w", block.num, err)
+	}
+
+	if activeBlockStore != nil {
+		activeBlockStore.upload(block.num, block.hash, payload.Bytes())
+	}
+
+	if printer != nil && CompactBlocksEnabled {
+		printer.printCompactBlock(&compactBlock{num: block.num, hash: block.hash, tail: payload.Bytes()})
+	} else {
+		syncContext.printer.Write(payload.Bytes())
+	}
+	syncContext.endBlockPrinter(block.num)
+	return nil
+}
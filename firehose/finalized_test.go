@@ -0,0 +1,85 @@
+package firehose
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmitFinalized(t *testing.T) {
+	defer func(confirmations uint64, bufferSize int, ctx *Context) {
+		FinalizedConfirmations, FinalizedBufferSize, syncContext = confirmations, bufferSize, ctx
+	}(FinalizedConfirmations, FinalizedBufferSize, syncContext)
+	defer ResumeFinalized(0)
+
+	out := bytes.NewBuffer(nil)
+	syncContext = NewContext(NewWriterGoroutinePrinter(out, 16), false)
+
+	// The blocks above the first two don't fit in memory
+	FinalizedConfirmations, FinalizedBufferSize = 2, 20
+
+	block := func(num uint64, branch string) *compactBlock {
+		return &compactBlock{num: num, hash: common.BytesToHash([]byte(branch + Uint64(num))), tail: []byte("BLOCK " + branch + Uint64(num) + "\n")}
+	}
+	canonical := map[uint64]common.Hash{}
+	for _, b := range []*compactBlock{block(1, "a"), block(2, "b"), block(3, "a"), block(4, "a"), block(5, "a")} {
+		canonical[b.num] = b.hash
+	}
+	canonicalHash := func(number uint64) common.Hash { return canonical[number] }
+
+	ResumeFinalized(1)
+	require.NoError(t, finalized.add(block(0, "a")))
+	for _, b := range []*compactBlock{block(1, "a"), block(2, "a"), block(2, "b"), block(3, "a"), block(4, "a")} {
+		require.NoError(t, finalized.add(b))
+	}
+	assert.NotNil(t, finalized.blocks[block(4, "a").hash].spill)
+
+	_, emitted, err := EmitFinalized(1, canonicalHash)
+	require.NoError(t, err)
+	assert.False(t, emitted)
+
+	last, emitted, err := EmitFinalized(3, canonicalHash)
+	require.NoError(t, err)
+	assert.True(t, emitted)
+	assert.Equal(t, uint64(1), last)
+
+	last, emitted, err = EmitFinalized(5, canonicalHash)
+	require.NoError(t, err)
+	assert.True(t, emitted)
+	assert.Equal(t, uint64(3), last)
+
+	// The block reorged out at an emitted height is dropped
+	require.Len(t, finalized.blocks, 1)
+	assert.Contains(t, finalized.blocks, block(4, "a").hash)
+
+	require.NoError(t, WaitOutputWritten())
+	assert.Equal(t, "BLOCK a1\nBLOCK b2\nBLOCK a3\n", out.String())
+
+	// Blocks at emitted heights are never buffered again
+	require.NoError(t, finalized.add(block(3, "c")))
+	assert.Len(t, finalized.blocks, 1)
+}
+
+func TestEmitFinalized_spillFileUnreadable(t *testing.T) {
+	defer func(confirmations uint64, bufferSize int, ctx *Context) {
+		FinalizedConfirmations, FinalizedBufferSize, syncContext = confirmations, bufferSize, ctx
+	}(FinalizedConfirmations, FinalizedBufferSize, syncContext)
+	defer ResumeFinalized(0)
+	defer resetOutputBroken()
+
+	syncContext = NewContext(NewWriterGoroutinePrinter(bytes.NewBuffer(nil), 16), false)
+	FinalizedConfirmations, FinalizedBufferSize = 1, 0
+
+	ResumeFinalized(1)
+	block := &compactBlock{num: 1, hash: common.HexToHash("0x01"), tail: []byte("BLOCK 1\n")}
+	require.NoError(t, finalized.add(block))
+	require.NotNil(t, block.spill)
+	require.NoError(t, block.spill.Close())
+
+	_, _, err := EmitFinalized(2, func(number uint64) common.Hash { return block.hash })
+	assert.Error(t, err)
+	assert.Equal(t, ErrOutputBroken, OutputError())
+}
@@ -0,0 +1,272 @@
+package firehose_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	fixtureKey, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	fixtureSender   = crypto.PubkeyToAddress(fixtureKey.PublicKey)
+	fixtureCoinbase = common.HexToAddress("0xc0ffee")
+	fixtureCaller   = common.HexToAddress("0xa1")
+	fixtureCallee   = common.HexToAddress("0xb1")
+)
+
+// forkSchedule lists the forks in activation order, a fixture activates its fork at block 1
+// on top of the previous ones active from genesis.
+var forkSchedule = []struct {
+	name     string
+	activate func(config *params.ChainConfig, at *big.Int)
+}{
+	{"frontier", func(config *params.ChainConfig, at *big.Int) {}},
+	{"homestead", func(config *params.ChainConfig, at *big.Int) { config.HomesteadBlock = at }},
+	{"eip150", func(config *params.ChainConfig, at *big.Int) { config.EIP150Block = at }},
+	{"eip158", func(config *params.ChainConfig, at *big.Int) { config.EIP155Block, config.EIP158Block = at, at }},
+	{"byzantium", func(config *params.ChainConfig, at *big.Int) { config.ByzantiumBlock = at }},
+	{"constantinople", func(config *params.ChainConfig, at *big.Int) {
+		config.ConstantinopleBlock, config.PetersburgBlock = at, at
+	}},
+	{"istanbul", func(config *params.ChainConfig, at *big.Int) { config.IstanbulBlock = at }},
+	{"berlin", func(config *params.ChainConfig, at *big.Int) { config.BerlinBlock = at }},
+}
+
+func forkConfig(fork string) *params.ChainConfig {
+	config := &params.ChainConfig{ChainID: big.NewInt(1), Ethash: new(params.EthashConfig)}
+	for _, scheduled := range forkSchedule {
+		if scheduled.name == fork {
+			scheduled.activate(config, big.NewInt(1))
+			return config
+		}
+		scheduled.activate(config, big.NewInt(0))
+	}
+
+	panic("unknown fork " + fork)
+}
+
+// revertWithReason is the code of a contract reverting with `Error("boom")`.
+func revertWithReason() []byte {
+	reason := common.FromHex("0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000004" +
+		"626f6f6d00000000000000000000000000000000000000000000000000000000")
+
+	code := []byte{
+		byte(vm.PUSH1), byte(len(reason)), byte(vm.PUSH1), 12, byte(vm.PUSH1), 0, byte(vm.CODECOPY),
+		byte(vm.PUSH1), byte(len(reason)), byte(vm.PUSH1), 0, byte(vm.REVERT),
+	}
+	return append(code, reason...)
+}
+
+// callCallee is the code of a contract calling the callee with `op`, forwarding all its gas
+// or 30000 gas, before EIP-150 a call asking more gas than left fails.
+func callCallee(op vm.OpCode, allGas bool) []byte {
+	code := []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0}
+	if op == vm.CALL {
+		code = append(code, byte(vm.PUSH1), 0)
+	}
+	code = append(code, byte(vm.PUSH1), fixtureCallee[19])
+	if allGas {
+		code = append(code, byte(vm.GAS))
+	} else {
+		code = append(code, byte(vm.PUSH2), 0x75, 0x30)
+	}
+	return append(code, byte(op), byte(vm.POP), byte(vm.STOP))
+}
+
+// storeCaller is the code of a contract storing its caller in slot 0.
+var storeCaller = []byte{byte(vm.CALLER), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+
+// forkFixtures are the hand-built chains exercising the distinctive behavior of each fork, in
+// transactions sent to the contracts of their genesis.
+var forkFixtures = []struct {
+	fork  string
+	alloc core.GenesisAlloc
+	txs   func(signer types.Signer) []types.TxData
+}{
+	{
+		fork: "frontier",
+		txs: func(signer types.Signer) []types.TxData {
+			return []types.TxData{
+				&types.LegacyTx{To: &fixtureCallee, Value: big.NewInt(1), Gas: 21000, GasPrice: big.NewInt(1)},
+				// Init code storing 1 in slot 0 and deploying `STOP`
+				&types.LegacyTx{Nonce: 1, Gas: 100000, GasPrice: big.NewInt(1), Data: common.FromHex("0x600160005560006000f3")},
+			}
+		},
+	},
+	{
+		// DELEGATECALL runs the callee's code on the caller's storage
+		fork:  "homestead",
+		alloc: core.GenesisAlloc{fixtureCaller: {Balance: big.NewInt(0), Code: callCallee(vm.DELEGATECALL, false)}, fixtureCallee: {Balance: big.NewInt(0), Code: storeCaller}},
+		txs: func(signer types.Signer) []types.TxData {
+			return []types.TxData{&types.LegacyTx{To: &fixtureCaller, Gas: 100000, GasPrice: big.NewInt(1)}}
+		},
+	},
+	{
+		// Calls forward at most 63/64 of the gas left
+		fork:  "eip150",
+		alloc: core.GenesisAlloc{fixtureCaller: {Balance: big.NewInt(0), Code: callCallee(vm.CALL, true)}, fixtureCallee: {Balance: big.NewInt(0), Code: storeCaller}},
+		txs: func(signer types.Signer) []types.TxData {
+			return []types.TxData{&types.LegacyTx{To: &fixtureCaller, Gas: 100000, GasPrice: big.NewInt(1)}}
+		},
+	},
+	{
+		// Touching an empty account deletes it, transactions are replay protected
+		fork:  "eip158",
+		alloc: core.GenesisAlloc{fixtureCallee: {Balance: big.NewInt(0)}},
+		txs: func(signer types.Signer) []types.TxData {
+			return []types.TxData{&types.LegacyTx{To: &fixtureCallee, Gas: 21000, GasPrice: big.NewInt(1)}}
+		},
+	},
+	{
+		// Receipts have a status, REVERT returns a reason and STATICCALL forbids state changes
+		fork: "byzantium",
+		alloc: core.GenesisAlloc{
+			fixtureCaller:                     {Balance: big.NewInt(0), Code: callCallee(vm.STATICCALL, true)},
+			fixtureCallee:                     {Balance: big.NewInt(0), Code: storeCaller},
+			common.HexToAddress("0xdeaddead"): {Balance: big.NewInt(0), Code: revertWithReason()},
+		},
+		txs: func(signer types.Signer) []types.TxData {
+			reverting := common.HexToAddress("0xdeaddead")
+			return []types.TxData{
+				&types.LegacyTx{Nonce: 0, To: &fixtureCaller, Gas: 100000, GasPrice: big.NewInt(1)},
+				&types.LegacyTx{Nonce: 1, To: &reverting, Gas: 100000, GasPrice: big.NewInt(1)},
+			}
+		},
+	},
+	{
+		// CREATE2 deploys at an address derived from a salt, `PUSH1 0 PUSH1 0 RETURN` being the init code
+		fork: "constantinople",
+		alloc: core.GenesisAlloc{fixtureCaller: {Balance: big.NewInt(0), Code: []byte{
+			byte(vm.PUSH5), 0x60, 0x00, 0x60, 0x00, 0xf3, byte(vm.PUSH1), 0, byte(vm.MSTORE),
+			byte(vm.PUSH1), 1, byte(vm.PUSH1), 5, byte(vm.PUSH1), 27, byte(vm.PUSH1), 0, byte(vm.CREATE2),
+			byte(vm.POP), byte(vm.STOP),
+		}}},
+		txs: func(signer types.Signer) []types.TxData {
+			return []types.TxData{&types.LegacyTx{To: &fixtureCaller, Gas: 100000, GasPrice: big.NewInt(1)}}
+		},
+	},
+	{
+		// Net gas metering refunds a slot restored to its original value, CHAINID and SELFBALANCE
+		fork: "istanbul",
+		alloc: core.GenesisAlloc{fixtureCaller: {Balance: big.NewInt(0), Code: []byte{
+			byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.SSTORE),
+			byte(vm.CHAINID), byte(vm.PUSH1), 1, byte(vm.SSTORE), byte(vm.SELFBALANCE), byte(vm.PUSH1), 2, byte(vm.SSTORE),
+			byte(vm.STOP),
+		}}},
+		txs: func(signer types.Signer) []types.TxData {
+			return []types.TxData{&types.LegacyTx{To: &fixtureCaller, Value: big.NewInt(7), Gas: 100000, GasPrice: big.NewInt(1)}}
+		},
+	},
+	{
+		// Access list transactions warm up slots and accounts, the others are cold
+		fork: "berlin",
+		alloc: core.GenesisAlloc{fixtureCaller: {Balance: big.NewInt(0), Code: []byte{
+			byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.PUSH1), 1, byte(vm.SLOAD), byte(vm.ADD), byte(vm.POP),
+			byte(vm.PUSH1), fixtureCallee[19], byte(vm.BALANCE), byte(vm.POP), byte(vm.STOP),
+		}}},
+		txs: func(signer types.Signer) []types.TxData {
+			return []types.TxData{&types.AccessListTx{
+				ChainID:    signer.ChainID(),
+				To:         &fixtureCaller,
+				Gas:        100000,
+				GasPrice:   big.NewInt(1),
+				AccessList: types.AccessList{{Address: fixtureCaller, StorageKeys: []common.Hash{{}}}},
+			}}
+		},
+	},
+}
+
+// recordForkFixture imports the fixture's chain of a single block in a blockchain and returns
+// the Firehose output from its genesis. The `INIT` messages are left out, they change with
+// the node's version.
+func recordForkFixture(t *testing.T, config *params.ChainConfig, alloc core.GenesisAlloc, txs []types.TxData) []byte {
+	defer func(enabled bool, genesis interface{}, buffer *bytes.Buffer) {
+		firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = enabled, genesis, buffer
+		firehose.SetWriter(nil)
+	}(firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer)
+	defer firehose.SetCaptureTime("1600000000000000000")()
+
+	genesisAlloc := core.GenesisAlloc{fixtureSender: {Balance: big.NewInt(1000000000000000000)}}
+	for addr, account := range alloc {
+		genesisAlloc[addr] = account
+	}
+	gspec := &core.Genesis{Config: config, Alloc: genesisAlloc}
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+
+	signer := types.LatestSigner(config)
+	blocks, _ := core.GenerateChain(config, genesis, ethash.NewFaker(), db, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(fixtureCoinbase)
+		for _, data := range txs {
+			tx, err := types.SignNewTx(fixtureKey, signer, data)
+			require.NoError(t, err)
+			b.AddTx(tx)
+		}
+	})
+
+	output := &bytes.Buffer{}
+	firehose.SetWriter(output)
+	firehose.Enabled, firehose.GenesisConfig, firehose.BlockSyncBuffer = true, gspec, &bytes.Buffer{}
+
+	blockchain, err := core.NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	require.NoError(t, err)
+	defer blockchain.Stop()
+
+	_, err = blockchain.InsertChain(blocks)
+	require.NoError(t, err)
+	require.NoError(t, firehose.WaitOutputWritten())
+
+	var lines []string
+	for _, line := range strings.SplitAfter(output.String(), "\n") {
+		if !strings.HasPrefix(line, "FIRE INIT ") {
+			lines = append(lines, line)
+		}
+	}
+	return []byte(strings.Join(lines, ""))
+}
+
+func TestForkFixtures_golden(t *testing.T) {
+	for _, fixture := range forkFixtures {
+		t.Run(fixture.fork, func(t *testing.T) {
+			config := forkConfig(fixture.fork)
+			actual := recordForkFixture(t, config, fixture.alloc, fixture.txs(types.LatestSigner(config)))
+
+			golden := filepath.Join("testdata", "forks", fixture.fork+".golden")
+			if *firehose.UpdateGolden {
+				require.NoError(t, ioutil.WriteFile(golden, actual, 0644))
+			}
+
+			expected, err := ioutil.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(actual), "run the tests with -update-golden to update %s", golden)
+		})
+	}
+}
+
+func TestForkFixtures_coverEveryFork(t *testing.T) {
+	covered := map[string]bool{}
+	for _, fixture := range forkFixtures {
+		covered[fixture.fork] = true
+	}
+
+	for _, scheduled := range forkSchedule {
+		assert.True(t, covered[scheduled.name], "fork %s has no fixture", scheduled.name)
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"reflect"
+	"strconv"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 )
@@ -42,19 +46,227 @@ var MiningEnabled = false
 // precedence over this setting.
 var BlockProgressEnabled = false
 
+// StreamingBlocksEnabled makes the records of blocks being imported written to the output as
+// they are produced instead of being staged in a per-block buffer written at once after the
+// block ends, see `NewBlockContext`.
+//
+// Streaming saves the memory of the staged block but if the node is killed in the middle of
+// a block, the output ends with a partial block that the reader has to discard, while with
+// staged blocks, the output can only ever be truncated at a block boundary.
+var StreamingBlocksEnabled = false
+
+// StartBlockNum is the height from which blocks are emitted, the blocks below it are fully
+// processed but recorded by `NoOpContext` so nothing of them is serialized, see
+// `NewBlockContextAt`. The INIT handshake and the genesis block are still emitted, the sink
+// knows the chain it follows before the first block.
+var StartBlockNum uint64 = 0
+
+// AllowNonArchive lets Firehose be enabled on a node syncing in a mode that doesn't execute
+// every block, like snap or fast sync, see `ValidateSyncMode`. The blocks below the sync pivot
+// have no Firehose data, the range skipped is reported in a `SKIPPED_RANGE` message when the
+// pivot becomes the head.
+var AllowNonArchive = false
+
+// StorageReadsEnabled makes each SLOAD recorded as a `STORAGE_READ` message, it's off by
+// default as reads are very high volume.
+var StorageReadsEnabled = false
+
+// AccessesEnabled makes each account and storage slot access checked against the EIP-2929
+// access list recorded as an `ACCOUNT_ACCESS` or `STORAGE_ACCESS` message telling if the
+// access was cold, from Berlin on only. It's off by default as accesses are very high volume.
+var AccessesEnabled = false
+
+// TouchedAccountsEnabled records at the end of each transaction the accounts accessed by
+// BALANCE, EXTCODESIZE, EXTCODEHASH, EXTCODECOPY, the CALL family and SELFDESTRUCT, changed
+// or not, it's off by default as the list can be large.
+var TouchedAccountsEnabled = false
+
+// KeccakDedupEnabled records the preimage of each hash computed by the SHA3 opcode once per
+// block, at its first occurrence, instead of once per occurrence.
+var KeccakDedupEnabled = true
+
+// MaxReturnDataSize, when non-zero, caps the return data recorded for each successful call,
+// larger payloads are truncated which is flagged in the `EVM_END_CALL` message. The return
+// data of reverted calls is capped by `MaxRevertDataSize` instead.
+var MaxReturnDataSize = 0
+
 // GenesisConfig keeps globally for the process the genesis config of the chain.
 // The genesis config extracted from the initialization code of Geth, otherwise
 // the operator will need to set the flag `--firehose-genesis-file` pointing
-// it to correct genesis.json file for the chain.
+// it to correct genesis.json file for the chain. Left unset, the blockchain
+// falls back to the embedded genesis of the known network whose genesis block
+// is stored in the database, if any.
 //
 // **Note** We use `interface{}` here instead of `*core.Genesis` because we otherwise
 // have a compilation cycle because `core` package already uses `firehose` package.
 // Consumer of this library make the cast back to the correct types when needed.
 var GenesisConfig interface{}
 
+// OutputConfig groups the settings controlling where Firehose lines are written.
+type OutputConfig struct {
+	// Outputs lists the output specs ('stdout', 'file:<path>', 'fd:<number>', 'socket:<path>' or 'pipe:<path>')
+	// Firehose lines are all written to, the first one is the primary output whose failures
+	// are fatal, failures of the others are only logged.
+	Outputs []string
+
+	// File is the path of the file Firehose lines are appended to, when empty, lines
+	// are written to stdout.
+	File string
+
+	// FileMaxSize, when non-zero, makes File the prefix of numbered files `<File>.<index>`,
+	// rolling to the next file on the first block boundary after reaching this size in bytes.
+	FileMaxSize int64
+
+	// FD is an inherited file descriptor Firehose lines are written to, 0 or 1 means stdout.
+	FD int
+
+	// Socket is the path of a Unix domain socket Firehose lines are streamed to. By
+	// default the socket is dialed, SocketListen makes Firehose listen on it instead.
+	Socket       string
+	SocketListen bool
+
+	// SocketBufferSize is the maximum of bytes retained while no socket peer is connected
+	// before block import blocks waiting for one.
+	SocketBufferSize int
+
+	// Pipe is the path of a named pipe Firehose lines are written to, the pipe is re-opened
+	// when its consumer restarts and the last PipeRetention blocks are replayed to it.
+	Pipe          string
+	PipeRetention int
+
+	// TCP is the `<host>:<port>` of a remote collector Firehose lines are streamed to, the
+	// collector is re-dialed when the connection breaks and the last TCPRetention blocks
+	// are replayed to it. The connection uses TLS when any of the TLS settings is set,
+	// TLSCert and TLSKey being the client certificate and TLSCA the authority verifying
+	// the collector.
+	TCP          string
+	TCPRetention int
+	TLSCert      string
+	TLSKey       string
+	TLSCA        string
+
+	// Encoding is the encoding of each message, either "line" (default when empty) or "framed".
+	Encoding string
+
+	// BytesEncoding is the encoding of byte fields, "hex" (default when empty) or "base64".
+	BytesEncoding string
+
+	// Compression is the compression applied to the output stream, "none" (default when
+	// empty) or "gzip".
+	Compression string
+
+	// FlushInterval is either a byte size or a duration controlling when buffered output
+	// is written in between block boundaries, see `ParseFlushPolicy`.
+	FlushInterval string
+
+	// BufferSize is the depth of the queue feeding the goroutine writing to the output,
+	// when 0, the output is written synchronously from the block import path.
+	BufferSize int
+
+	// BufferFullPolicy is what to do when the output queue is full, "block" (default when
+	// empty) or "crash".
+	BufferFullPolicy string
+
+	// OnWriteError is what to do when writing to the output fails, "crash" (default when
+	// empty) or "retry".
+	OnWriteError string
+
+	// WriteRetryDeadline is how long a failing write is retried when `OnWriteError` is
+	// "retry", the node crashes once it elapsed.
+	WriteRetryDeadline time.Duration
+
+	// LineSequence appends a per-process sequence number to every message, see `LineSequenceEnabled`.
+	LineSequence bool
+
+	// HeartbeatInterval is the interval at which heartbeats are emitted, see `HeartbeatInterval`.
+	HeartbeatInterval time.Duration
+
+	// CompactBlocks emits each block as two messages, see `CompactBlocksEnabled`, blocks larger
+	// than CompactBlocksSpillSize bytes (default when 0) are accumulated in a temporary file.
+	CompactBlocks          bool
+	CompactBlocksSpillSize int
+
+	// LegacyDMLog emits messages in the legacy deep mind syntax, "none" (default when empty),
+	// "dual" or "only", see `LegacyDMLogMode`.
+	LegacyDMLog string
+
+	// ChunkThreshold is the size above which fields are chunked, see `ChunkThreshold`.
+	ChunkThreshold int
+
+	// ReplayBlocks and ReplaySize bound the last emitted blocks retained in memory to be served
+	// over HTTP, see `ReplayHandler`, the replay buffer is disabled when ReplayBlocks is 0 and
+	// ReplaySize defaults to `ReplaySize` when 0.
+	ReplayBlocks int
+	ReplaySize   int
+
+	// LinePrefix is the token starting every line, `DefaultLinePrefix` when empty, see `LinePrefix`.
+	LinePrefix string
+
+	// RecordStorageReads records storage reads, see `StorageReadsEnabled`.
+	RecordStorageReads bool
+
+	// RecordAccesses records the account and storage slot accesses, see `AccessesEnabled`.
+	RecordAccesses bool
+
+	// RecordTouchedAccounts records the accounts touched by transactions, see `TouchedAccountsEnabled`.
+	RecordTouchedAccounts bool
+
+	// MaxReturnDataSize caps the return data recorded for each call, see `MaxReturnDataSize`.
+	MaxReturnDataSize int
+
+	// CodeCacheWindow is the number of blocks the reader keeps codes for, see `CodeCacheWindow`.
+	CodeCacheWindow int
+
+	// StorageChanges is "all" (default when empty) or "final", see `StorageChangesMode`.
+	StorageChanges string
+
+	// DisableKeccakDedup records keccak preimages on each occurrence, see `KeccakDedupEnabled`.
+	DisableKeccakDedup bool
+
+	// DeepTraceAddresses is the comma separated list of contracts recorded opcode by opcode,
+	// see `DeepTraceAddresses`, and DeepTraceMaxRecords caps their records per call, the
+	// default being kept when 0.
+	DeepTraceAddresses  string
+	DeepTraceMaxRecords int
+
+	// CheckOrdinals panics when a block's ordinals are not contiguous, see `OrdinalsCheckEnabled`.
+	CheckOrdinals bool
+
+	// Format is the syntax of messages, "fire" (default when empty) or "json", see `OutputFormat`.
+	Format string
+
+	// StreamingBlocks writes block records as they are produced, see `StreamingBlocksEnabled`.
+	StreamingBlocks bool
+
+	// AllowNonArchive accepts sync modes not executing every block, see `AllowNonArchive`.
+	AllowNonArchive bool
+
+	// StartBlockNum is the height from which blocks are emitted, see `StartBlockNum`.
+	StartBlockNum uint64
+
+	// StopBlockNum is the last block emitted before the node shuts down, see `StopBlockNum`.
+	StopBlockNum uint64
+
+	// FinalizedOnly buffers blocks until final, see `FinalizedOnlyEnabled`, they are final with
+	// FinalizedConfirmations blocks on top of them and FinalizedBufferSize bytes of them are kept
+	// in memory, see `FinalizedConfirmations` and `FinalizedBufferSize`.
+	FinalizedOnly          bool
+	FinalizedConfirmations uint64
+	FinalizedBufferSize    int
+
+	// BlockStoreURL, when set, is the `file://` or `s3://` URL of the store the Firehose
+	// payload of each block is uploaded to as a `<num>-<hash>.fireblock` object, in addition
+	// to the output. Uploads are performed by BlockStoreConcurrency goroutines and block import
+	// waits once BlockStoreWindow blocks ahead of the oldest pending upload.
+	BlockStoreURL         string
+	BlockStoreConcurrency int
+	BlockStoreWindow      int
+}
+
 var MissingGenesisPanicMessage = "Firehose requires to have the genesis config to properly emit genesis block for this chain " +
 	"but it appears it was not set properly. Ensure you are using either chain's specific flag like " +
-	"'--mainnet' or if using a custom network, you can use '--firehose-genesis' flag to provide. Firehose " +
+	"'--goerli', known networks being otherwise detected from the stored genesis block, or if using a custom " +
+	"network, you can use '--firehose-genesis-file' flag to provide. Firehose " +
 	"is going to validate it against what your Geth database contains, so can be sure that it's going to " +
 	"match what the databse have."
 
@@ -70,6 +282,8 @@ func Init(
 	genesis interface{},
 	genesisFile string,
 	newGenesis func() interface{},
+	genesisHash func(genesis interface{}) common.Hash,
+	outputConfig OutputConfig,
 	gethVersion string,
 ) error {
 	log.Debug("Initializing firehose")
@@ -84,28 +298,265 @@ func Init(
 	if !isNilInterfaceOrNilValue(genesis) {
 		GenesisConfig = genesis
 		genesisProvenance = "Geth Specific Flag (--<chain>)"
-	} else {
-		if genesisFilePath := genesisFile; genesisFilePath != "" {
-			file, err := os.Open(genesisFilePath)
+
+		// The file is redundant with the network's embedded genesis, it must at least agree with it
+		if genesisFile != "" {
+			fileGenesis, err := readGenesisFile(genesisFile, newGenesis)
 			if err != nil {
-				return fmt.Errorf("firehose open genesis file: %w", err)
+				return err
 			}
-			defer file.Close()
 
-			var genesis = newGenesis()
-			if err := json.NewDecoder(file).Decode(genesis); err != nil {
-				return fmt.Errorf("decode genesis file %q: %w", genesisFilePath, err)
+			if fileHash, expectedHash := genesisHash(fileGenesis), genesisHash(genesis); fileHash != expectedHash {
+				return fmt.Errorf("genesis file %q conflicts with the network's embedded genesis, its genesis block hash is %s but %s is expected", genesisFile, fileHash, expectedHash)
 			}
-
-			GenesisConfig = genesis
-			genesisProvenance = "Firehose Specific Flag (--firehose-genesis <file>)"
 		}
+	} else if genesisFile != "" {
+		fileGenesis, err := readGenesisFile(genesisFile, newGenesis)
+		if err != nil {
+			return err
+		}
+
+		GenesisConfig = fileGenesis
+		genesisProvenance = "Firehose Specific Flag (--firehose-genesis <file>)"
 	}
 
 	if Enabled {
 		AllocateBuffers()
 	}
 
+	var features []string
+	if outputConfig.Encoding != "" {
+		encoding, err := ParseOutputEncoding(outputConfig.Encoding)
+		if err != nil {
+			return fmt.Errorf("firehose output: %w", err)
+		}
+
+		Encoding = encoding
+		if Encoding != LineOutputEncoding {
+			features = append(features, "encoding="+string(Encoding))
+		}
+	}
+
+	bytesEncoding, err := ParseBytesEncoding(outputConfig.BytesEncoding)
+	if err != nil {
+		return fmt.Errorf("firehose output: %w", err)
+	}
+
+	ActiveBytesEncoding = bytesEncoding
+	if ActiveBytesEncoding != HexBytesEncoding {
+		features = append(features, "bytes_encoding="+string(ActiveBytesEncoding))
+	}
+
+	LineSequenceEnabled = outputConfig.LineSequence
+	if LineSequenceEnabled {
+		features = append(features, "line_sequence=true")
+	}
+
+	HeartbeatInterval = outputConfig.HeartbeatInterval
+
+	StorageReadsEnabled = outputConfig.RecordStorageReads
+	if StorageReadsEnabled {
+		features = append(features, "storage_reads=true")
+	}
+
+	AccessesEnabled = outputConfig.RecordAccesses
+	if AccessesEnabled {
+		features = append(features, "accesses=true")
+	}
+
+	TouchedAccountsEnabled = outputConfig.RecordTouchedAccounts
+	if TouchedAccountsEnabled {
+		features = append(features, "touched_accounts=true")
+	}
+
+	MaxReturnDataSize = outputConfig.MaxReturnDataSize
+	if MaxReturnDataSize > 0 {
+		features = append(features, "max_return_data_size="+strconv.Itoa(MaxReturnDataSize))
+	}
+
+	CodeCacheWindow = outputConfig.CodeCacheWindow
+	if CodeCacheWindow > 0 {
+		features = append(features, "code_cache_window="+strconv.Itoa(CodeCacheWindow))
+	}
+
+	storageChanges, err := ParseStorageChangesMode(outputConfig.StorageChanges)
+	if err != nil {
+		return fmt.Errorf("firehose output: %w", err)
+	}
+
+	StorageChanges = storageChanges
+	if StorageChanges != AllStorageChangesMode {
+		features = append(features, "storage_changes="+string(StorageChanges))
+	}
+
+	KeccakDedupEnabled = !outputConfig.DisableKeccakDedup
+	if !KeccakDedupEnabled {
+		features = append(features, "keccak_dedup=false")
+	}
+
+	deepTraceAddresses, err := ParseDeepTraceAddresses(outputConfig.DeepTraceAddresses)
+	if err != nil {
+		return fmt.Errorf("firehose output: %w", err)
+	}
+
+	DeepTraceAddresses = deepTraceAddresses
+	if outputConfig.DeepTraceMaxRecords > 0 {
+		DeepTraceMaxRecords = outputConfig.DeepTraceMaxRecords
+	}
+
+	if len(DeepTraceAddresses) > 0 {
+		features = append(features, "deep_trace=true")
+	}
+
+	// The check doesn't change the output, it's not announced
+	OrdinalsCheckEnabled = outputConfig.CheckOrdinals
+
+	CompactBlocksEnabled = outputConfig.CompactBlocks
+	if CompactBlocksEnabled {
+		if Encoding != LineOutputEncoding {
+			return fmt.Errorf("firehose output: compact blocks are only supported with the %q encoding", LineOutputEncoding)
+		}
+
+		if outputConfig.CompactBlocksSpillSize > 0 {
+			CompactBlocksSpillSize = outputConfig.CompactBlocksSpillSize
+		}
+
+		features = append(features, "compact_blocks=true")
+	}
+
+	legacyDMLog, err := ParseLegacyDMLogMode(outputConfig.LegacyDMLog)
+	if err != nil {
+		return fmt.Errorf("firehose output: %w", err)
+	}
+
+	LegacyDMLog = legacyDMLog
+	if LegacyDMLog != NoLegacyDMLogMode && (Encoding != LineOutputEncoding || LineSequenceEnabled || CompactBlocksEnabled) {
+		return fmt.Errorf("firehose output: legacy dmlog is only supported with the %q encoding, without line sequence nor compact blocks", LineOutputEncoding)
+	}
+
+	ChunkThreshold = outputConfig.ChunkThreshold
+	if ChunkThreshold > 0 && Encoding == LineOutputEncoding {
+		if LegacyDMLog != NoLegacyDMLogMode {
+			return fmt.Errorf("firehose output: chunking is not supported with legacy dmlog")
+		}
+
+		features = append(features, "chunk_threshold="+strconv.Itoa(ChunkThreshold))
+	}
+
+	format, err := ParseOutputFormat(outputConfig.Format)
+	if err != nil {
+		return fmt.Errorf("firehose output: %w", err)
+	}
+
+	ActiveOutputFormat = format
+	if ActiveOutputFormat == JSONOutputFormat {
+		if Encoding != LineOutputEncoding || LineSequenceEnabled || CompactBlocksEnabled || LegacyDMLog != NoLegacyDMLogMode || ChunkThreshold > 0 {
+			return fmt.Errorf("firehose output: json format is only supported with the %q encoding, without line sequence, compact blocks, legacy dmlog nor chunking", LineOutputEncoding)
+		}
+
+		log.Warn("Firehose JSON output format is meant for debugging the instrumentation, it must not be used in production")
+		features = append(features, "format="+string(ActiveOutputFormat))
+	}
+
+	LinePrefix = DefaultLinePrefix
+	if outputConfig.LinePrefix != "" && outputConfig.LinePrefix != DefaultLinePrefix {
+		if err := ValidateLinePrefix(outputConfig.LinePrefix); err != nil {
+			return fmt.Errorf("firehose output: %w", err)
+		}
+
+		if Encoding != LineOutputEncoding || ActiveOutputFormat == JSONOutputFormat || LegacyDMLog == OnlyLegacyDMLogMode {
+			return fmt.Errorf("firehose output: line prefix only applies to %q lines of the %q encoding", DefaultLinePrefix, LineOutputEncoding)
+		}
+
+		LinePrefix = outputConfig.LinePrefix
+		features = append(features, "line_prefix="+LinePrefix)
+	}
+
+	onWriteError, err := ParseWriteErrorPolicy(outputConfig.OnWriteError)
+	if err != nil {
+		return fmt.Errorf("firehose output: %w", err)
+	}
+
+	OnWriteError = onWriteError
+	if outputConfig.WriteRetryDeadline > 0 {
+		WriteRetryDeadline = outputConfig.WriteRetryDeadline
+	}
+
+	if outputConfig.Compression != "" && outputConfig.Compression != string(NoOutputCompression) {
+		features = append(features, "compression="+outputConfig.Compression)
+	}
+
+	AllowNonArchive = outputConfig.AllowNonArchive
+
+	StartBlockNum = outputConfig.StartBlockNum
+	if StartBlockNum > 0 {
+		features = append(features, "start_block="+Uint64(StartBlockNum))
+	}
+
+	StopBlockNum = outputConfig.StopBlockNum
+	if StopBlockNum > 0 && StopBlockNum < StartBlockNum {
+		return fmt.Errorf("firehose output: stop block %d is below the start block %d", StopBlockNum, StartBlockNum)
+	}
+	resetStopBlock()
+
+	StreamingBlocksEnabled = outputConfig.StreamingBlocks
+	if StreamingBlocksEnabled && (CompactBlocksEnabled || outputConfig.BlockStoreURL != "") {
+		return fmt.Errorf("firehose output: streaming blocks is not supported with compact blocks nor the block store, both need whole blocks")
+	}
+
+	FinalizedOnlyEnabled = outputConfig.FinalizedOnly
+	if FinalizedOnlyEnabled {
+		if StreamingBlocksEnabled {
+			return fmt.Errorf("firehose output: finalized only mode is not supported with streaming blocks, blocks are buffered whole until final")
+		}
+		if StopBlockNum > 0 {
+			return fmt.Errorf("firehose output: finalized only mode is not supported with a stop block, the blocks above it are needed for it to be final")
+		}
+
+		FinalizedConfirmations = outputConfig.FinalizedConfirmations
+		FinalizedBufferSize = outputConfig.FinalizedBufferSize
+		features = append(features, "finalized_only="+Uint64(FinalizedConfirmations))
+	}
+
+	if Enabled && outputConfig.BlockStoreURL != "" {
+		store, err := openBlockStore(outputConfig.BlockStoreURL)
+		if err != nil {
+			return fmt.Errorf("firehose block store: %w", err)
+		}
+
+		activeBlockStore = newBlockStoreSink(store, outputConfig.BlockStoreConcurrency, outputConfig.BlockStoreWindow)
+	}
+
+	if Enabled || BlockProgressEnabled {
+		out, err := openOutput(outputConfig)
+		if err != nil {
+			return fmt.Errorf("firehose output: %w", err)
+		}
+
+		// An embedding program installing its own writer handles signals itself
+		writer := defaultWriter
+		if writer == io.Writer(os.Stdout) {
+			ignoreSIGPIPE()
+		}
+
+		if out != nil {
+			output = out
+			writer = out
+		}
+
+		printer := NewWriterGoroutinePrinter(writer, printerQueueSize)
+		if outputConfig.ReplayBlocks > 0 {
+			ReplayBlocks = outputConfig.ReplayBlocks
+			if outputConfig.ReplaySize > 0 {
+				ReplaySize = outputConfig.ReplaySize
+			}
+
+			printer.replay = newReplayBuffer(ReplayBlocks, ReplaySize)
+		}
+
+		syncContext = NewContext(printer, false)
+	}
+
 	if Enabled || SyncInstrumentationEnabled || BlockProgressEnabled || MiningEnabled {
 		log.Info("Firehose initialized",
 			"enabled", Enabled,
@@ -114,21 +565,71 @@ func Init(
 			"block_progress_enabled", BlockProgressEnabled,
 			"genesis_configured", genesis != nil,
 			"genesis_provenance", genesisProvenance,
+			"outputs", outputConfig.Outputs,
+			"output_file", outputConfig.File,
+			"output_file_max_size", outputConfig.FileMaxSize,
+			"output_fd", outputConfig.FD,
+			"output_socket", outputConfig.Socket,
+			"output_pipe", outputConfig.Pipe,
+			"output_tcp", outputConfig.TCP,
+			"output_encoding", Encoding,
+			"output_format", ActiveOutputFormat,
+			"line_prefix", LinePrefix,
+			"output_bytes_encoding", ActiveBytesEncoding,
+			"output_compression", outputConfig.Compression,
+			"output_flush_interval", outputConfig.FlushInterval,
+			"output_on_write_error", OnWriteError,
+			"heartbeat_interval", HeartbeatInterval,
+			"storage_reads", StorageReadsEnabled,
+			"accesses", AccessesEnabled,
+			"touched_accounts", TouchedAccountsEnabled,
+			"max_return_data_size", MaxReturnDataSize,
+			"code_cache_window", CodeCacheWindow,
+			"storage_changes", StorageChanges,
+			"keccak_dedup", KeccakDedupEnabled,
+			"deep_trace_addresses", len(DeepTraceAddresses),
+			"deep_trace_max_records", DeepTraceMaxRecords,
+			"check_ordinals", OrdinalsCheckEnabled,
+			"compact_blocks", CompactBlocksEnabled,
+			"legacy_dmlog", LegacyDMLog,
+			"chunk_threshold", ChunkThreshold,
+			"streaming_blocks", StreamingBlocksEnabled,
+			"allow_non_archive", AllowNonArchive,
+			"start_block_num", StartBlockNum,
+			"stop_block_num", StopBlockNum,
+			"finalized_only", FinalizedOnlyEnabled,
+			"finalized_confirmations", FinalizedConfirmations,
+			"replay_blocks", outputConfig.ReplayBlocks,
+			"block_store_url", outputConfig.BlockStoreURL,
 			"firehose_version", params.FirehoseVersion(),
 			"geth_version", gethVersion,
 			"chain_variant", params.Variant,
 		)
 	}
 
-	MaybeSyncContext().InitVersion(
-		gethVersion,
-		params.FirehoseVersion(),
-		params.Variant,
-	)
+	// The INIT message is emitted once the chain configuration is known, see `InitChainConfig`
+	initHandshake.nodeVersion = gethVersion
+	initHandshake.features = features
 
 	return nil
 }
 
+// readGenesisFile decodes the genesis.json file at path in the value returned by newGenesis.
+func readGenesisFile(path string, newGenesis func() interface{}) (interface{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("firehose open genesis file: %w", err)
+	}
+	defer file.Close()
+
+	var genesis = newGenesis()
+	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+		return nil, fmt.Errorf("decode genesis file %q: %w", path, err)
+	}
+
+	return genesis, nil
+}
+
 func isNilInterfaceOrNilValue(in interface{}) bool {
 	if in == nil {
 		return true
@@ -0,0 +1,82 @@
+package firehose
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HeartbeatInterval is the interval at which a `HEARTBEAT` line is emitted so the reader
+// can tell an idle chain from a hung node, heartbeats are disabled when 0.
+var HeartbeatInterval time.Duration
+
+var (
+	heartbeatLock sync.Mutex
+	heartbeatStop chan struct{}
+	heartbeatDone chan struct{}
+)
+
+// StartHeartbeat starts emitting a `HEARTBEAT <unix-nanos> <head-block-num> <head-block-hash>`
+// line every `HeartbeatInterval` through the sync context, `head` returns the current
+// head block. It's a no-op if heartbeats are disabled or already started.
+//
+// The heartbeat is written through the same printer as blocks and skipped while a block
+// is being printed on the sync context, so it never ends up inside a block.
+func StartHeartbeat(head func() (uint64, common.Hash)) {
+	if HeartbeatInterval <= 0 || !(Enabled || BlockProgressEnabled) {
+		return
+	}
+
+	heartbeatLock.Lock()
+	defer heartbeatLock.Unlock()
+
+	if heartbeatStop != nil {
+		return
+	}
+
+	heartbeatStop = make(chan struct{})
+	heartbeatDone = make(chan struct{})
+
+	go runHeartbeat(HeartbeatInterval, head, heartbeatStop, heartbeatDone)
+}
+
+// StopHeartbeat stops the heartbeat, waiting for an in-flight heartbeat to be printed.
+// It's safe to call when the heartbeat was not started.
+func StopHeartbeat() {
+	heartbeatLock.Lock()
+	defer heartbeatLock.Unlock()
+
+	if heartbeatStop == nil {
+		return
+	}
+
+	close(heartbeatStop)
+	<-heartbeatDone
+
+	heartbeatStop = nil
+	heartbeatDone = nil
+}
+
+func runHeartbeat(interval time.Duration, head func() (uint64, common.Hash), stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if syncContext.inBlock.Load() {
+				continue
+			}
+
+			num, hash := head()
+			syncContext.printer.Print("HEARTBEAT", strconv.FormatInt(now.UnixNano(), 10), Uint64(num), Hash(hash))
+			syncContext.flushPrinter()
+		case <-stop:
+			return
+		}
+	}
+}
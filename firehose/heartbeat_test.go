@@ -0,0 +1,43 @@
+package firehose
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeartbeat(t *testing.T) {
+	defer func(enabled bool, interval time.Duration, ctx *Context) {
+		Enabled, HeartbeatInterval, syncContext = enabled, interval, ctx
+	}(Enabled, HeartbeatInterval, syncContext)
+
+	out := bytes.NewBuffer(nil)
+	Enabled, HeartbeatInterval = true, 5*time.Millisecond
+	syncContext = NewContext(NewWriterGoroutinePrinter(out, 16), false)
+
+	StartHeartbeat(func() (uint64, common.Hash) { return 12, common.HexToHash("0xab") })
+	time.Sleep(30 * time.Millisecond)
+	StopHeartbeat()
+	WaitOutputWritten()
+
+	emitted := out.String()
+	require.NotEmpty(t, emitted)
+
+	for _, line := range strings.Split(strings.TrimSuffix(emitted, "\n"), "\n") {
+		fields := strings.Fields(line)
+		require.Len(t, fields, 5)
+		assert.Equal(t, []string{"FIRE", "HEARTBEAT"}, fields[0:2])
+		assert.Equal(t, "12", fields[3])
+		assert.Equal(t, Hash(common.HexToHash("0xab")), fields[4])
+	}
+
+	// Stopped, nothing else is emitted
+	time.Sleep(15 * time.Millisecond)
+	WaitOutputWritten()
+	assert.Equal(t, emitted, out.String())
+}
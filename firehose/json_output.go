@@ -0,0 +1,393 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// OutputFormat controls the syntax of Firehose messages.
+type OutputFormat string
+
+const (
+	// FireOutputFormat writes the canonical `FIRE` messages, this is the default format.
+	FireOutputFormat OutputFormat = "fire"
+
+	// JSONOutputFormat writes each message as a single line JSON object with named fields,
+	// it's meant to debug the instrumentation and must not be used in production, it's
+	// slower and no reader consumes it.
+	JSONOutputFormat OutputFormat = "json"
+)
+
+// ActiveOutputFormat is the `OutputFormat` used by all printers.
+var ActiveOutputFormat = FireOutputFormat
+
+func ParseOutputFormat(in string) (OutputFormat, error) {
+	switch OutputFormat(in) {
+	case "", FireOutputFormat:
+		return FireOutputFormat, nil
+	case JSONOutputFormat:
+		return JSONOutputFormat, nil
+	}
+
+	return "", fmt.Errorf("invalid output format %q, valid values are %q and %q", in, FireOutputFormat, JSONOutputFormat)
+}
+
+// jsonFieldKind tells how a message field, as formatted for the `FIRE` format, is turned
+// into a JSON value.
+type jsonFieldKind int
+
+const (
+	// jsonNumber fields are decimal integers written as JSON numbers
+	jsonNumber jsonFieldKind = iota
+
+	// jsonString fields are written as JSON strings, as is
+	jsonString
+
+	// jsonBool fields are `true` or `false`
+	jsonBool
+
+	// jsonBytes fields are encoded bytes written as `0x` hex strings, `.` being `0x`
+	jsonBytes
+
+	// jsonOptionalBytes fields are like jsonBytes but `.` means no value, written as `null`
+	jsonOptionalBytes
+
+	// jsonBytesList fields are comma separated encoded bytes written as an array of `0x` strings
+	jsonBytesList
+
+	// jsonNumberList fields are comma separated decimal integers written as an array of numbers
+	jsonNumberList
+
+	// jsonAmount fields are encoded big integers written as decimal strings, `.` being `0`
+	jsonAmount
+
+	// jsonOptionalAmount fields are like jsonAmount but `.` means no value, written as `null`
+	jsonOptionalAmount
+
+	// jsonRaw fields are JSON documents embedded as is
+	jsonRaw
+
+	// jsonOptionalRaw fields are like jsonRaw but `.` means no value, written as `null`
+	jsonOptionalRaw
+
+	// jsonText fields are free form text encoded with `Text`, written as JSON strings
+	jsonText
+
+	// jsonOptionalString fields are like jsonString but `.` means no value, written as `null`
+	jsonOptionalString
+
+	// jsonAccessList fields are encoded access lists written as an array of address and
+	// storage keys objects, see `UnmarshalAccessList`
+	jsonAccessList
+)
+
+type jsonField struct {
+	name string
+	kind jsonFieldKind
+}
+
+// jsonLayouts lists the fields of each message in the order they are printed, the last
+// field of INIT being repeated for each of the features. Timestamps are decimal strings as
+// nanoseconds overflow the integers JSON readers can handle.
+var jsonLayouts = map[string][]jsonField{
+	"INIT": {{"version", jsonString}, {"variant", jsonString}, {"node_version", jsonString}, {"features", jsonString}},
+	"BEGIN_BLOCK": {
+		{"num", jsonNumber}, {"capture_time", jsonString}, {"logs_bloom", jsonBytes}, {"gas_used", jsonNumber},
+		{"gas_limit", jsonNumber}, {"trx_count", jsonNumber}, {"uncle_count", jsonNumber}, {"producer", jsonBytes},
+	},
+	"FINALIZE_BLOCK": {{"num", jsonNumber}, {"capture_time", jsonString}},
+	"END_BLOCK":      {{"num", jsonNumber}, {"size", jsonNumber}, {"meta", jsonRaw}, {"capture_time", jsonString}},
+	"CANCEL_BLOCK":   {{"num", jsonNumber}, {"reason", jsonString}},
+	"BEGIN_APPLY_TRX": {
+		{"hash", jsonBytes}, {"to", jsonOptionalBytes}, {"value", jsonAmount}, {"v", jsonBytes}, {"r", jsonBytes}, {"s", jsonBytes},
+		{"gas_limit", jsonNumber}, {"gas_price", jsonAmount}, {"nonce", jsonNumber}, {"data", jsonBytes}, {"access_list", jsonAccessList},
+		{"max_fee_per_gas", jsonOptionalAmount}, {"max_priority_fee_per_gas", jsonOptionalAmount}, {"trx_type", jsonNumber}, {"ordinal", jsonNumber}, {"index", jsonNumber},
+		{"raw", jsonBytes}, {"size", jsonNumber}, {"extra_fields", jsonBytes},
+	},
+	"BEGIN_SYSTEM_CALL":    {{"source", jsonString}, {"ordinal", jsonNumber}},
+	"END_SYSTEM_CALL":      {{"ordinal", jsonNumber}},
+	"TRX_FROM":             {{"from", jsonBytes}},
+	"TRX_INTRINSIC_GAS":    {{"gas", jsonNumber}},
+	"TRX_TOUCHED_ACCOUNTS": {{"addresses", jsonBytesList}},
+	"TRX_REVERTED_CALLS":   {{"call_indexes", jsonNumberList}},
+	"END_APPLY_TRX": {
+		{"gas_used", jsonNumber}, {"post_state", jsonBytes}, {"cumulative_gas_used", jsonNumber}, {"logs_bloom", jsonBytes},
+		{"ordinal", jsonNumber}, {"logs", jsonRaw}, {"status", jsonNumber},
+		{"failure", jsonOptionalString}, {"reason", jsonText},
+	},
+	"EVM_RUN_CALL": {{"call_type", jsonString}, {"call_index", jsonNumber}, {"ordinal", jsonNumber}},
+	"EVM_PARAM": {
+		{"call_type", jsonString}, {"call_index", jsonNumber}, {"caller", jsonBytes}, {"callee", jsonBytes}, {"value", jsonAmount},
+		{"gas_limit", jsonNumber}, {"input", jsonBytes}, {"static", jsonBool}, {"stipend", jsonNumber}, {"storage_address", jsonBytes},
+		{"code_address", jsonBytes},
+	},
+	"ACCOUNT_WITHOUT_CODE": {{"call_index", jsonNumber}},
+	"PRECOMPILED_CALL":     {{"call_index", jsonNumber}, {"gas_cost", jsonNumber}},
+	"EVM_CALL_FAILED":      {{"call_index", jsonNumber}, {"gas_left", jsonNumber}, {"failure", jsonOptionalString}, {"reason", jsonText}},
+	"EVM_CREATE_FAILED":    {{"call_index", jsonNumber}, {"address", jsonBytes}, {"account_exists", jsonBool}},
+	"EVM_REVERTED":         {{"call_index", jsonNumber}, {"truncated", jsonBool}, {"reason", jsonOptionalRaw}},
+	"EVM_END_CALL": {
+		{"call_index", jsonNumber}, {"gas_left", jsonNumber}, {"return_value", jsonBytes}, {"ordinal", jsonNumber},
+		{"truncated", jsonBool}, {"self_gas_used", jsonNumber}, {"children_gas_used", jsonNumber},
+	},
+	"EVM_KECCAK": {{"call_index", jsonNumber}, {"hash", jsonBytes}, {"data", jsonBytes}},
+	"EVM_OPCODE": {
+		{"call_index", jsonNumber}, {"pc", jsonNumber}, {"opcode", jsonString}, {"gas", jsonNumber}, {"cost", jsonNumber},
+		{"stack", jsonBytesList}, {"memory_offset", jsonNumber}, {"memory_size", jsonNumber}, {"ordinal", jsonNumber},
+	},
+	"GAS_CHANGE": {
+		{"call_index", jsonNumber}, {"old_value", jsonNumber}, {"new_value", jsonNumber}, {"reason", jsonString}, {"ordinal", jsonNumber},
+	},
+	"STORAGE_CHANGE": {
+		{"call_index", jsonNumber}, {"address", jsonBytes}, {"key", jsonBytes}, {"old_value", jsonBytes}, {"new_value", jsonBytes},
+		{"ordinal", jsonNumber},
+	},
+	"ACCOUNT_ACCESS": {{"call_index", jsonNumber}, {"address", jsonBytes}, {"cold", jsonBool}, {"ordinal", jsonNumber}},
+	"STORAGE_ACCESS": {
+		{"call_index", jsonNumber}, {"address", jsonBytes}, {"key", jsonBytes}, {"cold", jsonBool}, {"ordinal", jsonNumber},
+	},
+	"STORAGE_READ": {
+		{"call_index", jsonNumber}, {"address", jsonBytes}, {"key", jsonBytes}, {"value", jsonBytes}, {"ordinal", jsonNumber},
+	},
+	"BALANCE_CHANGE": {
+		{"call_index", jsonNumber}, {"address", jsonBytes}, {"old_value", jsonAmount}, {"new_value", jsonAmount}, {"reason", jsonString},
+		{"ordinal", jsonNumber},
+	},
+	"ADD_LOG": {
+		{"call_index", jsonNumber}, {"block_index", jsonNumber}, {"address", jsonBytes}, {"topics", jsonBytesList}, {"data", jsonBytes},
+		{"ordinal", jsonNumber}, {"trx_log_index", jsonNumber}, {"log_index", jsonNumber},
+	},
+	"SUICIDE_CHANGE":  {{"call_index", jsonNumber}, {"address", jsonBytes}, {"suicided", jsonBool}, {"balance", jsonAmount}},
+	"CREATED_ACCOUNT": {{"call_index", jsonNumber}, {"address", jsonBytes}, {"ordinal", jsonNumber}, {"reason", jsonString}},
+	"DELETED_ACCOUNT": {{"call_index", jsonNumber}, {"address", jsonBytes}, {"ordinal", jsonNumber}, {"reason", jsonString}},
+	"CODE_CHANGE": {
+		{"call_index", jsonNumber}, {"address", jsonBytes}, {"old_code_hash", jsonBytes}, {"old_code", jsonBytes},
+		{"new_code_hash", jsonOptionalBytes}, {"new_code", jsonBytes}, {"ordinal", jsonNumber}, {"init_code_hash", jsonOptionalBytes},
+		{"init_code", jsonBytes},
+	},
+	"NONCE_CHANGE": {
+		{"call_index", jsonNumber}, {"address", jsonBytes}, {"old_value", jsonNumber}, {"new_value", jsonNumber}, {"ordinal", jsonNumber},
+		{"reason", jsonString},
+	},
+	"UNCLE_REWARD": {
+		{"uncle_num", jsonNumber}, {"uncle_hash", jsonBytes}, {"coinbase", jsonBytes}, {"amount", jsonAmount}, {"ordinal", jsonNumber},
+	},
+	"FORK_ACTIVATION": {{"forks", jsonString}, {"fork_hash", jsonBytes}, {"fork_next", jsonNumber}},
+	"TRX_ENTER_POOL":  jsonTrxPoolLayout,
+	"TRX_DISCARDED":   jsonTrxPoolLayout,
+	"HEARTBEAT":       {{"time", jsonString}, {"num", jsonNumber}, {"hash", jsonBytes}},
+	"REORG": {
+		{"old_head_num", jsonNumber}, {"old_head_hash", jsonBytes}, {"new_head_num", jsonNumber}, {"new_head_hash", jsonBytes},
+		{"common_ancestor_num", jsonNumber},
+	},
+	"SKIPPED_RANGE": {{"from", jsonNumber}, {"to", jsonNumber}, {"reason", jsonString}},
+}
+
+// jsonGasRefundLayout is the layout of the `refund` GAS_CHANGE, having the refund counter
+// after the ordinal.
+var jsonGasRefundLayout = append(jsonLayouts["GAS_CHANGE"][:5:5], jsonField{"refund_counter", jsonNumber})
+
+var jsonTrxPoolLayout = []jsonField{
+	{"hash", jsonBytes}, {"from", jsonOptionalBytes}, {"to", jsonOptionalBytes}, {"value", jsonAmount}, {"v", jsonBytes}, {"r", jsonBytes},
+	{"s", jsonBytes}, {"gas_limit", jsonNumber}, {"gas_price", jsonAmount}, {"nonce", jsonNumber}, {"data", jsonBytes},
+}
+
+// formatJSONMessage formats the message as a single line JSON object, the message name being
+// its `type`. A message without layout, or whose fields don't match its layout, has its raw
+// fields written in `fields` so nothing is lost while debugging.
+func formatJSONMessage(input []string) []byte {
+	out := bytes.NewBuffer(make([]byte, 0, 256))
+	out.WriteString(`{"type":`)
+	writeJSONString(out, input[0])
+
+	fields := input[1:]
+	layout, found := jsonLayouts[input[0]]
+	if input[0] == "GAS_CHANGE" && len(fields) == len(jsonGasRefundLayout) {
+		layout = jsonGasRefundLayout
+	}
+	if !found || !jsonFieldsMatch(layout, input[0], fields) {
+		out.WriteString(`,"fields":`)
+		out.WriteString(JSON(fields))
+		out.WriteString("}\n")
+
+		return out.Bytes()
+	}
+
+	for i, field := range layout {
+		out.WriteString(`,"` + field.name + `":`)
+
+		if input[0] == "INIT" && i == len(layout)-1 {
+			out.WriteString(JSON(append([]string{}, fields[i:]...)))
+			break
+		}
+
+		writeJSONValue(out, field.kind, fields[i])
+	}
+
+	out.WriteString("}\n")
+	return out.Bytes()
+}
+
+func jsonFieldsMatch(layout []jsonField, name string, fields []string) bool {
+	if name == "INIT" {
+		return len(fields) >= len(layout)-1
+	}
+
+	return len(fields) == len(layout)
+}
+
+func writeJSONValue(out *bytes.Buffer, kind jsonFieldKind, field string) {
+	switch kind {
+	case jsonNumber:
+		if _, ok := new(big.Int).SetString(field, 10); ok {
+			out.WriteString(field)
+			return
+		}
+
+		writeJSONString(out, field)
+
+	case jsonBool:
+		if field == "true" || field == "false" {
+			out.WriteString(field)
+			return
+		}
+
+		writeJSONString(out, field)
+
+	case jsonBytes, jsonOptionalBytes:
+		if field == "." && kind == jsonOptionalBytes {
+			out.WriteString("null")
+			return
+		}
+
+		writeJSONString(out, jsonHex(field))
+
+	case jsonBytesList:
+		values := []string{}
+		if field != "" {
+			for _, value := range strings.Split(field, ",") {
+				values = append(values, jsonHex(value))
+			}
+		}
+
+		out.WriteString(JSON(values))
+
+	case jsonNumberList:
+		values := []json.RawMessage{}
+		if field != "" {
+			for _, value := range strings.Split(field, ",") {
+				if _, ok := new(big.Int).SetString(value, 10); !ok {
+					writeJSONString(out, field)
+					return
+				}
+				values = append(values, json.RawMessage(value))
+			}
+		}
+
+		out.WriteString(JSON(values))
+
+	case jsonAmount, jsonOptionalAmount:
+		if field == "." {
+			if kind == jsonOptionalAmount {
+				out.WriteString("null")
+			} else {
+				out.WriteString(`"0"`)
+			}
+			return
+		}
+
+		decoded, err := DecodeBytes(field, ActiveBytesEncoding)
+		if err != nil {
+			writeJSONString(out, field)
+			return
+		}
+
+		writeJSONString(out, new(big.Int).SetBytes(decoded).String())
+
+	case jsonRaw, jsonOptionalRaw:
+		if field == "." && kind == jsonOptionalRaw {
+			out.WriteString("null")
+			return
+		}
+
+		if json.Valid([]byte(field)) {
+			out.WriteString(field)
+			return
+		}
+
+		writeJSONString(out, field)
+
+	case jsonAccessList:
+		writeJSONAccessList(out, field)
+
+	case jsonText:
+		decoded, err := DecodeBytes(field, ActiveBytesEncoding)
+		if err != nil {
+			writeJSONString(out, field)
+			return
+		}
+
+		writeJSONString(out, string(decoded))
+
+	case jsonOptionalString:
+		if field == "." {
+			out.WriteString("null")
+			return
+		}
+
+		writeJSONString(out, field)
+
+	default:
+		writeJSONString(out, field)
+	}
+}
+
+type jsonAccessTuple struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storage_keys"`
+}
+
+// writeJSONAccessList writes the access list as an array of tuples, it's written as a
+// hex string if it can't be decoded.
+func writeJSONAccessList(out *bytes.Buffer, field string) {
+	decoded, err := DecodeBytes(field, ActiveBytesEncoding)
+	if err != nil {
+		writeJSONString(out, field)
+		return
+	}
+
+	accessList, err := UnmarshalAccessList(decoded)
+	if err != nil {
+		writeJSONString(out, jsonHex(field))
+		return
+	}
+
+	tuples := make([]jsonAccessTuple, len(accessList))
+	for i, tuple := range accessList {
+		tuples[i] = jsonAccessTuple{Address: "0x" + hex.EncodeToString(tuple.Address[:]), StorageKeys: make([]string, len(tuple.StorageKeys))}
+		for j, key := range tuple.StorageKeys {
+			tuples[i].StorageKeys[j] = "0x" + hex.EncodeToString(key[:])
+		}
+	}
+
+	out.WriteString(JSON(tuples))
+}
+
+// jsonHex turns an encoded bytes field in a `0x` hex string, it's returned as is if it can't
+// be decoded.
+func jsonHex(field string) string {
+	decoded, err := DecodeBytes(field, ActiveBytesEncoding)
+	if err != nil {
+		return field
+	}
+
+	return "0x" + hex.EncodeToString(decoded)
+}
+
+func writeJSONString(out *bytes.Buffer, in string) {
+	out.WriteString(JSON(in))
+}
@@ -0,0 +1,97 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "update the golden files of the tests")
+
+// recordAllJSONMessages records every message type in the JSON output format.
+func recordAllJSONMessages(t *testing.T) []byte {
+	defer func(previous OutputFormat) { ActiveOutputFormat = previous }(ActiveOutputFormat)
+	ActiveOutputFormat = JSONOutputFormat
+
+	out := bytes.NewBuffer(recordAllMessages(t, LineOutputEncoding))
+
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+	to := common.HexToAddress("0x02")
+	tx := types.NewTransaction(1, to, big.NewInt(10), 21000, big.NewInt(1), []byte{0xca, 0xfe})
+	ctx.RecordTrxPool("TRX_ENTER_POOL", tx, nil)
+	ctx.RecordTrxPool("TRX_DISCARDED", tx, nil)
+	ctx.RecordTrxIntrinsicGas(21000)
+	printer.Print("TRX_TOUCHED_ACCOUNTS", Addr(common.HexToAddress("0x01"))+","+Addr(to))
+	ctx.StartSystemCall(SystemCallSource("block_finalize"))
+	ctx.RecordUncleReward(&types.Header{Number: big.NewInt(1), Coinbase: to}, big.NewInt(10))
+	ctx.EndSystemCall()
+	ctx.RecordForkActivation(&params.ChainConfig{ChainID: big.NewInt(1), BerlinBlock: big.NewInt(10)}, common.HexToHash("0xee"), 10)
+	ctx.RecordAccountDeletion(to, AccountDeletionReason("empty_account_cleanup"))
+	ctx.RecordStorageRead(to, common.HexToHash("0x01"), common.HexToHash("0x02"))
+	ctx.RecordAccountAccess(to, true)
+	ctx.RecordStorageAccess(to, common.HexToHash("0x01"), false)
+	ctx.RecordOpcode(2, "MSTORE", 100, 6, []uint256.Int{*uint256.NewInt().SetUint64(64), {}}, 64, 32)
+	ctx.RecordCallPrecompiled(3000)
+	ctx.RecordCreateFailed(to, false)
+	printer.Print("HEARTBEAT", "1600000000000000000", Uint64(1), Hash(common.HexToHash("0xee")))
+	ctx.RecordReorg(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5)}), types.NewBlockWithHeader(&types.Header{Number: big.NewInt(6)}), 3)
+	ctx.RecordSkippedRange(1, 128, SkippedRangeReason("snap_sync"))
+	out.Write(printer.Buffer().Bytes())
+
+	return out.Bytes()
+}
+
+func TestJSONOutputFormat_golden(t *testing.T) {
+	golden := filepath.Join("testdata", "json_output.golden")
+
+	for _, encoding := range []BytesEncoding{HexBytesEncoding, Base64BytesEncoding} {
+		t.Run(string(encoding), func(t *testing.T) {
+			defer func(previous BytesEncoding) { ActiveBytesEncoding = previous }(ActiveBytesEncoding)
+			ActiveBytesEncoding = encoding
+
+			actual := recordAllJSONMessages(t)
+			if *updateGolden && encoding == HexBytesEncoding {
+				require.NoError(t, ioutil.WriteFile(golden, actual, 0644))
+			}
+
+			expected, err := ioutil.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(actual), "run the tests with -update-golden to update %s", golden)
+		})
+	}
+}
+
+func TestJSONOutputFormat_coversEveryMessage(t *testing.T) {
+	seen := map[string]bool{}
+	for _, line := range bytes.Split(bytes.TrimSuffix(recordAllJSONMessages(t), []byte("\n")), []byte("\n")) {
+		var message map[string]interface{}
+		require.NoError(t, json.Unmarshal(line, &message), string(line))
+		require.NotContains(t, message, "fields", "message fields don't match its layout: %s", line)
+
+		seen[message["type"].(string)] = true
+	}
+
+	for name := range jsonLayouts {
+		assert.True(t, seen[name], "message %s has a layout but is not covered by the golden file", name)
+	}
+
+	for name := range legacyDMLogLayouts {
+		assert.Contains(t, jsonLayouts, name, "message %s has no JSON layout", name)
+	}
+}
+
+func TestJSONOutputFormat_unknownMessage(t *testing.T) {
+	assert.Equal(t, `{"type":"UNKNOWN","fields":["a","b"]}`+"\n", string(formatJSONMessage([]string{"UNKNOWN", "a", "b"})))
+}
@@ -0,0 +1,136 @@
+package firehose
+
+import (
+	"fmt"
+	"strings"
+)
+
+// This file maps Firehose messages to the legacy deep mind `DMLOG` syntax, it only exists
+// while readers migrate to the `FIRE` format and can be deleted once they all did.
+
+// LegacyDMLogMode controls whether messages are also, or only, emitted in the legacy syntax.
+type LegacyDMLogMode string
+
+const (
+	// NoLegacyDMLogMode only emits `FIRE` lines, this is the default.
+	NoLegacyDMLogMode LegacyDMLogMode = "none"
+
+	// DualLegacyDMLogMode emits each message as a `DMLOG` line followed by its `FIRE` line.
+	DualLegacyDMLogMode LegacyDMLogMode = "dual"
+
+	// OnlyLegacyDMLogMode emits messages as `DMLOG` lines only, messages without a legacy
+	// equivalent are still emitted as `FIRE` lines which legacy readers ignore.
+	OnlyLegacyDMLogMode LegacyDMLogMode = "only"
+)
+
+// legacyDMLogVersion is the deep mind version announced in the legacy `INIT` line.
+const legacyDMLogVersion = "1.0"
+
+// LegacyDMLog is the active `LegacyDMLogMode`.
+var LegacyDMLog = NoLegacyDMLogMode
+
+func ParseLegacyDMLogMode(in string) (LegacyDMLogMode, error) {
+	switch LegacyDMLogMode(in) {
+	case "", NoLegacyDMLogMode:
+		return NoLegacyDMLogMode, nil
+	case DualLegacyDMLogMode, OnlyLegacyDMLogMode:
+		return LegacyDMLogMode(in), nil
+	}
+
+	return "", fmt.Errorf("invalid legacy dmlog mode %q, valid values are %q, %q and %q", in, NoLegacyDMLogMode, DualLegacyDMLogMode, OnlyLegacyDMLogMode)
+}
+
+// legacyDMLogLayouts maps each message having a legacy equivalent to the function turning its
+// fields (message name excluded) into the legacy fields. The legacy syntax has no ordinals,
+// no capture timestamps nor the transaction fields added after deep mind 1.0.
+var legacyDMLogLayouts = map[string]func(fields []string) []string{
+	"INIT": func(fields []string) []string {
+		// <version> <variant> <node version> [<feature>...]
+		return []string{legacyDMLogVersion, fields[1], fields[2]}
+	},
+	"BEGIN_BLOCK":    truncateLegacyFields(1),
+	"FINALIZE_BLOCK": dropLegacyField(1),
+	"END_BLOCK":      dropLegacyField(3),
+	"CANCEL_BLOCK":   keepLegacyFields,
+	"BEGIN_APPLY_TRX": func(fields []string) []string {
+		// <hash> <to> <value> <v> <r> <s> <gas limit> <gas price> <nonce> <data>, dropping
+		// <access list> <max fee> <max priority fee> <type> <ordinal> <index> <raw> <size> <extra fields>
+		return fields[:10]
+	},
+	"END_APPLY_TRX": func(fields []string) []string {
+		// <gas used> <post state> <cumulative gas used> <logs bloom> <logs>, dropping <ordinal> and the fields after <logs>
+		return append(append([]string{}, fields[:4]...), fields[5])
+	},
+	"TRX_FROM":             keepLegacyFields,
+	"EVM_RUN_CALL":         dropLegacyField(2),
+	"EVM_PARAM":            truncateLegacyFields(7),
+	"ACCOUNT_WITHOUT_CODE": keepLegacyFields,
+	"EVM_CALL_FAILED":      legacyCallFailed,
+	"EVM_REVERTED":         truncateLegacyFields(1),
+	"EVM_END_CALL":         truncateLegacyFields(3),
+	"EVM_KECCAK":           keepLegacyFields,
+	"GAS_CHANGE":           truncateLegacyFields(4),
+	"STORAGE_CHANGE":       dropLegacyField(5),
+	"BALANCE_CHANGE":       dropLegacyField(5),
+	"ADD_LOG":              truncateLegacyFields(5),
+	"SUICIDE_CHANGE":       keepLegacyFields,
+	"CREATED_ACCOUNT":      truncateLegacyFields(2),
+	"CODE_CHANGE":          truncateLegacyFields(6),
+	"NONCE_CHANGE":         truncateLegacyFields(4),
+	"TRX_ENTER_POOL":       keepLegacyFields,
+	"TRX_DISCARDED":        keepLegacyFields,
+}
+
+func keepLegacyFields(fields []string) []string {
+	return fields
+}
+
+// dropLegacyField drops the field at index, it's the ordinal of messages having one.
+func dropLegacyField(index int) func(fields []string) []string {
+	return func(fields []string) []string {
+		out := make([]string, 0, len(fields)-1)
+		out = append(out, fields[:index]...)
+		return append(out, fields[index+1:]...)
+	}
+}
+
+// truncateLegacyFields keeps the fields before index, dropping the ordinal at index if any and
+// the fields added after deep mind 1.0, like the revert reason of reverted calls or the reason
+// of account creations.
+func truncateLegacyFields(index int) func(fields []string) []string {
+	return func(fields []string) []string {
+		return fields[:index]
+	}
+}
+
+// legacyCallFailed keeps <call index> <gas left> <reason>, dropping <failure>, the reason
+// being plain text ending the legacy line.
+func legacyCallFailed(fields []string) []string {
+	reason, err := DecodeBytes(fields[3], ActiveBytesEncoding)
+	if err != nil {
+		reason = []byte(fields[3])
+	}
+
+	return []string{fields[0], fields[1], string(reason)}
+}
+
+// formatLegacyDMLog formats the message according to the active `LegacyDMLog` mode, `line`
+// being the message already formatted as a `FIRE` line.
+func formatLegacyDMLog(input []string, line string) []byte {
+	layout, found := legacyDMLogLayouts[input[0]]
+	if !found {
+		return []byte(line)
+	}
+
+	legacy := "DMLOG " + input[0]
+	if fields := layout(input[1:]); len(fields) > 0 {
+		legacy += " " + strings.Join(fields, " ")
+	}
+	legacy += "\n"
+
+	if LegacyDMLog == OnlyLegacyDMLogMode {
+		return []byte(legacy)
+	}
+
+	return []byte(legacy + line)
+}
@@ -0,0 +1,94 @@
+package firehose
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegacyDMLog_layouts(t *testing.T) {
+	defer func(previous LegacyDMLogMode) { LegacyDMLog = previous }(LegacyDMLog)
+	LegacyDMLog = OnlyLegacyDMLogMode
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"INIT 2.3 geth 1.10.1 encoding=line", "DMLOG INIT 1.0 geth 1.10.1"},
+		{"BEGIN_BLOCK 1 1600000000000000000 00 21000 30000000 1 0 0000000000000000000000000000000000000000", "DMLOG BEGIN_BLOCK 1"},
+		{"FINALIZE_BLOCK 1 1600000000000000000", "DMLOG FINALIZE_BLOCK 1"},
+		{"END_BLOCK 1 512 {} 1600000000000000000", "DMLOG END_BLOCK 1 512 {}"},
+		{"CANCEL_BLOCK 1 error", "DMLOG CANCEL_BLOCK 1 error"},
+		{"BEGIN_APPLY_TRX aa 02 0a 01 02 03 21000 01 0 cafe 00 . . 0 1 0 f801 2 .", "DMLOG BEGIN_APPLY_TRX aa 02 0a 01 02 03 21000 01 0 cafe"},
+		{"TRX_FROM 01", "DMLOG TRX_FROM 01"},
+		{"END_APPLY_TRX 21000 . 21000 00 9 [] 1", "DMLOG END_APPLY_TRX 21000 . 21000 00 []"},
+		{"EVM_RUN_CALL CALL 1 2", "DMLOG EVM_RUN_CALL CALL 1"},
+		{"EVM_PARAM CALL 1 01 02 0a 21000 . false 0 02 02", "DMLOG EVM_PARAM CALL 1 01 02 0a 21000 ."},
+		{"ACCOUNT_WITHOUT_CODE 1", "DMLOG ACCOUNT_WITHOUT_CODE 1"},
+		{"EVM_CALL_FAILED 1 100 revert 657865637574696f6e207265766572746564", "DMLOG EVM_CALL_FAILED 1 100 execution reverted"},
+		{"EVM_REVERTED 1 false .", "DMLOG EVM_REVERTED 1"},
+		{"EVM_REVERTED 1 false \"reason\"", "DMLOG EVM_REVERTED 1"},
+		{"EVM_END_CALL 1 100 . 8 false 80 20", "DMLOG EVM_END_CALL 1 100 ."},
+		{"EVM_KECCAK 1 bb 0a", "DMLOG EVM_KECCAK 1 bb 0a"},
+		{"GAS_CHANGE 1 21000 100 intrinsic_gas 3", "DMLOG GAS_CHANGE 1 21000 100 intrinsic_gas"},
+		{"GAS_CHANGE 1 110 130 refund 4 40", "DMLOG GAS_CHANGE 1 110 130 refund"},
+		{"STORAGE_CHANGE 1 02 01 00 02 4", "DMLOG STORAGE_CHANGE 1 02 01 00 02"},
+		{"BALANCE_CHANGE 1 02 . 0a transfer 5", "DMLOG BALANCE_CHANGE 1 02 . 0a transfer"},
+		{"ADD_LOG 1 0 02 aa,bb cafe 6 0 3", "DMLOG ADD_LOG 1 0 02 aa,bb cafe"},
+		{"SUICIDE_CHANGE 1 02 true 0a", "DMLOG SUICIDE_CHANGE 1 02 true 0a"},
+		{"CREATED_ACCOUNT 1 02 7 create", "DMLOG CREATED_ACCOUNT 1 02"},
+		{"CODE_CHANGE 1 02 . . cc 6001 8 . .", "DMLOG CODE_CHANGE 1 02 . . cc 6001"},
+		{"CODE_CHANGE 1 02 . . cc 6001 8 ee 6080", "DMLOG CODE_CHANGE 1 02 . . cc 6001"},
+		{"NONCE_CHANGE 1 02 0 1 9 transaction", "DMLOG NONCE_CHANGE 1 02 0 1"},
+		{"TRX_ENTER_POOL aa 01 02 0a 01 02 03 21000 01 0 cafe", "DMLOG TRX_ENTER_POOL aa 01 02 0a 01 02 03 21000 01 0 cafe"},
+		{"TRX_DISCARDED aa 01 02 0a 01 02 03 21000 01 0 cafe", "DMLOG TRX_DISCARDED aa 01 02 0a 01 02 03 21000 01 0 cafe"},
+
+		// No legacy equivalent, kept as is
+		{"HEARTBEAT 1 2 aa", "FIRE HEARTBEAT 1 2 aa"},
+		{"STORAGE_READ 1 02 01 02 4", "FIRE STORAGE_READ 1 02 01 02 4"},
+		{"ACCOUNT_ACCESS 1 02 true 4", "FIRE ACCOUNT_ACCESS 1 02 true 4"},
+		{"STORAGE_ACCESS 1 02 01 false 4", "FIRE STORAGE_ACCESS 1 02 01 false 4"},
+		{"EVM_OPCODE 1 2 MSTORE 100 6 40,. 64 32 4", "FIRE EVM_OPCODE 1 2 MSTORE 100 6 40,. 64 32 4"},
+		{"TRX_INTRINSIC_GAS 21000", "FIRE TRX_INTRINSIC_GAS 21000"},
+		{"TRX_TOUCHED_ACCOUNTS 01,02", "FIRE TRX_TOUCHED_ACCOUNTS 01,02"},
+		{"TRX_REVERTED_CALLS 2,3", "FIRE TRX_REVERTED_CALLS 2,3"},
+		{"UNCLE_REWARD 1 aa 02 0a 5", "FIRE UNCLE_REWARD 1 aa 02 0a 5"},
+		{"DELETED_ACCOUNT 1 02 7 empty_account_cleanup", "FIRE DELETED_ACCOUNT 1 02 7 empty_account_cleanup"},
+		{"FORK_ACTIVATION berlin 0eb440f6 0", "FIRE FORK_ACTIVATION berlin 0eb440f6 0"},
+		{"REORG 5 aa 6 bb 3", "FIRE REORG 5 aa 6 bb 3"},
+		{"SKIPPED_RANGE 1 128 snap_sync", "FIRE SKIPPED_RANGE 1 128 snap_sync"},
+		{"PRECOMPILED_CALL 1 3000", "FIRE PRECOMPILED_CALL 1 3000"},
+		{"EVM_CREATE_FAILED 1 02 false", "FIRE EVM_CREATE_FAILED 1 02 false"},
+		{"BEGIN_SYSTEM_CALL block_finalize 1", "FIRE BEGIN_SYSTEM_CALL block_finalize 1"},
+		{"END_SYSTEM_CALL 2", "FIRE END_SYSTEM_CALL 2"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected+"\n", string(formatMessage(strings.Split(test.input, " "))), test.input)
+	}
+}
+
+func TestLegacyDMLog_dual(t *testing.T) {
+	defer func(previous LegacyDMLogMode) { LegacyDMLog = previous }(LegacyDMLog)
+	LegacyDMLog = DualLegacyDMLogMode
+
+	assert.Equal(t, "DMLOG EVM_RUN_CALL CALL 1\nFIRE EVM_RUN_CALL CALL 1 2\n", string(formatMessage([]string{"EVM_RUN_CALL", "CALL", "1", "2"})))
+}
+
+func TestLegacyDMLog_coversAllRecords(t *testing.T) {
+	defer func(previous LegacyDMLogMode) { LegacyDMLog = previous }(LegacyDMLog)
+	LegacyDMLog = NoLegacyDMLogMode
+
+	// Records added after deep mind 1.0 without a legacy equivalent, emitted as `FIRE` lines
+	withoutLegacyEquivalent := map[string]bool{"TRX_REVERTED_CALLS": true}
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(recordAllMessages(t, LineOutputEncoding)), "\n"), "\n") {
+		record := strings.Fields(line)[1]
+		if withoutLegacyEquivalent[record] {
+			continue
+		}
+
+		assert.Contains(t, legacyDMLogLayouts, record, "record %s has no legacy layout", record)
+	}
+}
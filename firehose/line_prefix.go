@@ -0,0 +1,47 @@
+package firehose
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// DefaultLinePrefix is the token starting every line encoded message by default.
+const DefaultLinePrefix = "FIRE"
+
+// LinePrefix is the token starting every line encoded message, a node whose output is
+// multiplexed with the output of other nodes can use its own prefix so readers can tell
+// them apart. A non-default prefix is also announced in the INIT message.
+var LinePrefix = DefaultLinePrefix
+
+// reservedLineWords are the words used inside messages on top of the message names, a prefix
+// can't be any of them.
+var reservedLineWords = []string{"BLOCK_BEGIN", "BLOCK", "CHUNK", "FILE_BLOCK_RANGE", "DMLOG", "."}
+
+// ValidateLinePrefix checks that the prefix is a single token that can't be mistaken for
+// anything else found in Firehose lines.
+func ValidateLinePrefix(prefix string) error {
+	if prefix == "" {
+		return fmt.Errorf("line prefix cannot be empty")
+	}
+
+	if strings.IndexFunc(prefix, unicode.IsSpace) != -1 {
+		return fmt.Errorf("invalid line prefix %q, it must be a single token without spaces", prefix)
+	}
+
+	if strings.HasPrefix(prefix, chunkReferencePrefix) {
+		return fmt.Errorf("invalid line prefix %q, it cannot start with the chunk reference prefix %q", prefix, chunkReferencePrefix)
+	}
+
+	if _, found := jsonLayouts[prefix]; found {
+		return fmt.Errorf("invalid line prefix %q, it's a reserved message name", prefix)
+	}
+
+	for _, word := range reservedLineWords {
+		if prefix == word {
+			return fmt.Errorf("invalid line prefix %q, it's a reserved word", prefix)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package firehose
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLinePrefix(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		wantErr bool
+	}{
+		{"FIRE", false},
+		{"NODE1", false},
+		{"fire-eu-west-1", false},
+		{"", true},
+		{"NODE 1", true},
+		{"NODE\t1", true},
+		{"BEGIN_BLOCK", true},
+		{"CHUNK", true},
+		{"DMLOG", true},
+		{".", true},
+		{"@1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			err := ValidateLinePrefix(tt.prefix)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLinePrefix_appliedToEveryLine(t *testing.T) {
+	defer func(previous string) { LinePrefix = previous }(LinePrefix)
+	LinePrefix = "NODE1"
+
+	out := &bytes.Buffer{}
+	ctx := NewContext(&DelegateToWriterPrinter{writer: out}, false)
+	ctx.InitVersion("1.10.1", "2.3", "geth", "line_prefix=NODE1")
+	ctx.printer.Print("HEARTBEAT", "1", "2", "cafe")
+
+	assert.Equal(t, "NODE1 INIT 2.3 geth 1.10.1 line_prefix=NODE1\nNODE1 HEARTBEAT 1 2 cafe\n", out.String())
+
+	// Lines added to messages use it too
+	defer func(previous int) { ChunkThreshold = previous }(ChunkThreshold)
+	ChunkThreshold = 2
+
+	for _, line := range strings.SplitAfter(string(formatMessage([]string{"EVM_KECCAK", "1", "cafe"})), "\n")[:3] {
+		assert.True(t, strings.HasPrefix(line, "NODE1 "), line)
+	}
+}
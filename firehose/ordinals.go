@@ -0,0 +1,70 @@
+package firehose
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// OrdinalsCheckEnabled verifies when each block ends that the ordinals of its records are
+// exactly 1 to N, panicking on a gap or a duplicate. It's a debugging aid off by default as it
+// keeps every ordinal of the block around.
+var OrdinalsCheckEnabled = false
+
+// Ordinals give the total order of the records of a block having one. They come from a single
+// counter per block, owned by the block context and shared with the contexts of its
+// transactions, see `NewTransactionContext`, and reset when the block ends. An ordinal is
+// assigned when its record is printed, in the goroutine executing the block, so records are
+// printed in ordinal order and every assigned ordinal ends up in the block's output unless the
+// records of a transaction are discarded.
+
+// NewTransactionContext returns a speculative context recording a transaction of the block in
+// the buffer, to be merged in the block with `FlushTransaction`. It shares the block's ordinals
+// counter so ordinals are contiguous across the transactions and the block level records, and
+// the block's recorded keccaks so a preimage is recorded once per block.
+func (ctx *Context) NewTransactionContext(buffer *bytes.Buffer) *Context {
+	if ctx == nil {
+		return nil
+	}
+
+	txContext := NewSpeculativeExecutionContextWithBuffer(buffer)
+	txContext.totalOrderingCounter = ctx.totalOrderingCounter
+	txContext.blockKeccaks = ctx.blockKeccaks
+	txContext.sharesBlockState = true
+
+	return txContext
+}
+
+// nextOrdinal assigns the next ordinal of the block, it must only be called as an argument
+// of the record printed with it.
+func (ctx *Context) nextOrdinal() uint64 {
+	ordinal := ctx.totalOrderingCounter.Inc()
+	if OrdinalsCheckEnabled {
+		ctx.emittedOrdinals = append(ctx.emittedOrdinals, ordinal)
+	}
+
+	return ordinal
+}
+
+// checkOrdinals panics if the ordinals emitted in the block, including the ones of the flushed
+// transactions, are not exactly 1 to the last one assigned.
+func (ctx *Context) checkOrdinals(blockNum uint64) {
+	ordinals := append([]uint64(nil), ctx.emittedOrdinals...)
+	sort.Slice(ordinals, func(i, j int) bool { return ordinals[i] < ordinals[j] })
+
+	for i, ordinal := range ordinals {
+		expected := uint64(i + 1)
+		if ordinal == expected {
+			continue
+		}
+
+		if ordinal < expected {
+			panic(fmt.Errorf("block #%d has duplicated ordinal %d", blockNum, ordinal))
+		}
+		panic(fmt.Errorf("block #%d has a gap in ordinals, ordinal %d is missing", blockNum, expected))
+	}
+
+	if last := ctx.totalOrderingCounter.Load(); last != uint64(len(ordinals)) {
+		panic(fmt.Errorf("block #%d has a gap in ordinals, %d were assigned but %d were emitted", blockNum, last, len(ordinals)))
+	}
+}
@@ -0,0 +1,124 @@
+package firehose
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrdinals_contiguousAcrossRandomizedBlocks(t *testing.T) {
+	defer func(previous OutputFormat) { ActiveOutputFormat = previous }(ActiveOutputFormat)
+	defer func(previous StorageChangesMode) { StorageChanges = previous }(StorageChanges)
+	defer func(previous bool) { OrdinalsCheckEnabled = previous }(OrdinalsCheckEnabled)
+	ActiveOutputFormat = JSONOutputFormat
+	OrdinalsCheckEnabled = true
+
+	random := rand.New(rand.NewSource(73))
+	addr := func() common.Address { return common.BigToAddress(big.NewInt(random.Int63n(4))) }
+	slot := func() common.Hash { return common.BigToHash(big.NewInt(random.Int63n(4))) }
+
+	var recordCall func(ctx *Context, depth int)
+	recordCall = func(ctx *Context, depth int) {
+		ctx.StartCall("CALL")
+		for i := random.Intn(6); i > 0; i-- {
+			switch random.Intn(6) {
+			case 0:
+				ctx.RecordStorageChange(addr(), slot(), slot(), slot())
+			case 1:
+				ctx.RecordBalanceChange(addr(), big.NewInt(1), big.NewInt(2), BalanceChangeReason("transfer"))
+			case 2:
+				ctx.RecordGasConsume(100, 3, GasChangeReason("call"))
+			case 3:
+				ctx.RecordLog(&types.Log{Address: addr()}, 0)
+			case 4:
+				ctx.RecordNonceChange(addr(), 1, 2, NonceChangeReason("call"))
+			case 5:
+				if depth < 3 {
+					recordCall(ctx, depth+1)
+				}
+			}
+		}
+
+		if random.Intn(3) == 0 {
+			ctx.EndFailedCall(10, random.Intn(2) == 0, CallFailure("revert"), "")
+			return
+		}
+		ctx.EndCall(10, nil)
+	}
+
+	for blockNum := int64(1); blockNum <= 200; blockNum++ {
+		StorageChanges = AllStorageChangesMode
+		if random.Intn(2) == 0 {
+			StorageChanges = FinalStorageChangesMode
+		}
+
+		printer := NewToBufferPrinter(1024)
+		ctx := NewContext(printer, true)
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(blockNum)})
+		ctx.StartBlock(block, common.Address{})
+
+		if random.Intn(2) == 0 {
+			ctx.StartSystemCall(SystemCallSource("dao_fork"))
+			ctx.RecordStorageChange(addr(), slot(), slot(), slot())
+			ctx.RecordBalanceChange(addr(), big.NewInt(1), big.NewInt(0), BalanceChangeReason("dao_refund_contract"))
+			ctx.EndSystemCall()
+		}
+
+		txContext := ctx.NewTransactionContext(new(bytes.Buffer))
+		for i := random.Intn(5); i > 0; i-- {
+			txContext.StartTransactionRaw(common.Hash{}, nil, &big.Int{}, nil, nil, nil, 0, &big.Int{}, 0, nil, nil, nil, nil, 0, 0, nil, 0, nil)
+			recordCall(txContext, 0)
+			txContext.EndTransaction(&types.Receipt{})
+			ctx.FlushTransaction(txContext)
+		}
+
+		ctx.RecordBalanceChange(addr(), big.NewInt(0), big.NewInt(5), BalanceChangeReason("reward_mine_block"))
+		ctx.FinalizeBlock(block)
+		require.NotPanics(t, func() { ctx.EndBlock(block, big.NewInt(1)) }, "block #%d", blockNum)
+
+		var ordinals []uint64
+		scanner := bufio.NewScanner(printer.Buffer())
+		scanner.Buffer(nil, 1024*1024)
+		for scanner.Scan() {
+			var message struct {
+				Ordinal *uint64 `json:"ordinal"`
+			}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &message))
+			if message.Ordinal != nil {
+				ordinals = append(ordinals, *message.Ordinal)
+			}
+		}
+		require.NoError(t, scanner.Err())
+
+		// Records are printed in ordinal order from 1 without any gap
+		for i, ordinal := range ordinals {
+			require.Equal(t, uint64(i+1), ordinal, "block #%d", blockNum)
+		}
+	}
+}
+
+func TestCheckOrdinals(t *testing.T) {
+	ctx := NewContext(NewToBufferPrinter(1024), true)
+
+	ctx.totalOrderingCounter.Store(3)
+	ctx.emittedOrdinals = []uint64{2, 3, 1}
+	assert.NotPanics(t, func() { ctx.checkOrdinals(1) })
+
+	ctx.emittedOrdinals = []uint64{1, 3}
+	assert.PanicsWithError(t, "block #1 has a gap in ordinals, ordinal 2 is missing", func() { ctx.checkOrdinals(1) })
+
+	ctx.emittedOrdinals = []uint64{1, 2, 2}
+	assert.PanicsWithError(t, "block #1 has duplicated ordinal 2", func() { ctx.checkOrdinals(1) })
+
+	// The records of a transaction context reset without being flushed are missing from the block
+	ctx.emittedOrdinals = []uint64{1, 2}
+	assert.PanicsWithError(t, "block #1 has a gap in ordinals, 3 were assigned but 2 were emitted", func() { ctx.checkOrdinals(1) })
+}
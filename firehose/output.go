@@ -0,0 +1,360 @@
+package firehose
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// output is the active destination of the sync context printer, it's `nil` when
+// Firehose lines are written straight to stdout.
+var output outputWriter
+
+// outputWriter is the top-level writer of the output chain, it's flushed on block
+// boundaries and closed on shutdown.
+type outputWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// endOfBlockNotifier is implemented by output writers that need to know which block
+// was just completely written, `EndOfBlock` is called right after the block's flush.
+type endOfBlockNotifier interface {
+	EndOfBlock(blockNum uint64) error
+}
+
+// bufferedOutput is a buffered `io.Writer` whose content is flushed on block
+// boundaries and that owns the underlying destination, closing it on `Close`.
+//
+// Between block boundaries, buffered data is written to the destination when the
+// buffer is full or, if the flush policy has an interval, when the interval elapsed.
+// Those partial flushes only write to the destination without flushing it, so a
+// compressing, a socket or a pipe destination only ever sees `Flush` on block boundaries.
+type bufferedOutput struct {
+	lock   sync.Mutex
+	writer *bufio.Writer
+	dest   io.WriteCloser
+
+	stopTicker chan struct{}
+}
+
+func newBufferedOutput(dest io.WriteCloser, policy FlushPolicy) *bufferedOutput {
+	size := policy.Size
+	if size <= 0 {
+		// 1 MiB, most blocks are flushed in a few writes with this size
+		size = 1024 * 1024
+	}
+
+	o := &bufferedOutput{
+		writer: bufio.NewWriterSize(dest, size),
+		dest:   dest,
+	}
+
+	if policy.Interval > 0 {
+		o.stopTicker = make(chan struct{})
+		go o.flushEvery(policy.Interval)
+	}
+
+	return o
+}
+
+// Write buffers the data, each write reaches the destination in a single write so a whole
+// block written at once can't be split by a partial flush. Data not fitting in the buffer's
+// free space is written after the buffered data, straight to the destination if larger than
+// the buffer.
+func (o *bufferedOutput) Write(in []byte) (int, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if len(in) > o.writer.Available() && o.writer.Buffered() > 0 {
+		if err := o.writer.Flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	return o.writer.Write(in)
+}
+
+// Flush writes buffered data to the destination and then flushes the destination
+// itself if it's also buffering (for example to know about block boundaries).
+func (o *bufferedOutput) Flush() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if err := o.writer.Flush(); err != nil {
+		return err
+	}
+
+	return flushDestination(o.dest)
+}
+
+func (o *bufferedOutput) flushEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			o.lock.Lock()
+			// Errors are reported by the next write, the buffered writer keeps them around
+			o.writer.Flush()
+			o.lock.Unlock()
+		case <-o.stopTicker:
+			return
+		}
+	}
+}
+
+// flushDestination flushes the destination if it's buffering, it's a no-op otherwise.
+func flushDestination(dest io.Writer) error {
+	if flusher, ok := dest.(interface{ Flush() error }); ok {
+		return flusher.Flush()
+	}
+
+	return nil
+}
+
+func (o *bufferedOutput) Close() error {
+	if o.stopTicker != nil {
+		close(o.stopTicker)
+	}
+
+	if err := o.Flush(); err != nil {
+		o.dest.Close()
+		return fmt.Errorf("flush: %w", err)
+	}
+
+	return o.dest.Close()
+}
+
+// FlushPolicy controls when buffered output is written to the destination in between
+// block boundaries, at which point the output is always flushed.
+type FlushPolicy struct {
+	// Size is the size of the buffer, data is written to the destination once it's full.
+	Size int
+
+	// Interval, when non-zero, writes buffered data to the destination at this interval.
+	Interval time.Duration
+}
+
+// ParseFlushPolicy parses either a byte size (`4194304`, `512KiB`, `4MiB`) or a
+// duration (`500ms`, `2s`), an empty input being the default policy.
+func ParseFlushPolicy(in string) (FlushPolicy, error) {
+	if in == "" {
+		return FlushPolicy{}, nil
+	}
+
+	units := []struct {
+		suffix     string
+		multiplier int
+	}{{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3}, {"B", 1}, {"", 1}}
+
+	for _, unit := range units {
+		if !strings.HasSuffix(in, unit.suffix) {
+			continue
+		}
+
+		if value, err := strconv.Atoi(strings.TrimSuffix(in, unit.suffix)); err == nil {
+			if value <= 0 {
+				return FlushPolicy{}, fmt.Errorf("invalid flush size %q, must be positive", in)
+			}
+
+			return FlushPolicy{Size: value * unit.multiplier}, nil
+		}
+	}
+
+	interval, err := time.ParseDuration(in)
+	if err != nil || interval <= 0 {
+		return FlushPolicy{}, fmt.Errorf("invalid flush interval %q, must be a positive byte size (e.g. 4MiB) or duration (e.g. 500ms)", in)
+	}
+
+	return FlushPolicy{Interval: interval}, nil
+}
+
+// openOutput resolves the output destination from the config, returning `nil` when
+// Firehose lines should go straight to stdout (the default).
+func openOutput(config OutputConfig) (outputWriter, error) {
+	compression, err := ParseOutputCompression(config.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	bufferFullPolicy, err := ParseBufferFullPolicy(config.BufferFullPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	flushPolicy, err := ParseFlushPolicy(config.FlushInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := func(dest io.WriteCloser) *bufferedOutput {
+		dest = policyWriter{dest}
+
+		if compression == GzipOutputCompression {
+			dest = newGzipWriter(dest)
+		}
+
+		if config.BufferSize > 0 {
+			dest = newAsyncWriter(dest, config.BufferSize, bufferFullPolicy)
+		}
+
+		return newBufferedOutput(dest, flushPolicy)
+	}
+
+	if config.FileMaxSize > 0 {
+		if config.File == "" {
+			return nil, fmt.Errorf("output file max size can only be used with an output file")
+		}
+
+		if err := validateDestinations(config); err != nil {
+			return nil, err
+		}
+
+		return newRotatingOutput(config.File, config.FileMaxSize, chain)
+	}
+
+	dest, err := openDestination(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if dest == nil {
+		if compression == NoOutputCompression && config.BufferSize <= 0 && config.FlushInterval == "" {
+			return nil, nil
+		}
+
+		dest = stdoutDestination{}
+	}
+
+	return chain(dest), nil
+}
+
+func validateDestinations(config OutputConfig) error {
+	set := 0
+	for _, isSet := range []bool{config.File != "", config.Socket != "", config.Pipe != "", config.TCP != "", config.FD != 0 && config.FD != 1, len(config.Outputs) > 0} {
+		if isSet {
+			set++
+		}
+	}
+
+	if set > 1 {
+		return fmt.Errorf("outputs, output file, output socket, output pipe, output tcp and output file descriptor are mutually exclusive, only one of them can be set")
+	}
+
+	return nil
+}
+
+// openDestination opens the configured output destination, returning `nil` for stdout.
+func openDestination(config OutputConfig) (io.WriteCloser, error) {
+	if err := validateDestinations(config); err != nil {
+		return nil, err
+	}
+
+	if len(config.Outputs) > 0 {
+		return openFanout(config.Outputs, config)
+	}
+
+	if config.FD != 0 && config.FD != 1 {
+		return openFileDescriptor(config.FD)
+	}
+
+	if config.Pipe != "" {
+		return newPipeWriter(config.Pipe, config.PipeRetention)
+	}
+
+	if config.TCP != "" {
+		return openTCP(config.TCP, config)
+	}
+
+	if config.Socket != "" {
+		return newSocketWriter(config.Socket, config.SocketListen, config.SocketBufferSize)
+	}
+
+	if config.File == "" {
+		return nil, nil
+	}
+
+	file, err := os.OpenFile(config.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open output file %q: %w", config.File, err)
+	}
+
+	return file, nil
+}
+
+// openFileDescriptor opens an inherited file descriptor, failing if it's not open or not writable.
+func openFileDescriptor(fd int) (*os.File, error) {
+	if fd < 0 {
+		return nil, fmt.Errorf("invalid output file descriptor %d", fd)
+	}
+
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+	if _, err := file.Stat(); err != nil {
+		return nil, fmt.Errorf("output file descriptor %d is not open: %w", fd, err)
+	}
+
+	// An empty write still reaches the kernel which rejects it if the descriptor is not writable
+	if _, err := file.Write(nil); err != nil {
+		return nil, fmt.Errorf("output file descriptor %d is not writable: %w", fd, err)
+	}
+
+	return file, nil
+}
+
+// defaultWriter is the writer standing for stdout, see `SetWriter`.
+var defaultWriter io.Writer = os.Stdout
+
+// SetWriter installs the writer Firehose lines are written to in place of stdout, for
+// programs embedding the node as a library. It must be called before `Init`, outputs
+// configured by `Init` still take precedence over it while an output of `stdout` uses it.
+//
+// The writer is flushed on block boundaries if it has a `Flush() error` method. A nil
+// writer restores stdout.
+func SetWriter(writer io.Writer) {
+	if writer == nil {
+		writer = os.Stdout
+	}
+
+	defaultWriter = writer
+	syncContext = NewContext(&DelegateToWriterPrinter{writer: writer}, false)
+}
+
+// stdoutDestination writes to stdout, or the writer installed by `SetWriter`, but never
+// closes it, other parts of the process might still print to it.
+type stdoutDestination struct{}
+
+func (stdoutDestination) Write(in []byte) (int, error) { return defaultWriter.Write(in) }
+func (stdoutDestination) Flush() error                 { return flushDestination(defaultWriter) }
+func (stdoutDestination) Close() error                 { return nil }
+
+// Close stops the heartbeat, waits for the Firehose data printed so far to be written and uploaded to the block store, then
+// flushes and closes the Firehose output destination if it was configured to be something else than stdout. It's safe to call
+// when no output was opened.
+func Close() error {
+	StopHeartbeat()
+
+	// Blocks not final are dropped, they are executed again from the last emitted one
+	ResumeFinalized(0)
+	WaitOutputWritten()
+
+	if activeBlockStore != nil {
+		activeBlockStore.close()
+		activeBlockStore = nil
+	}
+
+	if output == nil {
+		return nil
+	}
+
+	err := output.Close()
+	output = nil
+
+	return err
+}
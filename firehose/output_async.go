@@ -0,0 +1,138 @@
+package firehose
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// BufferFullPolicy controls what the asynchronous output does when its queue is full.
+type BufferFullPolicy string
+
+const (
+	// BlockBufferFullPolicy waits for the writer goroutine to make room in the queue,
+	// slowing down block import to the pace of the output consumer.
+	BlockBufferFullPolicy BufferFullPolicy = "block"
+
+	// CrashBufferFullPolicy panics as soon as the queue is full.
+	CrashBufferFullPolicy BufferFullPolicy = "crash"
+)
+
+func ParseBufferFullPolicy(in string) (BufferFullPolicy, error) {
+	switch BufferFullPolicy(in) {
+	case "", BlockBufferFullPolicy:
+		return BlockBufferFullPolicy, nil
+	case CrashBufferFullPolicy:
+		return CrashBufferFullPolicy, nil
+	}
+
+	return "", fmt.Errorf("invalid buffer full policy %q, valid values are %q and %q", in, BlockBufferFullPolicy, CrashBufferFullPolicy)
+}
+
+// asyncWriter hands off writes to a dedicated goroutine through a bounded queue so
+// that a slow consumer does not directly stall the block import path. Flushes are
+// queued as well so they happen in order relative to the writes surrounding them.
+type asyncWriter struct {
+	dest   io.WriteCloser
+	policy BufferFullPolicy
+
+	queue chan asyncEntry
+	done  chan struct{}
+
+	errLock sync.Mutex
+	err     error
+}
+
+// asyncEntry is either data to write or a request to flush the destination.
+type asyncEntry struct {
+	data  []byte
+	flush bool
+}
+
+func newAsyncWriter(dest io.WriteCloser, queueSize int, policy BufferFullPolicy) *asyncWriter {
+	w := &asyncWriter{
+		dest:   dest,
+		policy: policy,
+		queue:  make(chan asyncEntry, queueSize),
+		done:   make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+func (w *asyncWriter) run() {
+	defer close(w.done)
+
+	for entry := range w.queue {
+		var err error
+		if entry.flush {
+			err = flushDestination(w.dest)
+		} else {
+			_, err = w.dest.Write(entry.data)
+		}
+
+		if err != nil {
+			w.errLock.Lock()
+			if w.err == nil {
+				w.err = err
+			}
+			w.errLock.Unlock()
+		}
+	}
+}
+
+// Write queues a copy of the input, the input is most probably re-used by the caller.
+// The error returned is the first error the writer goroutine encountered, if any.
+func (w *asyncWriter) Write(in []byte) (int, error) {
+	if err := w.lastError(); err != nil {
+		return 0, err
+	}
+
+	data := make([]byte, len(in))
+	copy(data, in)
+	w.enqueue(asyncEntry{data: data})
+
+	return len(in), nil
+}
+
+func (w *asyncWriter) Flush() error {
+	w.enqueue(asyncEntry{flush: true})
+
+	return w.lastError()
+}
+
+// Close drains the queue, waiting for all queued writes to reach the destination before
+// closing it.
+func (w *asyncWriter) Close() error {
+	close(w.queue)
+	<-w.done
+
+	if err := w.lastError(); err != nil {
+		w.dest.Close()
+		return err
+	}
+
+	return w.dest.Close()
+}
+
+func (w *asyncWriter) enqueue(entry asyncEntry) {
+	if w.policy == BlockBufferFullPolicy {
+		w.queue <- entry
+		return
+	}
+
+	select {
+	case w.queue <- entry:
+	default:
+		panic(fmt.Errorf("firehose output queue is full (%d entries), the output consumer is not keeping up with block import, crashing as requested by the %q buffer full policy", cap(w.queue), w.policy))
+	}
+}
+
+func (w *asyncWriter) lastError() error {
+	w.errLock.Lock()
+	defer w.errLock.Unlock()
+
+	return w.err
+}
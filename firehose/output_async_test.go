@@ -0,0 +1,53 @@
+package firehose
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingWriter blocks every write until `release` is closed.
+type blockingWriter struct {
+	bytes.Buffer
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(in []byte) (int, error) {
+	<-w.release
+	return w.Buffer.Write(in)
+}
+
+func (w *blockingWriter) Close() error { return nil }
+
+func TestAsyncWriter_closeDrainsQueue(t *testing.T) {
+	dest := &blockingWriter{release: make(chan struct{})}
+	writer := newAsyncWriter(dest, 16, BlockBufferFullPolicy)
+
+	for _, line := range []string{"FIRE BEGIN_BLOCK 1\n", "FIRE END_BLOCK 1\n"} {
+		_, err := writer.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Flush())
+
+	close(dest.release)
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, "FIRE BEGIN_BLOCK 1\nFIRE END_BLOCK 1\n", dest.String())
+}
+
+func TestAsyncWriter_crashWhenFull(t *testing.T) {
+	dest := &blockingWriter{release: make(chan struct{})}
+	writer := newAsyncWriter(dest, 1, CrashBufferFullPolicy)
+	defer func() {
+		close(dest.release)
+		writer.Close()
+	}()
+
+	assert.Panics(t, func() {
+		for i := 0; i < 3; i++ {
+			writer.Write([]byte("FIRE BEGIN_BLOCK 1\n"))
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package firehose
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/log"
+	"go.uber.org/atomic"
+)
+
+// ErrOutputBroken is returned once the Firehose output was found broken, typically because
+// the process reading it exited. Nothing is written to the output anymore from that point.
+var ErrOutputBroken = errors.New("firehose output is broken")
+
+var (
+	// outputIsBroken is checked on each write, the lock only guards the channel
+	outputIsBroken = atomic.NewBool(false)
+
+	outputBrokenLock sync.Mutex
+	outputBrokenCh   = make(chan struct{})
+)
+
+// OutputBroken returns a channel closed once the Firehose output is broken, the node must
+// then shut down as the blocks it imports can't be emitted anymore.
+func OutputBroken() <-chan struct{} {
+	outputBrokenLock.Lock()
+	defer outputBrokenLock.Unlock()
+
+	return outputBrokenCh
+}
+
+// OutputError returns `ErrOutputBroken` if the Firehose output is broken, nil otherwise.
+func OutputError() error {
+	if outputIsBroken.Load() {
+		return ErrOutputBroken
+	}
+
+	return nil
+}
+
+// isOutputBrokenError tells if the write error means the output will never accept data
+// again, retrying is then pointless.
+func isOutputBrokenError(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, os.ErrClosed)
+}
+
+// markOutputBroken records that the output is broken, it's logged only the first time.
+func markOutputBroken(operation string, err error) {
+	outputBrokenLock.Lock()
+	defer outputBrokenLock.Unlock()
+
+	if outputIsBroken.Load() {
+		return
+	}
+
+	log.Error("Fatal Firehose error, output is broken, block import stops and node shuts down", "operation", operation, "err", err)
+
+	outputIsBroken.Store(true)
+	close(outputBrokenCh)
+}
+
+func resetOutputBroken() {
+	outputBrokenLock.Lock()
+	defer outputBrokenLock.Unlock()
+
+	outputIsBroken.Store(false)
+	outputBrokenCh = make(chan struct{})
+}
+
+// ignoreSIGPIPE makes writes to a closed stdout (or fd 1 and 2 given as output) fail with `EPIPE` instead of the process
+// being killed by `SIGPIPE`, possibly in the middle of a database write.
+func ignoreSIGPIPE() {
+	signal.Ignore(syscall.SIGPIPE)
+}
@@ -0,0 +1,85 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"syscall"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenPipeWriter accepts `budget` bytes then fails like a pipe whose reader exited.
+type brokenPipeWriter struct {
+	bytes.Buffer
+	budget int
+}
+
+func (w *brokenPipeWriter) Write(in []byte) (int, error) {
+	if len(in) > w.budget {
+		written, _ := w.Buffer.Write(in[:w.budget])
+		w.budget = 0
+
+		return written, syscall.EPIPE
+	}
+
+	w.budget -= len(in)
+	return w.Buffer.Write(in)
+}
+
+func TestOutputBroken_noEndBlockForPartialBlock(t *testing.T) {
+	defer func(enabled bool, ctx *Context) { Enabled, syncContext = enabled, ctx }(Enabled, syncContext)
+	Enabled = true
+
+	tests := []struct {
+		name   string
+		record func(block *types.Block)
+	}{
+		{"buffered", func(block *types.Block) { recordBlock(block, 10) }},
+		{"incremental", func(block *types.Block) {
+			syncContext.StartBlock(block, common.Address{})
+			for i := 0; i < 10; i++ {
+				syncContext.printer.Print("BALANCE_CHANGE", Uint64(uint64(i)))
+			}
+			syncContext.FinalizeBlock(block)
+			syncContext.EndBlock(block, big.NewInt(1))
+			syncContext.FlushBlock()
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer resetOutputBroken()
+
+			writer := &brokenPipeWriter{budget: 60}
+			syncContext = NewContext(NewWriterGoroutinePrinter(writer, 4), false)
+
+			for i := int64(1); i <= 2; i++ {
+				test.record(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(i), Difficulty: big.NewInt(1)}))
+			}
+
+			require.Equal(t, ErrOutputBroken, WaitOutputWritten())
+			assert.Contains(t, writer.String(), "FIRE BEGIN_BLOCK 1")
+			assert.NotContains(t, writer.String(), "END_BLOCK")
+			assert.NotContains(t, writer.String(), "BEGIN_BLOCK 2")
+
+			select {
+			case <-OutputBroken():
+			default:
+				t.Fatal("output broken channel should be closed")
+			}
+		})
+	}
+}
+
+func TestOutputBroken_writeErrorPolicyNotApplied(t *testing.T) {
+	defer resetOutputBroken()
+	withWriteErrorPolicy(t, CrashWriteErrorPolicy, 0)
+
+	printer := &DelegateToWriterPrinter{writer: &brokenPipeWriter{}}
+	assert.NotPanics(t, func() { printer.Print("BEGIN_BLOCK", "1") })
+	assert.Equal(t, ErrOutputBroken, OutputError())
+}
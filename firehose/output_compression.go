@@ -0,0 +1,64 @@
+package firehose
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// OutputCompression is the compression algorithm applied to the whole Firehose output stream.
+type OutputCompression string
+
+const (
+	NoOutputCompression   OutputCompression = "none"
+	GzipOutputCompression OutputCompression = "gzip"
+)
+
+func ParseOutputCompression(in string) (OutputCompression, error) {
+	switch OutputCompression(in) {
+	case "", NoOutputCompression:
+		return NoOutputCompression, nil
+	case GzipOutputCompression:
+		return GzipOutputCompression, nil
+	}
+
+	return "", fmt.Errorf("invalid output compression %q, valid values are %q and %q", in, NoOutputCompression, GzipOutputCompression)
+}
+
+// gzipWriter compresses everything written to it into the destination. Each `Flush`
+// performs a gzip sync flush so all the data written up to that point, which always
+// ends on a block boundary, can be decompressed even if the process crashes right after.
+//
+// Readers can detect the compression from the gzip magic header `1f 8b` starting the
+// stream, the INIT message itself being written compressed.
+type gzipWriter struct {
+	*gzip.Writer
+
+	dest io.WriteCloser
+}
+
+func newGzipWriter(dest io.WriteCloser) *gzipWriter {
+	return &gzipWriter{Writer: gzip.NewWriter(dest), dest: dest}
+}
+
+func (w *gzipWriter) Flush() error {
+	if err := w.Writer.Flush(); err != nil {
+		return err
+	}
+
+	return flushDestination(w.dest)
+}
+
+func (w *gzipWriter) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		w.dest.Close()
+		return fmt.Errorf("close gzip: %w", err)
+	}
+
+	if err := flushDestination(w.dest); err != nil {
+		w.dest.Close()
+		return err
+	}
+
+	return w.dest.Close()
+}
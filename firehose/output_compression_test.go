@@ -0,0 +1,57 @@
+package firehose
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestGzipWriter_decompressibleAtBlockBoundary(t *testing.T) {
+	block := recordAllMessages(t, LineOutputEncoding)
+
+	compressed := bytes.NewBuffer(nil)
+	out := newBufferedOutput(newGzipWriter(nopWriteCloser{compressed}), FlushPolicy{})
+
+	_, err := out.Write(block)
+	require.NoError(t, err)
+	require.NoError(t, out.Flush())
+
+	// The stream is not closed, simulating a crash after the block was flushed
+	reader, err := gzip.NewReader(bytes.NewReader(compressed.Bytes()))
+	require.NoError(t, err)
+
+	decompressed, err := ioutil.ReadAll(reader)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, block, decompressed)
+}
+
+func BenchmarkOutputCompression(b *testing.B) {
+	block := recordAllMessages(&testing.T{}, LineOutputEncoding)
+
+	for _, compression := range []OutputCompression{NoOutputCompression, GzipOutputCompression} {
+		b.Run(string(compression), func(b *testing.B) {
+			var dest io.WriteCloser = nopWriteCloser{ioutil.Discard}
+			if compression == GzipOutputCompression {
+				dest = newGzipWriter(dest)
+			}
+
+			out := newBufferedOutput(dest, FlushPolicy{})
+			b.SetBytes(int64(len(block)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				out.Write(block)
+				out.Flush()
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package firehose
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"go.uber.org/atomic"
+)
+
+var secondarySinkFailureCounter = metrics.NewRegisteredCounter("firehose/output/secondary/failures", nil)
+
+// defaultSecondaryQueueSize is the queue depth of secondary sinks when no output buffer
+// size is configured.
+const defaultSecondaryQueueSize = 1024
+
+// openOutputSpec opens the destination described by an output spec, one of `stdout`,
+// `file:<path>`, `fd:<number>`, `socket:<path>`, `pipe:<path>` or `tcp:<host>:<port>`.
+func openOutputSpec(spec string, config OutputConfig) (io.WriteCloser, error) {
+	if spec == "stdout" {
+		return stdoutDestination{}, nil
+	}
+
+	kind, value := spec, ""
+	if index := strings.Index(spec, ":"); index != -1 {
+		kind, value = spec[:index], spec[index+1:]
+	}
+
+	if value == "" {
+		return nil, fmt.Errorf("invalid output %q, expected one of 'stdout', 'file:<path>', 'fd:<number>', 'socket:<path>', 'pipe:<path>' or 'tcp:<host>:<port>'", spec)
+	}
+
+	switch kind {
+	case "file":
+		return openDestination(OutputConfig{File: value})
+	case "fd":
+		fd, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output %q, file descriptor must be a number: %w", spec, err)
+		}
+
+		return openFileDescriptor(fd)
+	case "socket":
+		return newSocketWriter(value, config.SocketListen, config.SocketBufferSize)
+	case "pipe":
+		return newPipeWriter(value, config.PipeRetention)
+	case "tcp":
+		return openTCP(value, config)
+	}
+
+	return nil, fmt.Errorf("invalid output %q, unknown kind %q, expected one of 'stdout', 'file', 'fd', 'socket', 'pipe' or 'tcp'", spec, kind)
+}
+
+// openFanout opens all the output specs, the first one being the primary sink and all
+// others being secondary sinks.
+func openFanout(specs []string, config OutputConfig) (*fanoutWriter, error) {
+	queueSize := config.BufferSize
+	if queueSize <= 0 {
+		queueSize = defaultSecondaryQueueSize
+	}
+
+	writer := &fanoutWriter{}
+	for i, spec := range specs {
+		dest, err := openOutputSpec(spec, config)
+		if err != nil {
+			writer.Close()
+			return nil, err
+		}
+
+		if i == 0 {
+			writer.primary = dest
+			continue
+		}
+
+		writer.secondaries = append(writer.secondaries, newSecondarySink(spec, dest, queueSize))
+	}
+
+	return writer, nil
+}
+
+// fanoutWriter writes everything to a primary sink and to all secondary sinks. Errors
+// of the primary sink are returned to the caller while secondary sinks are isolated in
+// their own goroutine and queue so they can neither fail nor slow down the primary one.
+type fanoutWriter struct {
+	primary     io.WriteCloser
+	secondaries []*secondarySink
+}
+
+func (w *fanoutWriter) Write(in []byte) (int, error) {
+	for _, secondary := range w.secondaries {
+		secondary.enqueue(asyncEntry{data: append([]byte(nil), in...)})
+	}
+
+	return w.primary.Write(in)
+}
+
+func (w *fanoutWriter) Flush() error {
+	for _, secondary := range w.secondaries {
+		secondary.enqueue(asyncEntry{flush: true})
+	}
+
+	return flushDestination(w.primary)
+}
+
+func (w *fanoutWriter) Close() error {
+	for _, secondary := range w.secondaries {
+		secondary.close()
+	}
+
+	if w.primary == nil {
+		return nil
+	}
+
+	return w.primary.Close()
+}
+
+// secondarySink is a best effort sink, as soon as a write to it fails or its queue is
+// full, the failure is logged and counted and the sink is not written to anymore.
+type secondarySink struct {
+	name   string
+	dest   io.WriteCloser
+	queue  chan asyncEntry
+	done   chan struct{}
+	failed *atomic.Bool
+}
+
+func newSecondarySink(name string, dest io.WriteCloser, queueSize int) *secondarySink {
+	s := &secondarySink{
+		name:   name,
+		dest:   dest,
+		queue:  make(chan asyncEntry, queueSize),
+		done:   make(chan struct{}),
+		failed: atomic.NewBool(false),
+	}
+
+	go s.run()
+
+	return s
+}
+
+func (s *secondarySink) run() {
+	defer close(s.done)
+
+	for entry := range s.queue {
+		if s.failed.Load() {
+			continue
+		}
+
+		var err error
+		if entry.flush {
+			err = flushDestination(s.dest)
+		} else {
+			_, err = s.dest.Write(entry.data)
+		}
+
+		if err != nil {
+			s.fail(err)
+		}
+	}
+}
+
+func (s *secondarySink) enqueue(entry asyncEntry) {
+	if s.failed.Load() {
+		return
+	}
+
+	select {
+	case s.queue <- entry:
+	default:
+		s.fail(fmt.Errorf("queue is full (%d entries)", cap(s.queue)))
+	}
+}
+
+func (s *secondarySink) fail(err error) {
+	if s.failed.CAS(false, true) {
+		log.Error("Firehose secondary output failed, it will not receive any more data", "output", s.name, "err", err)
+		secondarySinkFailureCounter.Inc(1)
+	}
+}
+
+func (s *secondarySink) close() {
+	close(s.queue)
+	<-s.done
+
+	if err := s.dest.Close(); err != nil {
+		log.Warn("Failed to close Firehose secondary output", "output", s.name, "err", err)
+	}
+}
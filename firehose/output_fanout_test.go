@@ -0,0 +1,52 @@
+package firehose
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write(in []byte) (int, error) { return 0, w.err }
+func (w failingWriter) Close() error                 { return nil }
+
+func TestFanoutWriter_secondaryFailureIsNotFatal(t *testing.T) {
+	primary := bytes.NewBuffer(nil)
+	secondary := bytes.NewBuffer(nil)
+
+	writer := &fanoutWriter{
+		primary: nopWriteCloser{primary},
+		secondaries: []*secondarySink{
+			newSecondarySink("failing", failingWriter{errors.New("disk full")}, 4),
+			newSecondarySink("working", nopWriteCloser{secondary}, 4),
+		},
+	}
+
+	_, err := writer.Write([]byte("FIRE BEGIN_BLOCK 1\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, "FIRE BEGIN_BLOCK 1\n", primary.String())
+	assert.Equal(t, "FIRE BEGIN_BLOCK 1\n", secondary.String())
+	assert.True(t, writer.secondaries[0].failed.Load())
+	assert.False(t, writer.secondaries[1].failed.Load())
+}
+
+func TestFanoutWriter_primaryFailureIsReturned(t *testing.T) {
+	writer := &fanoutWriter{primary: failingWriter{errors.New("disk full")}}
+
+	_, err := writer.Write([]byte("FIRE BEGIN_BLOCK 1\n"))
+	assert.EqualError(t, err, "disk full")
+}
+
+func TestOpenOutputSpec_invalid(t *testing.T) {
+	for _, spec := range []string{"", "file:", "fd:abc", "http://localhost"} {
+		_, err := openOutputSpec(spec, OutputConfig{})
+		assert.Error(t, err, spec)
+	}
+}
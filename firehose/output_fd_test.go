@@ -0,0 +1,40 @@
+// +build !windows
+
+package firehose
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenFileDescriptor(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	defer reader.Close()
+	defer writer.Close()
+
+	// The files opened on a descriptor close it once garbage collected, duplicates are used
+	// so descriptors closed by this test can't be closed again once re-used by another one
+	dup := func(file *os.File) int {
+		fd, err := syscall.Dup(int(file.Fd()))
+		require.NoError(t, err)
+
+		return fd
+	}
+
+	writerFD := dup(writer)
+	file, err := openFileDescriptor(writerFD)
+	require.NoError(t, err)
+	defer file.Close()
+	assert.Equal(t, uintptr(writerFD), file.Fd())
+
+	_, err = openFileDescriptor(dup(reader))
+	assert.Error(t, err)
+
+	_, err = openFileDescriptor(1 << 20)
+	assert.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Output throughput and lag metrics, they are all no-ops unless metrics are enabled and are
+// only ever updated by the printer writing to the output, so when Firehose is enabled.
+var (
+	outputBytesMeter  = metrics.NewRegisteredMeter("firehose/output/bytes", nil)
+	outputLinesMeter  = metrics.NewRegisteredMeter("firehose/output/lines", nil)
+	outputBlocksMeter = metrics.NewRegisteredMeter("firehose/output/blocks", nil)
+
+	// outputQueueGauge is the number of operations handed over to the writer goroutine
+	// and not yet performed.
+	outputQueueGauge = metrics.NewRegisteredGauge("firehose/output/queue", nil)
+
+	// outputWriteTimer is the time spent writing to and flushing the output, outputHandOffTimer
+	// is the time the instrumented goroutines spent waiting on a full writer goroutine queue.
+	outputWriteTimer   = metrics.NewRegisteredTimer("firehose/output/write", nil)
+	outputHandOffTimer = metrics.NewRegisteredTimer("firehose/output/handoff", nil)
+)
+
+// countMessages counts the Firehose messages contained in the formatted data.
+func countMessages(in []byte) int {
+	if Encoding != FramedOutputEncoding {
+		return bytes.Count(in, []byte{'\n'})
+	}
+
+	count := 0
+	for len(in) >= 4 {
+		size := 4 + int(binary.BigEndian.Uint32(in))
+		if size > len(in) {
+			break
+		}
+
+		in = in[size:]
+		count++
+	}
+
+	return count
+}
@@ -0,0 +1,19 @@
+package firehose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountMessages(t *testing.T) {
+	defer func(previous OutputEncoding) { Encoding = previous }(Encoding)
+
+	for _, encoding := range []OutputEncoding{LineOutputEncoding, FramedOutputEncoding} {
+		Encoding = encoding
+
+		data := append(formatMessage([]string{"BEGIN_BLOCK", "1"}), formatMessage([]string{"END_BLOCK", "1", "{}"})...)
+		assert.Equal(t, 2, countMessages(data), string(encoding))
+		assert.Equal(t, 0, countMessages(nil), string(encoding))
+	}
+}
@@ -0,0 +1,125 @@
+package firehose
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// pipeWriter writes Firehose lines to a named pipe (FIFO) and survives restarts of the
+// consumer reading it. The last complete blocks are retained in memory and when the
+// consumer goes away (the write fails with `EPIPE`), the pipe is re-opened at the next
+// block boundary, waiting for a consumer to open it, and the retained blocks are replayed.
+//
+// A block boundary is marked by a call to `Flush`.
+type pipeWriter struct {
+	path string
+	file *os.File
+
+	// current is the block being written, retained holds the last complete blocks
+	// and is bounded to `retention` blocks.
+	current   []byte
+	retained  [][]byte
+	retention int
+}
+
+func newPipeWriter(path string, retention int) (*pipeWriter, error) {
+	if retention < 1 {
+		return nil, fmt.Errorf("invalid pipe retention %d, at least 1 block must be retained", retention)
+	}
+
+	w := &pipeWriter{path: path, retention: retention}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *pipeWriter) Write(in []byte) (int, error) {
+	w.current = append(w.current, in...)
+
+	if w.file != nil {
+		if err := w.write(in); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(in), nil
+}
+
+func (w *pipeWriter) Flush() error {
+	block := make([]byte, len(w.current))
+	copy(block, w.current)
+	w.current = w.current[:0]
+
+	w.retained = append(w.retained, block)
+	if len(w.retained) > w.retention {
+		w.retained = w.retained[1:]
+	}
+
+	if w.file != nil {
+		return nil
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	log.Info("Firehose output pipe consumer reconnected, replaying retained blocks", "path", w.path, "replayed_blocks", len(w.retained))
+	for _, retained := range w.retained {
+		if err := w.write(retained); err != nil {
+			return err
+		}
+
+		if w.file == nil {
+			// The consumer went away again while replaying, we will retry on next block boundary
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (w *pipeWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+
+	return err
+}
+
+// write writes to the pipe, a broken pipe is not an error, the pipe is closed and
+// will be re-opened on the next block boundary.
+func (w *pipeWriter) write(in []byte) error {
+	_, err := w.file.Write(in)
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, syscall.EPIPE) {
+		log.Warn("Firehose output pipe consumer went away, waiting for it to come back on next block boundary", "path", w.path)
+		w.file.Close()
+		w.file = nil
+		return nil
+	}
+
+	return fmt.Errorf("write to pipe %q: %w", w.path, err)
+}
+
+// open opens the pipe for writing, opening a FIFO blocks until a consumer opens it for reading.
+func (w *pipeWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("open pipe %q: %w", w.path, err)
+	}
+
+	w.file = file
+	return nil
+}
@@ -0,0 +1,67 @@
+// +build !windows
+
+package firehose
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeWriter_replayOnConsumerRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "firehose-pipe")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "fire.fifo")
+	require.NoError(t, syscall.Mkfifo(path, 0600))
+
+	consumed := consumePipe(t, path)
+	writer, err := newPipeWriter(path, 2)
+	require.NoError(t, err)
+
+	writeBlock(t, writer, "FIRE BLOCK 1\n")
+	require.NoError(t, writer.Close())
+	assert.Equal(t, "FIRE BLOCK 1\n", <-consumed)
+
+	// Simulate the consumer going away, the next write hits a broken pipe
+	consumer, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	require.NoError(t, err)
+	require.NoError(t, writer.open())
+	consumer.Close()
+
+	_, err = writer.Write([]byte("FIRE BLOCK 2\n"))
+	require.NoError(t, err)
+	assert.Nil(t, writer.file)
+
+	consumed = consumePipe(t, path)
+	require.NoError(t, writer.Flush())
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, "FIRE BLOCK 1\nFIRE BLOCK 2\n", <-consumed)
+}
+
+func consumePipe(t *testing.T, path string) <-chan string {
+	t.Helper()
+
+	consumed := make(chan string, 1)
+	go func() {
+		data, _ := ioutil.ReadFile(path)
+		consumed <- string(data)
+	}()
+
+	return consumed
+}
+
+func writeBlock(t *testing.T, writer *pipeWriter, block string) {
+	t.Helper()
+
+	_, err := writer.Write([]byte(block))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+}
@@ -0,0 +1,132 @@
+package firehose
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// rotatingOutput writes to numbered files `<path>.<index>`, rolling to the next index
+// once the current file reached the max size. Rolling only happens on a block boundary
+// so a block is never split across two files.
+//
+// Before a file is closed, a `FILE_BLOCK_RANGE <first> <last>` message is appended to
+// it with the first and last block number it contains, so downstream tooling can index
+// the files without scanning them.
+type rotatingOutput struct {
+	path    string
+	maxSize int64
+	chain   func(dest io.WriteCloser) *bufferedOutput
+
+	index   int
+	current *bufferedOutput
+	written int64
+
+	// firstBlock and lastBlock are the range of blocks in the current file, `hasBlock`
+	// is false until the first block ends in the current file.
+	firstBlock uint64
+	lastBlock  uint64
+	hasBlock   bool
+}
+
+func newRotatingOutput(path string, maxSize int64, chain func(dest io.WriteCloser) *bufferedOutput) (*rotatingOutput, error) {
+	index, err := nextRotationIndex(path)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &rotatingOutput{path: path, maxSize: maxSize, chain: chain, index: index}
+	if err := o.open(); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// nextRotationIndex returns the index following the highest one found on disk, so a
+// restarted node never appends to a file that was already closed with its trailer.
+func nextRotationIndex(path string) (int, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return 0, fmt.Errorf("list rotated files of %q: %w", path, err)
+	}
+
+	next := 0
+	for _, match := range matches {
+		index, err := strconv.Atoi(strings.TrimPrefix(match, path+"."))
+		if err == nil && index >= next {
+			next = index + 1
+		}
+	}
+
+	return next, nil
+}
+
+func (o *rotatingOutput) filename() string {
+	return fmt.Sprintf("%s.%06d", o.path, o.index)
+}
+
+func (o *rotatingOutput) open() error {
+	file, err := os.OpenFile(o.filename(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open output file %q: %w", o.filename(), err)
+	}
+
+	o.current = o.chain(file)
+	o.written = 0
+	o.hasBlock = false
+
+	return nil
+}
+
+func (o *rotatingOutput) Write(in []byte) (int, error) {
+	written, err := o.current.Write(in)
+	o.written += int64(written)
+
+	return written, err
+}
+
+func (o *rotatingOutput) Flush() error {
+	return o.current.Flush()
+}
+
+func (o *rotatingOutput) EndOfBlock(blockNum uint64) error {
+	if !o.hasBlock {
+		o.firstBlock = blockNum
+		o.hasBlock = true
+	}
+	o.lastBlock = blockNum
+
+	if o.written < o.maxSize {
+		return nil
+	}
+
+	if err := o.closeCurrent(); err != nil {
+		return err
+	}
+
+	log.Info("Firehose output file rotated", "file", o.filename(), "first_block", o.firstBlock, "last_block", o.lastBlock)
+
+	o.index++
+	return o.open()
+}
+
+func (o *rotatingOutput) Close() error {
+	return o.closeCurrent()
+}
+
+func (o *rotatingOutput) closeCurrent() error {
+	if o.hasBlock {
+		if _, err := o.current.Write(formatMessage([]string{"FILE_BLOCK_RANGE", Uint64(o.firstBlock), Uint64(o.lastBlock)})); err != nil {
+			o.current.Close()
+			return fmt.Errorf("write trailer of %q: %w", o.filename(), err)
+		}
+	}
+
+	return o.current.Close()
+}
@@ -0,0 +1,180 @@
+package firehose
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	socketConnectedGauge   = metrics.NewRegisteredGauge("firehose/output/socket/connected", nil)
+	socketReconnectCounter = metrics.NewRegisteredCounter("firehose/output/socket/reconnects", nil)
+)
+
+const (
+	socketMinBackoff = 100 * time.Millisecond
+	socketMaxBackoff = 5 * time.Second
+)
+
+// socketWriter streams Firehose lines over a Unix domain socket, either by dialing a
+// peer listening on the path or by listening on the path and accepting a single peer.
+//
+// All bytes written since the last block boundary that was fully delivered are retained
+// so that when the peer goes away, the new peer receives the stream starting back from
+// that boundary. A block boundary is marked by a call to `Flush`.
+type socketWriter struct {
+	path     string
+	listener net.Listener
+	conn     net.Conn
+
+	// pending holds the bytes written since the last block boundary fully delivered
+	// to the peer, sent is how many of those bytes were written on the active connection.
+	pending []byte
+	sent    int
+
+	// bufferLimit is the maximum of bytes kept in `pending` while no peer is connected,
+	// above it, writes block until a peer connects.
+	bufferLimit int
+}
+
+func newSocketWriter(path string, listen bool, bufferLimit int) (*socketWriter, error) {
+	w := &socketWriter{path: path, bufferLimit: bufferLimit}
+
+	if listen {
+		// A stale socket file left by a previous run would make the listen fail
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("remove stale socket %q: %w", path, err)
+		}
+
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("listen on socket %q: %w", path, err)
+		}
+
+		w.listener = listener
+	}
+
+	return w, nil
+}
+
+func (w *socketWriter) Write(in []byte) (int, error) {
+	w.pending = append(w.pending, in...)
+
+	if w.conn == nil && len(w.pending) > w.bufferLimit {
+		w.connect()
+	}
+
+	if w.conn != nil {
+		w.send()
+	}
+
+	return len(in), nil
+}
+
+// Flush marks a block boundary, once all pending bytes reached the peer, they are
+// discarded. When no peer is connected, pending bytes are retained up to the buffer
+// limit before blocking until a peer connects.
+func (w *socketWriter) Flush() error {
+	for w.conn == nil || w.sent < len(w.pending) {
+		if w.conn == nil && !w.tryConnect() {
+			if len(w.pending) <= w.bufferLimit {
+				return nil
+			}
+
+			w.connect()
+		}
+
+		w.send()
+	}
+
+	w.pending = w.pending[:0]
+	w.sent = 0
+
+	return nil
+}
+
+func (w *socketWriter) Close() error {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	if w.listener != nil {
+		return w.listener.Close()
+	}
+
+	return nil
+}
+
+// send writes what is not yet sent of the pending bytes to the active connection,
+// dropping the connection on error so that the next connection starts back from
+// the last delivered block boundary.
+func (w *socketWriter) send() {
+	written, err := w.conn.Write(w.pending[w.sent:])
+	w.sent += written
+
+	if err != nil {
+		log.Info("Firehose output socket peer disconnected", "path", w.path, "err", err)
+
+		w.conn.Close()
+		w.conn = nil
+		w.sent = 0
+		socketConnectedGauge.Update(0)
+	}
+}
+
+// tryConnect performs a single connection attempt, returning whether it succeeded.
+// In listening mode, an attempt is a blocking accept so it's only performed by `connect`.
+func (w *socketWriter) tryConnect() bool {
+	if w.listener != nil {
+		return false
+	}
+
+	conn, err := net.Dial("unix", w.path)
+	if err != nil {
+		return false
+	}
+
+	w.connected(conn)
+	return true
+}
+
+// connect blocks until a peer is connected, retrying with an exponential backoff.
+func (w *socketWriter) connect() {
+	backoff := socketMinBackoff
+
+	for {
+		var conn net.Conn
+		var err error
+		if w.listener != nil {
+			conn, err = w.listener.Accept()
+		} else {
+			conn, err = net.Dial("unix", w.path)
+		}
+
+		if err == nil {
+			w.connected(conn)
+			return
+		}
+
+		log.Debug("Firehose output socket connection failed, retrying", "path", w.path, "backoff", backoff, "err", err)
+		time.Sleep(backoff)
+
+		if backoff *= 2; backoff > socketMaxBackoff {
+			backoff = socketMaxBackoff
+		}
+	}
+}
+
+func (w *socketWriter) connected(conn net.Conn) {
+	log.Info("Firehose output socket peer connected", "path", w.path, "replayed_bytes", len(w.pending))
+
+	w.conn = conn
+	w.sent = 0
+	socketConnectedGauge.Update(1)
+	socketReconnectCounter.Inc(1)
+}
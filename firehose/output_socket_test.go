@@ -0,0 +1,50 @@
+package firehose
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocketWriter_bufferUntilPeerConnects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "firehose-socket")
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "fire.sock")
+
+	writer, err := newSocketWriter(path, false, 1024)
+	require.NoError(t, err)
+	defer writer.Close()
+
+	// No peer yet, lines are retained across block boundaries
+	writer.Write([]byte("FIRE BEGIN_BLOCK 1\n"))
+	require.NoError(t, writer.Flush())
+
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	writer.Write([]byte("FIRE BEGIN_BLOCK 2\n"))
+
+	received := make(chan []byte)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		defer conn.Close()
+
+		data, _ := ioutil.ReadAll(conn)
+		received <- data
+	}()
+
+	require.NoError(t, writer.Flush())
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, "FIRE BEGIN_BLOCK 1\nFIRE BEGIN_BLOCK 2\n", string(<-received))
+}
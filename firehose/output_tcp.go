@@ -0,0 +1,211 @@
+package firehose
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	tcpConnectedGauge   = metrics.NewRegisteredGauge("firehose/output/tcp/connected", nil)
+	tcpReconnectCounter = metrics.NewRegisteredCounter("firehose/output/tcp/reconnects", nil)
+)
+
+const (
+	tcpDialTimeout = 10 * time.Second
+	tcpMinBackoff  = 100 * time.Millisecond
+	tcpMaxBackoff  = 5 * time.Second
+)
+
+// tcpWriter streams Firehose lines to a remote collector over TCP, optionally secured
+// with (mutually authenticated) TLS, and survives restarts of the collector. The last
+// complete blocks are retained in memory and when the connection breaks, the collector
+// is re-dialed at the next block boundary, blocking until it's reachable again, and the
+// retained blocks are replayed.
+//
+// A block boundary is marked by a call to `Flush`.
+type tcpWriter struct {
+	address   string
+	tlsConfig *tls.Config
+	conn      net.Conn
+
+	// current is the block being written, retained holds the last complete blocks
+	// and is bounded to `retention` blocks.
+	current   []byte
+	retained  [][]byte
+	retention int
+}
+
+func newTCPWriter(address string, tlsConfig *tls.Config, retention int) (*tcpWriter, error) {
+	if retention < 1 {
+		return nil, fmt.Errorf("invalid tcp retention %d, at least 1 block must be retained", retention)
+	}
+
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid tcp address %q, expected <host>:<port>: %w", address, err)
+	}
+
+	w := &tcpWriter{address: address, tlsConfig: tlsConfig, retention: retention}
+	if err := w.dial(); err != nil {
+		log.Warn("Firehose output collector is not reachable, will connect on next block boundary", "address", address, "err", err)
+	}
+
+	return w, nil
+}
+
+// openTCP opens a writer to the collector at the address using the TLS settings of the config.
+func openTCP(address string, config OutputConfig) (*tcpWriter, error) {
+	tlsConfig, err := loadTCPTLSConfig(config.TLSCert, config.TLSKey, config.TLSCA)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTCPWriter(address, tlsConfig, config.TCPRetention)
+}
+
+// loadTCPTLSConfig builds the TLS configuration out of the client certificate, its key
+// and the certificate authority used to verify the collector, returning `nil` when none
+// are set, meaning plain TCP is used.
+func loadTCPTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" && caFile == "" {
+		return nil, nil
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("tls certificate and key must be set together")
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if certFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read tls ca %q: %w", caFile, err)
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls ca %q contains no valid certificate", caFile)
+		}
+	}
+
+	return config, nil
+}
+
+func (w *tcpWriter) Write(in []byte) (int, error) {
+	w.current = append(w.current, in...)
+
+	if w.conn != nil {
+		w.write(in)
+	}
+
+	return len(in), nil
+}
+
+func (w *tcpWriter) Flush() error {
+	block := make([]byte, len(w.current))
+	copy(block, w.current)
+	w.current = w.current[:0]
+
+	w.retained = append(w.retained, block)
+	if len(w.retained) > w.retention {
+		w.retained = w.retained[1:]
+	}
+
+	if w.conn != nil {
+		return nil
+	}
+
+	w.connect()
+
+	log.Info("Firehose output collector reconnected, replaying retained blocks", "address", w.address, "replayed_blocks", len(w.retained))
+	for _, retained := range w.retained {
+		w.write(retained)
+
+		if w.conn == nil {
+			// The collector went away again while replaying, we will retry on next block boundary
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (w *tcpWriter) Close() error {
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+	tcpConnectedGauge.Update(0)
+
+	return err
+}
+
+// write writes to the collector, a failure is not an error, the connection is closed
+// and the collector will be re-dialed on the next block boundary.
+func (w *tcpWriter) write(in []byte) {
+	if _, err := w.conn.Write(in); err != nil {
+		log.Warn("Firehose output collector connection broke, reconnecting on next block boundary", "address", w.address, "err", err)
+		w.Close()
+	}
+}
+
+// connect blocks until the collector is reachable, retrying with an exponential backoff.
+func (w *tcpWriter) connect() {
+	backoff := tcpMinBackoff
+
+	for {
+		err := w.dial()
+		if err == nil {
+			tcpReconnectCounter.Inc(1)
+			return
+		}
+
+		log.Debug("Firehose output collector connection failed, retrying", "address", w.address, "backoff", backoff, "err", err)
+		time.Sleep(backoff)
+
+		if backoff *= 2; backoff > tcpMaxBackoff {
+			backoff = tcpMaxBackoff
+		}
+	}
+}
+
+func (w *tcpWriter) dial() error {
+	dialer := &net.Dialer{Timeout: tcpDialTimeout, KeepAlive: 30 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if w.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", w.address, w.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", w.address)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log.Info("Firehose output collector connected", "address", w.address, "tls", w.tlsConfig != nil)
+
+	w.conn = conn
+	tcpConnectedGauge.Update(1)
+
+	return nil
+}
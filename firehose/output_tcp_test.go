@@ -0,0 +1,65 @@
+package firehose
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCPWriter_replayRetainedBlocksOnReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan []byte)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				close(received)
+				return
+			}
+
+			data, _ := ioutil.ReadAll(conn)
+			conn.Close()
+			received <- data
+		}
+	}()
+
+	writer, err := newTCPWriter(listener.Addr().String(), nil, 2)
+	require.NoError(t, err)
+
+	writer.Write([]byte("FIRE BEGIN_BLOCK 1\n"))
+	require.NoError(t, writer.Flush())
+
+	// Simulate the connection breaking in the middle of block 2
+	writer.conn.Close()
+	assert.Equal(t, "FIRE BEGIN_BLOCK 1\n", string(<-received))
+
+	broken, peer := net.Pipe()
+	peer.Close()
+	writer.conn = broken
+
+	writer.Write([]byte("FIRE BEGIN_BLOCK 2\n"))
+	assert.Nil(t, writer.conn)
+
+	require.NoError(t, writer.Flush())
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, "FIRE BEGIN_BLOCK 1\nFIRE BEGIN_BLOCK 2\n", string(<-received))
+}
+
+func TestLoadTCPTLSConfig(t *testing.T) {
+	config, err := loadTCPTLSConfig("", "", "")
+	require.NoError(t, err)
+	assert.Nil(t, config)
+
+	_, err = loadTCPTLSConfig("client.pem", "", "")
+	assert.Error(t, err)
+
+	_, err = loadTCPTLSConfig("", "", "/does/not/exist/ca.pem")
+	assert.Error(t, err)
+}
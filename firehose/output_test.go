@@ -0,0 +1,193 @@
+package firehose
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFlushPolicy(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      FlushPolicy
+		expectErr bool
+	}{
+		{"", FlushPolicy{}, false},
+		{"4096", FlushPolicy{Size: 4096}, false},
+		{"512KiB", FlushPolicy{Size: 512 * 1024}, false},
+		{"4MiB", FlushPolicy{Size: 4 * 1024 * 1024}, false},
+		{"2MB", FlushPolicy{Size: 2000000}, false},
+		{"500ms", FlushPolicy{Interval: 500 * time.Millisecond}, false},
+		{"2s", FlushPolicy{Interval: 2 * time.Second}, false},
+		{"0", FlushPolicy{}, true},
+		{"-1s", FlushPolicy{}, true},
+		{"4XB", FlushPolicy{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			policy, err := ParseFlushPolicy(tt.in)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, policy)
+		})
+	}
+}
+
+// countingWriter counts the writes it receives, each one being a syscall for a real file.
+type countingWriter struct{ writes int }
+
+func (w *countingWriter) Write(in []byte) (int, error) { w.writes++; return len(in), nil }
+func (w *countingWriter) Close() error                 { return nil }
+
+// BenchmarkFlushPolicy imports 10k blocks, reporting the number of writes reaching the
+// destination per block for unbuffered line per line writes and for buffered flush policies.
+func BenchmarkFlushPolicy(b *testing.B) {
+	lines := bytes.SplitAfter(recordAllMessages(&testing.T{}, LineOutputEncoding), []byte("\n"))
+
+	run := func(b *testing.B, dest *countingWriter, writer io.Writer, flush func()) {
+		for i := 0; i < b.N; i++ {
+			for block := 0; block < 10000; block++ {
+				for _, line := range lines {
+					writer.Write(line)
+				}
+				flush()
+			}
+		}
+
+		b.ReportMetric(float64(dest.writes)/float64(b.N*10000), "writes/block")
+	}
+
+	b.Run("per_line", func(b *testing.B) {
+		dest := &countingWriter{}
+		run(b, dest, dest, func() {})
+	})
+
+	for _, policy := range []string{"4MiB", "100ms"} {
+		b.Run(policy, func(b *testing.B) {
+			flushPolicy, err := ParseFlushPolicy(policy)
+			require.NoError(b, err)
+
+			dest := &countingWriter{}
+			out := newBufferedOutput(dest, flushPolicy)
+			run(b, dest, out, func() { out.Flush() })
+			out.Close()
+		})
+	}
+}
+
+func TestRotatingOutput_rollsOnBlockBoundary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "firehose-rotation")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "fire.log")
+	chain := func(dest io.WriteCloser) *bufferedOutput { return newBufferedOutput(dest, FlushPolicy{}) }
+
+	out, err := newRotatingOutput(path, 20, chain)
+	require.NoError(t, err)
+
+	for blockNum := uint64(1); blockNum <= 3; blockNum++ {
+		// The first line alone reaches the max size but rotation must wait for the block to end
+		out.Write([]byte(fmt.Sprintf("FIRE BEGIN_BLOCK %d\n", blockNum)))
+		out.Write([]byte(fmt.Sprintf("FIRE END_BLOCK %d\n", blockNum)))
+		require.NoError(t, out.Flush())
+		require.NoError(t, out.EndOfBlock(blockNum))
+	}
+	require.NoError(t, out.Close())
+
+	for i, expected := range []string{
+		"FIRE BEGIN_BLOCK 1\nFIRE END_BLOCK 1\nFIRE FILE_BLOCK_RANGE 1 1\n",
+		"FIRE BEGIN_BLOCK 2\nFIRE END_BLOCK 2\nFIRE FILE_BLOCK_RANGE 2 2\n",
+		"FIRE BEGIN_BLOCK 3\nFIRE END_BLOCK 3\nFIRE FILE_BLOCK_RANGE 3 3\n",
+		"",
+	} {
+		content, err := ioutil.ReadFile(fmt.Sprintf("%s.%06d", path, i))
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+	}
+
+	// A restart never appends to an already rotated file
+	index, err := nextRotationIndex(path)
+	require.NoError(t, err)
+	assert.Equal(t, 4, index)
+}
+
+// recordingWriter records each write it receives.
+type recordingWriter struct{ writes []string }
+
+func (w *recordingWriter) Write(in []byte) (int, error) {
+	w.writes = append(w.writes, string(in))
+	return len(in), nil
+}
+func (w *recordingWriter) Close() error { return nil }
+
+func TestBufferedOutput_writesReachDestinationWhole(t *testing.T) {
+	dest := &recordingWriter{}
+	out := newBufferedOutput(dest, FlushPolicy{Size: 16})
+
+	blocks := []string{"0123456789", "abcdefghij", "ABCDEFGHIJKLMNOPQRSTUVWXYZ", "klm"}
+	for _, block := range blocks {
+		_, err := out.Write([]byte(block))
+		require.NoError(t, err)
+	}
+	require.NoError(t, out.Flush())
+
+	assert.Equal(t, blocks, dest.writes)
+}
+
+func TestSetWriter(t *testing.T) {
+	defer func(enabled, progress bool, ctx *Context) {
+		Close()
+		SetWriter(nil)
+		Enabled, BlockProgressEnabled, syncContext = enabled, progress, ctx
+	}(Enabled, BlockProgressEnabled, syncContext)
+
+	out := &flushCountingBuffer{}
+	SetWriter(out)
+
+	require.NoError(t, Init(true, true, false, false, nil, "", nil, nil, OutputConfig{}, "1.10.1"))
+	InitChainConfig(params.TestChainConfig, common.Hash{}, 0)
+	require.NoError(t, WaitOutputWritten())
+
+	assert.True(t, bytes.HasPrefix(out.Bytes(), []byte("FIRE INIT ")), out.String())
+	assert.Equal(t, 1, out.flushes)
+}
+
+func TestInit_genesisFileConflict(t *testing.T) {
+	defer func(genesis interface{}) { GenesisConfig = genesis }(GenesisConfig)
+
+	type genesis struct{ Nonce uint64 }
+	newGenesis := func() interface{} { return new(genesis) }
+	genesisHash := func(in interface{}) common.Hash { return common.BigToHash(new(big.Int).SetUint64(in.(*genesis).Nonce)) }
+
+	path := filepath.Join(t.TempDir(), "genesis.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"Nonce": 66}`), 0600))
+
+	// The file agrees with the network's embedded genesis
+	require.NoError(t, Init(false, true, false, false, &genesis{Nonce: 66}, path, newGenesis, genesisHash, OutputConfig{}, "1.10.1"))
+	assert.Equal(t, &genesis{Nonce: 66}, GenesisConfig)
+
+	err := Init(false, true, false, false, &genesis{Nonce: 42}, path, newGenesis, genesisHash, OutputConfig{}, "1.10.1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "conflicts with the network's embedded genesis")
+
+	// Without a network, the file is the genesis
+	require.NoError(t, Init(false, true, false, false, nil, path, newGenesis, genesisHash, OutputConfig{}, "1.10.1"))
+	assert.Equal(t, &genesis{Nonce: 66}, GenesisConfig)
+}
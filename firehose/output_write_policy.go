@@ -0,0 +1,128 @@
+package firehose
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// WriteErrorPolicy controls what happens when Firehose data cannot be written to the output.
+//
+// Whatever the policy, the node never moves on with a block whose Firehose data was not
+// fully written, it either succeeds writing it or crashes. A broken output is never retried,
+// the node stops importing blocks and shuts down instead, see `OutputBroken`.
+type WriteErrorPolicy string
+
+const (
+	// CrashWriteErrorPolicy crashes the node on the first write error, this is the default.
+	CrashWriteErrorPolicy WriteErrorPolicy = "crash"
+
+	// RetryWriteErrorPolicy retries the failed write with an exponential backoff until
+	// `WriteRetryDeadline` elapsed, crashing the node if the write is still failing.
+	RetryWriteErrorPolicy WriteErrorPolicy = "retry"
+)
+
+const (
+	writeRetryMinBackoff = 100 * time.Millisecond
+	writeRetryMaxBackoff = 10 * time.Second
+)
+
+var (
+	// OnWriteError is the active `WriteErrorPolicy`.
+	OnWriteError = CrashWriteErrorPolicy
+
+	// WriteRetryDeadline is how long a failing write is retried under `RetryWriteErrorPolicy`.
+	WriteRetryDeadline = 1 * time.Minute
+)
+
+func ParseWriteErrorPolicy(in string) (WriteErrorPolicy, error) {
+	switch WriteErrorPolicy(in) {
+	case "", CrashWriteErrorPolicy:
+		return CrashWriteErrorPolicy, nil
+	case RetryWriteErrorPolicy:
+		return RetryWriteErrorPolicy, nil
+	}
+
+	return "", fmt.Errorf("invalid write error policy %q, valid values are %q and %q", in, CrashWriteErrorPolicy, RetryWriteErrorPolicy)
+}
+
+// applyWriteErrorPolicy performs the operation, applying the active `WriteErrorPolicy` if
+// it fails. The function only returns once the operation succeeded or the output is broken,
+// it panics otherwise.
+//
+// The operation must be resumable, a retry must continue where the failed attempt stopped.
+//
+// An operation failing because the output is broken is not retried, the output is marked
+// broken instead and all operations become no-ops so nothing else gets written, not even
+// the end of the block being written.
+func applyWriteErrorPolicy(operation string, attempt func() error) {
+	if outputIsBroken.Load() {
+		return
+	}
+
+	err := attempt()
+	if err == nil {
+		return
+	}
+
+	if isOutputBrokenError(err) {
+		markOutputBroken(operation, err)
+		return
+	}
+
+	if OnWriteError == RetryWriteErrorPolicy {
+		deadline := time.Now().Add(WriteRetryDeadline)
+		backoff := writeRetryMinBackoff
+
+		for err != nil && time.Now().Before(deadline) {
+			log.Warn("Firehose output write failed, retrying", "operation", operation, "backoff", backoff, "err", err)
+			time.Sleep(backoff)
+
+			if backoff *= 2; backoff > writeRetryMaxBackoff {
+				backoff = writeRetryMaxBackoff
+			}
+
+			err = attempt()
+		}
+
+		if err == nil {
+			log.Info("Firehose output write recovered", "operation", operation)
+			return
+		}
+	}
+
+	errstr := fmt.Sprintf("\nFIREHOSE FAILED %s TO OUTPUT (policy %s): %s\n", operation, OnWriteError, err)
+	ioutil.WriteFile("/tmp/firehose_writer_failed_print.log", []byte(errstr), 0644)
+
+	panic(errstr)
+}
+
+// policyWriter applies the active `WriteErrorPolicy` to writes and flushes of the output
+// destination, so the layers above it never see an error.
+type policyWriter struct {
+	dest io.WriteCloser
+}
+
+func (w policyWriter) Write(in []byte) (int, error) {
+	remaining := in
+	applyWriteErrorPolicy("writing", func() error {
+		return writeFully(w.dest, &remaining)
+	})
+
+	return len(in), nil
+}
+
+func (w policyWriter) Flush() error {
+	applyWriteErrorPolicy("flushing", func() error {
+		return flushDestination(w.dest)
+	})
+
+	return nil
+}
+
+func (w policyWriter) Close() error {
+	return w.dest.Close()
+}
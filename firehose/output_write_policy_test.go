@@ -0,0 +1,82 @@
+package firehose
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyWriter fails the first `failures` writes, each failing write still writing
+// up to `partial` bytes, then behaves like its buffer.
+type flakyWriter struct {
+	bytes.Buffer
+	failures int
+	partial  int
+}
+
+func (w *flakyWriter) Write(in []byte) (int, error) {
+	if w.failures > 0 {
+		w.failures--
+
+		written := w.partial
+		if written > len(in) {
+			written = len(in)
+		}
+
+		w.Buffer.Write(in[:written])
+		return written, errors.New("broken pipe")
+	}
+
+	return w.Buffer.Write(in)
+}
+
+func withWriteErrorPolicy(t *testing.T, policy WriteErrorPolicy, deadline time.Duration) {
+	previousPolicy, previousDeadline := OnWriteError, WriteRetryDeadline
+	OnWriteError, WriteRetryDeadline = policy, deadline
+
+	t.Cleanup(func() { OnWriteError, WriteRetryDeadline = previousPolicy, previousDeadline })
+}
+
+func TestParseWriteErrorPolicy(t *testing.T) {
+	policy, err := ParseWriteErrorPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, CrashWriteErrorPolicy, policy)
+
+	policy, err = ParseWriteErrorPolicy("retry")
+	require.NoError(t, err)
+	assert.Equal(t, RetryWriteErrorPolicy, policy)
+
+	_, err = ParseWriteErrorPolicy("ignore")
+	assert.Error(t, err)
+}
+
+func TestWriteErrorPolicy_crash(t *testing.T) {
+	withWriteErrorPolicy(t, CrashWriteErrorPolicy, time.Minute)
+
+	printer := &DelegateToWriterPrinter{writer: &flakyWriter{failures: 1}}
+
+	assert.Panics(t, func() { printer.Print("BEGIN_BLOCK", "1") })
+}
+
+func TestWriteErrorPolicy_retryResumesPartialWrite(t *testing.T) {
+	withWriteErrorPolicy(t, RetryWriteErrorPolicy, time.Minute)
+
+	writer := &flakyWriter{failures: 2, partial: 4}
+	printer := &DelegateToWriterPrinter{writer: writer}
+
+	printer.Print("BEGIN_BLOCK", "1")
+
+	assert.Equal(t, "FIRE BEGIN_BLOCK 1\n", writer.String())
+}
+
+func TestWriteErrorPolicy_retryCrashesAfterDeadline(t *testing.T) {
+	withWriteErrorPolicy(t, RetryWriteErrorPolicy, 150*time.Millisecond)
+
+	writer := policyWriter{nopWriteCloser{&flakyWriter{failures: 100}}}
+
+	assert.Panics(t, func() { writer.Write([]byte("FIRE BEGIN_BLOCK 1\n")) })
+}
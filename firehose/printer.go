@@ -2,18 +2,22 @@ package firehose
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"math/big"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
 )
 
 type Printer interface {
@@ -23,12 +27,141 @@ type Printer interface {
 	Write(in []byte)
 
 	// Print prints the input to the printer formatting the received input
-	// with `"FIRE" + join(<input>, " ") + "\n"`.
+	// with `LinePrefix + " " + join(<input>, " ") + "\n"` or as a frame when the active
+	// `Encoding` is `FramedOutputEncoding`.
 	Print(input ...string)
 }
 
+// OutputEncoding controls how each Firehose message is encoded when written by the printers.
+type OutputEncoding string
+
+const (
+	// LineOutputEncoding writes each message as a `FIRE <field> <field> ...\n` text line, `FIRE`
+	// being the active `LinePrefix`, this is the default encoding.
+	LineOutputEncoding OutputEncoding = "line"
+
+	// FramedOutputEncoding writes each message as a 4 bytes big-endian length followed by the
+	// payload, the payload being a uvarint fields count followed by each field written as
+	// uvarint length followed by the field's bytes.
+	FramedOutputEncoding OutputEncoding = "framed"
+)
+
+// Encoding is the active `OutputEncoding` used by all printers.
+var Encoding = LineOutputEncoding
+
+func ParseOutputEncoding(in string) (OutputEncoding, error) {
+	switch OutputEncoding(in) {
+	case LineOutputEncoding, FramedOutputEncoding:
+		return OutputEncoding(in), nil
+	}
+
+	return "", fmt.Errorf("invalid output encoding %q, valid values are %q and %q", in, LineOutputEncoding, FramedOutputEncoding)
+}
+
+// formatMessage encodes the message's fields according to the active `Encoding` and
+// `ActiveOutputFormat`.
+func formatMessage(input []string) []byte {
+	if ActiveOutputFormat == JSONOutputFormat {
+		return formatJSONMessage(input)
+	}
+
+	if Encoding == FramedOutputEncoding {
+		return frameMessage(input)
+	}
+
+	var chunks []byte
+	if ChunkThreshold > 0 {
+		input, chunks = chunkFields(input)
+	}
+
+	line := LinePrefix + " " + strings.Join(input, " ") + "\n"
+	if LegacyDMLog != NoLegacyDMLogMode {
+		return formatLegacyDMLog(input, line)
+	}
+
+	if chunks != nil {
+		return append(chunks, line...)
+	}
+
+	return []byte(line)
+}
+
+func frameMessage(input []string) []byte {
+	size := 4 + binary.MaxVarintLen32
+	for _, field := range input {
+		size += binary.MaxVarintLen32 + len(field)
+	}
+
+	out := make([]byte, size)
+	offset := 4
+	offset += binary.PutUvarint(out[offset:], uint64(len(input)))
+	for _, field := range input {
+		offset += binary.PutUvarint(out[offset:], uint64(len(field)))
+		offset += copy(out[offset:], field)
+	}
+
+	binary.BigEndian.PutUint32(out, uint32(offset-4))
+
+	return out[0:offset]
+}
+
+// ReadFramedMessage reads the next message written using `FramedOutputEncoding` from
+// the reader, returning its fields. It returns `io.EOF` when the reader is exhausted
+// on a message boundary.
+func ReadFramedMessage(reader io.Reader) ([]string, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, fmt.Errorf("read payload: %w", io.ErrUnexpectedEOF)
+	}
+
+	count, offset := binary.Uvarint(payload)
+	if offset <= 0 {
+		return nil, fmt.Errorf("invalid fields count")
+	}
+
+	fields := make([]string, count)
+	for i := range fields {
+		length, read := binary.Uvarint(payload[offset:])
+		if read <= 0 || uint64(len(payload)-offset-read) < length {
+			return nil, fmt.Errorf("invalid field %d", i)
+		}
+
+		offset += read
+		fields[i] = string(payload[offset : offset+int(length)])
+		offset += int(length)
+	}
+
+	if offset != len(payload) {
+		return nil, fmt.Errorf("%d trailing bytes after last field", len(payload)-offset)
+	}
+
+	return fields, nil
+}
+
+// LineSequenceEnabled appends a per-process sequence number as the last field of every
+// message written by `DelegateToWriterPrinter`, so the reader can detect lost messages.
+// The sequence is reset to 0 by the INIT message.
+var LineSequenceEnabled = false
+
 type DelegateToWriterPrinter struct {
 	writer io.Writer
+
+	// lock guards the sequence so a message is numbered and written atomically, concurrent
+	// call sites then always write the messages in the order of their sequence.
+	lock     sync.Mutex
+	sequence uint64
+
+	// queue, when set, hands writes, flushes and end of blocks over to the single goroutine
+	// performing them on the writer, see `NewWriterGoroutinePrinter`.
+	queue chan printerEntry
+
+	// replay, when set, retains the last blocks written, see `ReplayHandler`.
+	replay *replayBuffer
 }
 
 func (p *DelegateToWriterPrinter) Disabled() bool {
@@ -36,43 +169,208 @@ func (p *DelegateToWriterPrinter) Disabled() bool {
 }
 
 func (p *DelegateToWriterPrinter) Write(in []byte) {
-	flushToFirehose(in, p.writer)
+	if !LineSequenceEnabled {
+		p.write(in, false)
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.write(p.sequenceMessages(in), true)
 }
 
 func (p *DelegateToWriterPrinter) Print(input ...string) {
-	flushToFirehose([]byte("FIRE "+strings.Join(input, " ")+"\n"), p.writer)
+	if !LineSequenceEnabled {
+		p.write(formatMessage(input), true)
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// A message can be formatted as multiple lines when chunked, each one is sequenced
+	p.write(p.sequenceMessages(formatMessage(input)), true)
+}
+
+// write writes the data to the writer or hands it over to the writer goroutine, `owned`
+// tells if the data can be retained as is, it's copied otherwise as the caller is going
+// to re-use it.
+func (p *DelegateToWriterPrinter) write(in []byte, owned bool) {
+	if p.queue == nil {
+		p.writeOut(in)
+		return
+	}
+
+	if !owned {
+		in = append(make([]byte, 0, len(in)), in...)
+	}
+
+	p.handOff(printerEntry{data: in})
+}
+
+// writeOut writes the data to the writer, the write error policy being applied on failure.
+func (p *DelegateToWriterPrinter) writeOut(in []byte) {
+	if p.replay != nil {
+		p.replay.write(in)
+	}
+
+	if !metrics.Enabled {
+		flushToFirehose(in, p.writer)
+		return
+	}
+
+	start := time.Now()
+	flushToFirehose(in, p.writer)
+	outputWriteTimer.UpdateSince(start)
+
+	outputBytesMeter.Mark(int64(len(in)))
+	outputLinesMeter.Mark(int64(countMessages(in)))
+}
+
+func (p *DelegateToWriterPrinter) resetSequence() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.sequence = 0
 }
 
-// flushToFirehose sends data to Firehose via `io.Writter` checking for errors
-// and retrying if necessary.
-//
-// If error is still present after 10 retries, prints an error message to `writer`
-// as well as writing file `/tmp/firehose_writer_failed_print.log` with the same
-// error message.
+func (p *DelegateToWriterPrinter) nextSequence() string {
+	sequence := p.sequence
+	p.sequence++
+
+	return Uint64(sequence)
+}
+
+// sequenceMessages appends the next sequence number to each of the pre-formatted messages
+// of the input, which are usually the messages of a whole block buffered by a `ToBufferPrinter`.
+func (p *DelegateToWriterPrinter) sequenceMessages(in []byte) []byte {
+	out := make([]byte, 0, len(in)+len(in)/8)
+
+	if Encoding == FramedOutputEncoding {
+		reader := bytes.NewReader(in)
+		for {
+			fields, err := ReadFramedMessage(reader)
+			if err == io.EOF {
+				return out
+			}
+
+			if err != nil {
+				panic(fmt.Errorf("invalid framed message in buffered Firehose data: %w", err))
+			}
+
+			out = append(out, frameMessage(append(fields, p.nextSequence()))...)
+		}
+	}
+
+	for len(in) > 0 {
+		end := bytes.IndexByte(in, '\n')
+		if end == -1 {
+			in = append(in[:len(in):len(in)], '\n')
+			end = len(in) - 1
+		}
+
+		out = append(out, in[:end]...)
+		out = append(out, ' ')
+		out = append(out, p.nextSequence()...)
+		out = append(out, '\n')
+
+		in = in[end+1:]
+	}
+
+	return out
+}
+
+// Flush flushes the underlying writer when it's buffered, it's a no-op otherwise.
+func (p *DelegateToWriterPrinter) Flush() {
+	if p.queue != nil {
+		p.handOff(printerEntry{flush: true})
+		return
+	}
+
+	p.flushOutsideBlock()
+}
+
+// EndOfBlock flushes the underlying writer and notifies it that the block was completely
+// written if it needs to know about it.
+func (p *DelegateToWriterPrinter) EndOfBlock(blockNum uint64) {
+	if p.queue != nil {
+		p.handOff(printerEntry{flush: true, endOfBlock: true, blockNum: blockNum})
+		return
+	}
+
+	p.endOfBlock(blockNum)
+}
+
+func (p *DelegateToWriterPrinter) flush() {
+	flusher, ok := p.writer.(interface{ Flush() error })
+	if !ok {
+		return
+	}
+
+	defer outputWriteTimer.UpdateSince(time.Now())
+	applyWriteErrorPolicy("flushing", flusher.Flush)
+}
+
+// flushOutsideBlock flushes data that is not part of a block, like heartbeats or canceled
+// blocks, so it's not retained by the replay buffer.
+func (p *DelegateToWriterPrinter) flushOutsideBlock() {
+	p.flush()
+
+	if p.replay != nil {
+		p.replay.discard()
+	}
+}
+
+func (p *DelegateToWriterPrinter) endOfBlock(blockNum uint64) {
+	p.flush()
+	outputBlocksMeter.Mark(1)
+
+	if p.replay != nil {
+		p.replay.endOfBlock(blockNum)
+	}
+
+	if notifier, ok := p.writer.(endOfBlockNotifier); ok {
+		applyWriteErrorPolicy(fmt.Sprintf("ending block #%d", blockNum), func() error {
+			return notifier.EndOfBlock(blockNum)
+		})
+	}
+}
+
+// flushToFirehose sends data to Firehose via `io.Writter` checking for errors, the
+// `WriteErrorPolicy` being applied if the data could not be fully written.
 func flushToFirehose(in []byte, writer io.Writer) {
-	var written int
-	var err error
-	loops := 10
-	for i := 0; i < loops; i++ {
-		written, err = writer.Write(in)
-
-		if len(in) == written {
-			return
+	applyWriteErrorPolicy("writing", func() error {
+		return writeFully(writer, &in)
+	})
+}
+
+// writeFully writes the input to the writer until it's completely written, `remaining` is
+// updated on each partial write so a retry resumes where the previous attempt stopped.
+func writeFully(writer io.Writer, remaining *[]byte) error {
+	for len(*remaining) > 0 {
+		written, err := writer.Write(*remaining)
+		*remaining = (*remaining)[written:]
+
+		if err != nil {
+			return err
 		}
 
-		in = in[written:]
-		if i == loops-1 {
-			break
+		if written == 0 {
+			return io.ErrShortWrite
 		}
 	}
 
-	errstr := fmt.Sprintf("\nFIREHOSE FAILED WRITING %dx: %s\n", loops, err)
-	ioutil.WriteFile("/tmp/firehose_writer_failed_print.log", []byte(errstr), 0644)
-	fmt.Fprint(writer, errstr)
+	return nil
 }
 
 type ToBufferPrinter struct {
 	buffer *bytes.Buffer
+
+	// spillAbove, when non-zero, moves the buffered messages to the spill file once the
+	// buffer grows above it, see `CompactBlocksSpillSize`.
+	spillAbove int
+	spill      *os.File
 }
 
 func NewToBufferPrinter(initialAllocationSizeInBytes int) *ToBufferPrinter {
@@ -100,18 +398,71 @@ func (p *ToBufferPrinter) Disabled() bool {
 
 func (p *ToBufferPrinter) Write(in []byte) {
 	p.buffer.Write(in)
+
+	if p.spillAbove > 0 && p.buffer.Len() >= p.spillAbove {
+		p.spillBuffer()
+	}
 }
 
 func (p *ToBufferPrinter) Print(input ...string) {
-	p.buffer.WriteString("FIRE " + strings.Join(input, " ") + "\n")
+	p.buffer.Write(formatMessage(input))
+
+	if p.spillAbove > 0 && p.buffer.Len() >= p.spillAbove {
+		p.spillBuffer()
+	}
 }
 
 func (p *ToBufferPrinter) Buffer() *bytes.Buffer {
 	return p.buffer
 }
 
+// BytesEncoding controls how byte fields (addresses, hashes, payloads, big integers)
+// are encoded in Firehose messages.
+type BytesEncoding string
+
+const (
+	HexBytesEncoding    BytesEncoding = "hex"
+	Base64BytesEncoding BytesEncoding = "base64"
+)
+
+// ActiveBytesEncoding is the `BytesEncoding` used by `Addr`, `Hash`, `Hex` and `BigInt`.
+var ActiveBytesEncoding = HexBytesEncoding
+
+func ParseBytesEncoding(in string) (BytesEncoding, error) {
+	switch BytesEncoding(in) {
+	case "", HexBytesEncoding:
+		return HexBytesEncoding, nil
+	case Base64BytesEncoding:
+		return Base64BytesEncoding, nil
+	}
+
+	return "", fmt.Errorf("invalid bytes encoding %q, valid values are %q and %q", in, HexBytesEncoding, Base64BytesEncoding)
+}
+
+// encodeBytes encodes the input using the active bytes encoding, the input is never empty.
+func encodeBytes(in []byte) string {
+	if ActiveBytesEncoding == Base64BytesEncoding {
+		return base64.StdEncoding.EncodeToString(in)
+	}
+
+	return hex.EncodeToString(in)
+}
+
+// DecodeBytes is the inverse of `Hex`, decoding a field according to the given encoding.
+func DecodeBytes(in string, encoding BytesEncoding) ([]byte, error) {
+	if in == "." {
+		return nil, nil
+	}
+
+	if encoding == Base64BytesEncoding {
+		return base64.StdEncoding.DecodeString(in)
+	}
+
+	return hex.DecodeString(in)
+}
+
 func Addr(in common.Address) string {
-	return hex.EncodeToString(in[:])
+	return encodeBytes(in[:])
 }
 
 func Bool(in bool) string {
@@ -123,15 +474,23 @@ func Bool(in bool) string {
 }
 
 func Hash(in common.Hash) string {
-	return hex.EncodeToString(in[:])
+	return encodeBytes(in[:])
 }
 
+// Hex encodes the input using the active bytes encoding, which is hexadecimal by default,
+// empty input is encoded as ".".
 func Hex(in []byte) string {
 	if len(in) == 0 {
 		return "."
 	}
 
-	return hex.EncodeToString(in)
+	return encodeBytes(in)
+}
+
+// Text encodes free form text, like an error message, as a single field, its bytes being
+// encoded like `Hex`, empty text is encoded as ".".
+func Text(in string) string {
+	return Hex([]byte(in))
 }
 
 func BigInt(in *big.Int) string {
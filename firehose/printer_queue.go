@@ -0,0 +1,104 @@
+package firehose
+
+import (
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// printerQueueSize is how many operations can be handed over to the writer goroutine
+// before the printer blocks, it's a few blocks worth in buffered mode.
+const printerQueueSize = 64
+
+// printerEntry is an operation handed over to the writer goroutine, either data to
+// write, a flush (optionally ending a block), a function performing writes itself or a
+// barrier closed once all the entries queued before it were performed.
+type printerEntry struct {
+	data []byte
+
+	flush      bool
+	endOfBlock bool
+	blockNum   uint64
+
+	barrier chan struct{}
+
+	// emit, when set, performs writes itself from the writer goroutine
+	emit func()
+}
+
+// NewWriterGoroutinePrinter returns a printer whose writes, flushes and end of blocks
+// are all performed by a single goroutine owning the writer.
+//
+// The importing goroutine accumulates a whole block in its own buffer without any
+// locking and only hands the complete block over, it then moves on while the block
+// is written. Intra-block ordering is preserved by construction as there is a single
+// queue and a single goroutine writing. Use `Wait` to know when everything handed
+// over was written.
+func NewWriterGoroutinePrinter(writer io.Writer, queueSize int) *DelegateToWriterPrinter {
+	p := &DelegateToWriterPrinter{
+		writer: writer,
+		queue:  make(chan printerEntry, queueSize),
+	}
+
+	go p.run()
+
+	return p
+}
+
+func (p *DelegateToWriterPrinter) run() {
+	for entry := range p.queue {
+		outputQueueGauge.Update(int64(len(p.queue)))
+
+		switch {
+		case entry.barrier != nil:
+			close(entry.barrier)
+		case entry.emit != nil:
+			entry.emit()
+		case entry.endOfBlock:
+			p.endOfBlock(entry.blockNum)
+		case entry.flush:
+			p.flushOutsideBlock()
+		default:
+			// A write failure either resolves under the write error policy or crashes the node
+			p.writeOut(entry.data)
+		}
+	}
+}
+
+// handOff queues the entry for the writer goroutine, blocking while the queue is full.
+func (p *DelegateToWriterPrinter) handOff(entry printerEntry) {
+	if !metrics.Enabled {
+		p.queue <- entry
+		return
+	}
+
+	start := time.Now()
+	p.queue <- entry
+	outputHandOffTimer.UpdateSince(start)
+	outputQueueGauge.Update(int64(len(p.queue)))
+}
+
+// Wait blocks until everything printed so far was written to the writer, it returns
+// right away when the printer writes synchronously.
+func (p *DelegateToWriterPrinter) Wait() {
+	if p.queue == nil {
+		return
+	}
+
+	barrier := make(chan struct{})
+	p.handOff(printerEntry{barrier: barrier})
+	<-barrier
+}
+
+// WaitOutputWritten blocks until all the Firehose data printed so far by the sync context
+// was written to the output. The chain calls it before moving its head so that the head
+// never moves past a block whose Firehose data was not fully written, `ErrOutputBroken` is
+// returned if it could not be.
+func WaitOutputWritten() error {
+	if v, ok := syncContext.printer.(*DelegateToWriterPrinter); ok {
+		v.Wait()
+	}
+
+	return OutputError()
+}
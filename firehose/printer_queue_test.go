@@ -0,0 +1,41 @@
+package firehose
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flushCountingBuffer is a buffer counting how many times it's flushed.
+type flushCountingBuffer struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (b *flushCountingBuffer) Flush() error { b.flushes++; return nil }
+
+func TestWriterGoroutinePrinter_preservesOrderAndCopiesHandedOverBlocks(t *testing.T) {
+	out := &flushCountingBuffer{}
+	printer := NewWriterGoroutinePrinter(out, 2)
+
+	block := bytes.NewBuffer(nil)
+	for i := 1; i <= 3; i++ {
+		block.Reset()
+		block.WriteString("FIRE BEGIN_BLOCK " + Uint64(uint64(i)) + "\n")
+		block.WriteString("FIRE END_BLOCK " + Uint64(uint64(i)) + "\n")
+
+		// The buffer is re-used for the next block right away like the importing goroutine does
+		printer.Write(block.Bytes())
+		printer.EndOfBlock(uint64(i))
+	}
+
+	printer.Print("CANCEL_BLOCK", "4", "error")
+	printer.Wait()
+
+	assert.Equal(t, "FIRE BEGIN_BLOCK 1\nFIRE END_BLOCK 1\n"+
+		"FIRE BEGIN_BLOCK 2\nFIRE END_BLOCK 2\n"+
+		"FIRE BEGIN_BLOCK 3\nFIRE END_BLOCK 3\n"+
+		"FIRE CANCEL_BLOCK 4 error\n", out.String())
+	assert.Equal(t, 3, out.flushes)
+}
@@ -0,0 +1,222 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFramedOutputEncoding_roundTrip(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(string(recordAllMessages(t, LineOutputEncoding)), "\n"), "\n")
+
+	reader := bytes.NewReader(recordAllMessages(t, FramedOutputEncoding))
+	for _, line := range lines {
+		fields, err := ReadFramedMessage(reader)
+		require.NoError(t, err)
+
+		assert.Equal(t, strings.TrimPrefix(line, "FIRE "), strings.Join(fields, " "))
+	}
+
+	_, err := ReadFramedMessage(reader)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestFramedOutputEncoding_emptyAndTruncated(t *testing.T) {
+	frame := frameMessage([]string{"EVM_END_CALL", "", "."})
+
+	fields, err := ReadFramedMessage(bytes.NewReader(frame))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"EVM_END_CALL", "", "."}, fields)
+
+	_, err = ReadFramedMessage(bytes.NewReader(frame[:len(frame)-1]))
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+// recordAllMessages drives a context through every block, transaction and call
+// level record, returning what was printed using the given encoding.
+func recordAllMessages(t *testing.T, encoding OutputEncoding) []byte {
+	t.Helper()
+
+	defer func(previous OutputEncoding) { Encoding = previous }(Encoding)
+	Encoding = encoding
+
+	defer func(previous func() string) { captureTime = previous }(captureTime)
+	captureTime = func() string { return "1600000000000000000" }
+
+	ctx := NewSpeculativeExecutionContext(1024)
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(2)})
+	from := common.HexToAddress("0x01")
+	to := common.HexToAddress("0x02")
+
+	ctx.InitVersion("1.10.1", "2.3", "geth")
+	ctx.StartBlock(block, common.Address{})
+	ctx.StartTransactionRaw(common.HexToHash("0xaa"), &to, big.NewInt(10), []byte{1}, []byte{2}, []byte{3}, 21000, big.NewInt(1), 0, []byte{0xca, 0xfe}, AccessList{{Address: to, StorageKeys: []common.Hash{common.HexToHash("0x01")}}}, nil, nil, 0, 0, []byte{0xf8, 0x01}, 2, nil)
+	ctx.RecordTrxFrom(from)
+	ctx.StartCall("CALL")
+	ctx.RecordCallParams("CALL", from, to, big.NewInt(10), 21000, 0, nil, false, to)
+	ctx.RecordCallWithoutCode()
+	ctx.RecordKeccak(common.HexToHash("0xbb"), []byte("\n"))
+	ctx.RecordGasConsume(21000, 100, GasChangeReason("intrinsic_gas"))
+	ctx.RecordGasRefund(100, 10)
+	ctx.RecordTransactionGasRefund(110, 40, 20)
+	ctx.RecordStorageChange(to, common.HexToHash("0x01"), common.Hash{}, common.HexToHash("0x02"))
+	ctx.RecordBalanceChange(to, big.NewInt(0), big.NewInt(10), BalanceChangeReason("transfer"))
+	ctx.RecordLog(&types.Log{Address: to, Topics: []common.Hash{common.HexToHash("0xcc")}, Data: []byte{0x01}, Index: 3}, 1)
+	ctx.RecordSuicide(to, true, big.NewInt(10))
+	ctx.RecordNewAccount(to, AccountCreationReason("create"))
+	ctx.RecordCodeChange(to, nil, nil, common.HexToHash("0xdd"), []byte{0x60})
+	ctx.RecordCreationCodeChange(to, common.HexToHash("0xc5"), common.HexToHash("0xee"), []byte{0x60, 0x80}, true, []byte{0x60})
+	ctx.RecordNonceChange(from, 0, 1, NonceChangeReason("transaction"))
+	ctx.EndCall(100, []byte{0x01})
+	ctx.StartCall("CREATE")
+	ctx.EndFailedCall(50, true, CallFailure("revert"), "execution reverted")
+	ctx.EndTransaction(&types.Receipt{GasUsed: 21000})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, big.NewInt(2))
+	ctx.CancelBlock(block, errors.New("invalid block"))
+
+	return ctx.FirehoseLog()
+}
+
+func TestBytesEncoding(t *testing.T) {
+	maxCode := make([]byte, params.MaxCodeSize)
+	for i := range maxCode {
+		maxCode[i] = byte(i)
+	}
+
+	tests := []struct {
+		name     string
+		encoding BytesEncoding
+		in       []byte
+		want     string
+	}{
+		{"hex nil", HexBytesEncoding, nil, "."},
+		{"hex empty", HexBytesEncoding, []byte{}, "."},
+		{"hex single zero", HexBytesEncoding, []byte{0x00}, "00"},
+		{"hex bytes", HexBytesEncoding, []byte{0xca, 0xfe}, "cafe"},
+		{"hex max code size", HexBytesEncoding, maxCode, hex.EncodeToString(maxCode)},
+		{"base64 nil", Base64BytesEncoding, nil, "."},
+		{"base64 empty", Base64BytesEncoding, []byte{}, "."},
+		{"base64 single zero", Base64BytesEncoding, []byte{0x00}, "AA=="},
+		{"base64 bytes", Base64BytesEncoding, []byte{0xca, 0xfe}, "yv4="},
+		{"base64 max code size", Base64BytesEncoding, maxCode, base64.StdEncoding.EncodeToString(maxCode)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(previous BytesEncoding) { ActiveBytesEncoding = previous }(ActiveBytesEncoding)
+			ActiveBytesEncoding = tt.encoding
+
+			encoded := Hex(tt.in)
+			assert.Equal(t, tt.want, encoded)
+			assert.NotContains(t, encoded, " ")
+
+			decoded, err := DecodeBytes(encoded, tt.encoding)
+			require.NoError(t, err)
+			if len(tt.in) == 0 {
+				assert.Empty(t, decoded)
+			} else {
+				assert.Equal(t, tt.in, decoded)
+			}
+		})
+	}
+}
+
+func TestBytesEncoding_fixedSizeFields(t *testing.T) {
+	defer func(previous BytesEncoding) { ActiveBytesEncoding = previous }(ActiveBytesEncoding)
+	ActiveBytesEncoding = Base64BytesEncoding
+
+	addr := common.HexToAddress("0x1234567890123456789012345678901234567890")
+	decoded, err := DecodeBytes(Addr(addr), Base64BytesEncoding)
+	require.NoError(t, err)
+	assert.Equal(t, addr[:], decoded)
+
+	hash := common.HexToHash("0xab")
+	decoded, err = DecodeBytes(Hash(hash), Base64BytesEncoding)
+	require.NoError(t, err)
+	assert.Equal(t, hash[:], decoded)
+
+	assert.Equal(t, ".", BigInt(big.NewInt(0)))
+	assert.Equal(t, "AQA=", BigInt(big.NewInt(256)))
+}
+
+func TestDelegateToWriterPrinter_lineSequence(t *testing.T) {
+	defer func(previous bool) { LineSequenceEnabled = previous }(LineSequenceEnabled)
+	LineSequenceEnabled = true
+
+	out := bytes.NewBuffer(nil)
+	ctx := NewContext(&DelegateToWriterPrinter{writer: out}, false)
+
+	block := NewToBufferPrinter(128)
+	block.Print("BEGIN_BLOCK", "1")
+	block.Print("END_BLOCK", "1")
+
+	ctx.InitVersion("1.10.1", "2.3", "geth")
+	ctx.printer.Write(block.Buffer().Bytes())
+	ctx.InitVersion("1.10.1", "2.3", "geth")
+
+	assert.Equal(t, "FIRE INIT 2.3 geth 1.10.1 0\n"+
+		"FIRE BEGIN_BLOCK 1 1\n"+
+		"FIRE END_BLOCK 1 2\n"+
+		"FIRE INIT 2.3 geth 1.10.1 0\n", out.String())
+}
+
+func TestDelegateToWriterPrinter_lineSequenceFramed(t *testing.T) {
+	defer func(previous bool, encoding OutputEncoding) { LineSequenceEnabled, Encoding = previous, encoding }(LineSequenceEnabled, Encoding)
+	LineSequenceEnabled, Encoding = true, FramedOutputEncoding
+
+	out := bytes.NewBuffer(nil)
+	printer := &DelegateToWriterPrinter{writer: out}
+
+	block := NewToBufferPrinter(128)
+	block.Print("BEGIN_BLOCK", "1")
+	block.Print("END_BLOCK", "1")
+
+	printer.Print("INIT", "2.3")
+	printer.Write(block.Buffer().Bytes())
+
+	for _, expected := range [][]string{{"INIT", "2.3", "0"}, {"BEGIN_BLOCK", "1", "1"}, {"END_BLOCK", "1", "2"}} {
+		fields, err := ReadFramedMessage(out)
+		require.NoError(t, err)
+		assert.Equal(t, expected, fields)
+	}
+}
+
+func TestDelegateToWriterPrinter_lineSequenceConcurrentOrdering(t *testing.T) {
+	defer func(previous bool) { LineSequenceEnabled = previous }(LineSequenceEnabled)
+	LineSequenceEnabled = true
+
+	out := bytes.NewBuffer(nil)
+	printer := &DelegateToWriterPrinter{writer: out}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				printer.Print("BALANCE_CHANGE")
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 800)
+	for i, line := range lines {
+		assert.Equal(t, "FIRE BALANCE_CHANGE "+strconv.Itoa(i), line)
+	}
+}
@@ -0,0 +1,129 @@
+package firehose
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ReplayBlocks and ReplaySize bound the recently emitted blocks kept in memory so a sink
+// that restarted can fetch the blocks it missed over HTTP, see `ReplayHandler`. The replay
+// buffer is disabled when ReplayBlocks is 0.
+var (
+	ReplayBlocks = 64
+	ReplaySize   = 128 * 1024 * 1024
+)
+
+// replayBuffer retains the exact bytes of the last emitted blocks, bounded by both block
+// count and total size. It's only ever updated by the goroutine writing the output and
+// readers get an immutable snapshot, so reading it never blocks the writer.
+type replayBuffer struct {
+	maxBlocks int
+	maxSize   int
+
+	// current is the block being written, it's only accessed by the writing goroutine
+	current []byte
+
+	// blocks holds the `replayBlocks` snapshot, replaced on each retained block
+	blocks atomic.Value
+}
+
+type replayBlock struct {
+	num  uint64
+	data []byte
+}
+
+// replayBlocks are the retained blocks, oldest first, with their total size.
+type replayBlocks struct {
+	blocks []replayBlock
+	size   int
+}
+
+func newReplayBuffer(maxBlocks, maxSize int) *replayBuffer {
+	b := &replayBuffer{maxBlocks: maxBlocks, maxSize: maxSize}
+	b.blocks.Store(&replayBlocks{})
+
+	return b
+}
+
+// write records data written to the output as part of the current block.
+func (b *replayBuffer) write(in []byte) {
+	b.current = append(b.current, in...)
+}
+
+// discard drops the data written since the last block, it was not part of a block.
+func (b *replayBuffer) discard() {
+	b.current = b.current[:0]
+}
+
+// endOfBlock retains the data written since the last block as the block `num`, evicting
+// the oldest blocks to stay within bounds. A block larger than the size bound is not retained.
+func (b *replayBuffer) endOfBlock(num uint64) {
+	data := append([]byte(nil), b.current...)
+	b.current = b.current[:0]
+
+	previous := b.blocks.Load().(*replayBlocks)
+	if len(data) > b.maxSize {
+		return
+	}
+
+	next := &replayBlocks{
+		blocks: append(make([]replayBlock, 0, len(previous.blocks)+1), previous.blocks...),
+		size:   previous.size + len(data),
+	}
+	next.blocks = append(next.blocks, replayBlock{num: num, data: data})
+
+	for len(next.blocks) > b.maxBlocks || next.size > b.maxSize {
+		next.size -= len(next.blocks[0].data)
+		next.blocks = next.blocks[1:]
+	}
+
+	b.blocks.Store(next)
+}
+
+// get returns the bytes emitted for the block, the last emitted one if it was emitted more
+// than once, false if it's not retained.
+func (b *replayBuffer) get(num uint64) ([]byte, bool) {
+	snapshot := b.blocks.Load().(*replayBlocks)
+	for i := len(snapshot.blocks) - 1; i >= 0; i-- {
+		if snapshot.blocks[i].num == num {
+			return snapshot.blocks[i].data, true
+		}
+	}
+
+	return nil, false
+}
+
+// ReplayHandler serves `GET /firehose/blocks/<num>` with the exact bytes that were emitted
+// for the block, when it's still retained by the replay buffer of the sync context.
+func ReplayHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		num, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/firehose/blocks/"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid block number", http.StatusBadRequest)
+			return
+		}
+
+		printer, ok := syncContext.printer.(*DelegateToWriterPrinter)
+		if !ok || printer.replay == nil {
+			http.Error(w, "firehose replay buffer is disabled", http.StatusNotFound)
+			return
+		}
+
+		data, found := printer.replay.get(num)
+		if !found {
+			http.Error(w, "block not retained", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	})
+}
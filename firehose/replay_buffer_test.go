@@ -0,0 +1,81 @@
+package firehose
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayBuffer_bounds(t *testing.T) {
+	buffer := newReplayBuffer(3, 8)
+	for num, data := range []string{"aa", "bbb", "cc", "dd", "eeeeeeeeeeee", "fffff"} {
+		buffer.write([]byte(data))
+		buffer.endOfBlock(uint64(num))
+	}
+
+	_, found := buffer.get(1)
+	assert.False(t, found, "evicted by block count")
+
+	_, found = buffer.get(2)
+	assert.False(t, found, "evicted by size")
+
+	_, found = buffer.get(4)
+	assert.False(t, found, "larger than the size bound")
+
+	data, found := buffer.get(5)
+	assert.True(t, found)
+	assert.Equal(t, "fffff", string(data))
+
+	data, found = buffer.get(3)
+	assert.True(t, found)
+	assert.Equal(t, "dd", string(data))
+}
+
+func TestReplayHandler_servesEmittedBytes(t *testing.T) {
+	defer func(enabled bool, ctx *Context) { Enabled, syncContext = enabled, ctx }(Enabled, syncContext)
+	Enabled = true
+
+	output := &bytes.Buffer{}
+	printer := NewWriterGoroutinePrinter(output, 4)
+	printer.replay = newReplayBuffer(4, 1024*1024)
+	syncContext = NewContext(printer, false)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(7), Difficulty: big.NewInt(1)})
+	recordBlock(block, 3)
+	syncContext.printer.Print("HEARTBEAT", "1", "7", Hash(block.Hash()))
+	syncContext.flushPrinter()
+	printer.Wait()
+
+	server := httptest.NewServer(ReplayHandler())
+	defer server.Close()
+
+	get := func(path string) (int, string) {
+		response, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer response.Body.Close()
+
+		body, err := ioutil.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		return response.StatusCode, string(body)
+	}
+
+	status, body := get("/firehose/blocks/7")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, output.String()[:len(body)], body)
+	assert.NotContains(t, body, "HEARTBEAT")
+	assert.Contains(t, body, "FIRE END_BLOCK 7")
+
+	status, _ = get("/firehose/blocks/8")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = get("/firehose/blocks/latest")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
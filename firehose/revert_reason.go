@@ -0,0 +1,52 @@
+package firehose
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// MaxRevertDataSize caps the return data recorded for a reverted call, larger payloads are
+// truncated which is flagged in the `EVM_REVERTED` message.
+var MaxRevertDataSize = 4096
+
+var panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+
+// panicReasons are the codes Solidity panics with, see
+// https://docs.soliditylang.org/en/latest/control-structures.html#panic-via-assert-and-error-via-require
+var panicReasons = map[uint64]string{
+	0x00: "generic panic",
+	0x01: "assert(false)",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "enum overflow",
+	0x22: "invalid encoded storage byte array accessed",
+	0x31: "out-of-bounds array access; popping on an empty array",
+	0x32: "out-of-bounds access of an array or bytesN",
+	0x41: "out of memory",
+	0x51: "uninitialized function",
+}
+
+// decodeRevertReason decodes the `Error(string)` and `Panic(uint256)` revert payloads into a
+// human-readable message, it returns false for any other payload.
+func decodeRevertReason(data []byte) (string, bool) {
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		return reason, true
+	}
+
+	if len(data) != 4+32 || !bytes.Equal(data[:4], panicSelector) {
+		return "", false
+	}
+
+	code := new(big.Int).SetBytes(data[4:])
+	if code.IsUint64() {
+		if reason, found := panicReasons[code.Uint64()]; found {
+			return fmt.Sprintf("panic: %s (0x%x)", reason, code), true
+		}
+	}
+
+	return fmt.Sprintf("panic: unknown code (0x%x)", code), true
+}
@@ -0,0 +1,97 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// errorPayload is the `Error(string)` revert payload of the message, which must be at most 32 bytes.
+func errorPayload(message string) []byte {
+	payload := common.FromHex("0x08c379a0")
+	payload = append(payload, common.LeftPadBytes([]byte{0x20}, 32)...)
+	payload = append(payload, common.LeftPadBytes([]byte{byte(len(message))}, 32)...)
+	return append(payload, common.RightPadBytes([]byte(message), 32)...)
+}
+
+func TestDecodeRevertReason(t *testing.T) {
+	panicPayload := func(code byte) []byte {
+		return append(common.FromHex("0x4e487b71"), common.LeftPadBytes([]byte{code}, 32)...)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+		decoded  bool
+	}{
+		{"empty", nil, "", false},
+		{"error", errorPayload("ERC20: transfer amount exceeds balance"[:32]), "ERC20: transfer amount exceeds b", true},
+		{"panic", panicPayload(0x11), "panic: arithmetic underflow or overflow (0x11)", true},
+		{"unknown panic", panicPayload(0xff), "panic: unknown code (0xff)", true},
+		{"truncated panic", panicPayload(0x11)[:20], "", false},
+		{"custom error", common.FromHex("0xdeadbeef0000000000000000000000000000000000000000000000000000000000000001"), "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason, decoded := decodeRevertReason(test.data)
+			assert.Equal(t, test.decoded, decoded)
+			assert.Equal(t, test.expected, reason)
+		})
+	}
+}
+
+func TestRecordCallReverted(t *testing.T) {
+	defer func(previous int) { MaxRevertDataSize = previous }(MaxRevertDataSize)
+	MaxRevertDataSize = 8
+
+	custom := common.FromHex("0xdeadbeef")
+	large := bytes.Repeat([]byte{0xaa}, 12)
+
+	tests := []struct {
+		name       string
+		returnData []byte
+		expected   string
+	}{
+		{"empty", nil, "FIRE EVM_REVERTED 1 false .\nFIRE EVM_END_CALL 1 10 . 2 false 0 0\n"},
+		{"custom", custom, "FIRE EVM_REVERTED 1 false .\nFIRE EVM_END_CALL 1 10 deadbeef 2 false 0 0\n"},
+		{"large", large, "FIRE EVM_REVERTED 1 true .\nFIRE EVM_END_CALL 1 10 " + hex.EncodeToString(large[:8]) + " 2 true 0 0\n"},
+		{"error", errorPayload("insufficient balance"), "FIRE EVM_REVERTED 1 true \"insufficient balance\"\nFIRE EVM_END_CALL 1 10 " + hex.EncodeToString(errorPayload("insufficient balance")[:8]) + " 2 true 0 0\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			printer := NewToBufferPrinter(1024)
+			ctx := NewContext(printer, true)
+
+			ctx.StartCall("CALL")
+			printer.Buffer().Reset()
+
+			ctx.RecordCallReverted(test.returnData)
+			ctx.EndCall(10, test.returnData)
+			assert.Equal(t, test.expected, printer.Buffer().String())
+		})
+	}
+}
+
+func TestRecordCallReverted_onlyCapsRevertedCall(t *testing.T) {
+	defer func(previous int) { MaxRevertDataSize = previous }(MaxRevertDataSize)
+	MaxRevertDataSize = 2
+
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+
+	ctx.StartCall("CALL")
+	ctx.StartCall("CALL")
+	ctx.RecordCallReverted([]byte{1, 2, 3})
+	ctx.EndCall(10, []byte{1, 2, 3})
+	ctx.EndCall(20, []byte{4, 5, 6})
+
+	lines := strings.Split(strings.TrimSuffix(printer.Buffer().String(), "\n"), "\n")
+	assert.Equal(t, []string{"FIRE EVM_END_CALL 2 10 0102 3 true 0 0", "FIRE EVM_END_CALL 1 20 040506 4 false 0 0"}, lines[len(lines)-2:])
+}
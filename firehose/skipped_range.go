@@ -0,0 +1,32 @@
+package firehose
+
+import (
+	"fmt"
+)
+
+// SkippedRangeReason tells why a range of blocks is never executed, like "snap_sync".
+type SkippedRangeReason string
+
+// ValidateSyncMode returns an error when Firehose is enabled and the node is about to sync in
+// a mode that doesn't execute every block, unless `AllowNonArchive` is set, so a node never
+// silently starts its output above the blocks it skipped. A node already having blocks always
+// syncs in full, whatever mode is configured.
+func ValidateSyncMode(mode string) error {
+	if !Enabled || mode == "full" || AllowNonArchive {
+		return nil
+	}
+
+	return fmt.Errorf("firehose requires the 'full' sync mode, the blocks below the pivot of a %q sync are never executed and have no Firehose data, allow it with --firehose-allow-non-archive", mode)
+}
+
+// RecordSkippedRange emits a `SKIPPED_RANGE` message for the blocks `from` to `to`, inclusive,
+// the node won't execute, like the blocks up to the pivot once a snap or fast sync commits it
+// as the head. It's printed before the first block executed above the range.
+func (ctx *Context) RecordSkippedRange(from, to uint64, reason SkippedRangeReason) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.printer.Print("SKIPPED_RANGE", Uint64(from), Uint64(to), string(reason))
+	ctx.flushPrinter()
+}
@@ -0,0 +1,24 @@
+package firehose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSyncMode(t *testing.T) {
+	defer func(enabled, allowNonArchive bool) {
+		Enabled, AllowNonArchive = enabled, allowNonArchive
+	}(Enabled, AllowNonArchive)
+
+	Enabled, AllowNonArchive = false, false
+	assert.NoError(t, ValidateSyncMode("snap"))
+
+	Enabled = true
+	assert.NoError(t, ValidateSyncMode("full"))
+	assert.EqualError(t, ValidateSyncMode("snap"), `firehose requires the 'full' sync mode, the blocks below the pivot of a "snap" sync are never executed and have no Firehose data, allow it with --firehose-allow-non-archive`)
+	assert.Error(t, ValidateSyncMode("fast"))
+
+	AllowNonArchive = true
+	assert.NoError(t, ValidateSyncMode("snap"))
+}
@@ -0,0 +1,78 @@
+package firehose
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	"go.uber.org/atomic"
+)
+
+// StopBlockNum is the last block emitted, block import stops once the canonical head reaches
+// it and the node shuts down, see `StopBlockReached`. Disabled when 0.
+var StopBlockNum uint64 = 0
+
+// ErrStopBlockReached is returned when importing a block while the stop block is the head, or
+// one above it, nothing is imported past the stop block.
+var ErrStopBlockReached = errors.New("firehose stop block reached")
+
+var (
+	// stopBlockIsReached is checked on each imported block, the lock only guards the channel
+	stopBlockIsReached = atomic.NewBool(false)
+
+	stopBlockLock      sync.Mutex
+	stopBlockReachedCh = make(chan struct{})
+)
+
+// StopBlockReached returns a channel closed once the stop block became the canonical head, its
+// Firehose data being fully written at that point, the node must then shut down.
+func StopBlockReached() <-chan struct{} {
+	stopBlockLock.Lock()
+	defer stopBlockLock.Unlock()
+
+	return stopBlockReachedCh
+}
+
+// CheckStopBlock returns `ErrStopBlockReached` if the block must not be imported, the stop block
+// being the head already or the block being above it. Blocks at the stop height are imported
+// until one becomes canonical, a stop block replaced by a reorg is then followed.
+func CheckStopBlock(blockNum uint64) error {
+	if StopBlockNum == 0 {
+		return nil
+	}
+
+	if stopBlockIsReached.Load() || blockNum > StopBlockNum {
+		return ErrStopBlockReached
+	}
+
+	return nil
+}
+
+// RecordHeadBlock is called once the canonical head moved to the block, after its Firehose data
+// was written, and with the head loaded when the chain starts. It marks the stop block reached
+// when the head is at its height or above, on a node started past it.
+func RecordHeadBlock(blockNum uint64) {
+	if StopBlockNum == 0 || blockNum < StopBlockNum {
+		return
+	}
+
+	stopBlockLock.Lock()
+	defer stopBlockLock.Unlock()
+
+	if stopBlockIsReached.Load() {
+		return
+	}
+
+	log.Info("Firehose stop block reached, block import stops and node shuts down", "number", blockNum)
+
+	stopBlockIsReached.Store(true)
+	close(stopBlockReachedCh)
+}
+
+func resetStopBlock() {
+	stopBlockLock.Lock()
+	defer stopBlockLock.Unlock()
+
+	stopBlockIsReached.Store(false)
+	stopBlockReachedCh = make(chan struct{})
+}
@@ -0,0 +1,41 @@
+package firehose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStopBlock(t *testing.T) {
+	defer func(previous uint64) { StopBlockNum = previous }(StopBlockNum)
+	defer resetStopBlock()
+
+	StopBlockNum = 0
+	RecordHeadBlock(10)
+	assert.NoError(t, CheckStopBlock(11))
+	assert.False(t, isClosed(StopBlockReached()))
+
+	StopBlockNum = 10
+	assert.NoError(t, CheckStopBlock(10))
+	assert.Equal(t, ErrStopBlockReached, CheckStopBlock(11))
+
+	RecordHeadBlock(9)
+	assert.False(t, isClosed(StopBlockReached()))
+	assert.NoError(t, CheckStopBlock(10))
+
+	// Blocks at the stop height are refused once one of them is the head
+	RecordHeadBlock(10)
+	assert.True(t, isClosed(StopBlockReached()))
+	assert.Equal(t, ErrStopBlockReached, CheckStopBlock(10))
+
+	assert.NotPanics(t, func() { RecordHeadBlock(10) })
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
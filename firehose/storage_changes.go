@@ -0,0 +1,107 @@
+package firehose
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// StorageChangesMode controls whether every storage change is recorded or only the final
+// change of each slot per call.
+type StorageChangesMode string
+
+const (
+	// AllStorageChangesMode records a `STORAGE_CHANGE` message on each write, this is the default.
+	AllStorageChangesMode StorageChangesMode = "all"
+
+	// FinalStorageChangesMode records a single `STORAGE_CHANGE` message per call and slot when
+	// the call ends, with the value of the slot before the first write of the call and the value
+	// written last. The changes of a call are recorded in the order of their last write with
+	// ordinals assigned when the call ends, so ordinals stay gap-free. The changes of a sub-call
+	// are its own, so a reverted sub-call never contributes to the values of its parent.
+	FinalStorageChangesMode StorageChangesMode = "final"
+)
+
+// StorageChanges is the active `StorageChangesMode`.
+var StorageChanges = AllStorageChangesMode
+
+func ParseStorageChangesMode(in string) (StorageChangesMode, error) {
+	switch StorageChangesMode(in) {
+	case "", AllStorageChangesMode:
+		return AllStorageChangesMode, nil
+	case FinalStorageChangesMode:
+		return FinalStorageChangesMode, nil
+	}
+
+	return "", fmt.Errorf("invalid storage changes mode %q, valid values are %q and %q", in, AllStorageChangesMode, FinalStorageChangesMode)
+}
+
+type storageSlot struct {
+	address common.Address
+	key     common.Hash
+}
+
+// finalStorageChange is the change of a slot in a call pending the end of the call, see
+// `FinalStorageChangesMode`.
+type finalStorageChange struct {
+	storageSlot
+	oldValue common.Hash
+	newValue common.Hash
+
+	// lastWrite orders the changes of the call by their last write
+	lastWrite uint64
+}
+
+// recordFinalStorageChange merges the change into the pending final change of the slot in
+// the active call.
+func (ctx *Context) recordFinalStorageChange(addr common.Address, key, oldData, newData common.Hash) {
+	callIndex := ctx.callIndex()
+	if ctx.finalStorageChanges == nil {
+		ctx.finalStorageChanges = make(map[string]map[storageSlot]*finalStorageChange)
+	}
+
+	changes := ctx.finalStorageChanges[callIndex]
+	if changes == nil {
+		changes = make(map[storageSlot]*finalStorageChange)
+		ctx.finalStorageChanges[callIndex] = changes
+	}
+
+	slot := storageSlot{addr, key}
+	change, found := changes[slot]
+	if !found {
+		change = &finalStorageChange{storageSlot: slot, oldValue: oldData}
+		changes[slot] = change
+	}
+
+	ctx.finalStorageWrites++
+	change.newValue = newData
+	change.lastWrite = ctx.finalStorageWrites
+}
+
+// flushFinalStorageChanges records the pending final changes of the call, in the order of
+// their last write.
+func (ctx *Context) flushFinalStorageChanges(callIndex string) {
+	changes := ctx.finalStorageChanges[callIndex]
+	if len(changes) == 0 {
+		return
+	}
+	delete(ctx.finalStorageChanges, callIndex)
+
+	sorted := make([]*finalStorageChange, 0, len(changes))
+	for _, change := range changes {
+		sorted = append(sorted, change)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].lastWrite < sorted[j].lastWrite })
+
+	for _, change := range sorted {
+		ctx.printer.Print("STORAGE_CHANGE",
+			callIndex,
+			Addr(change.address),
+			Hash(change.key),
+			Hash(change.oldValue),
+			Hash(change.newValue),
+			Uint64(ctx.nextOrdinal()),
+		)
+	}
+}
@@ -0,0 +1,67 @@
+package firehose
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStorageChangesMode(t *testing.T) {
+	for in, expected := range map[string]StorageChangesMode{
+		"":      AllStorageChangesMode,
+		"all":   AllStorageChangesMode,
+		"final": FinalStorageChangesMode,
+	} {
+		mode, err := ParseStorageChangesMode(in)
+		require.NoError(t, err)
+		assert.Equal(t, expected, mode, in)
+	}
+
+	_, err := ParseStorageChangesMode("last")
+	assert.EqualError(t, err, `invalid storage changes mode "last", valid values are "all" and "final"`)
+}
+
+func TestRecordStorageChange_final(t *testing.T) {
+	defer func(previous StorageChangesMode) { StorageChanges = previous }(StorageChanges)
+	StorageChanges = FinalStorageChangesMode
+
+	addr := common.HexToAddress("0x02")
+	slotA, slotB := common.HexToHash("0x01"), common.HexToHash("0x02")
+	a, b, c, d := common.HexToHash("0x0a"), common.HexToHash("0x0b"), common.HexToHash("0x0c"), common.HexToHash("0x0d")
+
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer, true)
+	ctx.resetTransaction()
+
+	ctx.StartCall("CALL")
+	ctx.RecordStorageChange(addr, slotA, a, b)
+	ctx.RecordStorageChange(addr, slotB, a, b)
+
+	change := func(callIndex string, key, oldValue, newValue common.Hash, ordinal uint64) string {
+		return fmt.Sprintf("FIRE STORAGE_CHANGE %s %s %s %s %s %d\n", callIndex, "0000000000000000000000000000000000000002",
+			hashHex(key.Hex()), hashHex(oldValue.Hex()), hashHex(newValue.Hex()), ordinal)
+	}
+
+	ctx.StartCall("CALL")
+	ctx.RecordStorageChange(addr, slotA, b, c)
+	printer.Buffer().Reset()
+	ctx.EndFailedCall(0, true, CallFailure("revert"), "")
+
+	// The reverted sub-call's write is recorded with its own changes only
+	assert.Equal(t, "FIRE EVM_CALL_FAILED 2 0 revert .\n"+
+		"FIRE EVM_REVERTED 2 false .\n"+
+		change("2", slotA, b, c, 3)+
+		"FIRE EVM_END_CALL 2 0 . 4 false 0 0\n", printer.Buffer().String())
+
+	ctx.RecordStorageChange(addr, slotA, b, d)
+	printer.Buffer().Reset()
+	ctx.EndCall(10, nil)
+
+	// The parent's change spans from the value before its first write to the value it wrote last
+	assert.Equal(t, change("1", slotB, a, b, 5)+
+		change("1", slotA, a, d, 6)+
+		"FIRE EVM_END_CALL 1 10 . 7 false 0 0\n", printer.Buffer().String())
+}
@@ -0,0 +1,50 @@
+package firehose
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TransactionType describes a transaction type specific to a chain derived from this one, like
+// the deposit transactions of OP stack chains, so it's recorded instead of panicking as an
+// unhandled type. Downstream forks register their types with `RegisterTransactionType`, from an
+// `init` function. The instrumentation never recovers the sender of a transaction, the state
+// processor supplies it with `RecordTrxFrom` whatever the type.
+type TransactionType struct {
+	// GasPrice returns the gas price of the transaction, nil when the type has none in which
+	// case it's recorded empty. Optional, the gas price is always empty without it.
+	GasPrice func(tx *types.Transaction) *big.Int
+
+	// ExtraFields returns an opaque encoding of the fields of the transaction `BEGIN_APPLY_TRX`
+	// has no field for, recorded as is so the transaction round-trips. Optional.
+	ExtraFields func(tx *types.Transaction) []byte
+}
+
+var transactionTypes = map[uint8]TransactionType{}
+
+// RegisterTransactionType registers a chain specific transaction type, it panics if the type
+// is one of this chain's or was already registered.
+func RegisterTransactionType(txType uint8, description TransactionType) {
+	switch txType {
+	case types.LegacyTxType, types.AccessListTxType:
+		panic(fmt.Errorf("transaction type %d is built in and cannot be registered", txType))
+	}
+
+	if _, found := transactionTypes[txType]; found {
+		panic(fmt.Errorf("transaction type %d is already registered", txType))
+	}
+
+	transactionTypes[txType] = description
+}
+
+// transactionExtraFields returns the extra fields of a registered transaction type, nil for the
+// built in types.
+func transactionExtraFields(tx *types.Transaction) []byte {
+	if txType, found := transactionTypes[tx.Type()]; found && txType.ExtraFields != nil {
+		return txType.ExtraFields(tx)
+	}
+
+	return nil
+}
@@ -45,6 +45,42 @@ type BalanceChangeReason string
 // IgnoredBalanceChangeReason **On purposely defined using a different syntax, check `BalanceChangeReason` type doc above**
 var IgnoredBalanceChangeReason BalanceChangeReason = "ignored"
 
+// AccountCreationReason denotes why a given account came into existence in the state.
+//
+// **Important!** For easier extraction of all possible `AccountCreationReason`, ensure you always
+//
+//	define valid value using the type wrapper so it matches the extraction
+//	regex `AccountCreationReason\("[a-z0-9_]+"\)`. All other values that should not
+//	be matched can be defined here using `var X AccountCreationReason = "something"`
+//	since does not match the above regexp.
+type AccountCreationReason string
+
+// IgnoredAccountCreationReason **On purposely defined using a different syntax, check `AccountCreationReason` type doc above**
+var IgnoredAccountCreationReason AccountCreationReason = "ignored"
+
+// AccountDeletionReason denotes why a given account was removed from the state.
+//
+// **Important!** For easier extraction of all possible `AccountDeletionReason`, ensure you always
+//
+//	define valid value using the type wrapper so it matches the extraction
+//	regex `AccountDeletionReason\("[a-z0-9_]+"\)`. All other values that should not
+//	be matched can be defined here using `var X AccountDeletionReason = "something"`
+//	since does not match the above regexp.
+type AccountDeletionReason string
+
+// NonceChangeReason denotes why a given account's nonce changed.
+//
+// **Important!** For easier extraction of all possible `NonceChangeReason`, ensure you always
+//
+//	define valid value using the type wrapper so it matches the extraction
+//	regex `NonceChangeReason\("[a-z0-9_]+"\)`. All other values that should not
+//	be matched can be defined here using `var X NonceChangeReason = "something"`
+//	since does not match the above regexp.
+type NonceChangeReason string
+
+// IgnoredNonceChangeReason **On purposely defined using a different syntax, check `NonceChangeReason` type doc above**
+var IgnoredNonceChangeReason NonceChangeReason = "ignored"
+
 // GasChangeReason denotes a reason why a given gas cost was incurred for an operation.
 //
 // **Important!** For easier extraction of all possible `GasChangeReason`, ensure you always
@@ -58,8 +94,55 @@ type GasChangeReason string
 // RefundAfterExecutionGasChangeReason to be used for all gas refund operation
 var RefundAfterExecutionGasChangeReason = GasChangeReason("refund_after_execution")
 
+// RefundGasChangeReason to be used for the refund counter applied at the end of a transaction
+var RefundGasChangeReason = GasChangeReason("refund")
+
+// ColdAccessGasChangeReason to be used for the EIP-2929 surcharge of accessing an account or storage slot
+// for the first time in the transaction
+var ColdAccessGasChangeReason = GasChangeReason("state_cold_access")
+
+// MemoryExpansionGasChangeReason to be used for the cost of expanding the memory of a call, summed
+// per call, see `Context.AccumulateGasConsume`
+var MemoryExpansionGasChangeReason = GasChangeReason("memory_expansion")
+
+// OpCodeGasChangeReason to be used for the cost of the op codes without a reason of their own,
+// summed per call, see `Context.AccumulateGasConsume`
+var OpCodeGasChangeReason = GasChangeReason("op_code")
+
 // FailedExecutionGasChangeReason to be used for all call failure remaining gas burning operation
 var FailedExecutionGasChangeReason = GasChangeReason("failed_execution")
 
 // IgnoredGasChangeReason **On purposely defined using a different syntax, check `GasChangeReason` type doc above**
 var IgnoredGasChangeReason GasChangeReason = "ignored"
+
+// SystemCallSource denotes what performed the state changes recorded in a system call, i.e.
+// outside of any transaction.
+//
+// **Important!** For easier extraction of all possible `SystemCallSource`, ensure you always
+//
+//	define valid value using the type wrapper so it matches the extraction
+//	regex `SystemCallSource\("[a-z0-9_]+"\)`. All other values that should not
+//	be matched can be defined here using `var X SystemCallSource = "something"`
+type SystemCallSource string
+
+// CallFailure denotes the kind of failure that made a given call fail, the original error
+// message being recorded along with it for diagnostics.
+//
+// **Important!** For easier extraction of all possible `CallFailure`, ensure you always
+//
+//	define valid value using the type wrapper so it matches the extraction
+//	regex `CallFailure\("[a-z0-9_]+"\)`. All other values that should not
+//	be matched can be defined here using `var X CallFailure = "something"`
+type CallFailure string
+
+// field returns the failure as a message field, "." for no failure.
+func (f CallFailure) field() string {
+	if f == "" {
+		return "."
+	}
+
+	return string(f)
+}
+
+// UnknownCallFailure to be used for all errors not mapped to a more specific call failure kind
+var UnknownCallFailure = CallFailure("unknown")
@@ -23,7 +23,10 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"runtime"
+	"strings"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/log"
@@ -112,9 +115,245 @@ var (
 	}
 	firehoseGenesisFileFlag = cli.StringFlag{
 		Name:  "firehose-genesis-file",
-		Usage: "On private chains where the genesis config is not known to Geth, you **must** provide the 'genesis.json' file path for proper instrumentation of genesis block",
+		Usage: "On private chains where the genesis config is not known to Geth, you **must** provide the 'genesis.json' file path for proper instrumentation of genesis block, known networks use their embedded genesis and a file conflicting with it is rejected",
 		Value: "",
 	}
+	firehoseOutputFlag = cli.StringSliceFlag{
+		Name:  "firehose-output",
+		Usage: "Firehose output(s), repeated or comma-separated, each one of 'stdout', 'file:<path>', 'fd:<number>', 'socket:<path>', 'pipe:<path>' or 'tcp:<host>:<port>', all outputs receive every line, the first one is the primary output whose failures are fatal while failures of the others are only logged",
+	}
+	firehoseOutputFileFlag = cli.StringFlag{
+		Name:  "firehose-output-file",
+		Usage: "Append Firehose lines to the given file instead of writing them to stdout, the file is created if it does not exist",
+		Value: "",
+	}
+	firehoseOutputFileMaxSizeFlag = cli.Int64Flag{
+		Name:  "firehose-output-file-max-size",
+		Usage: "When non-zero, --firehose-output-file becomes the prefix of numbered files '<file>.<index>', rolling to the next file on the first block boundary once the current file reached this size in bytes, each file ends with a 'FILE_BLOCK_RANGE <first> <last>' line",
+		Value: 0,
+	}
+	firehoseOutputFDFlag = cli.IntFlag{
+		Name:  "firehose-output-fd",
+		Usage: "Inherited file descriptor Firehose lines are written to, isolating them from regular stdout/stderr traffic, the descriptor must be opened for writing by the parent process (e.g. 'geth --firehose-output-fd=3 3>>/var/lib/geth/firehose.log' from a shell, or 'exec 3>fifo' in a wrapper script before exec'ing geth)",
+		Value: 1,
+	}
+	firehoseOutputSocketFlag = cli.StringFlag{
+		Name:  "firehose-output-socket",
+		Usage: "Stream Firehose lines over the Unix domain socket at the given path instead of writing them to stdout, the socket is dialed unless --firehose-output-socket-listen is set",
+		Value: "",
+	}
+	firehoseOutputSocketListenFlag = cli.BoolFlag{
+		Name:  "firehose-output-socket-listen",
+		Usage: "Listen on the --firehose-output-socket path and wait for the reader to connect instead of dialing it",
+	}
+	firehoseOutputSocketBufferSizeFlag = cli.IntFlag{
+		Name:  "firehose-output-socket-buffer-size",
+		Usage: "Maximum of bytes of Firehose lines kept in memory while no reader is connected to the output socket, block import waits for a reader once reached",
+		Value: 64 * 1024 * 1024,
+	}
+	firehoseOutputPipeFlag = cli.StringFlag{
+		Name:  "firehose-output-pipe",
+		Usage: "Write Firehose lines to the named pipe (FIFO) at the given path, when the pipe's consumer restarts, the pipe is re-opened and the last retained blocks are replayed",
+		Value: "",
+	}
+	firehoseOutputPipeRetentionFlag = cli.IntFlag{
+		Name:  "firehose-output-pipe-retention",
+		Usage: "Number of complete blocks retained in memory to be replayed to the --firehose-output-pipe consumer when it reconnects",
+		Value: 16,
+	}
+	firehoseOutputTCPFlag = cli.StringFlag{
+		Name:  "firehose-output-tcp",
+		Usage: "Stream Firehose lines to the remote collector at the given <host>:<port>, when the connection breaks, the collector is re-dialed and the last retained blocks are replayed",
+	}
+	firehoseOutputTCPRetentionFlag = cli.IntFlag{
+		Name:  "firehose-output-tcp-retention",
+		Usage: "Number of complete blocks retained in memory to be replayed to the --firehose-output-tcp collector when it reconnects",
+		Value: 16,
+	}
+	firehoseOutputTLSCertFlag = cli.StringFlag{
+		Name:  "firehose-output-tls-cert",
+		Usage: "Client certificate presented to the --firehose-output-tcp collector, enables TLS",
+	}
+	firehoseOutputTLSKeyFlag = cli.StringFlag{
+		Name:  "firehose-output-tls-key",
+		Usage: "Key of the --firehose-output-tls-cert client certificate",
+	}
+	firehoseOutputTLSCAFlag = cli.StringFlag{
+		Name:  "firehose-output-tls-ca",
+		Usage: "Certificate authority used to verify the --firehose-output-tcp collector, enables TLS",
+	}
+	firehoseOutputEncodingFlag = cli.StringFlag{
+		Name:  "firehose-output-encoding",
+		Usage: "Encoding of each Firehose message, 'line' writes space separated 'FIRE' text lines, 'framed' writes each message as a 4 bytes big-endian length followed by a binary payload",
+		Value: "line",
+	}
+	firehoseOutputFormatFlag = cli.StringFlag{
+		Name:  "firehose-output-format",
+		Usage: "Syntax of Firehose messages, 'fire' writes the canonical 'FIRE' lines, 'json' writes each message as a single line JSON object with named fields to debug the instrumentation, never use it in production",
+		Value: "fire",
+	}
+	firehoseLinePrefixFlag = cli.StringFlag{
+		Name:  "firehose-line-prefix",
+		Usage: "Token starting every Firehose line, including INIT and HEARTBEAT lines, to tell nodes apart when their outputs are multiplexed, must be a single token that is not a reserved Firehose word, a non-default prefix is announced in the INIT line",
+		Value: "FIRE",
+	}
+	firehoseBytesEncodingFlag = cli.StringFlag{
+		Name:  "firehose-bytes-encoding",
+		Usage: "Encoding of every byte field (addresses, hashes, payloads, code, amounts) in Firehose messages, 'hex' or 'base64', the choice is announced in the INIT message",
+		Value: "hex",
+	}
+	firehoseOutputCompressionFlag = cli.StringFlag{
+		Name:  "firehose-output-compression",
+		Usage: "Compression applied to the Firehose output stream, 'none' or 'gzip', the stream is flushed on each block boundary so it's always decompressible up to the last written block",
+		Value: "none",
+	}
+	firehoseFlushIntervalFlag = cli.StringFlag{
+		Name:  "firehose-flush-interval",
+		Usage: "Either a byte size (e.g. '4MiB') or a duration (e.g. '500ms') after which buffered Firehose output is written out in between blocks, the output is always flushed at the end of each block, default is a 1 MiB buffer",
+		Value: "",
+	}
+	firehoseOutputBufferSizeFlag = cli.IntFlag{
+		Name:  "firehose-output-buffer-size",
+		Usage: "Depth of the queue of chunks handed to a dedicated goroutine writing the Firehose output, 0 writes synchronously from the block import path",
+		Value: 0,
+	}
+	firehoseOutputBufferFullFlag = cli.StringFlag{
+		Name:  "firehose-output-buffer-full",
+		Usage: "What to do when the Firehose output queue is full, 'block' waits for the consumer to catch up, 'crash' stops the node with an error, data is never dropped",
+		Value: "block",
+	}
+	firehoseLineSequenceFlag = cli.BoolFlag{
+		Name:  "firehose-line-sequence",
+		Usage: "Append a per-process sequence number, reset by the INIT message, as the last field of every Firehose line so the reader can detect lost lines",
+	}
+	firehoseHeartbeatIntervalFlag = cli.DurationFlag{
+		Name:  "firehose-heartbeat-interval",
+		Usage: "Interval at which a Firehose HEARTBEAT line carrying the current head block is emitted so readers can tell an idle chain from a hung node, disabled when 0",
+	}
+	firehoseCompactBlocksFlag = cli.BoolFlag{
+		Name:  "firehose-compact-blocks",
+		Usage: "Emit each Firehose block as exactly two lines, 'BLOCK_BEGIN <num>' and 'BLOCK <num> <hash> <payload>', the payload being the base64 encoded lines of the block, requires the 'line' output encoding",
+	}
+	firehoseCompactBlocksSpillSizeFlag = cli.IntFlag{
+		Name:  "firehose-compact-blocks-spill-size",
+		Usage: "Size in bytes above which a block accumulated with --firehose-compact-blocks is spilled to a temporary file instead of being kept in memory",
+		Value: 256 * 1024 * 1024,
+	}
+	firehoseLegacyDMLogFlag = cli.StringFlag{
+		Name:  "firehose-legacy-dmlog",
+		Usage: "Emit Firehose lines in the legacy deep mind 'DMLOG' syntax for readers not yet migrated, 'none' emits 'FIRE' lines only, 'dual' emits each line in both syntaxes and 'only' in the legacy syntax only",
+		Value: "none",
+	}
+	firehoseChunkThresholdFlag = cli.IntFlag{
+		Name:  "firehose-chunk-threshold",
+		Usage: "Size in bytes above which a Firehose line field is emitted in a sequence of 'CHUNK <id> <index> <total> <data>' lines preceding the line, disabled when 0, only applies to the 'line' output encoding",
+	}
+	firehoseStreamingBlocksFlag = cli.BoolFlag{
+		Name:  "firehose-streaming-blocks",
+		Usage: "Write the Firehose lines of a block as they are produced instead of staging the whole block in memory and writing it at once, saves memory but a crash can leave a partial block at the end of the output",
+	}
+	firehoseReplayBlocksFlag = cli.IntFlag{
+		Name:  "firehose-replay-blocks",
+		Usage: "Number of last emitted Firehose blocks retained in memory and served as emitted on 'GET /firehose/blocks/<num>' of the --pprof HTTP server, disabled when 0",
+		Value: 64,
+	}
+	firehoseReplaySizeFlag = cli.IntFlag{
+		Name:  "firehose-replay-size",
+		Usage: "Maximum total size in bytes of the Firehose blocks retained for --firehose-replay-blocks",
+		Value: 128 * 1024 * 1024,
+	}
+	firehoseBlockStoreURLFlag = cli.StringFlag{
+		Name:  "firehose-blockstore-url",
+		Usage: "Also upload the Firehose payload of each block as a '<num>-<hash>.fireblock' object to the given store, either 'file:///<directory>' or 's3://<bucket>[/<prefix>][?endpoint=<url>&region=<region>]', an upload still failing after retries halts the node",
+	}
+	firehoseBlockStoreConcurrencyFlag = cli.IntFlag{
+		Name:  "firehose-blockstore-concurrency",
+		Usage: "Number of concurrent --firehose-blockstore-url uploads",
+		Value: 4,
+	}
+	firehoseBlockStoreWindowFlag = cli.IntFlag{
+		Name:  "firehose-blockstore-window",
+		Usage: "Number of blocks block import may be ahead of the oldest --firehose-blockstore-url upload not yet acknowledged",
+		Value: 64,
+	}
+	firehoseRecordStorageReadsFlag = cli.BoolFlag{
+		Name:  "firehose-record-storage-reads",
+		Usage: "Record every SLOAD as a Firehose STORAGE_READ line with the value read, reads are much more frequent than writes so this greatly increases the output volume",
+	}
+	firehoseRecordAccessesFlag = cli.BoolFlag{
+		Name:  "firehose-record-accesses",
+		Usage: "Record every account and storage slot access checked against the access list from Berlin on as Firehose ACCOUNT_ACCESS and STORAGE_ACCESS lines telling if the access was cold, this greatly increases the output volume",
+	}
+	firehoseRecordTouchedAccountsFlag = cli.BoolFlag{
+		Name:  "firehose-record-touched-accounts",
+		Usage: "Record at the end of each transaction the accounts it accessed through BALANCE, EXTCODESIZE, EXTCODEHASH, EXTCODECOPY, calls and SELFDESTRUCT beneficiaries, whether their state changed or not",
+	}
+	firehoseMaxReturnDataSizeFlag = cli.IntFlag{
+		Name:  "firehose-max-return-data-size",
+		Usage: "Size in bytes above which the return data of a successful call is truncated in its Firehose EVM_END_CALL line, which flags the truncation, unlimited when 0",
+	}
+	firehoseCodeCacheWindowFlag = cli.IntFlag{
+		Name:  "firehose-code-cache-window",
+		Usage: "Number of blocks the Firehose reader keeps the code it received for, a CODE_CHANGE line then records only the hash of a code recorded in full within this many blocks, disabled when 0",
+	}
+	firehoseStorageChangesFlag = cli.StringFlag{
+		Name:  "firehose-storage-changes",
+		Usage: "Storage changes recorded by Firehose, 'all' records every write, 'final' records a single change per call and slot when the call ends, from the value before the call's first write to the value written last",
+		Value: "all",
+	}
+	firehoseDisableKeccakDedupFlag = cli.BoolFlag{
+		Name:  "firehose-disable-keccak-dedup",
+		Usage: "Record the preimage of hashes computed by the SHA3 opcode on each occurrence instead of once per block at its first occurrence",
+	}
+	firehoseDeepTraceAddressesFlag = cli.StringFlag{
+		Name:  "firehose-deep-trace-addresses",
+		Usage: "Comma separated list of contract addresses whose execution is recorded opcode by opcode as Firehose EVM_OPCODE lines with the gas, the top of the stack and the memory range touched, for debugging",
+	}
+	firehoseDeepTraceMaxRecordsFlag = cli.IntFlag{
+		Name:  "firehose-deep-trace-max-records",
+		Usage: "Maximum number of Firehose EVM_OPCODE lines recorded per call of a --firehose-deep-trace-addresses contract",
+		Value: 1000,
+	}
+	firehoseCheckOrdinalsFlag = cli.BoolFlag{
+		Name:  "firehose-check-ordinals",
+		Usage: "Verify at the end of each block that the ordinals of its Firehose records are contiguous from 1 and crash on a gap or a duplicate, for debugging",
+	}
+	firehoseOnWriteErrorFlag = cli.StringFlag{
+		Name:  "firehose-on-write-error",
+		Usage: "What to do when writing Firehose output fails, 'crash' stops the node, 'retry' retries with an exponential backoff up to --firehose-write-retry-deadline before stopping the node",
+		Value: "crash",
+	}
+	firehoseAllowNonArchiveFlag = cli.BoolFlag{
+		Name:  "firehose-allow-non-archive",
+		Usage: "Allow Firehose with a sync mode other than 'full', the blocks below the sync pivot are never executed and have no Firehose data, the range skipped is reported as a Firehose SKIPPED_RANGE line",
+	}
+	firehoseStartBlockNumFlag = cli.Uint64Flag{
+		Name:  "firehose-start-block-num",
+		Usage: "Height from which Firehose blocks are emitted, the blocks below it are processed without being recorded, the genesis block is still emitted",
+	}
+	firehoseStopBlockNumFlag = cli.Uint64Flag{
+		Name:  "firehose-stop-block-num",
+		Usage: "Last Firehose block emitted, once the block at that height is the canonical head and its Firehose data written, block import stops and the node shuts down, disabled when 0",
+	}
+	firehoseFinalizedOnlyFlag = cli.BoolFlag{
+		Name:  "firehose-finalized-only",
+		Usage: "Buffer the executed Firehose blocks and only emit them once final, in canonical order, a block reorged out is never emitted, the buffered blocks are dropped on shutdown and executed again on restart, from the last emitted one up to the head which is kept, the node fails to start if that's more than " + fmt.Sprint(firehose.FinalizedMaxReplayBlocks) + " blocks below the head or if no state is found within them",
+	}
+	firehoseFinalizedConfirmationsFlag = cli.Uint64Flag{
+		Name:  "firehose-finalized-confirmations",
+		Usage: "Number of canonical blocks on top of a block for it to be final in --firehose-finalized-only mode",
+		Value: firehose.FinalizedConfirmations,
+	}
+	firehoseFinalizedBufferSizeFlag = cli.IntFlag{
+		Name:  "firehose-finalized-buffer-size",
+		Usage: "Size in bytes of the blocks waiting to be final kept in memory in --firehose-finalized-only mode, the following ones are spilled to temporary files",
+		Value: firehose.FinalizedBufferSize,
+	}
+	firehoseWriteRetryDeadlineFlag = cli.DurationFlag{
+		Name:  "firehose-write-retry-deadline",
+		Usage: "How long a failing Firehose output write is retried when --firehose-on-write-error is 'retry'",
+		Value: 1 * time.Minute,
+	}
 )
 
 // Flags holds all command-line flags required for debugging.
@@ -128,6 +367,21 @@ var Flags = []cli.Flag{
 var FirehoseFlags = []cli.Flag{
 	firehoseEnabledFlag, firehoseSyncInstrumentationFlag, firehoseMiningEnabledFlag, firehoseBlockProgressFlag,
 	firehoseGenesisFileFlag,
+	firehoseOutputFlag, firehoseOutputFileFlag, firehoseOutputFileMaxSizeFlag, firehoseOutputFDFlag,
+	firehoseOutputSocketFlag, firehoseOutputSocketListenFlag, firehoseOutputSocketBufferSizeFlag,
+	firehoseOutputPipeFlag, firehoseOutputPipeRetentionFlag,
+	firehoseOutputTCPFlag, firehoseOutputTCPRetentionFlag,
+	firehoseOutputTLSCertFlag, firehoseOutputTLSKeyFlag, firehoseOutputTLSCAFlag,
+	firehoseOutputEncodingFlag, firehoseOutputFormatFlag, firehoseLinePrefixFlag, firehoseBytesEncodingFlag, firehoseOutputCompressionFlag,
+	firehoseFlushIntervalFlag, firehoseOutputBufferSizeFlag, firehoseOutputBufferFullFlag,
+	firehoseOnWriteErrorFlag, firehoseWriteRetryDeadlineFlag, firehoseLineSequenceFlag,
+	firehoseHeartbeatIntervalFlag, firehoseCompactBlocksFlag, firehoseCompactBlocksSpillSizeFlag,
+	firehoseLegacyDMLogFlag, firehoseChunkThresholdFlag, firehoseStreamingBlocksFlag, firehoseAllowNonArchiveFlag, firehoseStartBlockNumFlag, firehoseStopBlockNumFlag,
+	firehoseFinalizedOnlyFlag, firehoseFinalizedConfirmationsFlag, firehoseFinalizedBufferSizeFlag,
+	firehoseRecordStorageReadsFlag, firehoseRecordAccessesFlag, firehoseRecordTouchedAccountsFlag, firehoseMaxReturnDataSizeFlag, firehoseCodeCacheWindowFlag, firehoseStorageChangesFlag, firehoseDisableKeccakDedupFlag,
+	firehoseDeepTraceAddressesFlag, firehoseDeepTraceMaxRecordsFlag, firehoseCheckOrdinalsFlag,
+	firehoseBlockStoreURLFlag, firehoseBlockStoreConcurrencyFlag, firehoseBlockStoreWindowFlag,
+	firehoseReplayBlocksFlag, firehoseReplaySizeFlag,
 }
 
 var (
@@ -198,6 +452,61 @@ func Setup(ctx *cli.Context, firehoseGenesis *core.Genesis, firehoseGethVersion
 		firehoseGenesis,
 		ctx.GlobalString(firehoseGenesisFileFlag.Name),
 		func() interface{} { return new(core.Genesis) },
+		func(genesis interface{}) common.Hash { return genesis.(*core.Genesis).ToBlock(nil).Hash() },
+		firehose.OutputConfig{
+			Outputs:                splitFirehoseOutputs(ctx.GlobalStringSlice(firehoseOutputFlag.Name)),
+			File:                   ctx.GlobalString(firehoseOutputFileFlag.Name),
+			FileMaxSize:            ctx.GlobalInt64(firehoseOutputFileMaxSizeFlag.Name),
+			FD:                     ctx.GlobalInt(firehoseOutputFDFlag.Name),
+			Socket:                 ctx.GlobalString(firehoseOutputSocketFlag.Name),
+			SocketListen:           ctx.GlobalBool(firehoseOutputSocketListenFlag.Name),
+			SocketBufferSize:       ctx.GlobalInt(firehoseOutputSocketBufferSizeFlag.Name),
+			Pipe:                   ctx.GlobalString(firehoseOutputPipeFlag.Name),
+			PipeRetention:          ctx.GlobalInt(firehoseOutputPipeRetentionFlag.Name),
+			TCP:                    ctx.GlobalString(firehoseOutputTCPFlag.Name),
+			TCPRetention:           ctx.GlobalInt(firehoseOutputTCPRetentionFlag.Name),
+			TLSCert:                ctx.GlobalString(firehoseOutputTLSCertFlag.Name),
+			TLSKey:                 ctx.GlobalString(firehoseOutputTLSKeyFlag.Name),
+			TLSCA:                  ctx.GlobalString(firehoseOutputTLSCAFlag.Name),
+			Encoding:               ctx.GlobalString(firehoseOutputEncodingFlag.Name),
+			RecordStorageReads:     ctx.GlobalBool(firehoseRecordStorageReadsFlag.Name),
+			RecordAccesses:         ctx.GlobalBool(firehoseRecordAccessesFlag.Name),
+			RecordTouchedAccounts:  ctx.GlobalBool(firehoseRecordTouchedAccountsFlag.Name),
+			MaxReturnDataSize:      ctx.GlobalInt(firehoseMaxReturnDataSizeFlag.Name),
+			CodeCacheWindow:        ctx.GlobalInt(firehoseCodeCacheWindowFlag.Name),
+			StorageChanges:         ctx.GlobalString(firehoseStorageChangesFlag.Name),
+			DisableKeccakDedup:     ctx.GlobalBool(firehoseDisableKeccakDedupFlag.Name),
+			DeepTraceAddresses:     ctx.GlobalString(firehoseDeepTraceAddressesFlag.Name),
+			DeepTraceMaxRecords:    ctx.GlobalInt(firehoseDeepTraceMaxRecordsFlag.Name),
+			CheckOrdinals:          ctx.GlobalBool(firehoseCheckOrdinalsFlag.Name),
+			LinePrefix:             ctx.GlobalString(firehoseLinePrefixFlag.Name),
+			Format:                 ctx.GlobalString(firehoseOutputFormatFlag.Name),
+			BytesEncoding:          ctx.GlobalString(firehoseBytesEncodingFlag.Name),
+			Compression:            ctx.GlobalString(firehoseOutputCompressionFlag.Name),
+			FlushInterval:          ctx.GlobalString(firehoseFlushIntervalFlag.Name),
+			BufferSize:             ctx.GlobalInt(firehoseOutputBufferSizeFlag.Name),
+			BufferFullPolicy:       ctx.GlobalString(firehoseOutputBufferFullFlag.Name),
+			OnWriteError:           ctx.GlobalString(firehoseOnWriteErrorFlag.Name),
+			WriteRetryDeadline:     ctx.GlobalDuration(firehoseWriteRetryDeadlineFlag.Name),
+			LineSequence:           ctx.GlobalBool(firehoseLineSequenceFlag.Name),
+			HeartbeatInterval:      ctx.GlobalDuration(firehoseHeartbeatIntervalFlag.Name),
+			CompactBlocks:          ctx.GlobalBool(firehoseCompactBlocksFlag.Name),
+			CompactBlocksSpillSize: ctx.GlobalInt(firehoseCompactBlocksSpillSizeFlag.Name),
+			LegacyDMLog:            ctx.GlobalString(firehoseLegacyDMLogFlag.Name),
+			ChunkThreshold:         ctx.GlobalInt(firehoseChunkThresholdFlag.Name),
+			StreamingBlocks:        ctx.GlobalBool(firehoseStreamingBlocksFlag.Name),
+			AllowNonArchive:        ctx.GlobalBool(firehoseAllowNonArchiveFlag.Name),
+			StartBlockNum:          ctx.GlobalUint64(firehoseStartBlockNumFlag.Name),
+			StopBlockNum:           ctx.GlobalUint64(firehoseStopBlockNumFlag.Name),
+			FinalizedOnly:          ctx.GlobalBool(firehoseFinalizedOnlyFlag.Name),
+			FinalizedConfirmations: ctx.GlobalUint64(firehoseFinalizedConfirmationsFlag.Name),
+			FinalizedBufferSize:    ctx.GlobalInt(firehoseFinalizedBufferSizeFlag.Name),
+			ReplayBlocks:           ctx.GlobalInt(firehoseReplayBlocksFlag.Name),
+			ReplaySize:             ctx.GlobalInt(firehoseReplaySizeFlag.Name),
+			BlockStoreURL:          ctx.GlobalString(firehoseBlockStoreURLFlag.Name),
+			BlockStoreConcurrency:  ctx.GlobalInt(firehoseBlockStoreConcurrencyFlag.Name),
+			BlockStoreWindow:       ctx.GlobalInt(firehoseBlockStoreWindowFlag.Name),
+		},
 		firehoseGethVersion,
 	); err != nil {
 		return fmt.Errorf("initializing firehose: %w", err)
@@ -206,6 +515,20 @@ func Setup(ctx *cli.Context, firehoseGenesis *core.Genesis, firehoseGethVersion
 	return nil
 }
 
+// splitFirehoseOutputs flattens the repeated `--firehose-output` values which can each be
+// a comma-separated list.
+func splitFirehoseOutputs(values []string) (out []string) {
+	for _, value := range values {
+		for _, spec := range strings.Split(value, ",") {
+			if spec = strings.TrimSpace(spec); spec != "" {
+				out = append(out, spec)
+			}
+		}
+	}
+
+	return out
+}
+
 func StartPProf(address string, withMetrics bool) {
 	// Hook go-metrics into expvar on any /debug/metrics request, load all vars
 	// from the registry into expvar, and execute regular expvar handler.
@@ -213,6 +536,7 @@ func StartPProf(address string, withMetrics bool) {
 		exp.Exp(metrics.DefaultRegistry)
 	}
 	http.Handle("/memsize/", http.StripPrefix("/memsize", &Memsize))
+	http.Handle("/firehose/blocks/", firehose.ReplayHandler())
 	log.Info("Starting pprof server", "addr", fmt.Sprintf("http://%s/debug/pprof", address))
 	go func() {
 		if err := http.ListenAndServe(address, nil); err != nil {
@@ -226,4 +550,8 @@ func StartPProf(address string, withMetrics bool) {
 func Exit() {
 	Handler.StopCPUProfile()
 	Handler.StopGoTrace()
+
+	if err := firehose.Close(); err != nil {
+		log.Error("Failed to close Firehose output", "err", err)
+	}
 }
@@ -826,7 +826,7 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 	for addr, account := range overrides {
 		// Override account nonce.
 		if account.Nonce != nil {
-			state.SetNonce(addr, uint64(*account.Nonce), firehose.NoOpContext)
+			state.SetNonce(addr, uint64(*account.Nonce), firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 		}
 		// Override account(contract) code.
 		if account.Code != nil {
@@ -885,11 +885,14 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 			msg.GasPrice(),
 			msg.Nonce(),
 			msg.Data(),
+			firehose.AccessList(msg.AccessList()),
 			nil,
 			nil,
-			nil,
 			0,
 			0,
+			nil,
+			0,
+			nil,
 		)
 		firehoseContext.RecordTrxFrom(msg.From())
 	}
@@ -220,7 +220,7 @@ func MakePreState(db ethdb.Database, accounts core.GenesisAlloc, snapshotter boo
 	statedb, _ := state.New(common.Hash{}, sdb, nil)
 	for addr, a := range accounts {
 		statedb.SetCode(addr, a.Code, firehose.NoOpContext)
-		statedb.SetNonce(addr, a.Nonce, firehose.NoOpContext)
+		statedb.SetNonce(addr, a.Nonce, firehose.NoOpContext, firehose.IgnoredNonceChangeReason)
 		statedb.SetBalance(addr, a.Balance, firehose.NoOpContext, "test")
 		for k, v := range a.Storage {
 			statedb.SetState(addr, k, v, firehose.NoOpContext)